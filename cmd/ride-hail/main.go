@@ -4,28 +4,53 @@ import (
 	"context"
 	"flag"
 	"log/slog"
+	"os"
 	"ride-hail/config"
 	"ride-hail/internal/app"
+	"ride-hail/internal/app/admin"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
 )
 
 var (
 	modeFlag       = flag.String("mode", "-", "mode service")
 	modeConfigPAth = flag.String("config-path", "./config.yaml", "path to config file")
+	createAdmin    = flag.String("create-admin", "", "create an admin with this email (password from ADMIN_PASSWORD) and exit")
+	adminName      = flag.String("admin-name", "Administrator", "name of the admin created with -create-admin")
 )
 
 func Run() {
 	flag.Parse()
 	cfg, err := config.New(*modeConfigPAth, *modeFlag)
 	if err != nil {
-		slog.Error("error in parsing config", err)
+		slog.Error("error in parsing config", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *createAdmin != "" {
+		if cfg.Mode != types.ModeAdmin {
+			slog.Error("-create-admin requires -mode admin")
+			return
+		}
+		err = admin.Bootstrap(ctx, *cfg, models.RegistrationRequest{
+			Email:    *createAdmin,
+			Password: os.Getenv("ADMIN_PASSWORD"),
+			Name:     *adminName,
+		})
+		if err != nil {
+			slog.Error("error in creating admin", "error", err)
+			return
+		}
+		slog.Info("admin created", "email", *createAdmin)
 		return
 	}
 
-	ctx, _ := context.WithCancel(context.Background())
 	app, err := app.New(ctx, *cfg)
 	if err != nil {
-		slog.Error("error in creating app", err)
-		//cansel()
+		slog.Error("error in creating app", "error", err)
 		return
 	}
 	app.Start()
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"os"
 	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/jwtkey"
 	"ride-hail/pkg/potgres"
 	"ride-hail/pkg/rabbit"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -24,8 +26,97 @@ type Config struct {
 		AdminService          int
 	}
 	JWT struct {
-		Secret      string
+		jwtkey.Config
 		ExpireHours int
+		AccessTTL   time.Duration
+	}
+	Routing struct {
+		Provider       string
+		OSRMURL        string
+		Timeout        time.Duration
+		CacheTTL       time.Duration
+		CacheSize      int
+		CachePrecision int
+	}
+	Pricing struct {
+		Timezone string
+	}
+	// Commission is the platform share of a ride in percent, by ride type
+	Commission map[string]float64
+	Payouts    struct {
+		Interval  time.Duration
+		ExportDir string
+		MinAmount float64
+	}
+	// Scheduling controls rides booked for later: when they are dispatched to
+	// matching, when the passenger is reminded and the allowed booking window
+	Scheduling struct {
+		LeadTime        time.Duration
+		ReminderBefore  time.Duration
+		MinAdvance      time.Duration
+		MaxAdvance      time.Duration
+		PollInterval    time.Duration
+		CancellationFee float64
+	}
+	// Pool limits how POOL rides are combined on one trip
+	Pool struct {
+		MaxDetour   float64
+		MaxPickupKM float64
+	}
+	// Matching controls offers of regular rides to drivers, how soon a ride
+	// still without a driver is sent to matching again and when drivers who
+	// cancel too often stop getting offers
+	Matching struct {
+		RadiusKM        float64
+		OfferDrivers    int
+		RedispatchAfter time.Duration
+		CancelWindow    time.Duration
+		MaxCancelRate   float64
+		CancelMinRides  int
+		BlockFor        time.Duration
+	}
+	// Mail is the SMTP server for outgoing email; with an empty host emails are
+	// appended to File, or printed to stdout when it is empty too
+	Mail struct {
+		Host     string
+		Port     int
+		From     string
+		Username string
+		Password string `json:"-"`
+		File     string
+	}
+	// OTP controls phone login codes. Until an SMS gateway is added the codes
+	// are appended to LogFile. Secret keys the stored code hashes
+	OTP struct {
+		Secret         string `json:"-"`
+		TTL            time.Duration
+		Length         int
+		MaxAttempts    int
+		ResendInterval time.Duration
+		LogFile        string
+	}
+	// Account controls email verification and password reset tokens; LinkURL
+	// is the frontend address the links in the emails point to
+	Account struct {
+		VerifyTTL time.Duration
+		ResetTTL  time.Duration
+		LinkURL   string
+	}
+	Argon2 struct {
+		MemoryKiB   uint32
+		Iterations  uint32
+		Parallelism uint8
+	}
+	// Login locks an account or a client address after too many failed logins
+	// in a row; every further lock doubles, up to LockoutMax. The count starts
+	// over after FailureWindow without failures
+	Login struct {
+		MaxFailures       int
+		IPMaxFailures     int
+		LockoutBase       time.Duration
+		LockoutMax        time.Duration
+		FailureWindow     time.Duration
+		TrustForwardedFor bool
 	}
 }
 
@@ -87,7 +178,7 @@ func parseConfig(filename string) (*Config, error) {
 		}
 
 		switch key {
-		case "postgres", "rabbitmq", "websocket", "services", "jwt":
+		case "postgres", "rabbitmq", "websocket", "services", "jwt", "routing", "pricing", "commission", "payouts", "scheduling", "pool", "matching", "mail", "argon2", "login", "account", "otp":
 			section = key
 
 		default:
@@ -115,6 +206,10 @@ func parseConfig(filename string) (*Config, error) {
 					cfg.RabbitMQ.User = value
 				case "password":
 					cfg.RabbitMQ.Password = value
+				case "message_secret":
+					cfg.RabbitMQ.MessageSecret = value
+				case "message_max_age":
+					cfg.RabbitMQ.MessageMaxAge, _ = time.ParseDuration(value)
 				}
 			case "websocket":
 				if key == "port" {
@@ -135,6 +230,152 @@ func parseConfig(filename string) (*Config, error) {
 					cfg.JWT.Secret = value
 				case "expire_hours":
 					cfg.JWT.ExpireHours, _ = strconv.Atoi(value)
+				case "access_ttl":
+					cfg.JWT.AccessTTL, _ = time.ParseDuration(value)
+				case "signing_key":
+					cfg.JWT.SigningKey = value
+				case "verify_keys":
+					for _, path := range strings.Split(value, ",") {
+						if path = strings.TrimSpace(path); path != "" {
+							cfg.JWT.VerifyKeys = append(cfg.JWT.VerifyKeys, path)
+						}
+					}
+				}
+			case "routing":
+				switch key {
+				case "provider":
+					cfg.Routing.Provider = value
+				case "osrm_url":
+					cfg.Routing.OSRMURL = value
+				case "timeout":
+					cfg.Routing.Timeout, _ = time.ParseDuration(value)
+				case "cache_ttl":
+					cfg.Routing.CacheTTL, _ = time.ParseDuration(value)
+				case "cache_size":
+					cfg.Routing.CacheSize, _ = strconv.Atoi(value)
+				case "cache_precision":
+					cfg.Routing.CachePrecision, _ = strconv.Atoi(value)
+				}
+			case "pricing":
+				if key == "timezone" {
+					cfg.Pricing.Timezone = value
+				}
+			case "commission":
+				if cfg.Commission == nil {
+					cfg.Commission = make(map[string]float64)
+				}
+				cfg.Commission[strings.ToUpper(key)], _ = strconv.ParseFloat(value, 64)
+			case "payouts":
+				switch key {
+				case "interval":
+					cfg.Payouts.Interval, _ = time.ParseDuration(value)
+				case "export_dir":
+					cfg.Payouts.ExportDir = value
+				case "min_amount":
+					cfg.Payouts.MinAmount, _ = strconv.ParseFloat(value, 64)
+				}
+			case "scheduling":
+				switch key {
+				case "lead_time":
+					cfg.Scheduling.LeadTime, _ = time.ParseDuration(value)
+				case "reminder_before":
+					cfg.Scheduling.ReminderBefore, _ = time.ParseDuration(value)
+				case "min_advance":
+					cfg.Scheduling.MinAdvance, _ = time.ParseDuration(value)
+				case "max_advance":
+					cfg.Scheduling.MaxAdvance, _ = time.ParseDuration(value)
+				case "poll_interval":
+					cfg.Scheduling.PollInterval, _ = time.ParseDuration(value)
+				case "cancellation_fee":
+					cfg.Scheduling.CancellationFee, _ = strconv.ParseFloat(value, 64)
+				}
+			case "pool":
+				switch key {
+				case "max_detour":
+					cfg.Pool.MaxDetour, _ = strconv.ParseFloat(value, 64)
+				case "max_pickup_km":
+					cfg.Pool.MaxPickupKM, _ = strconv.ParseFloat(value, 64)
+				}
+			case "matching":
+				switch key {
+				case "radius_km":
+					cfg.Matching.RadiusKM, _ = strconv.ParseFloat(value, 64)
+				case "offer_drivers":
+					cfg.Matching.OfferDrivers, _ = strconv.Atoi(value)
+				case "redispatch_after":
+					cfg.Matching.RedispatchAfter, _ = time.ParseDuration(value)
+				case "cancel_window":
+					cfg.Matching.CancelWindow, _ = time.ParseDuration(value)
+				case "max_cancel_rate":
+					cfg.Matching.MaxCancelRate, _ = strconv.ParseFloat(value, 64)
+				case "cancel_min_rides":
+					cfg.Matching.CancelMinRides, _ = strconv.Atoi(value)
+				case "block_for":
+					cfg.Matching.BlockFor, _ = time.ParseDuration(value)
+				}
+			case "mail":
+				switch key {
+				case "host":
+					cfg.Mail.Host = value
+				case "port":
+					cfg.Mail.Port, _ = strconv.Atoi(value)
+				case "from":
+					cfg.Mail.From = value
+				case "username":
+					cfg.Mail.Username = value
+				case "password":
+					cfg.Mail.Password = value
+				case "file":
+					cfg.Mail.File = value
+				}
+			case "argon2":
+				n, _ := strconv.ParseUint(value, 10, 32)
+				switch key {
+				case "memory_kib":
+					cfg.Argon2.MemoryKiB = uint32(n)
+				case "iterations":
+					cfg.Argon2.Iterations = uint32(n)
+				case "parallelism":
+					cfg.Argon2.Parallelism = uint8(min(n, 255))
+				}
+			case "login":
+				switch key {
+				case "max_failures":
+					cfg.Login.MaxFailures, _ = strconv.Atoi(value)
+				case "ip_max_failures":
+					cfg.Login.IPMaxFailures, _ = strconv.Atoi(value)
+				case "lockout_base":
+					cfg.Login.LockoutBase, _ = time.ParseDuration(value)
+				case "lockout_max":
+					cfg.Login.LockoutMax, _ = time.ParseDuration(value)
+				case "failure_window":
+					cfg.Login.FailureWindow, _ = time.ParseDuration(value)
+				case "trust_forwarded_for":
+					cfg.Login.TrustForwardedFor, _ = strconv.ParseBool(value)
+				}
+			case "otp":
+				switch key {
+				case "secret":
+					cfg.OTP.Secret = value
+				case "ttl":
+					cfg.OTP.TTL, _ = time.ParseDuration(value)
+				case "length":
+					cfg.OTP.Length, _ = strconv.Atoi(value)
+				case "max_attempts":
+					cfg.OTP.MaxAttempts, _ = strconv.Atoi(value)
+				case "resend_interval":
+					cfg.OTP.ResendInterval, _ = time.ParseDuration(value)
+				case "log_file":
+					cfg.OTP.LogFile = value
+				}
+			case "account":
+				switch key {
+				case "verify_ttl":
+					cfg.Account.VerifyTTL, _ = time.ParseDuration(value)
+				case "reset_ttl":
+					cfg.Account.ResetTTL, _ = time.ParseDuration(value)
+				case "link_url":
+					cfg.Account.LinkURL = strings.TrimSuffix(value, "/")
 				}
 			}
 		}
@@ -147,6 +388,122 @@ func parseConfig(filename string) (*Config, error) {
 		cfg.Database.MaxIdleTime = "15m"
 	}
 
+	if cfg.RabbitMQ.MessageMaxAge == 0 {
+		cfg.RabbitMQ.MessageMaxAge = 15 * time.Minute
+	}
+
+	if cfg.Routing.Provider == "" {
+		cfg.Routing.Provider = "haversine"
+	}
+	if cfg.JWT.ExpireHours == 0 {
+		cfg.JWT.ExpireHours = 24
+	}
+	if cfg.JWT.AccessTTL == 0 {
+		cfg.JWT.AccessTTL = 15 * time.Minute
+	}
+	if cfg.Argon2.MemoryKiB == 0 {
+		cfg.Argon2.MemoryKiB = 64 * 1024
+	}
+	if cfg.Argon2.Iterations == 0 {
+		cfg.Argon2.Iterations = 3
+	}
+	if cfg.Argon2.Parallelism == 0 {
+		cfg.Argon2.Parallelism = 4
+	}
+	if cfg.Login.MaxFailures == 0 {
+		cfg.Login.MaxFailures = 5
+	}
+	if cfg.Login.IPMaxFailures == 0 {
+		cfg.Login.IPMaxFailures = 50
+	}
+	if cfg.Login.LockoutBase == 0 {
+		cfg.Login.LockoutBase = time.Minute
+	}
+	if cfg.Login.LockoutMax == 0 {
+		cfg.Login.LockoutMax = time.Hour
+	}
+	if cfg.Login.FailureWindow == 0 {
+		cfg.Login.FailureWindow = 15 * time.Minute
+	}
+	if cfg.OTP.TTL == 0 {
+		cfg.OTP.TTL = 5 * time.Minute
+	}
+	if cfg.OTP.Length < 4 || cfg.OTP.Length > 10 {
+		cfg.OTP.Length = 6
+	}
+	if cfg.OTP.MaxAttempts == 0 {
+		cfg.OTP.MaxAttempts = 5
+	}
+	if cfg.OTP.ResendInterval == 0 {
+		cfg.OTP.ResendInterval = time.Minute
+	}
+	if cfg.OTP.LogFile == "" {
+		cfg.OTP.LogFile = "sms.log"
+	}
+	if cfg.Account.VerifyTTL == 0 {
+		cfg.Account.VerifyTTL = 48 * time.Hour
+	}
+	if cfg.Account.ResetTTL == 0 {
+		cfg.Account.ResetTTL = time.Hour
+	}
+	if cfg.Account.LinkURL == "" {
+		cfg.Account.LinkURL = "http://localhost:3000"
+	}
+	if cfg.Pricing.Timezone == "" {
+		cfg.Pricing.Timezone = "UTC"
+	}
+	if cfg.Routing.Timeout == 0 {
+		cfg.Routing.Timeout = 2 * time.Second
+	}
+	if cfg.Mail.Port == 0 {
+		cfg.Mail.Port = 25
+	}
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = "no-reply@ride-hail.local"
+	}
+	if cfg.Payouts.ExportDir == "" {
+		cfg.Payouts.ExportDir = "payouts"
+	}
+	if cfg.Scheduling.LeadTime == 0 {
+		cfg.Scheduling.LeadTime = 15 * time.Minute
+	}
+	if cfg.Scheduling.ReminderBefore == 0 {
+		cfg.Scheduling.ReminderBefore = time.Hour
+	}
+	if cfg.Scheduling.MaxAdvance == 0 {
+		cfg.Scheduling.MaxAdvance = 7 * 24 * time.Hour
+	}
+	if cfg.Pool.MaxDetour == 0 {
+		cfg.Pool.MaxDetour = 0.5
+	}
+	if cfg.Pool.MaxPickupKM == 0 {
+		cfg.Pool.MaxPickupKM = 3
+	}
+	if cfg.Matching.RadiusKM == 0 {
+		cfg.Matching.RadiusKM = 5
+	}
+	if cfg.Matching.OfferDrivers == 0 {
+		cfg.Matching.OfferDrivers = 3
+	}
+	if cfg.Matching.RedispatchAfter == 0 {
+		cfg.Matching.RedispatchAfter = time.Minute
+	}
+	if cfg.Matching.CancelWindow == 0 {
+		cfg.Matching.CancelWindow = 24 * time.Hour
+	}
+	if cfg.Matching.MaxCancelRate == 0 {
+		cfg.Matching.MaxCancelRate = 0.3
+	}
+	if cfg.Matching.CancelMinRides == 0 {
+		cfg.Matching.CancelMinRides = 5
+	}
+	if cfg.Matching.BlockFor == 0 {
+		cfg.Matching.BlockFor = time.Hour
+	}
+	if cfg.Scheduling.PollInterval == 0 {
+		cfg.Scheduling.PollInterval = 30 * time.Second
+	}
+
 	return &cfg, scanner.Err()
 }
 
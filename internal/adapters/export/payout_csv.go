@@ -0,0 +1,72 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+var payoutHeader = []string{"batch_id", "payout_id", "driver_id", "amount", "status", "ledger_transaction_id", "created_at"}
+
+// PayoutCSV writes payout batches as CSV files for finance.
+type PayoutCSV struct {
+	dir string
+}
+
+func NewPayoutCSV(dir string) *PayoutCSV {
+	return &PayoutCSV{
+		dir: dir,
+	}
+}
+
+// Export writes the batch to <dir>/payouts_<date>_<batch id>.csv and returns the file path.
+func (e *PayoutCSV) Export(batch models.PayoutBatch, payouts []models.Payout) (string, error) {
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create export dir: %w", err)
+	}
+
+	name := fmt.Sprintf("payouts_%s_%s.csv", batch.CreatedAt.UTC().Format("20060102"), batch.ID)
+	path := filepath.Join(e.dir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+
+	if err = e.Write(f, batch, payouts); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+func (e *PayoutCSV) Write(w io.Writer, batch models.PayoutBatch, payouts []models.Payout) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(payoutHeader); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	for _, p := range payouts {
+		row := []string{
+			batch.ID,
+			p.ID,
+			p.DriverID,
+			strconv.FormatFloat(p.Amount, 'f', 2, 64),
+			p.Status,
+			p.LedgerTransactionID,
+			p.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,147 @@
+package handle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ride-hail/internal/adapters/http/handle/dto"
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+)
+
+type AdminHandle struct {
+	promo  ports.PromoService
+	payout ports.PayoutService
+	auth   ports.AuthService
+	log    *logger.Logger
+}
+
+func NewAdminHandle(promo ports.PromoService, payout ports.PayoutService, auth ports.AuthService, log *logger.Logger) *AdminHandle {
+	return &AdminHandle{
+		promo:  promo,
+		payout: payout,
+		auth:   auth,
+		log:    log,
+	}
+}
+
+type AdminHandler interface {
+	CreatePromoCode(w http.ResponseWriter, r *http.Request)
+	RunPayouts(w http.ResponseWriter, r *http.Request)
+	ExportPayoutBatch(w http.ResponseWriter, r *http.Request)
+	UnlockUser(w http.ResponseWriter, r *http.Request)
+	CreateAdmin(w http.ResponseWriter, r *http.Request)
+}
+
+func (h *AdminHandle) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("AdminHandle.CreatePromoCode")
+	ctx := r.Context()
+
+	promo := models.PromoCode{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&promo); err != nil {
+		log.Error(ctx, action.CreatePromo, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if ok, msg := dto.ValidatePromo(promo); !ok {
+		log.Warn(ctx, action.CreatePromo, "invalid request", "reason", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.promo.CreatePromo(ctx, promo)
+	if err != nil {
+		if errors.Is(err, types.ErrPromoAlreadyExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *AdminHandle) RunPayouts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	batch, err := h.payout.RunBatch(ctx)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if batch.ID == "" {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"message": "no payable balances",
+		})
+		return
+	}
+	writeJSON(w, http.StatusCreated, batch)
+}
+
+func (h *AdminHandle) ExportPayoutBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	batchID := r.PathValue("batch_id")
+
+	var buf bytes.Buffer
+	if err := h.payout.ExportBatch(ctx, batchID, &buf); err != nil {
+		if errors.Is(err, types.ErrPayoutBatchNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=payouts_%s.csv", batchID))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (h *AdminHandle) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := h.auth.UnlockUser(ctx, r.PathValue("user_id")); err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "account unlocked"})
+}
+
+// CreateAdmin creates another admin account. The new admin verifies the email
+// like any other user.
+func (h *AdminHandle) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("AdminHandle.CreateAdmin")
+	ctx := r.Context()
+
+	var req models.RegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if ok, msg := dto.ValidateAdmin(&req); !ok {
+		log.Warn(ctx, action.Registration, "invalid request", "reason", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	req.Role = types.RoleAdmin
+	if err := h.auth.CreateNewUser(ctx, req); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "admin created, the verification email has been sent"})
+}
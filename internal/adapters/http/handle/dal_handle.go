@@ -1,21 +1,34 @@
 package handle
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
 	"ride-hail/internal/core/ports"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsm"
 )
 
 type DalHandle struct {
-	svc ports.DalService
-	log *logger.Logger
+	svc      ports.DalService
+	earnings ports.EarningsService
+	wsm      wsm.HandlerWS
+	log      *logger.Logger
 }
 
-func NewDalHandle(svc ports.DalService, log *logger.Logger) *DalHandle {
+func NewDalHandle(svc ports.DalService, earnings ports.EarningsService, wsm wsm.HandlerWS, log *logger.Logger) *DalHandle {
 	return &DalHandle{
-		svc: svc,
-		log: log,
+		svc:      svc,
+		earnings: earnings,
+		wsm:      wsm,
+		log:      log,
 	}
 }
 
@@ -23,6 +36,392 @@ type DalHandler interface {
 	DriverGoesOnline(w http.ResponseWriter, r *http.Request)
 	DriverGoesOffline(w http.ResponseWriter, r *http.Request)
 	UpdateDriverLocation(w http.ResponseWriter, r *http.Request)
+	AcceptRide(w http.ResponseWriter, r *http.Request)
+	DriverArrived(w http.ResponseWriter, r *http.Request)
 	StartRide(w http.ResponseWriter, r *http.Request)
+	CancelRide(w http.ResponseWriter, r *http.Request)
+	StopArrived(w http.ResponseWriter, r *http.Request)
+	StopDeparted(w http.ResponseWriter, r *http.Request)
 	CompleteRide(w http.ResponseWriter, r *http.Request)
+	GetBalance(w http.ResponseWriter, r *http.Request)
+	ListTransactions(w http.ResponseWriter, r *http.Request)
+	GetEarnings(w http.ResponseWriter, r *http.Request)
+	ListRideEarnings(w http.ResponseWriter, r *http.Request)
+	WSDriver(w http.ResponseWriter, r *http.Request)
+}
+
+func (h *DalHandle) DriverGoesOnline(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("DalHandle.DriverGoesOnline")
+	ctx := r.Context()
+
+	driverID := r.PathValue("driver_id")
+
+	req := models.DriverOnlineRequest{DriverID: driverID}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(ctx, action.DriverOnline, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		log.Warn(ctx, action.DriverOnline, "invalid coordinates")
+		http.Error(w, "invalid coordinates", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.svc.GoOnline(ctx, req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) DriverGoesOffline(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	driverID := r.PathValue("driver_id")
+
+	resp, err := h.svc.GoOffline(ctx, driverID)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) UpdateDriverLocation(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("DalHandle.UpdateDriverLocation")
+	ctx := r.Context()
+
+	driverID := r.PathValue("driver_id")
+
+	req := models.LocationUpdateRequest{DriverID: driverID}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(ctx, action.UpdateLocation, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		log.Warn(ctx, action.UpdateLocation, "invalid coordinates")
+		http.Error(w, "invalid coordinates", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.svc.UpdateLocation(ctx, req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) AcceptRide(w http.ResponseWriter, r *http.Request) {
+	req := models.DriverRideRequest{DriverID: r.PathValue("driver_id"), RideID: r.PathValue("ride_id")}
+
+	resp, err := h.svc.AcceptRide(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) DriverArrived(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeDriverRide(w, r, action.DriverArrived)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.DriverArrived(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) StartRide(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeDriverRide(w, r, action.StartRide)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.StartRide(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) CancelRide(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("DalHandle.CancelRide")
+
+	driverID := r.PathValue("driver_id")
+
+	// the reason is optional, so is the body
+	req := models.DriverCancelRequest{DriverID: driverID, RideID: r.PathValue("ride_id")}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.Error(r.Context(), action.DriverCancel, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.svc.CancelRide(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) StopArrived(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeDriverStop(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.ArriveAtStop(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) StopDeparted(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeDriverStop(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.DepartFromStop(r.Context(), req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) CompleteRide(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("DalHandle.CompleteRide")
+	ctx := r.Context()
+
+	driverID := r.PathValue("driver_id")
+
+	req := models.CompleteRideRequest{DriverID: driverID}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(ctx, action.CompleteRide, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.RideID == "" || req.ActualDistanceKm < 0 || req.ActualDurationMinutes < 0 {
+		log.Warn(ctx, action.CompleteRide, "invalid request")
+		http.Error(w, "ride_id is required, actual_distance_km and actual_duration_minutes can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	for _, c := range req.ExtraCharges {
+		switch c.Type {
+		case types.ExtraChargeToll, types.ExtraChargeAirportFee, types.ExtraChargeOther:
+		default:
+			http.Error(w, "invalid extra charge type: "+c.Type, http.StatusBadRequest)
+			return
+		}
+		if c.Amount < 0 {
+			http.Error(w, "extra charge amount must be >= 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := h.svc.CompleteRide(ctx, req)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+
+	log.Debug(ctx, action.CompleteRide, "ride completed", "ride_id", resp.RideID, "final_fare", resp.FinalFare)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) GetBalance(w http.ResponseWriter, r *http.Request) {
+	driverID := r.PathValue("driver_id")
+
+	resp, err := h.svc.GetDriverBalance(r.Context(), driverID)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *DalHandle) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	driverID := r.PathValue("driver_id")
+
+	limit, offset, err := getPagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lines, err := h.svc.ListDriverTransactions(r.Context(), driverID, limit, offset)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"transactions": lines,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+func (h *DalHandle) GetEarnings(w http.ResponseWriter, r *http.Request) {
+	driverID := r.PathValue("driver_id")
+
+	period := r.URL.Query().Get("period")
+	if period != "" && period != types.EarningsPeriodDaily && period != types.EarningsPeriodWeekly {
+		http.Error(w, "period must be daily or weekly", http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := getDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.earnings.GetStatement(r.Context(), driverID, period, from, to)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (h *DalHandle) ListRideEarnings(w http.ResponseWriter, r *http.Request) {
+	driverID := r.PathValue("driver_id")
+
+	from, to, err := getDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rides, err := h.earnings.ListRideEarnings(r.Context(), driverID, from, to)
+	if err != nil {
+		writeDalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"rides": rides,
+	})
+}
+
+func (h *DalHandle) WSDriver(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("DalHandle.WSDriver")
+
+	driverID := r.PathValue("driver_id")
+
+	log.Debug(r.Context(), action.WSDriver, "connection request received from driver")
+	serveWS(w, r, driverID, h.wsm, log, action.WSDriver)
+}
+
+func (h *DalHandle) decodeDriverRide(w http.ResponseWriter, r *http.Request, act string) (models.DriverRideRequest, bool) {
+	log := h.log.Func("DalHandle.decodeDriverRide")
+
+	driverID := r.PathValue("driver_id")
+
+	req := models.DriverRideRequest{DriverID: driverID}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(r.Context(), act, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return models.DriverRideRequest{}, false
+	}
+	if req.RideID == "" {
+		http.Error(w, "ride_id is required", http.StatusBadRequest)
+		return models.DriverRideRequest{}, false
+	}
+	return req, true
+}
+
+func (h *DalHandle) decodeDriverStop(w http.ResponseWriter, r *http.Request) (models.DriverStopRequest, bool) {
+	log := h.log.Func("DalHandle.decodeDriverStop")
+
+	driverID := r.PathValue("driver_id")
+
+	req := models.DriverStopRequest{DriverID: driverID}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(r.Context(), action.RideStop, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return models.DriverStopRequest{}, false
+	}
+	if req.RideID == "" || req.Sequence <= 0 {
+		http.Error(w, "ride_id and sequence are required", http.StatusBadRequest)
+		return models.DriverStopRequest{}, false
+	}
+	return req, true
+}
+
+func writeDalError(w http.ResponseWriter, err error) {
+	var active *types.ActiveRideError
+	switch {
+	case errors.As(err, &active):
+		writeJSON(w, http.StatusConflict, models.ActiveRideConflict{Error: active.Err.Error(), RideID: active.RideID})
+	case errors.Is(err, types.ErrDriverBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, types.ErrRideNotFound), errors.Is(err, types.ErrDriverNotFound), errors.Is(err, types.ErrStopNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, types.ErrRideNotAssigned), errors.Is(err, types.ErrRideNotOffered):
+		WriteForbidden(w, err.Error())
+	case errors.Is(err, types.ErrInvalidRideStatus), errors.Is(err, types.ErrInvalidStopStatus):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, types.ErrPaymentFailed):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// getPagination reads limit/offset query parameters (default 20/0, max limit 100).
+func getPagination(r *http.Request) (int, int, error) {
+	limit, offset := 20, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			return 0, 0, errors.New("limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be >= 0")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+// getDateRange reads optional from/to query parameters in YYYY-MM-DD format.
+func getDateRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a date in YYYY-MM-DD format")
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.DateOnly, v); err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date in YYYY-MM-DD format")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	return from, to, nil
 }
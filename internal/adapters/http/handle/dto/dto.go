@@ -1,50 +1,129 @@
 package dto
 
 import (
+	"fmt"
 	"ride-hail/internal/adapters/http/handle/dto/validate"
 	"ride-hail/internal/core/domain/models"
 	"ride-hail/internal/core/domain/types"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
-var mode string
+var vehicleTypes = []string{types.RideTypeECONOMY, types.RideTypePREMIUM, types.RideTypeXL}
 
-func ValidateLogin(u *models.User) (bool, string) {
+// ValidateRegistration checks a self-registration of a passenger or a driver.
+func ValidateRegistration(req *models.RegistrationRequest) (bool, string) {
 	var res []string
 
-	if !validate.ValidateEmail(u.Email, true) {
-		res = append(res, "email is invalid")
+	switch req.Role {
+	case types.RolePassenger:
+		if req.Driver != nil {
+			res = append(res, "driver profile is only allowed for role DRIVER")
+		}
+	case types.RoleDriver:
+		if req.Driver == nil {
+			res = append(res, "driver profile is required for role DRIVER")
+		} else {
+			res = append(res, validateDriverProfile(req.Driver)...)
+		}
+	case types.RoleAdmin:
+		res = append(res, "admin accounts are created by an admin")
+	default:
+		res = append(res, "role must be PASSENGER or DRIVER")
 	}
 
-	localPart := ""
-	parts := strings.Split(u.Email, "@")
-	if len(parts) == 2 {
-		localPart = parts[0]
+	res = append(res, validateAccount(req.Email, req.Password, req.Name)...)
+	if !validate.ValidatePhone(req.Phone) {
+		res = append(res, "phone must be in E.164 format, e.g. +77011234567")
+	}
+
+	return len(res) == 0, strings.Join(res, ", ")
+}
+
+// ValidateAdmin checks an admin account created by another admin. The phone
+// is optional.
+func ValidateAdmin(req *models.RegistrationRequest) (bool, string) {
+	res := validateAccount(req.Email, req.Password, req.Name)
+	if req.Phone != "" && !validate.ValidatePhone(req.Phone) {
+		res = append(res, "phone must be in E.164 format, e.g. +77011234567")
 	}
+	if req.Driver != nil {
+		res = append(res, "driver profile is only allowed for role DRIVER")
+	}
+	return len(res) == 0, strings.Join(res, ", ")
+}
 
-	if ok, msg := validate.ValidatePassword(u.Password, localPart); !ok {
+func validateAccount(email, password, name string) []string {
+	var res []string
+
+	if !validate.ValidateEmail(email, true) {
+		res = append(res, "email is invalid")
+	}
+	if ok, msg := validate.ValidatePassword(password, emailLocalPart(email)); !ok {
 		res = append(res, msg)
 	}
+	if name = strings.TrimSpace(name); name == "" || utf8.RuneCountInString(name) > 100 {
+		res = append(res, "name is required (max 100 characters)")
+	}
+	return res
+}
+
+func validateDriverProfile(d *models.DriverProfile) []string {
+	var res []string
+
+	if d.LicenseNumber == "" || len(d.LicenseNumber) > 50 {
+		res = append(res, "license_number is required (max 50 characters)")
+	}
+	if !slices.Contains(vehicleTypes, d.VehicleType) {
+		res = append(res, fmt.Sprintf("vehicle_type must be one of %s", strings.Join(vehicleTypes, ", ")))
+	}
+	if strings.TrimSpace(d.Vehicle.Plate) == "" {
+		res = append(res, "vehicle_plate is required")
+	}
+	if d.Vehicle.Seats < 0 || d.Vehicle.Seats > 8 {
+		res = append(res, "seats must be between 0 and 8")
+	}
+	return res
+}
 
-	if len(res) == 0 {
-		getRole(u)
-		return true, ""
+// ValidateOTPRequest checks the phone a code is requested for.
+func ValidateOTPRequest(req models.OTPRequest) (bool, string) {
+	if !validate.ValidatePhone(req.Phone) {
+		return false, "phone must be in E.164 format, e.g. +77011234567"
 	}
+	return true, ""
+}
 
-	return false, strings.Join(res, ", ")
+// ValidateOTPVerify checks the phone and that the code is made of digits.
+func ValidateOTPVerify(req models.OTPVerifyRequest) (bool, string) {
+	var res []string
+
+	if !validate.ValidatePhone(req.Phone) {
+		res = append(res, "phone must be in E.164 format, e.g. +77011234567")
+	}
+	if req.Code == "" || len(req.Code) > 10 || strings.Trim(req.Code, "0123456789") != "" {
+		res = append(res, "code must be digits")
+	}
+	return len(res) == 0, strings.Join(res, ", ")
 }
 
-func InitMode(m string) {
-	mode = m
+// ValidateResetPassword checks the new password of a reset against the same
+// policy as registration.
+func ValidateResetPassword(req models.ResetPasswordRequest, email string) (bool, string) {
+	if req.Token == "" {
+		return false, "token is required"
+	}
+	if ok, msg := validate.ValidatePassword(req.Password, emailLocalPart(email)); !ok {
+		return false, msg
+	}
+	return true, ""
 }
 
-func getRole(user *models.User) {
-	switch mode {
-	case types.ModeAdmin:
-		user.Role = types.RoleAdmin
-	case types.ModeDAL:
-		user.Role = types.RoleDriver
-	case types.ModeRide:
-		user.Role = types.RoleCustomer
+func emailLocalPart(email string) string {
+	parts := strings.Split(email, "@")
+	if len(parts) == 2 {
+		return parts[0]
 	}
+	return ""
 }
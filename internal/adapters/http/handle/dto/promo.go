@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+)
+
+var promoCodeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{3,50}$`)
+
+func ValidatePromo(p models.PromoCode) (bool, string) {
+	var reasons []string
+
+	if !promoCodeRe.MatchString(p.Code) {
+		reasons = append(reasons, "invalid_code")
+	}
+
+	switch p.DiscountType {
+	case types.DiscountTypePercent:
+		if p.Value <= 0 || p.Value > 100 {
+			reasons = append(reasons, "percent_value_out_of_range")
+		}
+	case types.DiscountTypeFixed:
+		if p.Value <= 0 {
+			reasons = append(reasons, "invalid_value")
+		}
+	default:
+		reasons = append(reasons, fmt.Sprintf("invalid_discount_type: %s", p.DiscountType))
+	}
+
+	if p.MaxDiscount != nil && *p.MaxDiscount <= 0 {
+		reasons = append(reasons, "invalid_max_discount")
+	}
+	if p.UsageLimit != nil && *p.UsageLimit <= 0 {
+		reasons = append(reasons, "invalid_usage_limit")
+	}
+	if p.PerUserLimit < 0 {
+		reasons = append(reasons, "invalid_per_user_limit")
+	}
+	if p.ValidUntil != nil && !p.ValidFrom.IsZero() && !p.ValidUntil.After(p.ValidFrom) {
+		reasons = append(reasons, "invalid_validity_window")
+	}
+
+	for _, rt := range p.RideTypes {
+		if !slices.Contains(DefaultRideRules.AllowRideTypes, rt) {
+			reasons = append(reasons, fmt.Sprintf("invalid_ride_type: %s", rt))
+		}
+	}
+
+	return len(reasons) == 0, strings.Join(reasons, ", ")
+}
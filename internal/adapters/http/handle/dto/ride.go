@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"slices"
 	"strings"
 )
 
@@ -12,7 +14,12 @@ type RideRules struct {
 }
 
 var DefaultRideRules = RideRules{
-	AllowRideTypes: []string{"ECONOMY", "PREMIUM", "XL"},
+	AllowRideTypes: []string{"ECONOMY", "PREMIUM", "XL", "POOL"},
+}
+
+var rideStatuses = []string{
+	types.RideStatusSCHEDULED, types.RideStatusREQUESTED, types.RideStatusMATCHED, types.RideStatusEN_ROUTE,
+	types.RideStatusARRIVED, types.RideStatusIN_PROGRESS, types.RideStatusCOMPLETED, types.RideStatusCANCELLED,
 }
 
 func isValidUUID(u string) bool {
@@ -49,6 +56,12 @@ func ValidateRideDTO(dto models.CreateRideRequest) (bool, string) {
 		reasons = append(reasons, "empty_destination_address")
 	}
 
+	for i, stop := range dto.Stops {
+		if reason := validateStop(stop); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("stop_%d_%s", i+1, reason))
+		}
+	}
+
 	validType := false
 	for _, allowed := range DefaultRideRules.AllowRideTypes {
 		if strings.EqualFold(dto.RideType, allowed) {
@@ -62,3 +75,36 @@ func ValidateRideDTO(dto models.CreateRideRequest) (bool, string) {
 
 	return len(reasons) == 0, strings.Join(reasons, ", ")
 }
+
+// ValidateStopDTO checks a stop the passenger adds or changes mid-ride.
+func ValidateStopDTO(dto models.StopRequest) (bool, string) {
+	if dto.Sequence < 0 {
+		return false, "invalid_sequence"
+	}
+	reason := validateStop(dto)
+	return reason == "", reason
+}
+
+func validateStop(stop models.StopRequest) string {
+	switch {
+	case stop.Latitude < -90 || stop.Latitude > 90:
+		return "invalid_latitude"
+	case stop.Longitude < -180 || stop.Longitude > 180:
+		return "invalid_longitude"
+	case strings.TrimSpace(stop.Address) == "":
+		return "empty_address"
+	}
+	return ""
+}
+
+// ValidateRideFilter checks the query of the passenger's ride history.
+func ValidateRideFilter(f models.RideFilter) (bool, string) {
+	var reasons []string
+	if f.Status != "" && !slices.Contains(rideStatuses, f.Status) {
+		reasons = append(reasons, fmt.Sprintf("invalid_status: %s", f.Status))
+	}
+	if f.Limit < 1 || f.Limit > 100 {
+		reasons = append(reasons, "limit must be between 1 and 100")
+	}
+	return len(reasons) == 0, strings.Join(reasons, ", ")
+}
@@ -0,0 +1,11 @@
+package validate
+
+import "regexp"
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// ValidatePhone checks that the phone is in E.164 format: a plus sign and up
+// to 15 digits, e.g. +77011234567.
+func ValidatePhone(phone string) bool {
+	return e164.MatchString(phone)
+}
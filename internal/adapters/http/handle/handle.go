@@ -3,7 +3,9 @@ package handle
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"ride-hail/config"
 	"ride-hail/internal/adapters/http/handle/dto"
@@ -12,25 +14,43 @@ import (
 	"ride-hail/internal/core/domain/types"
 	"ride-hail/internal/core/ports"
 	"ride-hail/pkg/logger"
+	"strconv"
+	"time"
+)
+
+const (
+	accessCookie  = "Authorization"
+	refreshCookie = "Refresh"
 )
 
 type Handle struct {
-	svc    ports.AuthService
-	log    *logger.Logger
-	expJWT int
+	svc       ports.AuthService
+	otp       ports.OTPService
+	log       *logger.Logger
+	expJWT    int
+	accessTTL time.Duration
 }
 
 type AuthHandle interface {
 	Registration(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerification(w http.ResponseWriter, r *http.Request)
+	ForgotPassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+	RequestOTP(w http.ResponseWriter, r *http.Request)
+	VerifyOTP(w http.ResponseWriter, r *http.Request)
 }
 
-func New(cfg config.Config, svc ports.AuthService, log *logger.Logger) *Handle {
-	dto.InitMode(cfg.Mode)
+func New(cfg config.Config, svc ports.AuthService, otp ports.OTPService, log *logger.Logger) *Handle {
 	return &Handle{
-		svc:    svc,
-		log:    log,
-		expJWT: cfg.JWT.ExpireHours,
+		svc:       svc,
+		otp:       otp,
+		log:       log,
+		expJWT:    cfg.JWT.ExpireHours,
+		accessTTL: cfg.JWT.AccessTTL,
 	}
 }
 
@@ -52,7 +72,7 @@ func (h *Handle) Registration(w http.ResponseWriter, r *http.Request) {
 		"registration request started",
 	)
 
-	var req models.User
+	var req models.RegistrationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Error(
 			ctx,
@@ -64,7 +84,7 @@ func (h *Handle) Registration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok, msg := dto.ValidateLogin(&req); !ok {
+	if ok, msg := dto.ValidateRegistration(&req); !ok {
 		log.Error(
 			ctx,
 			action.Registration, msg,
@@ -76,16 +96,12 @@ func (h *Handle) Registration(w http.ResponseWriter, r *http.Request) {
 
 	err := h.svc.CreateNewUser(ctx, req)
 	if err != nil {
-		if errors.Is(err, types.ErrUserAlreadyExists) {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRegistrationError(w, err)
 		return
 	}
 
 	log.Debug(ctx, action.Registration, "registration request finished")
-	writeJSON(w, http.StatusCreated, map[string]string{"message": "registration successful"})
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "registration successful, check your email to verify it"})
 }
 
 func (h *Handle) Login(w http.ResponseWriter, r *http.Request) {
@@ -117,8 +133,8 @@ func (h *Handle) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	if err = json.Unmarshal(body, &user); err != nil {
+	var req models.LoginRequest
+	if err = json.Unmarshal(body, &req); err != nil {
 		log.Error(
 			ctx,
 			action.Login,
@@ -129,31 +145,329 @@ func (h *Handle) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.svc.Login(ctx, user)
+	pair, err := h.svc.Login(ctx, req.User)
 	if err != nil {
+		var locked *types.LoginLockedError
 		switch {
-		case errors.Is(err, types.ErrUserNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, types.ErrIncorrectPassword):
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.As(err, &locked):
+			writeLoginLocked(w, locked)
+		case errors.Is(err, types.ErrInvalidCredentials):
+			WriteUnauthorized(w, "", err.Error())
+		case errors.Is(err, types.ErrEmailNotVerified):
+			WriteForbidden(w, err.Error())
 		default:
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	h.setTokenCookies(w, pair)
+
+	log.Info(ctx, action.Login, "user successfully logged in")
+	writeJSON(w, http.StatusOK, tokenResponse("login successful", pair, req.ReturnTokens))
+}
+
+func (h *Handle) Refresh(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("Refresh")
+	ctx := r.Context()
+
+	// browsers send the cookie, other clients the token they got in the body
+	refreshToken, fromBody := refreshTokenFrom(r)
+	if refreshToken == "" {
+		log.Warn(ctx, action.RefreshToken, "no refresh token")
+		WriteUnauthorized(w, "", "no refresh token")
+		return
+	}
+
+	pair, err := h.svc.Refresh(ctx, refreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrInvalidRefreshToken), errors.Is(err, types.ErrRefreshTokenReused):
+			clearTokenCookies(w)
+			WriteUnauthorized(w, "invalid_token", err.Error())
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.setTokenCookies(w, pair)
+	writeJSON(w, http.StatusOK, tokenResponse("token refreshed", pair, fromBody))
+}
+
+func (h *Handle) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	refreshToken, _ := refreshTokenFrom(r)
+	if err := h.svc.Logout(ctx, refreshToken); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	clearTokenCookies(w)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "logout successful"})
+}
+
+func (h *Handle) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req models.AccountTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.VerifyEmail(ctx, req.Token); err != nil {
+		if errors.Is(err, types.ErrInvalidAccountToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "email verified"})
+}
+
+func (h *Handle) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.ResendVerification(ctx, req.Email); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email is registered and not verified yet, a verification link has been sent",
+	})
+}
+
+func (h *Handle) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.ForgotPassword(ctx, req.Email); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email is registered, a password reset link has been sent",
+	})
+}
+
+func (h *Handle) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("ResetPassword")
+	ctx := r.Context()
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// the token names the user, whose email the password must not contain
+	u, err := h.svc.CheckResetToken(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidAccountToken) || errors.Is(err, types.ErrUserNotFound) {
+			http.Error(w, types.ErrInvalidAccountToken.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if ok, msg := dto.ValidateResetPassword(req, u.Email); !ok {
+		log.Warn(ctx, action.ResetPassword, msg, "error", ErrorInValidateLogin)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err = h.svc.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		if errors.Is(err, types.ErrInvalidAccountToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	clearTokenCookies(w)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "password changed, log in with the new password"})
+}
+
+func (h *Handle) RequestOTP(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("RequestOTP")
+	ctx := r.Context()
+
+	var req models.OTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if ok, msg := dto.ValidateOTPRequest(req); !ok {
+		log.Warn(ctx, action.OTPLogin, "invalid request", "reason", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.otp.RequestOTP(ctx, req.Phone); err != nil {
+		var locked *types.LoginLockedError
+		if errors.As(err, &locked) {
+			writeLoginLocked(w, locked)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the phone is registered, a code has been sent",
+	})
+}
+
+func (h *Handle) VerifyOTP(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("VerifyOTP")
+	ctx := r.Context()
+
+	var req models.OTPVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if ok, msg := dto.ValidateOTPVerify(req); !ok {
+		log.Warn(ctx, action.OTPLogin, "invalid request", "reason", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.otp.VerifyOTP(ctx, req.Phone, req.Code)
+	if err != nil {
+		var locked *types.LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			writeLoginLocked(w, locked)
+		case errors.Is(err, types.ErrInvalidOTP):
+			WriteUnauthorized(w, "", err.Error())
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.setTokenCookies(w, pair)
+	writeJSON(w, http.StatusOK, tokenResponse("login successful", pair, req.ReturnTokens))
+}
+
+// writeLoginLocked responds 429 with the seconds left of the lockout.
+func writeLoginLocked(w http.ResponseWriter, locked *types.LoginLockedError) {
+	retryAfter := int(math.Ceil(time.Until(locked.Until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	http.Error(w, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
+
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrUserAlreadyExists),
+		errors.Is(err, types.ErrPhoneAlreadyExists),
+		errors.Is(err, types.ErrLicenseAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// refreshTokenFrom reads the refresh token from the Refresh cookie or, for
+// clients without cookies, from the JSON body. fromBody reports the latter.
+func refreshTokenFrom(r *http.Request) (token string, fromBody bool) {
+	if cookie, err := r.Cookie(refreshCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, false
+	}
+
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return "", false
+	}
+	return req.RefreshToken, req.RefreshToken != ""
+}
+
+// tokenResponse puts the tokens into the body only when asked, browsers keep
+// using the HttpOnly cookies.
+func tokenResponse(msg string, pair models.TokenPair, withTokens bool) models.TokenResponse {
+	resp := models.TokenResponse{Message: msg}
+	if withTokens {
+		resp.AccessToken = pair.AccessToken
+		resp.TokenType = "Bearer"
+		resp.ExpiresIn = int(time.Until(pair.AccessExpiresAt).Round(time.Second).Seconds())
+		resp.RefreshToken = pair.RefreshToken
+	}
+	return resp
+}
+
+func (h *Handle) setTokenCookies(w http.ResponseWriter, pair models.TokenPair) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookie,
+		Value:    pair.AccessToken,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(h.accessTTL.Seconds()),
+	})
 	http.SetCookie(w, &http.Cookie{
-		Name:     "Authorization",
-		Value:    token,
+		Name:     refreshCookie,
+		Value:    pair.RefreshToken,
 		HttpOnly: true,
 		Secure:   true,
 		Path:     "/",
 		SameSite: http.SameSiteStrictMode,
 		MaxAge:   h.expJWT * 60 * 60,
 	})
+}
 
-	log.Info(ctx, action.Login, "user successfully logged in")
-	writeJSON(w, http.StatusOK, map[string]string{"message": "login successful"})
+func clearTokenCookies(w http.ResponseWriter) {
+	for _, name := range []string{accessCookie, refreshCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			HttpOnly: true,
+			Secure:   true,
+			Path:     "/",
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   -1,
+		})
+	}
+}
+
+// AuthChallenge builds a WWW-Authenticate value for the Bearer scheme
+// (RFC 6750). errCode is left out for requests that carried no token.
+func AuthChallenge(errCode, description string) string {
+	challenge := `Bearer realm="ride-hail"`
+	if errCode != "" {
+		challenge += fmt.Sprintf(`, error=%q, error_description=%q`, errCode, description)
+	}
+	return challenge
+}
+
+// WriteUnauthorized responds 401 with a Bearer challenge.
+func WriteUnauthorized(w http.ResponseWriter, errCode, description string) {
+	w.Header().Set("WWW-Authenticate", AuthChallenge(errCode, description))
+	msg := description
+	if msg == "" {
+		msg = http.StatusText(http.StatusUnauthorized)
+	}
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+// WriteForbidden responds 403 with a JSON error body.
+func WriteForbidden(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusForbidden, models.ErrorResponse{Error: "forbidden", Message: message})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
@@ -2,8 +2,8 @@ package handle
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"net/http"
 	"ride-hail/internal/adapters/http/handle/dto"
 	"ride-hail/internal/core/domain/action"
@@ -12,27 +12,36 @@ import (
 	"ride-hail/internal/core/ports"
 	"ride-hail/pkg/logger"
 	"ride-hail/pkg/wsm"
+	"strconv"
 	"strings"
-	"time"
 )
 
 type RideHandle struct {
-	svc ports.RideService
-	wsm wsm.HandlerWS
-	log *logger.Logger
+	svc      ports.RideService
+	receipts ports.ReceiptService
+	wsm      wsm.HandlerWS
+	log      *logger.Logger
 }
 
-func NewRideHandle(svc ports.RideService, wsm wsm.HandlerWS, log *logger.Logger) *RideHandle {
+func NewRideHandle(svc ports.RideService, receipts ports.ReceiptService, wsm wsm.HandlerWS, log *logger.Logger) *RideHandle {
 	return &RideHandle{
-		svc: svc,
-		wsm: wsm,
-		log: log,
+		svc:      svc,
+		receipts: receipts,
+		wsm:      wsm,
+		log:      log,
 	}
 }
 
 type RideHandler interface {
 	CreateNewRide(w http.ResponseWriter, r *http.Request)
 	CancelRide(w http.ResponseWriter, r *http.Request)
+	GetRide(w http.ResponseWriter, r *http.Request)
+	ListRides(w http.ResponseWriter, r *http.Request)
+	AddStop(w http.ResponseWriter, r *http.Request)
+	ChangeStop(w http.ResponseWriter, r *http.Request)
+	WSPassenger(w http.ResponseWriter, r *http.Request)
+	TipRide(w http.ResponseWriter, r *http.Request)
+	GetReceipt(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *RideHandle) CreateNewRide(w http.ResponseWriter, r *http.Request) {
@@ -41,11 +50,6 @@ func (h *RideHandle) CreateNewRide(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug(ctx, action.CreateRide, "request to create a Ride has been launched")
 
-	if logger.GetRole(ctx) != types.RoleCustomer {
-		log.Error(ctx, action.CreateRide, "invalid role", "role", logger.GetRole(ctx))
-		http.Error(w, msgForbidden, http.StatusForbidden)
-		return
-	}
 	var rideDto models.CreateRideRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&rideDto); err != nil {
@@ -61,7 +65,18 @@ func (h *RideHandle) CreateNewRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if resp, err := h.svc.CreateNewRide(ctx, rideDto); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		var active *types.ActiveRideError
+		switch {
+		case errors.As(err, &active):
+			writeJSON(w, http.StatusConflict, models.ActiveRideConflict{Error: active.Err.Error(), RideID: active.RideID})
+		case errors.Is(err, types.ErrPromoNotFound), errors.Is(err, types.ErrPromoExpired),
+			errors.Is(err, types.ErrPromoLimitReached), errors.Is(err, types.ErrPromoNotApplicable),
+			errors.Is(err, types.ErrInvalidScheduleTime), errors.Is(err, types.ErrTooManyStops),
+			errors.Is(err, types.ErrInvalidSeats):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
 		return
 	} else {
 		log.Debug(ctx, action.CreateRide, "the request to create a trip was successfully completed")
@@ -75,11 +90,6 @@ func (h *RideHandle) CancelRide(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	log.Debug(ctx, action.CloseRide, "a request to close the ride has been launched")
-	if logger.GetRole(ctx) != types.RoleCustomer {
-		log.Error(ctx, action.CloseRide, "invalid role")
-		http.Error(w, msgForbidden, http.StatusForbidden)
-		return
-	}
 
 	closeReq := models.CloseRideRequest{
 		RideID: getRideID(r),
@@ -92,7 +102,18 @@ func (h *RideHandle) CancelRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if resp, err := h.svc.CloseRide(ctx, closeReq); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, types.ErrRideNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, types.ErrRideNotOwned):
+			WriteForbidden(w, err.Error())
+		case errors.Is(err, types.ErrInvalidRideStatus):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, types.ErrPaymentFailed):
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
 		return
 	} else {
 		log.Debug(ctx, action.CloseRide, "the request to cancel the ride has been completed")
@@ -101,107 +122,221 @@ func (h *RideHandle) CancelRide(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// GetRide returns the ride detail to its passenger, its driver or an admin.
+func (h *RideHandle) GetRide(w http.ResponseWriter, r *http.Request) {
+	ride, err := h.svc.GetRide(r.Context(), r.PathValue("ride_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrRideNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, types.ErrRideNotOwned):
+			WriteForbidden(w, msgForbidden)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, ride)
 }
 
-func (h *RideHandle) WSPassenger(w http.ResponseWriter, r *http.Request) {
-	log := h.log.Func("RideHandle.WSPassenger")
+// ListRides returns the passenger's rides newest first, filtered by status
+// and by request date (from/to in YYYY-MM-DD, both inclusive).
+func (h *RideHandle) ListRides(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	log.Debug(ctx, action.WSPassenger, "connection request received from passenger")
-	if logger.GetRole(ctx) != types.RoleCustomer {
-		log.Error(ctx, action.WSPassenger, "invalid role")
-		http.Error(w, msgForbidden, http.StatusForbidden)
+	from, to, err := getDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
 
-	var passengerID string
-	if _, err := fmt.Sscanf(r.URL.Path, "/ws/passengers/%s", &passengerID); err != nil {
-		log.Error(ctx, action.WSPassenger, "invalid passenger ID", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	q := r.URL.Query()
+	f := models.RideFilter{
+		PassengerID: logger.GetUserID(ctx),
+		Status:      strings.ToUpper(q.Get("status")),
+		From:        from,
+		To:          to,
+		Cursor:      q.Get("cursor"),
+		Limit:       20,
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	if ok, reason := dto.ValidateRideFilter(f); !ok {
+		http.Error(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.svc.ListRides(ctx, f)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	writeJSON(w, http.StatusOK, page)
+}
 
-	if passengerID != logger.GetUserID(ctx) {
-		log.Error(ctx, action.WSPassenger, "invalid passenger ID")
-		http.Error(w, "invalid passenger ID", http.StatusBadRequest)
+// AddStop adds an intermediate stop to the ride and returns the new quote.
+func (h *RideHandle) AddStop(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeStop(w, r)
+	if !ok {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	quote, err := h.svc.AddStop(r.Context(), req)
 	if err != nil {
-		log.Error(ctx, action.WSPassenger, "error upgrading connection", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStopError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, quote)
+}
 
-	h.wsm.AddConn(passengerID, conn)
-	defer conn.Close()
-
-	log.Debug(ctx, action.WSPassenger, "connection established")
-	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
-		log.Error(ctx, action.WSPassenger, "error while setting read deadline", "error", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// ChangeStop moves the stop {sequence} of the ride and returns the new quote.
+func (h *RideHandle) ChangeStop(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeStop(w, r)
+	if !ok {
 		return
 	}
 
-	conn.SetPongHandler(func(appData string) error {
-		log.Debug(ctx, action.WSPassenger, "deadline update")
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
-	})
+	seq, err := strconv.Atoi(r.PathValue("sequence"))
+	if err != nil || seq <= 0 {
+		http.Error(w, "invalid stop sequence", http.StatusBadRequest)
+		return
+	}
+	req.Sequence = seq
 
-	var auth dto.Auth
-	messageType, data, err := conn.ReadMessage()
+	quote, err := h.svc.ChangeStop(r.Context(), req)
 	if err != nil {
-		log.Error(ctx, action.WSPassenger, "error reading message", "error", err)
+		writeStopError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, quote)
+}
+
+func (h *RideHandle) decodeStop(w http.ResponseWriter, r *http.Request) (models.StopRequest, bool) {
+	log := h.log.Func("RideHandle.decodeStop")
+	ctx := r.Context()
+
+	req := models.StopRequest{RideID: r.PathValue("ride_id")}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(ctx, action.ChangeStops, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return models.StopRequest{}, false
+	}
+
+	if ok, reason := dto.ValidateStopDTO(req); !ok {
+		log.Warn(ctx, action.ChangeStops, "invalid request", "reason", reason)
+		http.Error(w, reason, http.StatusBadRequest)
+		return models.StopRequest{}, false
+	}
+	return req, true
+}
+
+func writeStopError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrRideNotFound), errors.Is(err, types.ErrStopNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, types.ErrRideNotOwned):
+		WriteForbidden(w, err.Error())
+	case errors.Is(err, types.ErrInvalidRideStatus), errors.Is(err, types.ErrStopAlreadyVisited):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, types.ErrTooManyStops):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *RideHandle) WSPassenger(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("RideHandle.WSPassenger")
+	ctx := r.Context()
+
+	log.Debug(ctx, action.WSPassenger, "connection request received from passenger")
+	serveWS(w, r, r.PathValue("passenger_id"), h.wsm, log, action.WSPassenger)
+}
+
+func (h *RideHandle) TipRide(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("RideHandle.TipRide")
+	ctx := r.Context()
+
+	req := models.TipRequest{
+		PassengerID: logger.GetUserID(ctx),
+		RideID:      r.PathValue("ride_id"),
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(ctx, action.TipDriver, "error decoding body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
-		log.Error(ctx, action.WSPassenger, "invalid message type")
+	resp, err := h.svc.TipDriver(ctx, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrRideNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, types.ErrRideNotOwned):
+			WriteForbidden(w, err.Error())
+		case errors.Is(err, types.ErrInvalidRideStatus), errors.Is(err, types.ErrTipAlreadyAdded),
+			errors.Is(err, types.ErrTipWindowClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, types.ErrInvalidTipPercent):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, types.ErrPaymentFailed):
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
 		return
 	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// GetReceipt serves the receipt of a completed ride as json (default), html or pdf.
+func (h *RideHandle) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	log := h.log.Func("RideHandle.GetReceipt")
+	ctx := r.Context()
 
-	if err = json.Unmarshal(data, &auth); err != nil {
-		log.Error(ctx, action.WSPassenger, "error decoding auth message", "error", err)
-		h.wsm.Send(passengerID, []byte("incorrect auth message"))
+	receipt, err := h.receipts.GetReceipt(ctx, r.PathValue("ride_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrRideNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, types.ErrRideNotOwned):
+			WriteForbidden(w, msgForbidden)
+		case errors.Is(err, types.ErrInvalidRideStatus):
+			http.Error(w, "receipt is available once the ride is completed", http.StatusConflict)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	if err = auth.Validate(); err != nil {
-		log.Error(ctx, action.WSPassenger, "invalid auth", "error", err)
-		if err := h.wsm.Send(passengerID, []byte("invalid auth token")); err != nil {
-			log.Error(ctx, action.WSPassenger, "error sending auth", "error", err)
+	data, contentType, err := h.receipts.RenderReceipt(receipt, r.URL.Query().Get("format"))
+	if err != nil {
+		if errors.Is(err, types.ErrUnknownReceiptFormat) {
+			http.Error(w, "format must be json, html or pdf", http.StatusBadRequest)
 			return
 		}
+		log.Error(ctx, action.Receipt, "error rendering receipt", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	done := make(chan struct{})
-	defer close(done)
-
-	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				log.Debug(ctx, action.WSPassenger, "pink")
-				if err = conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					log.Error(ctx, action.WSPassenger, "error while writing ping message", "error", err)
-					conn.Close()
-					return
-				}
-			case <-done:
-				log.Debug(ctx, action.WSPassenger, "stopped goroutine in ticker ping-pong")
-				return
-			}
-		}
-	}()
+	w.Header().Set("Content-Type", contentType)
+	if contentType == "application/pdf" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=receipt_%s.pdf", receipt.RideNumber))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
 }
 
 func getRideID(r *http.Request) string {
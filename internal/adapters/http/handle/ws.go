@@ -0,0 +1,107 @@
+package handle
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ride-hail/internal/adapters/http/handle/dto"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsm"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// serveWS upgrades the request, registers the connection in manager under id,
+// waits for the auth message and keeps the socket open until the client disconnects.
+func serveWS(w http.ResponseWriter, r *http.Request, id string, manager wsm.HandlerWS, log *logger.FuncLogger, act string) {
+	ctx := r.Context()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(ctx, act, "error upgrading connection", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	manager.AddConn(id, conn)
+	defer conn.Close()
+
+	log.Debug(ctx, act, "connection established")
+	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		log.Error(ctx, act, "error while setting read deadline", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	conn.SetPongHandler(func(appData string) error {
+		log.Debug(ctx, act, "deadline update")
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	var auth dto.Auth
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		log.Error(ctx, act, "error reading message", "error", err)
+		return
+	}
+
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		log.Error(ctx, act, "invalid message type")
+		return
+	}
+
+	if err = json.Unmarshal(data, &auth); err != nil {
+		log.Error(ctx, act, "error decoding auth message", "error", err)
+		manager.Send(id, []byte("incorrect auth message"))
+		return
+	}
+
+	if err = auth.Validate(); err != nil {
+		log.Error(ctx, act, "invalid auth", "error", err)
+		if err := manager.Send(id, []byte("invalid auth token")); err != nil {
+			log.Error(ctx, act, "error sending auth", "error", err)
+			return
+		}
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Debug(ctx, act, "pink")
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					log.Error(ctx, act, "error while writing ping message", "error", err)
+					conn.Close()
+					return
+				}
+			case <-done:
+				log.Debug(ctx, act, "stopped goroutine in ticker ping-pong")
+				return
+			}
+		}
+	}()
+
+	// держим соединение открытым, пока клиент не отключится
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			log.Debug(ctx, act, "connection closed", "error", err)
+			manager.RemoveConn(id)
+			return
+		}
+	}
+}
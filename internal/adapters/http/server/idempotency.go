@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyTTL is how long a response is kept for replay
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLease is how long a key stays in progress; a reservation left
+	// behind by a crashed process stops blocking retries once it expires
+	idempotencyLease     = time.Minute
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key. Keys are scoped to the user, so it must run after
+// jwtMiddleware. Reusing a key for a different request is rejected with 422;
+// server errors and panics are not stored so that the client can retry.
+func (a *API) idempotency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || a.idem == nil {
+			next(w, r)
+			return
+		}
+
+		log := a.log.Func("api.idempotency")
+		ctx := r.Context()
+
+		if len(key) > maxIdempotencyKeyLen {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		h.Write(body)
+
+		rec := models.IdempotencyRecord{
+			UserID:      logger.GetUserID(ctx),
+			Key:         key,
+			RequestHash: hex.EncodeToString(h.Sum(nil)),
+			ExpiresAt:   time.Now().Add(idempotencyLease),
+		}
+		stored, reserved, err := a.idem.Reserve(ctx, rec)
+		if err != nil {
+			log.Error(ctx, action.Idempotency, "error reserving idempotency key", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case stored.RequestHash != rec.RequestHash:
+				log.Warn(ctx, action.Idempotency, "idempotency key reused with a different request", "key", key)
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			case stored.StatusCode == 0:
+				http.Error(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+			default:
+				log.Debug(ctx, action.Idempotency, "replaying stored response", "key", key, "status", stored.StatusCode)
+				if stored.ContentType != "" {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+			}
+			return
+		}
+		rec.Token = stored.Token
+
+		// the response is already sent, store it even if the client went away
+		release := func() {
+			if err := a.idem.Release(context.WithoutCancel(ctx), rec); err != nil {
+				log.Error(ctx, action.Idempotency, "error releasing idempotency key", "error", err)
+			}
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+
+		ctx = context.WithoutCancel(ctx)
+		if rw.status >= http.StatusInternalServerError {
+			release()
+			return
+		}
+
+		rec.StatusCode, rec.ContentType, rec.Body = rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes()
+		rec.ExpiresAt = time.Now().Add(idempotencyTTL)
+		switch err = a.idem.Complete(ctx, rec); {
+		case errors.Is(err, types.ErrReservationLost):
+			log.Warn(ctx, action.Idempotency, "idempotency key was taken over before the response was stored", "key", key)
+		case err != nil:
+			log.Error(ctx, action.Idempotency, "error storing idempotent response", "error", err)
+		}
+	}
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
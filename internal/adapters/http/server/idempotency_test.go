@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/logger"
+)
+
+// memoryKeys follows IdempotencyRepository: a key can be taken over once its
+// lease expires, and only the holder of the current token completes or
+// releases it.
+type memoryKeys struct {
+	mu     sync.Mutex
+	recs   map[string]models.IdempotencyRecord
+	tokens int
+}
+
+func newMemoryKeys() *memoryKeys {
+	return &memoryKeys{recs: map[string]models.IdempotencyRecord{}}
+}
+
+func (m *memoryKeys) Reserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.recs[rec.UserID+"/"+rec.Key]; ok && stored.ExpiresAt.After(time.Now()) {
+		return stored, false, nil
+	}
+	m.tokens++
+	rec.Token = strconv.Itoa(m.tokens)
+	m.recs[rec.UserID+"/"+rec.Key] = rec
+	return rec, true, nil
+}
+
+func (m *memoryKeys) Complete(ctx context.Context, rec models.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.recs[rec.UserID+"/"+rec.Key].Token != rec.Token {
+		return types.ErrReservationLost
+	}
+	m.recs[rec.UserID+"/"+rec.Key] = rec
+	return nil
+}
+
+func (m *memoryKeys) Release(ctx context.Context, rec models.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.recs[rec.UserID+"/"+rec.Key].Token == rec.Token {
+		delete(m.recs, rec.UserID+"/"+rec.Key)
+	}
+	return nil
+}
+
+func (m *memoryKeys) DeleteExpired(ctx context.Context) error {
+	return nil
+}
+
+// expire ends the lease of the key as if the request holding it had stalled.
+func (m *memoryKeys) expire(userID, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec := m.recs[userID+"/"+key]
+	rec.ExpiresAt = time.Now().Add(-time.Second)
+	m.recs[userID+"/"+key] = rec
+}
+
+func newIdempotencyAPI(keys *memoryKeys) *API {
+	return &API{
+		log:  logger.NewLogger("test", logger.LoggerOptions{Output: io.Discard}),
+		idem: keys,
+	}
+}
+
+func send(h http.HandlerFunc, user, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(body))
+	r = r.WithContext(logger.WithUserID(r.Context(), user))
+	if key != "" {
+		r.Header.Set(idempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	calls := 0
+	h := newIdempotencyAPI(newMemoryKeys()).idempotency(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"ride_number":"RIDE-`+strconv.Itoa(calls)+`"}`)
+	})
+
+	first := send(h, "passenger-1", "key-1", `{"pickup":"a"}`)
+	retry := send(h, "passenger-1", "key-1", `{"pickup":"a"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry headers = %v", retry.Header())
+	}
+
+	// keys are per user: the same key from someone else is a new request
+	if other := send(h, "passenger-2", "key-1", `{"pickup":"a"}`); other.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+		t.Errorf("another user's request was replayed: %d %s", other.Code, other.Body)
+	}
+}
+
+func TestIdempotencyRejectsAKeyReusedForAnotherRequest(t *testing.T) {
+	calls := 0
+	h := newIdempotencyAPI(newMemoryKeys()).idempotency(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	send(h, "passenger-1", "key-1", `{"pickup":"a"}`)
+	w := send(h, "passenger-1", "key-1", `{"pickup":"b"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want once", calls)
+	}
+}
+
+func TestIdempotencyRejectsARetryWhileTheRequestRuns(t *testing.T) {
+	var retry *httptest.ResponseRecorder
+	var h http.HandlerFunc
+	h = newIdempotencyAPI(newMemoryKeys()).idempotency(func(w http.ResponseWriter, r *http.Request) {
+		if retry == nil {
+			retry = send(h, "passenger-1", "key-1", `{}`)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	send(h, "passenger-1", "key-1", `{}`)
+
+	if retry.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+}
+
+func TestIdempotencyLetsServerErrorsBeRetried(t *testing.T) {
+	fail := func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) }
+	panics := func(w http.ResponseWriter) { panic("handler crashed") }
+
+	for name, first := range map[string]func(http.ResponseWriter){"500": fail, "panic": panics} {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			h := newIdempotencyAPI(newMemoryKeys()).idempotency(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					first(w)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			func() {
+				defer func() { recover() }()
+				send(h, "passenger-1", "key-1", `{}`)
+			}()
+
+			if w := send(h, "passenger-1", "key-1", `{}`); w.Code != http.StatusCreated || calls != 2 {
+				t.Errorf("retry = %d after %d calls, want 201 after 2", w.Code, calls)
+			}
+		})
+	}
+}
+
+// A request that outlives its lease loses the key to a retry; whatever it
+// ends with, the retry's response is the one kept for replay.
+func TestIdempotencyStalledRequestDoesNotTouchATakenOverKey(t *testing.T) {
+	for _, status := range []int{http.StatusCreated, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			keys := newMemoryKeys()
+
+			stalled := false
+			var h http.HandlerFunc
+			h = newIdempotencyAPI(keys).idempotency(func(w http.ResponseWriter, r *http.Request) {
+				if !stalled {
+					stalled = true
+					keys.expire("passenger-1", "key-1")
+					send(h, "passenger-1", "key-1", `{}`)
+					w.WriteHeader(status)
+					io.WriteString(w, "stalled")
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "retried")
+			})
+
+			send(h, "passenger-1", "key-1", `{}`)
+
+			replay := send(h, "passenger-1", "key-1", `{}`)
+			if replay.Body.String() != "retried" || replay.Header().Get("Idempotent-Replayed") != "true" {
+				t.Errorf("replay = %d %q, want the retried response", replay.Code, replay.Body)
+			}
+		})
+	}
+}
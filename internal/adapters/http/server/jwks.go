@@ -0,0 +1,14 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// jwks publishes the public keys that verify access tokens, so other services
+// can check tokens without the signing key.
+func (a *API) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(a.keys.JWKS())
+}
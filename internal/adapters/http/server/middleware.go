@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"net"
 	"net/http"
+	"ride-hail/internal/adapters/http/handle"
 	"ride-hail/internal/core/domain/action"
 	"ride-hail/pkg/logger"
+	"strings"
 	"time"
 )
 
@@ -21,51 +24,59 @@ func (a *API) middleware(next http.Handler) http.Handler {
 
 		w.Header().Set("X-Request-ID", reqID)
 		ctx := logger.WithRequestID(r.Context(), reqID)
+		ctx = logger.WithClientIP(ctx, a.clientIP(r))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// clientIP returns the address of the client. X-Forwarded-For is only
+// believed when configured, otherwise anyone could pick the address their
+// failed logins are counted against.
+func (a *API) clientIP(r *http.Request) string {
+	if a.cfg.Login.TrustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (a *API) jwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log := a.log.Func("api.jwtMiddleware")
 
-		// Получение cookie
-		cookie, err := r.Cookie("Authorization")
+		// Получение токена: заголовок Authorization, затем cookie
+		token, err := accessToken(r)
 		if err != nil {
-			if errors.Is(err, http.ErrNoCookie) {
-				log.Warn(r.Context(), action.Authorization, "no authorization cookie")
-				http.Error(w, "no authorization cookie", http.StatusUnauthorized)
+			if errors.Is(err, errNoToken) {
+				log.Warn(r.Context(), action.Authorization, "no token provided")
+				handle.WriteUnauthorized(w, "", "")
 				return
 			}
-			log.Error(r.Context(), action.Authorization, "error getting authorization cookie", "error", err)
-			http.Error(w, "cookie error", http.StatusBadRequest)
-			return
-		}
-
-		token := cookie.Value
-		if token == "" {
-			log.Warn(r.Context(), action.Authorization, "no token provided")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			log.Warn(r.Context(), action.Authorization, "malformed authorization header", "error", err)
+			w.Header().Set("WWW-Authenticate", handle.AuthChallenge("invalid_request", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Парсинг JWT токена
-		t, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-			if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(a.cfg.JWT.Secret), nil
-		})
+		t, err := jwt.Parse(token, a.keys.Keyfunc)
 
 		if err != nil {
 			log.Warn(r.Context(), action.Authorization, "failed to parse token", "error", err)
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handle.WriteUnauthorized(w, "invalid_token", "the access token is invalid")
 			return
 		}
 
 		if !t.Valid {
 			log.Warn(r.Context(), action.Authorization, "token is invalid")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handle.WriteUnauthorized(w, "invalid_token", "the access token is invalid")
 			return
 		}
 
@@ -73,32 +84,48 @@ func (a *API) jwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		claims, ok := t.Claims.(jwt.MapClaims)
 		if !ok {
 			log.Warn(r.Context(), action.Authorization, "invalid claims type")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handle.WriteUnauthorized(w, "invalid_token", "the access token is invalid")
 			return
 		}
 
-		// Валидация user_id и role
+		// Валидация user_id, role и claims_id
 		userID, okID := claims["user_id"].(string)
 		role, okRole := claims["role"].(string)
+		claimsID, okClaims := claims["claims_id"].(string)
 
-		if !okID || !okRole {
-			log.Warn(r.Context(), action.Authorization, "invalid user_id or role in claims",
+		if !okID || !okRole || !okClaims {
+			log.Warn(r.Context(), action.Authorization, "invalid user_id, role or claims_id in claims",
 				"has_user_id", okID,
 				"has_role", okRole,
+				"has_claims_id", okClaims,
 			)
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handle.WriteUnauthorized(w, "invalid_token", "the access token is invalid")
 			return
 		}
 
-		if userID == "" || role == "" {
-			log.Warn(r.Context(), action.Authorization, "empty user_id or role")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		if userID == "" || role == "" || claimsID == "" {
+			log.Warn(r.Context(), action.Authorization, "empty user_id, role or claims_id")
+			handle.WriteUnauthorized(w, "invalid_token", "the access token is invalid")
+			return
+		}
+
+		// Проверка списка отозванных токенов
+		revoked, err := a.tokens.IsAccessTokenRevoked(r.Context(), claimsID)
+		if err != nil {
+			log.Error(r.Context(), action.Authorization, "error checking revoked token", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			log.Warn(r.Context(), action.Authorization, "token is revoked", "user_id", userID)
+			handle.WriteUnauthorized(w, "invalid_token", "the access token has been revoked")
 			return
 		}
 
 		// Обогащение контекста
 		ctx := logger.WithUserID(r.Context(), userID)
 		ctx = logger.WithRole(ctx, role)
+		ctx = logger.WithClaimsID(ctx, claimsID)
 
 		log.Debug(r.Context(), action.Authorization, "user authorized",
 			"user_id", userID,
@@ -109,6 +136,30 @@ func (a *API) jwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+var errNoToken = errors.New("no access token")
+
+// accessToken returns the access token of the request. The Authorization
+// header takes precedence over the cookie, so an API client is never
+// authenticated by a stale browser cookie. A present but malformed header is
+// an error rather than a fallback to the cookie.
+func accessToken(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", errors.New("authorization header must use the Bearer scheme")
+		}
+		if token = strings.TrimSpace(token); token == "" {
+			return "", errors.New("empty bearer token")
+		}
+		return token, nil
+	}
+
+	if cookie, err := r.Cookie("Authorization"); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	return "", errNoToken
+}
+
 func newRequestID() string {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"ride-hail/internal/adapters/http/handle"
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/pkg/logger"
+)
+
+// access declares who may call a route: the token role must be one of roles
+// and, when owner is set, the {owner} path value must be the token subject.
+type access struct {
+	roles []string
+	owner string
+}
+
+func allow(roles ...string) access {
+	return access{roles: roles}
+}
+
+// ownedBy requires the pathValue segment to be the caller's own user id.
+func (p access) ownedBy(pathValue string) access {
+	p.owner = pathValue
+	return p
+}
+
+// authorize authenticates the request and enforces p before calling next.
+func (a *API) authorize(p access, next http.HandlerFunc) http.HandlerFunc {
+	return a.jwtMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		log := a.log.Func("api.authorize")
+		ctx := r.Context()
+
+		role := logger.GetRole(ctx)
+		if !slices.Contains(p.roles, role) {
+			log.Warn(ctx, action.Authorization, "role is not allowed", "role", role, "path", r.URL.Path)
+			handle.WriteForbidden(w, fmt.Sprintf("role %s cannot access this resource", role))
+			return
+		}
+
+		if p.owner != "" && r.PathValue(p.owner) != logger.GetUserID(ctx) {
+			log.Warn(ctx, action.Authorization, "resource belongs to another user", p.owner, r.PathValue(p.owner))
+			handle.WriteForbidden(w, "resource belongs to another user")
+			return
+		}
+
+		next(w, r)
+	})
+}
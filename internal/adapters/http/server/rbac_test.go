@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/jwtkey"
+	"ride-hail/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// revokedTokens answers the revocation check of jwtMiddleware.
+type revokedTokens struct {
+	ports.TokenRepository
+	claims map[string]bool
+}
+
+func (r revokedTokens) IsAccessTokenRevoked(ctx context.Context, claimsID string) (bool, error) {
+	return r.claims[claimsID], nil
+}
+
+// rbacServer serves a driver-owned and an admin route behind authorize and
+// signs access tokens for it.
+type rbacServer struct {
+	mux  *http.ServeMux
+	keys *jwtkey.KeySet
+}
+
+func newRBACServer(t *testing.T, revoked ...string) *rbacServer {
+	t.Helper()
+	keys, err := jwtkey.New(jwtkey.Config{Secret: strings.Repeat("k", 48)})
+	if err != nil {
+		t.Fatalf("jwtkey.New: %v", err)
+	}
+	tokens := revokedTokens{claims: map[string]bool{}}
+	for _, id := range revoked {
+		tokens.claims[id] = true
+	}
+	a := &API{
+		log:    logger.NewLogger("test", logger.LoggerOptions{Output: io.Discard}),
+		tokens: tokens,
+		keys:   keys,
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, logger.GetUserID(r.Context()))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /drivers/{driver_id}/online", a.authorize(allow(types.RoleDriver).ownedBy("driver_id"), ok))
+	mux.HandleFunc("GET /admin/overview", a.authorize(allow(types.RoleAdmin), ok))
+	return &rbacServer{mux: mux, keys: keys}
+}
+
+func (s *rbacServer) token(t *testing.T, userID, role, claimsID string) string {
+	t.Helper()
+	token, err := s.keys.Sign(models.Claims{
+		ClaimsID: claimsID,
+		UserID:   userID,
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func (s *rbacServer) do(method, path, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthorizeLetsDriversActOnlyOnThemselves(t *testing.T) {
+	s := newRBACServer(t)
+
+	own := s.do(http.MethodPost, "/drivers/driver-1/online", s.token(t, "driver-1", types.RoleDriver, "c1"))
+	if own.Code != http.StatusOK || own.Body.String() != "driver-1" {
+		t.Fatalf("own driver route = %d %q, want 200 with the caller in the context", own.Code, own.Body)
+	}
+
+	other := s.do(http.MethodPost, "/drivers/driver-2/online", s.token(t, "driver-1", types.RoleDriver, "c1"))
+	if other.Code != http.StatusForbidden {
+		t.Errorf("another driver's route = %d, want 403", other.Code)
+	}
+
+	// an admin has no driver role, even for the route of a real driver
+	admin := s.do(http.MethodPost, "/drivers/driver-1/online", s.token(t, "driver-1", types.RoleAdmin, "c2"))
+	if admin.Code != http.StatusForbidden {
+		t.Errorf("admin on a driver route = %d, want 403", admin.Code)
+	}
+}
+
+func TestAuthorizeChecksTheRoleBeforeTheHandler(t *testing.T) {
+	s := newRBACServer(t, "revoked")
+
+	for _, tc := range []struct {
+		role, claimsID string
+		want           int
+	}{
+		{types.RoleAdmin, "c1", http.StatusOK},
+		{types.RolePassenger, "c2", http.StatusForbidden},
+		{types.RoleDriver, "c3", http.StatusForbidden},
+		{"SUPERUSER", "c4", http.StatusForbidden},
+		{types.RoleAdmin, "revoked", http.StatusUnauthorized},
+	} {
+		if got := s.do(http.MethodGet, "/admin/overview", s.token(t, "user-1", tc.role, tc.claimsID)); got.Code != tc.want {
+			t.Errorf("%s token %s: status %d, want %d", tc.role, tc.claimsID, got.Code, tc.want)
+		}
+	}
+
+	if got := s.do(http.MethodGet, "/admin/overview", ""); got.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", got.Code)
+	}
+}
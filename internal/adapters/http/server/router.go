@@ -14,6 +14,9 @@ func (a *API) setupRoutes(mux *http.ServeMux) error {
 
 	switch a.cfg.Mode {
 	case types.ModeAdmin:
+		if err := a.setupAdminRoutes(mux); err != nil {
+			return err
+		}
 	case types.ModeDAL:
 		if err := a.setupDalRoutes(mux); err != nil {
 			return err
@@ -32,6 +35,15 @@ func (a *API) setupDefaultRoutes(mux *http.ServeMux) error {
 	}
 	mux.HandleFunc("POST /registration", a.h.auth.Registration)
 	mux.HandleFunc("POST /login", a.h.auth.Login)
+	mux.HandleFunc("POST /auth/refresh", a.h.auth.Refresh)
+	mux.HandleFunc("POST /logout", a.jwtMiddleware(a.h.auth.Logout))
+	mux.HandleFunc("POST /email/verify", a.h.auth.VerifyEmail)
+	mux.HandleFunc("POST /email/verify/resend", a.h.auth.ResendVerification)
+	mux.HandleFunc("POST /password/forgot", a.h.auth.ForgotPassword)
+	mux.HandleFunc("POST /password/reset", a.h.auth.ResetPassword)
+	mux.HandleFunc("POST /auth/otp/request", a.h.auth.RequestOTP)
+	mux.HandleFunc("POST /auth/otp/verify", a.h.auth.VerifyOTP)
+	mux.HandleFunc("GET /.well-known/jwks.json", a.jwks)
 	return nil
 }
 
@@ -39,8 +51,19 @@ func (a *API) setupRideRoutes(mux *http.ServeMux) error {
 	if a.h.ride == nil {
 		return errors.New("ride service is required")
 	}
-	mux.HandleFunc("/rides", a.jwtMiddleware(a.h.ride.CreateNewRide))
-	mux.HandleFunc("/rides/{ride_id}/cancel", a.jwtMiddleware(a.h.ride.CancelRide))
+	passenger := allow(types.RolePassenger)
+	// the service checks that the caller is the ride's passenger or driver
+	rideParty := allow(types.RolePassenger, types.RoleDriver, types.RoleAdmin)
+
+	mux.HandleFunc("POST /rides", a.authorize(passenger, a.idempotency(a.h.ride.CreateNewRide)))
+	mux.HandleFunc("GET /rides", a.authorize(passenger, a.h.ride.ListRides))
+	mux.HandleFunc("GET /rides/{ride_id}", a.authorize(rideParty, a.h.ride.GetRide))
+	mux.HandleFunc("/rides/{ride_id}/cancel", a.authorize(passenger, a.idempotency(a.h.ride.CancelRide)))
+	mux.HandleFunc("POST /rides/{ride_id}/stops", a.authorize(passenger, a.idempotency(a.h.ride.AddStop)))
+	mux.HandleFunc("PUT /rides/{ride_id}/stops/{sequence}", a.authorize(passenger, a.idempotency(a.h.ride.ChangeStop)))
+	mux.HandleFunc("POST /rides/{ride_id}/tip", a.authorize(passenger, a.idempotency(a.h.ride.TipRide)))
+	mux.HandleFunc("GET /rides/{ride_id}/receipt", a.authorize(rideParty, a.h.ride.GetReceipt))
+	mux.HandleFunc("/ws/passengers/{passenger_id}", a.authorize(passenger.ownedBy("passenger_id"), a.h.ride.WSPassenger))
 	return nil
 }
 
@@ -48,10 +71,36 @@ func (a *API) setupDalRoutes(mux *http.ServeMux) error {
 	if a.h.dal == nil {
 		return errors.New("dal service is required")
 	}
-	mux.HandleFunc("/drivers/{driver_id}/online", a.h.ride.CreateNewRide)
-	mux.HandleFunc("/drivers/{driver_id}/offline", a.h.ride.CancelRide)
-	mux.HandleFunc("/drivers/{driver_id}/location", a.h.ride.CancelRide)
-	mux.HandleFunc("/drivers/{driver_id}/start", a.h.ride.CancelRide)
-	mux.HandleFunc("/drivers/{driver_id}/complete", a.h.ride.CancelRide)
+	driver := allow(types.RoleDriver).ownedBy("driver_id")
+
+	mux.HandleFunc("POST /drivers/{driver_id}/online", a.authorize(driver, a.h.dal.DriverGoesOnline))
+	mux.HandleFunc("POST /drivers/{driver_id}/offline", a.authorize(driver, a.h.dal.DriverGoesOffline))
+	mux.HandleFunc("POST /drivers/{driver_id}/location", a.authorize(driver, a.h.dal.UpdateDriverLocation))
+	mux.HandleFunc("POST /drivers/{driver_id}/rides/{ride_id}/accept", a.authorize(driver, a.idempotency(a.h.dal.AcceptRide)))
+	mux.HandleFunc("POST /drivers/{driver_id}/arrived", a.authorize(driver, a.idempotency(a.h.dal.DriverArrived)))
+	mux.HandleFunc("POST /drivers/{driver_id}/start", a.authorize(driver, a.idempotency(a.h.dal.StartRide)))
+	mux.HandleFunc("POST /drivers/{driver_id}/rides/{ride_id}/cancel", a.authorize(driver, a.idempotency(a.h.dal.CancelRide)))
+	mux.HandleFunc("POST /drivers/{driver_id}/stops/arrived", a.authorize(driver, a.idempotency(a.h.dal.StopArrived)))
+	mux.HandleFunc("POST /drivers/{driver_id}/stops/departed", a.authorize(driver, a.idempotency(a.h.dal.StopDeparted)))
+	mux.HandleFunc("POST /drivers/{driver_id}/complete", a.authorize(driver, a.idempotency(a.h.dal.CompleteRide)))
+	mux.HandleFunc("GET /drivers/{driver_id}/balance", a.authorize(driver, a.h.dal.GetBalance))
+	mux.HandleFunc("GET /drivers/{driver_id}/transactions", a.authorize(driver, a.h.dal.ListTransactions))
+	mux.HandleFunc("GET /drivers/{driver_id}/earnings", a.authorize(driver, a.h.dal.GetEarnings))
+	mux.HandleFunc("GET /drivers/{driver_id}/earnings/rides", a.authorize(driver, a.h.dal.ListRideEarnings))
+	mux.HandleFunc("/ws/drivers/{driver_id}", a.authorize(driver, a.h.dal.WSDriver))
+	return nil
+}
+
+func (a *API) setupAdminRoutes(mux *http.ServeMux) error {
+	if a.h.admin == nil {
+		return errors.New("admin service is required")
+	}
+	admin := allow(types.RoleAdmin)
+
+	mux.HandleFunc("POST /admin/promos", a.authorize(admin, a.idempotency(a.h.admin.CreatePromoCode)))
+	mux.HandleFunc("POST /admin/payouts/run", a.authorize(admin, a.idempotency(a.h.admin.RunPayouts)))
+	mux.HandleFunc("GET /admin/payouts/batches/{batch_id}/csv", a.authorize(admin, a.h.admin.ExportPayoutBatch))
+	mux.HandleFunc("POST /admin/users/{user_id}/unlock", a.authorize(admin, a.h.admin.UnlockUser))
+	mux.HandleFunc("POST /admin/admins", a.authorize(admin, a.idempotency(a.h.admin.CreateAdmin)))
 	return nil
 }
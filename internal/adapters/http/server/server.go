@@ -9,21 +9,27 @@ import (
 	"ride-hail/internal/adapters/http/handle"
 	"ride-hail/internal/core/domain/action"
 	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/jwtkey"
 	"ride-hail/pkg/logger"
 )
 
 type API struct {
-	h    *handlers
-	serv *http.Server
-	cfg  config.Config
-	log  *logger.Logger
-	addr int
+	h      *handlers
+	serv   *http.Server
+	cfg    config.Config
+	log    *logger.Logger
+	idem   ports.IdempotencyRepository
+	tokens ports.TokenRepository
+	keys   *jwtkey.KeySet
+	addr   int
 }
 
 type handlers struct {
-	auth handle.AuthHandle
-	ride handle.RideHandler
-	dal  handle.DalHandler
+	auth  handle.AuthHandle
+	ride  handle.RideHandler
+	dal   handle.DalHandler
+	admin handle.AdminHandler
 }
 
 type Server interface {
@@ -31,16 +37,22 @@ type Server interface {
 	Stop(ctx context.Context) error
 }
 
-func New(cfg config.Config, log *logger.Logger, auth handle.AuthHandle, ride handle.RideHandler) (*API, error) {
+func New(cfg config.Config, log *logger.Logger, auth handle.AuthHandle, ride handle.RideHandler, dal handle.DalHandler, admin handle.AdminHandler,
+	idem ports.IdempotencyRepository, tokens ports.TokenRepository, keys *jwtkey.KeySet) (*API, error) {
 	h := &handlers{
-		auth: auth,
-		ride: ride,
+		auth:  auth,
+		ride:  ride,
+		dal:   dal,
+		admin: admin,
 	}
 
 	api := &API{
-		h:   h,
-		cfg: cfg,
-		log: log,
+		h:      h,
+		cfg:    cfg,
+		log:    log,
+		idem:   idem,
+		tokens: tokens,
+		keys:   keys,
 	}
 	mux := http.NewServeMux()
 	if err := api.setupRoutes(mux); err != nil {
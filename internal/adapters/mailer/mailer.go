@@ -0,0 +1,19 @@
+package mailer
+
+import (
+	"ride-hail/config"
+	"ride-hail/internal/core/ports"
+)
+
+// New returns the SMTP mailer when a host is configured, otherwise the file or
+// stdout mailer for local development.
+func New(cfg config.Config) (ports.Mailer, error) {
+	switch {
+	case cfg.Mail.Host != "":
+		return NewSMTPMailer(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.From, cfg.Mail.Username, cfg.Mail.Password), nil
+	case cfg.Mail.File != "":
+		return NewFileMailer(cfg.Mail.File)
+	default:
+		return NewStdoutMailer(), nil
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// StdoutMailer prints emails instead of sending them, for local development.
+// NewFileMailer makes it append them to a file instead.
+type StdoutMailer struct {
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func NewStdoutMailer() *StdoutMailer {
+	return &StdoutMailer{
+		out: os.Stdout,
+	}
+}
+
+func NewFileMailer(path string) (*StdoutMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail file: %w", err)
+	}
+	return &StdoutMailer{
+		out: f,
+	}, nil
+}
+
+func (m *StdoutMailer) Send(ctx context.Context, email models.Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(m.out, "---- email to %s ----\nSubject: %s\n\n%s\n", email.To, email.Subject, email.Text)
+	for _, a := range email.Attachments {
+		fmt.Fprintf(m.out, "[attachment %s, %s, %d bytes]\n", a.Filename, a.ContentType, len(a.Data))
+	}
+	fmt.Fprintln(m.out, "----")
+	return nil
+}
@@ -0,0 +1,74 @@
+package osrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+const SourceOSRM = "osrm"
+
+// RouteProvider talks to a self-hosted OSRM-compatible HTTP API.
+type RouteProvider struct {
+	baseURL string
+	profile string
+	client  *http.Client
+}
+
+func NewRouteProvider(baseURL string, timeout time.Duration) *RouteProvider {
+	return &RouteProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		profile: "driving",
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type routeResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		Distance float64 `json:"distance"` // meters
+		Duration float64 `json:"duration"` // seconds
+	} `json:"routes"`
+}
+
+func (p *RouteProvider) Route(ctx context.Context, from, to models.Point) (models.Route, error) {
+	url := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false",
+		p.baseURL, p.profile, from.Lng, from.Lat, to.Lng, to.Lat)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return models.Route{}, fmt.Errorf("failed to build osrm request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.Route{}, fmt.Errorf("failed to call osrm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.Route{}, fmt.Errorf("osrm returned status %d", resp.StatusCode)
+	}
+
+	var body routeResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return models.Route{}, fmt.Errorf("failed to decode osrm response: %w", err)
+	}
+
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return models.Route{}, fmt.Errorf("osrm returned no route: %s %s", body.Code, body.Message)
+	}
+
+	return models.Route{
+		DistanceKM:      body.Routes[0].Distance / 1000,
+		DurationMinutes: int(math.Ceil(body.Routes[0].Duration / 60)),
+		Source:          SourceOSRM,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package osrm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+func TestRouteProviderRoute(t *testing.T) {
+	from := models.Point{Lat: 43.238949, Lng: 76.889709}
+	to := models.Point{Lat: 43.222015, Lng: 76.851511}
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		delay   time.Duration
+		want    models.Route
+		wantErr bool
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+			body:   `{"code":"Ok","routes":[{"distance":5432.1,"duration":601}]}`,
+			want:   models.Route{DistanceKM: 5.4321, DurationMinutes: 11, Source: SourceOSRM},
+		},
+		{
+			name:   "first of several routes",
+			status: http.StatusOK,
+			body:   `{"code":"Ok","routes":[{"distance":1000,"duration":60},{"distance":900,"duration":300}]}`,
+			want:   models.Route{DistanceKM: 1, DurationMinutes: 1, Source: SourceOSRM},
+		},
+		{
+			name:    "no route",
+			status:  http.StatusOK,
+			body:    `{"code":"NoRoute","message":"Impossible route between points","routes":[]}`,
+			wantErr: true,
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			body:    `{}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			status:  http.StatusOK,
+			body:    `{"code":`,
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			status:  http.StatusOK,
+			body:    `{"code":"Ok","routes":[{"distance":1000,"duration":60}]}`,
+			delay:   200 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				time.Sleep(tt.delay)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := NewRouteProvider(srv.URL+"/", 100*time.Millisecond)
+			got, err := p.Route(context.Background(), from, to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Route() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Route() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Route() = %+v, want %+v", got, tt.want)
+			}
+
+			// OSRM takes longitude first
+			wantPath := "/route/v1/driving/76.889709,43.238949;76.851511,43.222015"
+			if path != wantPath {
+				t.Errorf("request path = %q, want %q", path, wantPath)
+			}
+		})
+	}
+}
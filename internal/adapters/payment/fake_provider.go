@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider accepts every charge and keeps them in memory. It stands in
+// for a real payment gateway in development.
+type FakeProvider struct {
+	mu         sync.Mutex
+	charges    map[string]float64
+	references map[string]string
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		charges:    make(map[string]float64),
+		references: make(map[string]string),
+	}
+}
+
+func (p *FakeProvider) Charge(_ context.Context, customerID string, amount float64, reference string) (string, error) {
+	if amount < 0 {
+		return "", fmt.Errorf("invalid charge amount %.2f for %s", amount, customerID)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.references[reference]; ok {
+		return id, nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := "ch_fake_" + hex.EncodeToString(b)
+
+	p.charges[id] = amount
+	p.references[reference] = id
+	return id, nil
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
 	"ride-hail/pkg/executor"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -45,6 +48,10 @@ func (repo *DriverRepository) CreateDriver(ctx context.Context, driver models.Dr
 		driver.IsVerified,
 	).Scan(&id)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "drivers_license_number_key" {
+			return "", types.ErrLicenseAlreadyExists
+		}
 		return "", fmt.Errorf("failed to create driver: %w", err)
 	}
 
@@ -75,7 +82,7 @@ func (repo *DriverRepository) GetDriverByID(ctx context.Context, id string) (*mo
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("driver not found: %w", err)
+			return nil, types.ErrDriverNotFound
 		}
 		return nil, fmt.Errorf("failed to get driver by id %s: %w", id, err)
 	}
@@ -191,3 +198,225 @@ func (repo *DriverRepository) UpdateDriverStatus(ctx context.Context, driverID s
 
 	return nil
 }
+
+func (repo *DriverRepository) StartSession(ctx context.Context, driverID string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO driver_sessions (driver_id) VALUES ($1) RETURNING id;`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, driverID).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to start driver session: %w", err)
+	}
+	return id, nil
+}
+
+// EndSession closes the driver's open session and returns it.
+func (repo *DriverRepository) EndSession(ctx context.Context, driverID string) (*models.DriverSession, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE driver_sessions
+		SET ended_at = now()
+		WHERE driver_id = $1 AND ended_at IS NULL
+		RETURNING id, driver_id, started_at, ended_at, coalesce(total_rides, 0), coalesce(total_earnings, 0);`
+
+	var s models.DriverSession
+	err := ex.QueryRow(ctx, query, driverID).Scan(
+		&s.ID,
+		&s.DriverID,
+		&s.StartedAt,
+		&s.EndedAt,
+		&s.TotalRides,
+		&s.TotalEarnings,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to end driver session: %w", err)
+	}
+	return &s, nil
+}
+
+// AddRideEarnings credits a completed ride to the driver and to the driver's open session.
+func (repo *DriverRepository) AddRideEarnings(ctx context.Context, driverID string, amount float64) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE drivers
+		SET total_rides = total_rides + 1, total_earnings = total_earnings + $2, updated_at = now()
+		WHERE id = $1;`
+
+	result, err := ex.Exec(ctx, query, driverID, amount)
+	if err != nil {
+		return fmt.Errorf("failed to update driver earnings: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no driver found with id %s", driverID)
+	}
+
+	query = `UPDATE driver_sessions
+		SET total_rides = total_rides + 1, total_earnings = total_earnings + $2
+		WHERE driver_id = $1 AND ended_at IS NULL;`
+
+	if _, err = ex.Exec(ctx, query, driverID, amount); err != nil {
+		return fmt.Errorf("failed to update driver session earnings: %w", err)
+	}
+	return nil
+}
+
+// AddTip credits a tip to the driver's total and open session earnings.
+func (repo *DriverRepository) AddTip(ctx context.Context, driverID string, amount float64) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE drivers
+		SET total_earnings = total_earnings + $2, updated_at = now()
+		WHERE id = $1;`
+
+	result, err := ex.Exec(ctx, query, driverID, amount)
+	if err != nil {
+		return fmt.Errorf("failed to add driver tip: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrDriverNotFound
+	}
+
+	query = `UPDATE driver_sessions
+		SET total_earnings = total_earnings + $2
+		WHERE driver_id = $1 AND ended_at IS NULL;`
+
+	if _, err = ex.Exec(ctx, query, driverID, amount); err != nil {
+		return fmt.Errorf("failed to add driver session tip: %w", err)
+	}
+	return nil
+}
+
+// ListAvailableDrivers returns AVAILABLE drivers of the given vehicle types
+// that are not blocked from offers, together with their last recorded location.
+func (repo *DriverRepository) ListAvailableDrivers(ctx context.Context, vehicleTypes ...string) ([]models.AvailableDriver, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT d.id, d.vehicle_type, coalesce(d.vehicle_attrs, '{}'::jsonb), l.latitude, l.longitude
+	FROM drivers d
+	JOIN LATERAL (
+	    SELECT latitude, longitude FROM location_history
+	    WHERE driver_id = d.id
+	    ORDER BY recorded_at DESC
+	    LIMIT 1
+	) l ON true
+	WHERE d.status = 'AVAILABLE' AND d.vehicle_type = ANY($1)
+	  AND (d.blocked_until IS NULL OR d.blocked_until <= now())`
+
+	rows, err := ex.Query(ctx, query, vehicleTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available drivers: %w", err)
+	}
+	defer rows.Close()
+
+	drivers := make([]models.AvailableDriver, 0)
+	for rows.Next() {
+		var d models.AvailableDriver
+		if err = rows.Scan(&d.ID, &d.VehicleType, &d.VehicleAttrs, &d.Latitude, &d.Longitude); err != nil {
+			return nil, fmt.Errorf("failed to scan available driver: %w", err)
+		}
+		drivers = append(drivers, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating available drivers: %w", err)
+	}
+	return drivers, nil
+}
+
+func (repo *DriverRepository) RecordCancellation(ctx context.Context, driverID, rideID, reason string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO driver_cancellations (driver_id, ride_id, reason) VALUES ($1, $2, nullif($3, ''))`
+
+	if _, err := ex.Exec(ctx, query, driverID, rideID, reason); err != nil {
+		return fmt.Errorf("failed to record driver cancellation: %w", err)
+	}
+	return nil
+}
+
+func (repo *DriverRepository) CountCancellations(ctx context.Context, driverID string, since time.Time) (int, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT count(*) FROM driver_cancellations WHERE driver_id = $1 AND created_at >= $2`
+
+	var n int
+	if err := ex.QueryRow(ctx, query, driverID, since).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count driver cancellations: %w", err)
+	}
+	return n, nil
+}
+
+// ListRideCancellers returns the drivers who cancelled the ride.
+func (repo *DriverRepository) ListRideCancellers(ctx context.Context, rideID string) ([]string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT DISTINCT driver_id FROM driver_cancellations WHERE ride_id = $1`
+
+	rows, err := ex.Query(ctx, query, rideID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ride cancellers: %w", err)
+	}
+
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan ride canceller: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ride cancellers: %w", err)
+	}
+	return ids, nil
+}
+
+// BlockDriver stops ride offers to the driver until the given time.
+func (repo *DriverRepository) BlockDriver(ctx context.Context, driverID string, until time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE drivers SET blocked_until = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, driverID, until)
+	if err != nil {
+		return fmt.Errorf("failed to block driver: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrDriverNotFound
+	}
+	return nil
+}
+
+func (repo *DriverRepository) GetOpenSession(ctx context.Context, driverID string) (*models.DriverSession, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, driver_id, started_at, ended_at, coalesce(total_rides, 0), coalesce(total_earnings, 0)
+		FROM driver_sessions
+		WHERE driver_id = $1 AND ended_at IS NULL
+		ORDER BY started_at DESC
+		LIMIT 1;`
+
+	var s models.DriverSession
+	err := ex.QueryRow(ctx, query, driverID).Scan(
+		&s.ID,
+		&s.DriverID,
+		&s.StartedAt,
+		&s.EndedAt,
+		&s.TotalRides,
+		&s.TotalEarnings,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get open session for driver %s: %w", driverID, err)
+	}
+	return &s, nil
+}
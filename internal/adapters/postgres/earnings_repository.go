@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type EarningsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEarningsRepository(pool *pgxpool.Pool) *EarningsRepository {
+	return &EarningsRepository{
+		pool: pool,
+	}
+}
+
+// SummarizeEarnings groups the driver's ride payments and tips from the ledger
+// by day or week (trunc is "day" or "week") in the given time zone.
+func (repo *EarningsRepository) SummarizeEarnings(ctx context.Context, driverID, trunc string, loc *time.Location, from, to time.Time) ([]models.EarningsSummary, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `WITH tx AS (
+		SELECT t.id, t.kind, t.created_at,
+		       coalesce(sum(e.amount) FILTER (WHERE a.type = 'DRIVER'), 0) AS driver_amount,
+		       coalesce(sum(e.amount) FILTER (WHERE a.type = 'PLATFORM'), 0) AS platform_amount
+		FROM ledger_transactions t
+		JOIN ledger_entries e ON e.transaction_id = t.id
+		JOIN ledger_accounts a ON a.id = e.account_id
+		WHERE t.kind IN ('RIDE_PAYMENT', 'TIP')
+		  AND t.created_at >= $2 AND t.created_at < $3
+		  AND EXISTS (
+		      SELECT 1 FROM ledger_entries de
+		      JOIN ledger_accounts da ON da.id = de.account_id
+		      WHERE de.transaction_id = t.id AND da.type = 'DRIVER' AND da.owner_id = $1
+		  )
+		GROUP BY t.id
+	)
+	SELECT date_trunc($4, created_at AT TIME ZONE $5) AS period,
+	       count(*) FILTER (WHERE kind = 'RIDE_PAYMENT'),
+	       coalesce(sum(driver_amount + platform_amount) FILTER (WHERE kind = 'RIDE_PAYMENT'), 0),
+	       coalesce(sum(platform_amount) FILTER (WHERE kind = 'RIDE_PAYMENT'), 0),
+	       coalesce(sum(driver_amount) FILTER (WHERE kind = 'TIP'), 0),
+	       coalesce(sum(driver_amount), 0)
+	FROM tx
+	GROUP BY period
+	ORDER BY period`
+
+	rows, err := ex.Query(ctx, query, driverID, from, to, trunc, loc.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize earnings: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.EarningsSummary, 0)
+	for rows.Next() {
+		var s models.EarningsSummary
+		var period time.Time
+		if err = rows.Scan(&period, &s.Rides, &s.GrossFares, &s.Commission, &s.Tips, &s.Net); err != nil {
+			return nil, fmt.Errorf("failed to scan earnings summary: %w", err)
+		}
+		// period is a local wall-clock timestamp without zone
+		s.PeriodStart = time.Date(period.Year(), period.Month(), period.Day(), 0, 0, 0, 0, loc)
+		items = append(items, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating earnings summary: %w", err)
+	}
+	return items, nil
+}
+
+func (repo *EarningsRepository) ListRideEarnings(ctx context.Context, driverID string, from, to time.Time) ([]models.RideEarning, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT r.id, r.ride_number, r.completed_at, coalesce(r.final_fare, 0), coalesce(r.discount_amount, 0),
+	       coalesce(sum(e.amount) FILTER (WHERE t.kind = 'RIDE_PAYMENT' AND a.type IN ('DRIVER', 'PLATFORM')), 0),
+	       coalesce(sum(e.amount) FILTER (WHERE t.kind = 'RIDE_PAYMENT' AND a.type = 'PLATFORM'), 0),
+	       coalesce(sum(e.amount) FILTER (WHERE t.kind = 'TIP' AND a.type = 'DRIVER'), 0),
+	       coalesce(sum(e.amount) FILTER (WHERE a.type = 'DRIVER'), 0)
+	FROM rides r
+	JOIN ledger_transactions t ON t.ride_id = r.id AND t.kind IN ('RIDE_PAYMENT', 'TIP')
+	JOIN ledger_entries e ON e.transaction_id = t.id
+	JOIN ledger_accounts a ON a.id = e.account_id
+	WHERE r.driver_id = $1 AND r.status = 'COMPLETED'
+	  AND r.completed_at >= $2 AND r.completed_at < $3
+	GROUP BY r.id
+	ORDER BY r.completed_at DESC`
+
+	rows, err := ex.Query(ctx, query, driverID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ride earnings: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.RideEarning, 0)
+	for rows.Next() {
+		var re models.RideEarning
+		if err = rows.Scan(&re.RideID, &re.RideNumber, &re.CompletedAt, &re.FinalFare, &re.Discount,
+			&re.GrossFare, &re.Commission, &re.Tips, &re.Net); err != nil {
+			return nil, fmt.Errorf("failed to scan ride earnings: %w", err)
+		}
+		items = append(items, re)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ride earnings: %w", err)
+	}
+	return items, nil
+}
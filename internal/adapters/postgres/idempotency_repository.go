@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewIdempotencyRepository(pool *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		pool: pool,
+	}
+}
+
+// Reserve stores a new in-progress record for the key until rec.ExpiresAt,
+// replacing an expired one, including a reservation whose request never
+// finished. The returned record carries the token of the new reservation.
+// When the key is already taken it returns the stored record and false.
+func (repo *IdempotencyRepository) Reserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id, key) DO UPDATE
+	SET request_hash = excluded.request_hash, expires_at = excluded.expires_at, created_at = now(),
+	    token = gen_random_uuid(), status_code = NULL, content_type = NULL, body = NULL
+	WHERE idempotency_keys.expires_at <= now()
+	RETURNING token`
+
+	err := ex.QueryRow(ctx, query, rec.UserID, rec.Key, rec.RequestHash, rec.ExpiresAt).Scan(&rec.Token)
+	if err == nil {
+		return rec, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	query = `SELECT user_id, key, token, request_hash, coalesce(status_code, 0), coalesce(content_type, ''), body, expires_at
+	FROM idempotency_keys
+	WHERE user_id = $1 AND key = $2`
+
+	var stored models.IdempotencyRecord
+	if err = ex.QueryRow(ctx, query, rec.UserID, rec.Key).Scan(&stored.UserID, &stored.Key, &stored.Token, &stored.RequestHash,
+		&stored.StatusCode, &stored.ContentType, &stored.Body, &stored.ExpiresAt); err != nil {
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return stored, false, nil
+}
+
+// Complete stores the response of a reserved key and keeps it until the
+// record's expiry. It returns ErrReservationLost when the lease ran out and
+// another request has taken the key over.
+func (repo *IdempotencyRepository) Complete(ctx context.Context, rec models.IdempotencyRecord) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE idempotency_keys
+	SET status_code = $4, content_type = nullif($5, ''), body = $6, expires_at = $7
+	WHERE user_id = $1 AND key = $2 AND token = $3`
+
+	result, err := ex.Exec(ctx, query, rec.UserID, rec.Key, rec.Token, rec.StatusCode, rec.ContentType, rec.Body, rec.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrReservationLost
+	}
+	return nil
+}
+
+// Release deletes a reserved key so that the request can be retried. A key
+// another request has taken over in the meantime is left alone.
+func (repo *IdempotencyRepository) Release(ctx context.Context, rec models.IdempotencyRecord) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND token = $3`
+
+	if _, err := ex.Exec(ctx, query, rec.UserID, rec.Key, rec.Token); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (repo *IdempotencyRepository) DeleteExpired(ctx context.Context) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+
+	if _, err := ex.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return nil
+}
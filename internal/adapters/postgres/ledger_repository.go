@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LedgerRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLedgerRepository(pool *pgxpool.Pool) *LedgerRepository {
+	return &LedgerRepository{
+		pool: pool,
+	}
+}
+
+// GetOrCreateAccount returns the account id of the given type and owner,
+// creating the account on first use. ownerID is empty for platform accounts.
+func (repo *LedgerRepository) GetOrCreateAccount(ctx context.Context, accountType, ownerID string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO ledger_accounts (type, owner_id)
+	VALUES ($1, nullif($2, '')::uuid)
+	ON CONFLICT (type, coalesce(owner_id, '00000000-0000-0000-0000-000000000000'::uuid))
+	DO UPDATE SET type = excluded.type
+	RETURNING id`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, accountType, ownerID).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to get ledger account: %w", err)
+	}
+	return id, nil
+}
+
+func (repo *LedgerRepository) CreateTransaction(ctx context.Context, t models.LedgerTransaction) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO ledger_transactions (kind, ride_id, reference, description,
+	    charge_customer_id, charge_amount, charge_status)
+	VALUES ($1, $2, nullif($3, ''), nullif($4, ''), nullif($5, '')::uuid, nullif($6, 0), nullif($7, ''))
+	RETURNING id`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, t.Kind, t.RideID, t.Reference, t.Description,
+		t.ChargeCustomerID, t.ChargeAmount, t.ChargeStatus,
+	).Scan(&id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", types.ErrDuplicateTransaction
+		}
+		return "", fmt.Errorf("failed to create ledger transaction: %w", err)
+	}
+
+	for _, e := range t.Entries {
+		if _, err := ex.Exec(ctx,
+			`INSERT INTO ledger_entries (transaction_id, account_id, amount) VALUES ($1, $2, $3)`,
+			id, e.AccountID, e.Amount,
+		); err != nil {
+			return "", fmt.Errorf("failed to create ledger entry: %w", err)
+		}
+	}
+	return id, nil
+}
+
+// ListPendingCharges returns the oldest transactions whose charge is not
+// taken yet.
+func (repo *LedgerRepository) ListPendingCharges(ctx context.Context, limit int) ([]models.LedgerTransaction, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, kind, ride_id, coalesce(description, ''),
+	    charge_customer_id::text, charge_amount, charge_status
+	FROM ledger_transactions
+	WHERE charge_status = 'PENDING'
+	ORDER BY created_at
+	LIMIT $1`
+
+	rows, err := ex.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending charges: %w", err)
+	}
+	defer rows.Close()
+
+	txs := make([]models.LedgerTransaction, 0)
+	for rows.Next() {
+		var t models.LedgerTransaction
+		if err = rows.Scan(&t.ID, &t.CreatedAt, &t.Kind, &t.RideID, &t.Description,
+			&t.ChargeCustomerID, &t.ChargeAmount, &t.ChargeStatus,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pending charge: %w", err)
+		}
+		txs = append(txs, t)
+	}
+	return txs, rows.Err()
+}
+
+// GetPendingCharge returns the transaction if its charge is not taken yet.
+func (repo *LedgerRepository) GetPendingCharge(ctx context.Context, transactionID string) (models.LedgerTransaction, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, kind, ride_id, coalesce(description, ''),
+	    charge_customer_id::text, charge_amount, charge_status
+	FROM ledger_transactions
+	WHERE id = $1 AND charge_status = 'PENDING'`
+
+	var t models.LedgerTransaction
+	if err := ex.QueryRow(ctx, query, transactionID).Scan(&t.ID, &t.CreatedAt, &t.Kind, &t.RideID, &t.Description,
+		&t.ChargeCustomerID, &t.ChargeAmount, &t.ChargeStatus,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.LedgerTransaction{}, types.ErrNoPendingCharge
+		}
+		return models.LedgerTransaction{}, fmt.Errorf("failed to get pending charge: %w", err)
+	}
+	return t, nil
+}
+
+// MarkCharged stores the provider charge id of a pending charge.
+func (repo *LedgerRepository) MarkCharged(ctx context.Context, transactionID, reference string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE ledger_transactions
+	SET charge_status = 'CHARGED', reference = $2
+	WHERE id = $1 AND charge_status = 'PENDING'`
+
+	if _, err := ex.Exec(ctx, query, transactionID, reference); err != nil {
+		return fmt.Errorf("failed to mark charge taken: %w", err)
+	}
+	return nil
+}
+
+// RecordChargeFailure counts a refused charge and gives up on it after
+// maxAttempts. It reports whether the charge is now FAILED.
+func (repo *LedgerRepository) RecordChargeFailure(ctx context.Context, transactionID string, maxAttempts int) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE ledger_transactions
+	SET charge_attempts = charge_attempts + 1,
+	    charge_status = CASE WHEN charge_attempts + 1 >= $2 THEN 'FAILED' ELSE charge_status END
+	WHERE id = $1 AND charge_status = 'PENDING'
+	RETURNING charge_status`
+
+	var status string
+	if err := ex.QueryRow(ctx, query, transactionID, maxAttempts).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record charge failure: %w", err)
+	}
+	return status == types.ChargeStatusFailed, nil
+}
+
+func (repo *LedgerRepository) GetBalance(ctx context.Context, accountID string) (models.AccountBalance, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT a.id, a.type, coalesce(a.owner_id::text, ''), a.currency, coalesce(sum(e.amount), 0)
+	FROM ledger_accounts a
+	LEFT JOIN ledger_entries e ON e.account_id = a.id
+	WHERE a.id = $1
+	GROUP BY a.id`
+
+	var b models.AccountBalance
+	if err := ex.QueryRow(ctx, query, accountID).Scan(&b.AccountID, &b.Type, &b.OwnerID, &b.Currency, &b.Balance); err != nil {
+		return models.AccountBalance{}, fmt.Errorf("failed to get account balance: %w", err)
+	}
+	return b, nil
+}
+
+func (repo *LedgerRepository) ListAccountEntries(ctx context.Context, accountID string, limit, offset int) ([]models.AccountStatementLine, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT e.id, t.id, t.kind, t.ride_id, coalesce(t.description, ''), e.amount, e.created_at
+	FROM ledger_entries e
+	JOIN ledger_transactions t ON t.id = e.transaction_id
+	WHERE e.account_id = $1
+	ORDER BY e.created_at DESC, e.id
+	LIMIT $2 OFFSET $3`
+
+	rows, err := ex.Query(ctx, query, accountID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	lines := make([]models.AccountStatementLine, 0)
+	for rows.Next() {
+		var l models.AccountStatementLine
+		if err = rows.Scan(&l.EntryID, &l.TransactionID, &l.Kind, &l.RideID, &l.Description, &l.Amount, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		lines = append(lines, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger entries: %w", err)
+	}
+	return lines, nil
+}
@@ -26,15 +26,14 @@ func NewLocationRepository(pool *pgxpool.Pool) *LocationRepository {
 func (repo *LocationRepository) SaveLocation(ctx context.Context, location models.LocationHistory) (string, error) {
 	ex := executor.GetExecutor(ctx, repo.pool)
 
-	query := `INSERT INTO location_history (id, coordinate_id, driver_id, latitude, 
+	query := `INSERT INTO location_history (coordinate_id, driver_id, latitude, 
 	longitude, accuracy_meters, speed_kmh, heading_degrees, recorded_at, ride_id)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	RETURNING id;`
 
 	var id string
 	err := ex.QueryRow(
 		ctx, query,
-		location.ID,
 		location.CoordinateID,
 		location.DriverID,
 		location.Latitude,
@@ -143,3 +142,64 @@ func (repo *LocationRepository) DeleteLocationHistory(ctx context.Context, drive
 
 	return nil
 }
+
+// ListRideRoute returns the points recorded for the ride in chronological order.
+func (repo *LocationRepository) ListRideRoute(ctx context.Context, rideID string) ([]models.Point, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT latitude, longitude
+	FROM location_history
+	WHERE ride_id = $1
+	ORDER BY recorded_at;`
+
+	rows, err := ex.Query(ctx, query, rideID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route for ride %s: %w", rideID, err)
+	}
+	defer rows.Close()
+
+	points := make([]models.Point, 0)
+	for rows.Next() {
+		var p models.Point
+		if err = rows.Scan(&p.Lat, &p.Lng); err != nil {
+			return nil, fmt.Errorf("failed to scan route point: %w", err)
+		}
+		points = append(points, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating route points: %w", err)
+	}
+	return points, nil
+}
+
+// ListRideRouteSince returns the points recorded for the ride from since on in
+// chronological order.
+func (repo *LocationRepository) ListRideRouteSince(ctx context.Context, rideID string, since time.Time) ([]models.Point, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT latitude, longitude
+	FROM location_history
+	WHERE ride_id = $1 AND recorded_at >= $2
+	ORDER BY recorded_at;`
+
+	rows, err := ex.Query(ctx, query, rideID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route for ride %s: %w", rideID, err)
+	}
+	defer rows.Close()
+
+	points := make([]models.Point, 0)
+	for rows.Next() {
+		var p models.Point
+		if err = rows.Scan(&p.Lat, &p.Lng); err != nil {
+			return nil, fmt.Errorf("failed to scan route point: %w", err)
+		}
+		points = append(points, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating route points: %w", err)
+	}
+	return points, nil
+}
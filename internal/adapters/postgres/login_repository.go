@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginAttemptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLoginAttemptRepository(pool *pgxpool.Pool) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		pool: pool,
+	}
+}
+
+// GetLockedUntil returns the latest lockout of the keys that is still in
+// effect, or the zero time when none of them is locked.
+func (repo *LoginAttemptRepository) GetLockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT max(locked_until) FROM login_failures
+	WHERE key = any($1) AND locked_until > now()`
+
+	var until *time.Time
+	if err := ex.QueryRow(ctx, query, keys).Scan(&until); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get login lockout: %w", err)
+	}
+	if until == nil {
+		return time.Time{}, nil
+	}
+	return *until, nil
+}
+
+// RecordFailure counts a failed login for the key and returns the number of
+// failures so far. The count starts over when the key has been quiet, neither
+// failing nor locked, since resetBefore.
+func (repo *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, resetBefore time.Time) (int, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO login_failures (key, failures, last_failure_at)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE
+	        WHEN greatest(login_failures.last_failure_at, login_failures.locked_until) < $2 THEN 1
+	        ELSE login_failures.failures + 1
+	    END,
+	    last_failure_at = now()
+	RETURNING failures`
+
+	var failures int
+	if err := ex.QueryRow(ctx, query, key, resetBefore).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return failures, nil
+}
+
+func (repo *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE login_failures SET locked_until = $2 WHERE key = $1`
+
+	if _, err := ex.Exec(ctx, query, key, until); err != nil {
+		return fmt.Errorf("failed to lock login %s: %w", key, err)
+	}
+	return nil
+}
+
+// ClearFailures forgets the failures and lockout of the key and reports
+// whether there was anything to forget.
+func (repo *LoginAttemptRepository) ClearFailures(ctx context.Context, key string) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	result, err := ex.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear login failures: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (repo *LoginAttemptRepository) CreateAuditEntry(ctx context.Context, e models.LoginAuditEntry) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO auth_audit_log (event, subject, user_id, actor_id, ip, failures, locked_until)
+	VALUES ($1, $2, nullif($3, '')::uuid, nullif($4, '')::uuid, nullif($5, ''), $6, $7)`
+
+	if _, err := ex.Exec(ctx, query, e.Event, e.Subject, e.UserID, e.ActorID, e.IP, e.Failures, e.LockedUntil); err != nil {
+		return fmt.Errorf("failed to create auth audit entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes keys that have been quiet since resetBefore, their
+// count would start over anyway.
+func (repo *LoginAttemptRepository) DeleteExpired(ctx context.Context, resetBefore time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM login_failures WHERE greatest(last_failure_at, locked_until) < $1`
+
+	if _, err := ex.Exec(ctx, query, resetBefore); err != nil {
+		return fmt.Errorf("failed to delete expired login failures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MessageReceiptRepository is the rabbit.ReceiptStore of the services
+// consuming RabbitMQ messages.
+type MessageReceiptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewMessageReceiptRepository(pool *pgxpool.Pool) *MessageReceiptRepository {
+	return &MessageReceiptRepository{
+		pool: pool,
+	}
+}
+
+// Claim records the message id for the queue and reports false when it was
+// already recorded. With takeOver a message that was claimed but never
+// handled is claimed again.
+func (repo *MessageReceiptRepository) Claim(ctx context.Context, queue, messageID string, expiresAt time.Time, takeOver bool) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO message_receipts (queue, message_id, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (queue, message_id) DO UPDATE
+	SET received_at = now(), expires_at = excluded.expires_at
+	WHERE $4::boolean AND message_receipts.handled_at IS NULL`
+
+	result, err := ex.Exec(ctx, query, queue, messageID, expiresAt, takeOver)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim message receipt: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (repo *MessageReceiptRepository) Complete(ctx context.Context, queue, messageID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE message_receipts SET handled_at = now() WHERE queue = $1 AND message_id = $2`
+
+	if _, err := ex.Exec(ctx, query, queue, messageID); err != nil {
+		return fmt.Errorf("failed to complete message receipt: %w", err)
+	}
+	return nil
+}
+
+func (repo *MessageReceiptRepository) Release(ctx context.Context, queue, messageID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM message_receipts WHERE queue = $1 AND message_id = $2`
+
+	if _, err := ex.Exec(ctx, query, queue, messageID); err != nil {
+		return fmt.Errorf("failed to release message receipt: %w", err)
+	}
+	return nil
+}
+
+func (repo *MessageReceiptRepository) DeleteExpired(ctx context.Context) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM message_receipts WHERE expires_at <= now()`
+
+	if _, err := ex.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to delete expired message receipts: %w", err)
+	}
+	return nil
+}
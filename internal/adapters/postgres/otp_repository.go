@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OTPRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOTPRepository(pool *pgxpool.Pool) *OTPRepository {
+	return &OTPRepository{
+		pool: pool,
+	}
+}
+
+// CreateOTP stores a new code of the phone. Unused codes sent before stop
+// working.
+func (repo *OTPRepository) CreateOTP(ctx context.Context, code models.OTPCode) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `WITH superseded AS (
+		UPDATE otp_codes SET used_at = now()
+		WHERE phone = $1 AND used_at IS NULL
+	)
+	INSERT INTO otp_codes (phone, code_hash, expires_at)
+	VALUES ($1, $2, $3)`
+
+	if _, err := ex.Exec(ctx, query, code.Phone, code.CodeHash, code.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create otp code: %w", err)
+	}
+	return nil
+}
+
+// GetLastSentAt returns when the last code was sent to the phone, or the zero
+// time when none was.
+func (repo *OTPRepository) GetLastSentAt(ctx context.Context, phone string) (time.Time, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	var sentAt *time.Time
+	if err := ex.QueryRow(ctx, `SELECT max(created_at) FROM otp_codes WHERE phone = $1`, phone).Scan(&sentAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last otp code: %w", err)
+	}
+	if sentAt == nil {
+		return time.Time{}, nil
+	}
+	return *sentAt, nil
+}
+
+// CheckOTP counts an attempt against the current code of the phone and uses
+// the code up when the hash matches. It reports false when the hash does not
+// match or there is no code left that is unused, unexpired and under
+// maxAttempts.
+func (repo *OTPRepository) CheckOTP(ctx context.Context, phone, codeHash string, maxAttempts int) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE otp_codes
+	SET attempts = attempts + 1,
+	    used_at = CASE WHEN code_hash = $2 THEN now() END
+	WHERE id = (
+		SELECT id FROM otp_codes
+		WHERE phone = $1 AND used_at IS NULL AND expires_at > now() AND attempts < $3
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	)
+	RETURNING used_at IS NOT NULL`
+
+	var ok bool
+	if err := ex.QueryRow(ctx, query, phone, codeHash, maxAttempts).Scan(&ok); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check otp code: %w", err)
+	}
+	return ok, nil
+}
+
+func (repo *OTPRepository) DeleteExpired(ctx context.Context) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	if _, err := ex.Exec(ctx, `DELETE FROM otp_codes WHERE expires_at <= now()`); err != nil {
+		return fmt.Errorf("failed to delete expired otp codes: %w", err)
+	}
+	return nil
+}
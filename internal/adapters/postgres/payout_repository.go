@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PayoutRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPayoutRepository(pool *pgxpool.Pool) *PayoutRepository {
+	return &PayoutRepository{
+		pool: pool,
+	}
+}
+
+// LockPayouts takes a transaction-scoped advisory lock so that only one
+// payout batch runs at a time.
+func (repo *PayoutRepository) LockPayouts(ctx context.Context) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	if _, err := ex.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('payout_batch'))`); err != nil {
+		return fmt.Errorf("failed to lock payouts: %w", err)
+	}
+	return nil
+}
+
+// ListPayableBalances returns driver ledger accounts whose balance is at least minAmount.
+func (repo *PayoutRepository) ListPayableBalances(ctx context.Context, minAmount float64) ([]models.AccountBalance, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT a.id, a.type, a.owner_id::text, a.currency, sum(e.amount)
+	FROM ledger_accounts a
+	JOIN ledger_entries e ON e.account_id = a.id
+	WHERE a.type = 'DRIVER'
+	GROUP BY a.id
+	HAVING sum(e.amount) >= $1 AND sum(e.amount) > 0`
+
+	rows, err := ex.Query(ctx, query, minAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payable balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make([]models.AccountBalance, 0)
+	for rows.Next() {
+		var b models.AccountBalance
+		if err = rows.Scan(&b.AccountID, &b.Type, &b.OwnerID, &b.Currency, &b.Balance); err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %w", err)
+		}
+		balances = append(balances, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating balances: %w", err)
+	}
+	return balances, nil
+}
+
+func (repo *PayoutRepository) CreateBatch(ctx context.Context) (models.PayoutBatch, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	var b models.PayoutBatch
+	query := `INSERT INTO payout_batches DEFAULT VALUES RETURNING id, created_at`
+	if err := ex.QueryRow(ctx, query).Scan(&b.ID, &b.CreatedAt); err != nil {
+		return models.PayoutBatch{}, fmt.Errorf("failed to create payout batch: %w", err)
+	}
+	return b, nil
+}
+
+func (repo *PayoutRepository) UpdateBatch(ctx context.Context, b models.PayoutBatch) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE payout_batches
+	SET total_amount = $2, drivers_count = $3, export_path = nullif($4, '')
+	WHERE id = $1`
+
+	if _, err := ex.Exec(ctx, query, b.ID, b.TotalAmount, b.DriversCount, b.ExportPath); err != nil {
+		return fmt.Errorf("failed to update payout batch: %w", err)
+	}
+	return nil
+}
+
+func (repo *PayoutRepository) GetBatch(ctx context.Context, id string) (models.PayoutBatch, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, total_amount, drivers_count, coalesce(export_path, '')
+	FROM payout_batches WHERE id = $1`
+
+	var b models.PayoutBatch
+	err := ex.QueryRow(ctx, query, id).Scan(&b.ID, &b.CreatedAt, &b.TotalAmount, &b.DriversCount, &b.ExportPath)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PayoutBatch{}, types.ErrPayoutBatchNotFound
+		}
+		return models.PayoutBatch{}, fmt.Errorf("failed to get payout batch: %w", err)
+	}
+	return b, nil
+}
+
+func (repo *PayoutRepository) CreatePayout(ctx context.Context, p models.Payout) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO payouts (batch_id, driver_id, amount, status, ledger_transaction_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, p.BatchID, p.DriverID, p.Amount, p.Status, p.LedgerTransactionID).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to create payout: %w", err)
+	}
+	return id, nil
+}
+
+func (repo *PayoutRepository) ListPayouts(ctx context.Context, batchID string) ([]models.Payout, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, batch_id, driver_id, amount, status, ledger_transaction_id
+	FROM payouts WHERE batch_id = $1
+	ORDER BY driver_id`
+
+	rows, err := ex.Query(ctx, query, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := make([]models.Payout, 0)
+	for rows.Next() {
+		var p models.Payout
+		if err = rows.Scan(&p.ID, &p.CreatedAt, &p.BatchID, &p.DriverID, &p.Amount, &p.Status, &p.LedgerTransactionID); err != nil {
+			return nil, fmt.Errorf("failed to scan payout: %w", err)
+		}
+		payouts = append(payouts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payouts: %w", err)
+	}
+	return payouts, nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PromoRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPromoRepository(pool *pgxpool.Pool) *PromoRepository {
+	return &PromoRepository{
+		pool: pool,
+	}
+}
+
+const promoColumns = `id, created_at, updated_at, code, discount_type, value, max_discount,
+	usage_limit, per_user_limit, used_count, valid_from, valid_until, first_ride_only,
+	ride_types, is_active`
+
+func (repo *PromoRepository) CreatePromo(ctx context.Context, p models.PromoCode) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO promo_codes
+		(code, discount_type, value, max_discount, usage_limit, per_user_limit,
+		valid_from, valid_until, first_ride_only, ride_types, is_active)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id`
+
+	var id string
+	err := ex.QueryRow(
+		ctx, query,
+		p.Code, p.DiscountType, p.Value, p.MaxDiscount, p.UsageLimit, p.PerUserLimit,
+		p.ValidFrom, p.ValidUntil, p.FirstRideOnly, p.RideTypes, p.IsActive,
+	).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", types.ErrPromoAlreadyExists
+		}
+		return "", fmt.Errorf("failed to create promo code: %w", err)
+	}
+	return id, nil
+}
+
+func (repo *PromoRepository) GetPromoByCode(ctx context.Context, code string) (models.PromoCode, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + promoColumns + ` FROM promo_codes WHERE upper(code) = upper($1)`
+	return scanPromo(ex.QueryRow(ctx, query, code))
+}
+
+// GetPromoForUpdate locks the promo row until the surrounding transaction ends,
+// serializing concurrent redemptions of the same code.
+func (repo *PromoRepository) GetPromoForUpdate(ctx context.Context, id string) (models.PromoCode, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + promoColumns + ` FROM promo_codes WHERE id = $1 FOR UPDATE`
+	return scanPromo(ex.QueryRow(ctx, query, id))
+}
+
+func (repo *PromoRepository) CountUserRedemptions(ctx context.Context, promoID, userID string) (int, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	var count int
+	query := `SELECT count(*) FROM promo_redemptions WHERE promo_code_id = $1 AND user_id = $2`
+	if err := ex.QueryRow(ctx, query, promoID, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count promo redemptions: %w", err)
+	}
+	return count, nil
+}
+
+func (repo *PromoRepository) IncrementUsage(ctx context.Context, promoID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE promo_codes
+	SET used_count = used_count + 1, updated_at = now()
+	WHERE id = $1 AND (usage_limit IS NULL OR used_count < usage_limit)`
+
+	result, err := ex.Exec(ctx, query, promoID)
+	if err != nil {
+		return fmt.Errorf("failed to increment promo usage: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrPromoLimitReached
+	}
+	return nil
+}
+
+func (repo *PromoRepository) CreateRedemption(ctx context.Context, r models.PromoRedemption) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO promo_redemptions (promo_code_id, user_id, ride_id, discount_amount)
+	VALUES ($1, $2, $3, $4)`
+
+	if _, err := ex.Exec(ctx, query, r.PromoCodeID, r.UserID, r.RideID, r.DiscountAmount); err != nil {
+		return fmt.Errorf("failed to create promo redemption: %w", err)
+	}
+	return nil
+}
+
+func scanPromo(row pgx.Row) (models.PromoCode, error) {
+	var p models.PromoCode
+	err := row.Scan(
+		&p.ID,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.Code,
+		&p.DiscountType,
+		&p.Value,
+		&p.MaxDiscount,
+		&p.UsageLimit,
+		&p.PerUserLimit,
+		&p.UsedCount,
+		&p.ValidFrom,
+		&p.ValidUntil,
+		&p.FirstRideOnly,
+		&p.RideTypes,
+		&p.IsActive,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PromoCode{}, types.ErrPromoNotFound
+		}
+		return models.PromoCode{}, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	return p, nil
+}
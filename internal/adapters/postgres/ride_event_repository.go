@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RideEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRideEventRepository(pool *pgxpool.Pool) *RideEventRepository {
+	return &RideEventRepository{
+		pool: pool,
+	}
+}
+
+func (repo *RideEventRepository) CreateEvent(ctx context.Context, rideID, eventType string, data any) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	query := `INSERT INTO ride_events (ride_id, event_type, event_data) VALUES ($1, $2, $3::jsonb)`
+
+	if _, err = ex.Exec(ctx, query, rideID, eventType, payload); err != nil {
+		return fmt.Errorf("failed to insert ride event: %w", err)
+	}
+	return nil
+}
+
+// GetLastEvent decodes the data of the latest event of the given type into dest.
+func (repo *RideEventRepository) GetLastEvent(ctx context.Context, rideID, eventType string, dest any) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT event_data FROM ride_events
+	WHERE ride_id = $1 AND event_type = $2
+	ORDER BY created_at DESC
+	LIMIT 1`
+
+	var payload []byte
+	if err := ex.QueryRow(ctx, query, rideID, eventType).Scan(&payload); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return types.ErrEventNotFound
+		}
+		return fmt.Errorf("failed to get ride event: %w", err)
+	}
+
+	if err := json.Unmarshal(payload, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal event data: %w", err)
+	}
+	return nil
+}
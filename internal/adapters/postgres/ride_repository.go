@@ -7,8 +7,10 @@ import (
 	"ride-hail/internal/core/domain/models"
 	"ride-hail/internal/core/domain/types"
 	"ride-hail/pkg/executor"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,8 +29,9 @@ func (repo *RideRepository) CreateNewRide(ctx context.Context, ride models.Ride)
 
 	query := `INSERT INTO rides (
 		ride_number, passenger_id, vehicle_type, status,
-		estimated_fare, pickup_coordinate_id, destination_coordinate_id
-	) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		estimated_fare, pickup_coordinate_id, destination_coordinate_id,
+		promo_code_id, discount_amount, scheduled_at, seats, priority
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	RETURNING id`
 
 	var id string
@@ -41,29 +44,50 @@ func (repo *RideRepository) CreateNewRide(ctx context.Context, ride models.Ride)
 		ride.EstimatedFare,
 		ride.PickupCoordinateId,
 		ride.DestinationCoordinateId,
+		ride.PromoCodeID,
+		ride.DiscountAmount,
+		ride.ScheduledAt,
+		max(ride.Seats, 1),
+		max(ride.Priority, types.RidePriorityMin),
 	).Scan(&id)
 	if err != nil {
+		if conflict := activeRideConflict(err); conflict != nil {
+			return "", conflict
+		}
 		return "", fmt.Errorf("failed to create ride: %w", err)
 	}
 
 	return id, nil
 }
 
-func (repo *RideRepository) GetRide(ctx context.Context, id string) (models.Ride, error) {
-	ex := executor.GetExecutor(ctx, repo.pool)
+// activeRideConflict maps violations of the one-active-ride indexes to
+// domain errors. Returns nil for any other error.
+func activeRideConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return nil
+	}
+	switch pgErr.ConstraintName {
+	case "uq_rides_passenger_active":
+		return types.ErrActiveRideExists
+	case "uq_rides_driver_active":
+		return types.ErrDriverBusy
+	}
+	return nil
+}
 
-	query := `
-	SELECT id, created_at, updated_at, ride_number, passenger_id, driver_id, vehicle_type,
-	       status, priority, requested_at, matched_at, arrived_at, started_at,
-	       completed_at, cancelled_at, cancellation_reason, estimated_fare, final_fare,
-	       pickup_coordinate_id, destination_coordinate_id
-	FROM rides
-	WHERE id = $1
-	`
+// rideColumns are qualified with the table name so that queries can join
+// rides with other tables.
+const rideColumns = `rides.id, rides.created_at, rides.updated_at, rides.ride_number, rides.passenger_id,
+	       coalesce(rides.driver_id::text, ''), rides.vehicle_type, rides.status, rides.priority,
+	       rides.requested_at, rides.matched_at, rides.arrived_at, rides.started_at, rides.completed_at,
+	       rides.cancelled_at, coalesce(rides.cancellation_reason, ''), coalesce(rides.estimated_fare, 0),
+	       coalesce(rides.final_fare, 0), rides.pickup_coordinate_id, rides.destination_coordinate_id,
+	       rides.promo_code_id, coalesce(rides.discount_amount, 0), rides.scheduled_at, rides.trip_id, rides.seats`
 
+func scanRide(row pgx.Row) (models.Ride, error) {
 	var ride models.Ride
-
-	err := ex.QueryRow(ctx, query, id).Scan(
+	err := row.Scan(
 		&ride.ID,
 		&ride.CreatedAt,
 		&ride.UpdatedAt,
@@ -84,7 +108,21 @@ func (repo *RideRepository) GetRide(ctx context.Context, id string) (models.Ride
 		&ride.FinalFare,
 		&ride.PickupCoordinateId,
 		&ride.DestinationCoordinateId,
+		&ride.PromoCodeID,
+		&ride.DiscountAmount,
+		&ride.ScheduledAt,
+		&ride.TripID,
+		&ride.Seats,
 	)
+	return ride, err
+}
+
+func (repo *RideRepository) GetRide(ctx context.Context, id string) (models.Ride, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + rideColumns + ` FROM rides WHERE id = $1`
+
+	ride, err := scanRide(ex.QueryRow(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.Ride{}, types.ErrRideNotFound
@@ -95,6 +133,85 @@ func (repo *RideRepository) GetRide(ctx context.Context, id string) (models.Ride
 	return ride, nil
 }
 
+const rideDetailQuery = `SELECT ` + rideColumns + `,
+	       p.address, p.latitude, p.longitude, d.address, d.latitude, d.longitude
+	FROM rides
+	JOIN coordinates p ON p.id = rides.pickup_coordinate_id
+	JOIN coordinates d ON d.id = rides.destination_coordinate_id`
+
+func (repo *RideRepository) GetRideDetail(ctx context.Context, id string) (models.RideDetail, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	ride, err := scanRideDetail(ex.QueryRow(ctx, rideDetailQuery+` WHERE rides.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RideDetail{}, types.ErrRideNotFound
+		}
+		return models.RideDetail{}, fmt.Errorf("failed to get ride detail by id %s: %w", id, err)
+	}
+	return ride, nil
+}
+
+// ListPassengerRides returns up to limit rides of the passenger ordered by
+// requested_at and id descending, starting after the cursor if given.
+func (repo *RideRepository) ListPassengerRides(ctx context.Context, f models.RideFilter, after *models.RideCursor, limit int) ([]models.RideDetail, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := rideDetailQuery + ` WHERE rides.passenger_id = $1`
+	args := []any{f.PassengerID}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		query += fmt.Sprintf(` AND rides.status = $%d`, len(args))
+	}
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		query += fmt.Sprintf(` AND rides.requested_at >= $%d`, len(args))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		query += fmt.Sprintf(` AND rides.requested_at < $%d`, len(args))
+	}
+	if after != nil {
+		args = append(args, after.RequestedAt, after.ID)
+		query += fmt.Sprintf(` AND (rides.requested_at, rides.id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY rides.requested_at DESC, rides.id DESC LIMIT $%d`, len(args))
+
+	rows, err := ex.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passenger rides: %w", err)
+	}
+	defer rows.Close()
+
+	rides := make([]models.RideDetail, 0)
+	for rows.Next() {
+		ride, err := scanRideDetail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ride detail: %w", err)
+		}
+		rides = append(rides, ride)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating passenger rides: %w", err)
+	}
+	return rides, nil
+}
+
+func scanRideDetail(row pgx.Row) (models.RideDetail, error) {
+	var d models.RideDetail
+	err := row.Scan(
+		&d.ID, &d.CreatedAt, &d.UpdatedAt, &d.RideNumber, &d.PassengerID, &d.DriverID, &d.VehicleType,
+		&d.Status, &d.Priority, &d.RequestedAt, &d.MatchedAt, &d.ArrivedAt, &d.StartedAt, &d.CompletedAt,
+		&d.CancelledAt, &d.CancellationReason, &d.EstimatedFare, &d.FinalFare, &d.PickupCoordinateId,
+		&d.DestinationCoordinateId, &d.PromoCodeID, &d.DiscountAmount, &d.ScheduledAt, &d.TripID, &d.Seats,
+		&d.Pickup.Address, &d.Pickup.Latitude, &d.Pickup.Longitude,
+		&d.Destination.Address, &d.Destination.Latitude, &d.Destination.Longitude,
+	)
+	return d, err
+}
+
 func (repo *RideRepository) GenerateRideNumber(ctx context.Context) (int, error) {
 	ex := executor.GetExecutor(ctx, repo.pool)
 	var counter int
@@ -106,9 +223,348 @@ func (repo *RideRepository) GenerateRideNumber(ctx context.Context) (int, error)
         SET counter = ride_counters.counter + 1
         RETURNING counter
     `).Scan(&counter)
-	
+
 	if err != nil {
 		return 0, err
 	}
 	return counter, nil
 }
+
+// UpdateRideStatus moves the ride from expectedStatus to newStatus and stamps
+// the matching timestamp column; a ride moving to REQUESTED is stamped as
+// dispatched, its requested_at stays. Returns types.ErrInvalidRideStatus when
+// the ride is not in expectedStatus.
+func (repo *RideRepository) UpdateRideStatus(ctx context.Context, rideID, expectedStatus, newStatus string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides
+	SET status = $3::text,
+	    updated_at = now(),
+	    dispatched_at = CASE WHEN $3::text = 'REQUESTED' THEN now() ELSE dispatched_at END,
+	    matched_at = CASE WHEN $3::text = 'MATCHED' THEN now() ELSE matched_at END,
+	    arrived_at = CASE WHEN $3::text = 'ARRIVED' THEN now() ELSE arrived_at END,
+	    started_at = CASE WHEN $3::text = 'IN_PROGRESS' THEN now() ELSE started_at END,
+	    completed_at = CASE WHEN $3::text = 'COMPLETED' THEN now() ELSE completed_at END,
+	    cancelled_at = CASE WHEN $3::text = 'CANCELLED' THEN now() ELSE cancelled_at END
+	WHERE id = $1 AND status = $2`
+
+	result, err := ex.Exec(ctx, query, rideID, expectedStatus, newStatus)
+	if err != nil {
+		if conflict := activeRideConflict(err); conflict != nil {
+			return conflict
+		}
+		return fmt.Errorf("failed to update ride status: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidRideStatus
+	}
+	return nil
+}
+
+func (repo *RideRepository) SetFinalFare(ctx context.Context, rideID string, fare float64) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides SET final_fare = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, rideID, fare)
+	if err != nil {
+		return fmt.Errorf("failed to set final fare: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrRideNotFound
+	}
+	return nil
+}
+
+func (repo *RideRepository) CountCompletedRides(ctx context.Context, passengerID string) (int, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	var count int
+	query := `SELECT count(*) FROM rides WHERE passenger_id = $1 AND status = 'COMPLETED'`
+	if err := ex.QueryRow(ctx, query, passengerID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count completed rides: %w", err)
+	}
+	return count, nil
+}
+
+// GetActiveRideID returns the id of the driver's ride in progress or on the way
+// to pickup, or an empty string when the driver has none.
+func (repo *RideRepository) GetActiveRideID(ctx context.Context, driverID string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id FROM rides
+	WHERE driver_id = $1 AND status IN ('MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
+	ORDER BY requested_at DESC
+	LIMIT 1`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, driverID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get active ride for driver %s: %w", driverID, err)
+	}
+	return id, nil
+}
+
+// GetPassengerActiveRideID returns the id of the passenger's ride that is
+// waiting for a driver or under way, or an empty string when there is none.
+func (repo *RideRepository) GetPassengerActiveRideID(ctx context.Context, passengerID string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id FROM rides
+	WHERE passenger_id = $1 AND status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
+	LIMIT 1`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, passengerID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get active ride for passenger %s: %w", passengerID, err)
+	}
+	return id, nil
+}
+
+// AssignDriver matches a REQUESTED ride that has no driver yet to the driver
+// who accepted its offer.
+func (repo *RideRepository) AssignDriver(ctx context.Context, rideID, driverID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides
+	SET status = 'MATCHED', driver_id = $2, matched_at = now(), updated_at = now()
+	WHERE id = $1 AND status = 'REQUESTED' AND driver_id IS NULL`
+
+	result, err := ex.Exec(ctx, query, rideID, driverID)
+	if err != nil {
+		if conflict := activeRideConflict(err); conflict != nil {
+			return conflict
+		}
+		return fmt.Errorf("failed to assign driver to ride: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidRideStatus
+	}
+	return nil
+}
+
+// AssignTrip matches a REQUESTED ride to the driver of a pool trip.
+func (repo *RideRepository) AssignTrip(ctx context.Context, rideID, driverID, tripID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides
+	SET status = 'MATCHED', driver_id = $2, trip_id = $3, matched_at = now(), updated_at = now()
+	WHERE id = $1 AND status = 'REQUESTED'`
+
+	result, err := ex.Exec(ctx, query, rideID, driverID, tripID)
+	if err != nil {
+		if conflict := activeRideConflict(err); conflict != nil {
+			return conflict
+		}
+		return fmt.Errorf("failed to assign ride to trip: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidRideStatus
+	}
+	return nil
+}
+
+// ListTripRides returns the rides of a trip that were not cancelled.
+func (repo *RideRepository) ListTripRides(ctx context.Context, tripID string) ([]models.Ride, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + rideColumns + ` FROM rides
+	WHERE trip_id = $1 AND status <> 'CANCELLED'
+	ORDER BY matched_at`
+
+	return repo.listRides(ctx, ex, query, tripID)
+}
+
+func (repo *RideRepository) SetEstimatedFare(ctx context.Context, rideID string, fare float64) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides SET estimated_fare = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, rideID, fare)
+	if err != nil {
+		return fmt.Errorf("failed to set estimated fare: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrRideNotFound
+	}
+	return nil
+}
+
+func (repo *RideRepository) SetCancellationReason(ctx context.Context, rideID, reason string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides SET cancellation_reason = nullif($2, ''), updated_at = now() WHERE id = $1`
+
+	if _, err := ex.Exec(ctx, query, rideID, reason); err != nil {
+		return fmt.Errorf("failed to set cancellation reason: %w", err)
+	}
+	return nil
+}
+
+// ListDueScheduledRides locks up to limit SCHEDULED rides with pickup before the
+// given time. Rows locked by another instance are skipped.
+func (repo *RideRepository) ListDueScheduledRides(ctx context.Context, before time.Time, limit int) ([]models.Ride, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + rideColumns + ` FROM rides
+	WHERE status = 'SCHEDULED' AND scheduled_at <= $1
+	ORDER BY scheduled_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	return repo.listRides(ctx, ex, query, before, limit)
+}
+
+// ListRidesToRemind locks SCHEDULED rides with pickup before the given time
+// that have not been reminded yet.
+func (repo *RideRepository) ListRidesToRemind(ctx context.Context, before time.Time, limit int) ([]models.Ride, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + rideColumns + ` FROM rides
+	WHERE status = 'SCHEDULED' AND scheduled_at <= $1 AND reminder_sent_at IS NULL
+	ORDER BY scheduled_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	return repo.listRides(ctx, ex, query, before, limit)
+}
+
+func (repo *RideRepository) MarkReminderSent(ctx context.Context, rideID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides SET reminder_sent_at = now() WHERE id = $1`
+
+	if _, err := ex.Exec(ctx, query, rideID); err != nil {
+		return fmt.Errorf("failed to mark reminder sent: %w", err)
+	}
+	return nil
+}
+
+func (repo *RideRepository) SetPriority(ctx context.Context, rideID string, priority int) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides SET priority = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, rideID, priority)
+	if err != nil {
+		return fmt.Errorf("failed to set ride priority: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrRideNotFound
+	}
+	return nil
+}
+
+// ListWaitingRides locks REQUESTED rides not updated since the given time,
+// i.e. rides that were published to matching and found no driver, the longest
+// waiting first within a priority.
+func (repo *RideRepository) ListWaitingRides(ctx context.Context, before time.Time, limit int) ([]models.Ride, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT ` + rideColumns + ` FROM rides
+	WHERE status = 'REQUESTED' AND driver_id IS NULL AND updated_at <= $1
+	ORDER BY priority DESC, dispatched_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	return repo.listRides(ctx, ex, query, before, limit)
+}
+
+// ListWaitingPickups returns the pickups of REQUESTED rides of the vehicle
+// type with at least the given priority that have no driver yet.
+func (repo *RideRepository) ListWaitingPickups(ctx context.Context, vehicleType string, minPriority int) ([]models.Point, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT p.latitude, p.longitude FROM rides
+	JOIN coordinates p ON p.id = rides.pickup_coordinate_id
+	WHERE rides.status = 'REQUESTED' AND rides.driver_id IS NULL AND rides.vehicle_type = $1 AND rides.priority >= $2`
+
+	rows, err := ex.Query(ctx, query, vehicleType, minPriority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waiting pickups: %w", err)
+	}
+	defer rows.Close()
+
+	pickups := make([]models.Point, 0)
+	for rows.Next() {
+		var p models.Point
+		if err = rows.Scan(&p.Lat, &p.Lng); err != nil {
+			return nil, fmt.Errorf("failed to scan waiting pickup: %w", err)
+		}
+		pickups = append(pickups, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating waiting pickups: %w", err)
+	}
+	return pickups, nil
+}
+
+// ReleaseDriver returns a ride that has not started yet to REQUESTED with the
+// given priority, detaching it from the driver and the pool trip. The ride is
+// stamped as dispatched again, its requested_at stays.
+func (repo *RideRepository) ReleaseDriver(ctx context.Context, rideID, driverID string, priority int) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE rides
+	SET status = 'REQUESTED', driver_id = NULL, trip_id = NULL, matched_at = NULL, arrived_at = NULL,
+	    priority = $3, dispatched_at = now(), updated_at = now()
+	WHERE id = $1 AND driver_id = $2 AND status IN ('MATCHED', 'EN_ROUTE', 'ARRIVED')`
+
+	result, err := ex.Exec(ctx, query, rideID, driverID, priority)
+	if err != nil {
+		return fmt.Errorf("failed to release ride driver: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidRideStatus
+	}
+	return nil
+}
+
+// CountMatchedRides counts rides matched to the driver since the given time
+// that the driver did not give up.
+func (repo *RideRepository) CountMatchedRides(ctx context.Context, driverID string, since time.Time) (int, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT count(*) FROM rides WHERE driver_id = $1 AND matched_at >= $2`
+
+	var n int
+	if err := ex.QueryRow(ctx, query, driverID, since).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count matched rides: %w", err)
+	}
+	return n, nil
+}
+
+func (repo *RideRepository) listRides(ctx context.Context, ex executor.DBExecutor, query string, args ...any) ([]models.Ride, error) {
+	rows, err := ex.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rides: %w", err)
+	}
+	defer rows.Close()
+
+	rides := make([]models.Ride, 0)
+	for rows.Next() {
+		ride, err := scanRide(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ride: %w", err)
+		}
+		rides = append(rides, ride)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rides: %w", err)
+	}
+	return rides, nil
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RideStopRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRideStopRepository(pool *pgxpool.Pool) *RideStopRepository {
+	return &RideStopRepository{
+		pool: pool,
+	}
+}
+
+func (repo *RideStopRepository) CreateStop(ctx context.Context, stop models.RideStop) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO ride_stops (ride_id, sequence, coordinate_id)
+	VALUES ($1, $2, $3)
+	RETURNING id`
+
+	var id string
+	if err := ex.QueryRow(ctx, query, stop.RideID, stop.Sequence, stop.CoordinateID).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to insert ride stop: %w", err)
+	}
+	return id, nil
+}
+
+// ListStops returns the stops of a ride in visiting order.
+func (repo *RideStopRepository) ListStops(ctx context.Context, rideID string) ([]models.RideStop, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT s.id, s.ride_id, s.coordinate_id, s.sequence,
+	       c.latitude, c.longitude, c.address, s.arrived_at, s.departed_at
+	FROM ride_stops s
+	JOIN coordinates c ON c.id = s.coordinate_id
+	WHERE s.ride_id = $1
+	ORDER BY s.sequence`
+
+	rows, err := ex.Query(ctx, query, rideID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ride stops: %w", err)
+	}
+	defer rows.Close()
+
+	stops := make([]models.RideStop, 0)
+	for rows.Next() {
+		var s models.RideStop
+		if err = rows.Scan(
+			&s.ID, &s.RideID, &s.CoordinateID, &s.Sequence,
+			&s.Latitude, &s.Longitude, &s.Address, &s.ArrivedAt, &s.DepartedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan ride stop: %w", err)
+		}
+		stops = append(stops, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ride stops: %w", err)
+	}
+	return stops, nil
+}
+
+// DeletePendingStops removes the stops the driver has not arrived at yet.
+func (repo *RideStopRepository) DeletePendingStops(ctx context.Context, rideID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `DELETE FROM ride_stops WHERE ride_id = $1 AND arrived_at IS NULL`
+
+	if _, err := ex.Exec(ctx, query, rideID); err != nil {
+		return fmt.Errorf("failed to delete pending ride stops: %w", err)
+	}
+	return nil
+}
+
+func (repo *RideStopRepository) MarkStopArrived(ctx context.Context, stopID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE ride_stops SET arrived_at = now(), updated_at = now()
+	WHERE id = $1 AND arrived_at IS NULL`
+
+	result, err := ex.Exec(ctx, query, stopID)
+	if err != nil {
+		return fmt.Errorf("failed to mark stop arrival: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidStopStatus
+	}
+	return nil
+}
+
+func (repo *RideStopRepository) MarkStopDeparted(ctx context.Context, stopID string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE ride_stops SET departed_at = now(), updated_at = now()
+	WHERE id = $1 AND arrived_at IS NOT NULL AND departed_at IS NULL`
+
+	result, err := ex.Exec(ctx, query, stopID)
+	if err != nil {
+		return fmt.Errorf("failed to mark stop departure: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrInvalidStopStatus
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TokenRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTokenRepository(pool *pgxpool.Pool) *TokenRepository {
+	return &TokenRepository{
+		pool: pool,
+	}
+}
+
+func (repo *TokenRepository) CreateRefreshToken(ctx context.Context, t models.RefreshToken) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_claims_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := ex.Exec(ctx, query, t.UserID, t.FamilyID, t.TokenHash, t.AccessClaimsID, t.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshToken returns the token with the given hash and locks it until the
+// end of the transaction. Returns types.ErrInvalidRefreshToken when there is none.
+func (repo *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, user_id, family_id, token_hash, access_claims_id, expires_at, used_at, revoked_at
+	FROM refresh_tokens
+	WHERE token_hash = $1
+	FOR UPDATE`
+
+	var t models.RefreshToken
+	err := ex.QueryRow(ctx, query, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.AccessClaimsID, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, types.ErrInvalidRefreshToken
+		}
+		return models.RefreshToken{}, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return t, nil
+}
+
+// MarkRefreshTokenUsed marks the token as rotated. Returns
+// types.ErrRefreshTokenReused when it was already used or revoked.
+func (repo *TokenRepository) MarkRefreshTokenUsed(ctx context.Context, id string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE refresh_tokens SET used_at = now()
+	WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := ex.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token of the family together with the
+// access tokens issued with them. accessExpiresAt bounds how long the access
+// tokens stay on the revocation list.
+func (repo *TokenRepository) RevokeTokenFamily(ctx context.Context, familyID string, accessExpiresAt time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `WITH revoked AS (
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+		RETURNING access_claims_id
+	)
+	INSERT INTO revoked_tokens (claims_id, expires_at)
+	SELECT access_claims_id, $2 FROM revoked
+	ON CONFLICT (claims_id) DO NOTHING`
+
+	if _, err := ex.Exec(ctx, query, familyID, accessExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token family %s: %w", familyID, err)
+	}
+	return nil
+}
+
+// RevokeUserTokens revokes every refresh token family of the user together
+// with the access tokens issued with them, e.g. after a password reset.
+func (repo *TokenRepository) RevokeUserTokens(ctx context.Context, userID string, accessExpiresAt time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `WITH revoked AS (
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING access_claims_id
+	)
+	INSERT INTO revoked_tokens (claims_id, expires_at)
+	SELECT access_claims_id, $2 FROM revoked
+	ON CONFLICT (claims_id) DO NOTHING`
+
+	if _, err := ex.Exec(ctx, query, userID, accessExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %s: %w", userID, err)
+	}
+	return nil
+}
+
+// RevokeAccessToken puts the access token on the revocation list until it expires.
+func (repo *TokenRepository) RevokeAccessToken(ctx context.Context, claimsID string, expiresAt time.Time) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `INSERT INTO revoked_tokens (claims_id, expires_at) VALUES ($1, $2)
+	ON CONFLICT (claims_id) DO NOTHING`
+
+	if _, err := ex.Exec(ctx, query, claimsID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+func (repo *TokenRepository) IsAccessTokenRevoked(ctx context.Context, claimsID string) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE claims_id = $1)`
+
+	var revoked bool
+	if err := ex.QueryRow(ctx, query, claimsID).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+	return revoked, nil
+}
+
+// CreateAccountToken stores a new single-use token. Unused tokens of the same
+// user and purpose stop working, only the latest email counts.
+func (repo *TokenRepository) CreateAccountToken(ctx context.Context, t models.AccountToken) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `WITH superseded AS (
+		UPDATE account_tokens SET used_at = now()
+		WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL
+	)
+	INSERT INTO account_tokens (id, user_id, purpose, expires_at)
+	VALUES ($1, $2, $3, $4)`
+
+	if _, err := ex.Exec(ctx, query, t.ID, t.UserID, t.Purpose, t.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create account token: %w", err)
+	}
+	return nil
+}
+
+// GetAccountTokenUser returns the user of an unused and unexpired token.
+// Returns types.ErrInvalidAccountToken otherwise.
+func (repo *TokenRepository) GetAccountTokenUser(ctx context.Context, id, purpose string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT user_id FROM account_tokens
+	WHERE id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()`
+
+	var userID string
+	if err := ex.QueryRow(ctx, query, id, purpose).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", types.ErrInvalidAccountToken
+		}
+		return "", fmt.Errorf("failed to get account token: %w", err)
+	}
+	return userID, nil
+}
+
+// ConsumeAccountToken marks the token used and returns its user. Returns
+// types.ErrInvalidAccountToken when it is unknown, used or expired.
+func (repo *TokenRepository) ConsumeAccountToken(ctx context.Context, id, purpose string) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE account_tokens SET used_at = now()
+	WHERE id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+	RETURNING user_id`
+
+	var userID string
+	if err := ex.QueryRow(ctx, query, id, purpose).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", types.ErrInvalidAccountToken
+		}
+		return "", fmt.Errorf("failed to consume account token: %w", err)
+	}
+	return userID, nil
+}
+
+// DeleteExpired removes refresh tokens, account tokens and revocation entries
+// past their expiry.
+func (repo *TokenRepository) DeleteExpired(ctx context.Context) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	if _, err := ex.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= now()`); err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	if _, err := ex.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at <= now()`); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	if _, err := ex.Exec(ctx, `DELETE FROM account_tokens WHERE expires_at <= now()`); err != nil {
+		return fmt.Errorf("failed to delete expired account tokens: %w", err)
+	}
+	return nil
+}
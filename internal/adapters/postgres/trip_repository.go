@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/pkg/executor"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TripRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTripRepository(pool *pgxpool.Pool) *TripRepository {
+	return &TripRepository{
+		pool: pool,
+	}
+}
+
+func (repo *TripRepository) CreateTrip(ctx context.Context, trip models.Trip) (string, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	plan, err := json.Marshal(trip.Plan)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal trip plan: %w", err)
+	}
+
+	query := `INSERT INTO trips (driver_id, seats_total, plan)
+	VALUES ($1, $2, $3)
+	RETURNING id`
+
+	var id string
+	if err = ex.QueryRow(ctx, query, trip.DriverID, trip.SeatsTotal, plan).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to insert trip: %w", err)
+	}
+	return id, nil
+}
+
+func (repo *TripRepository) GetTrip(ctx context.Context, id string) (models.Trip, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, driver_id, status, seats_total, plan FROM trips WHERE id = $1`
+
+	trip, err := scanTrip(ex.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Trip{}, types.ErrTripNotFound
+		}
+		return models.Trip{}, fmt.Errorf("failed to get trip: %w", err)
+	}
+	return trip, nil
+}
+
+// ListOpenTrips locks the OPEN trips so that concurrent matchers do not add
+// rides to the same trip at once.
+func (repo *TripRepository) ListOpenTrips(ctx context.Context) ([]models.Trip, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, created_at, driver_id, status, seats_total, plan
+	FROM trips
+	WHERE status = 'OPEN'
+	ORDER BY created_at
+	FOR UPDATE`
+
+	rows, err := ex.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := make([]models.Trip, 0)
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips = append(trips, trip)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trips: %w", err)
+	}
+	return trips, nil
+}
+
+func (repo *TripRepository) UpdatePlan(ctx context.Context, tripID string, plan []models.PlanStop) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trip plan: %w", err)
+	}
+
+	query := `UPDATE trips SET plan = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, tripID, data)
+	if err != nil {
+		return fmt.Errorf("failed to update trip plan: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return types.ErrTripNotFound
+	}
+	return nil
+}
+
+// CloseTripIfDone completes the trip once none of its rides is active, or
+// cancels it when every ride was cancelled. Returns true when the trip closed.
+func (repo *TripRepository) CloseTripIfDone(ctx context.Context, tripID string) (bool, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE trips t
+	SET status = CASE WHEN EXISTS (
+	        SELECT 1 FROM rides r WHERE r.trip_id = t.id AND r.status = 'COMPLETED'
+	    ) THEN 'COMPLETED' ELSE 'CANCELLED' END,
+	    updated_at = now()
+	WHERE t.id = $1 AND t.status = 'OPEN' AND NOT EXISTS (
+	    SELECT 1 FROM rides r WHERE r.trip_id = t.id AND r.status NOT IN ('COMPLETED', 'CANCELLED')
+	)`
+
+	result, err := ex.Exec(ctx, query, tripID)
+	if err != nil {
+		return false, fmt.Errorf("failed to close trip: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func scanTrip(row pgx.Row) (models.Trip, error) {
+	var (
+		trip models.Trip
+		plan []byte
+	)
+	if err := row.Scan(&trip.ID, &trip.CreatedAt, &trip.DriverID, &trip.Status, &trip.SeatsTotal, &plan); err != nil {
+		return models.Trip{}, err
+	}
+	if err := json.Unmarshal(plan, &trip.Plan); err != nil {
+		return models.Trip{}, fmt.Errorf("failed to unmarshal trip plan: %w", err)
+	}
+	return trip, nil
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,29 +22,36 @@ func NewRepo(pool *pgxpool.Pool) *UserRepository {
 	}
 }
 
-func (repo *UserRepository) CreateNewUser(ctx context.Context, user models.User) error {
+// CreateNewUser inserts the user and returns the new id. The name goes to
+// attrs, an empty phone is stored as null.
+func (repo *UserRepository) CreateNewUser(ctx context.Context, user models.User) (string, error) {
 	ex := executor.GetExecutor(ctx, repo.pool)
 
 	query := `
-	INSERT INTO users (email, role, password_hash)
-	VALUES ($1, $2, $3)
+	INSERT INTO users (email, role, password_hash, phone, attrs, email_verified_at)
+	VALUES ($1, $2, $3, nullif($4, ''), jsonb_build_object('name', $5::text), $6)
+	RETURNING id
 	`
 
-	_, err := ex.Exec(ctx, query, user.Email, user.Role, user.Password)
+	var id string
+	err := ex.QueryRow(ctx, query, user.Email, user.Role, user.Password, user.Phone, user.Name, user.EmailVerifiedAt).Scan(&id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return types.ErrUserAlreadyExists
+			if pgErr.ConstraintName == "users_phone_key" {
+				return "", types.ErrPhoneAlreadyExists
+			}
+			return "", types.ErrUserAlreadyExists
 		}
-		return err
+		return "", err
 	}
-	return nil
+	return id, nil
 }
 
 func (repo *UserRepository) GetGyUserEmail(ctx context.Context, email string) (models.User, error) {
 	ex := executor.GetExecutor(ctx, repo.pool)
 
-	query := `SELECT id, email, role, status, password_hash FROM users WHERE email = $1`
+	query := `SELECT id, email, role, status, password_hash, email_verified_at FROM users WHERE email = $1`
 
 	var user models.User
 	err := ex.QueryRow(ctx, query, email).Scan(
@@ -52,6 +60,53 @@ func (repo *UserRepository) GetGyUserEmail(ctx context.Context, email string) (m
 		&user.Role,
 		&user.Status,
 		&user.Password,
+		&user.EmailVerifiedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, types.ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (repo *UserRepository) GetUserByPhone(ctx context.Context, phone string) (models.User, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, email, role, status, phone FROM users WHERE phone = $1`
+
+	var user models.User
+	err := ex.QueryRow(ctx, query, phone).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Role,
+		&user.Status,
+		&user.Phone,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, types.ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (repo *UserRepository) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `SELECT id, email, role, status, email_verified_at FROM users WHERE id = $1`
+
+	var user models.User
+	err := ex.QueryRow(ctx, query, id).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Role,
+		&user.Status,
+		&user.EmailVerifiedAt,
 	)
 
 	if err != nil {
@@ -62,3 +117,32 @@ func (repo *UserRepository) GetGyUserEmail(ctx context.Context, email string) (m
 	}
 	return user, nil
 }
+
+func (repo *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE users SET password_hash = $2, updated_at = now() WHERE id = $1`
+
+	result, err := ex.Exec(ctx, query, id, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return types.ErrUserNotFound
+	}
+	return nil
+}
+
+// MarkEmailVerified records the email confirmation, keeping the first one.
+func (repo *UserRepository) MarkEmailVerified(ctx context.Context, id string) error {
+	ex := executor.GetExecutor(ctx, repo.pool)
+
+	query := `UPDATE users SET email_verified_at = now(), updated_at = now()
+	WHERE id = $1 AND email_verified_at IS NULL`
+
+	if _, err := ex.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
 	"ride-hail/internal/core/ports"
 )
 
@@ -25,29 +26,15 @@ func NewDALConsumer(dalService ports.DalService, publisher *Publisher) *DALConsu
 
 // HandleRideRequest processes incoming ride requests for driver matching
 func (dc *DALConsumer) HandleRideRequest(ctx context.Context, message []byte, routingKey string) error {
-	var rideReq models.CreateRideRequest
+	var rideReq models.RideRequestMessage
 	if err := json.Unmarshal(message, &rideReq); err != nil {
 		return fmt.Errorf("failed to unmarshal ride request: %w", err)
 	}
 
-	// Find nearby available drivers
-	availableDrivers, err := dc.dalService.ListAvailableDriversNear(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list available drivers: %w", err)
+	if rideReq.RideType == types.RideTypePOOL {
+		return dc.dalService.MatchPoolRide(ctx, rideReq)
 	}
-
-	// Filter drivers by proximity and vehicle type
-	nearbyDrivers := dc.filterNearbyDrivers(availableDrivers, rideReq.RideType)
-
-	// Send ride offers to nearby drivers via WebSocket
-	// This would be handled by your WebSocket service
-	dc.sendRideOffersToDrivers(ctx, rideReq, nearbyDrivers)
-
-	// Log the matching attempt
-	fmt.Printf("Processed ride request %s, found %d nearby drivers\n",
-		rideReq.PassengerID, len(nearbyDrivers))
-
-	return nil
+	return dc.dalService.MatchRide(ctx, rideReq)
 }
 
 // HandleRideStatusUpdate processes ride status updates
@@ -73,6 +60,16 @@ func (dc *DALConsumer) HandleRideStatusUpdate(ctx context.Context, message []byt
 	return nil
 }
 
+// HandleDriverNotification forwards notifications (tips etc.) to the driver
+func (dc *DALConsumer) HandleDriverNotification(ctx context.Context, message []byte, routingKey string) error {
+	var n models.DriverNotification
+	if err := json.Unmarshal(message, &n); err != nil {
+		return fmt.Errorf("failed to unmarshal driver notification: %w", err)
+	}
+
+	return dc.dalService.NotifyDriver(ctx, n)
+}
+
 // HandleDriverResponse processes driver responses to ride offers (from WebSocket)
 func (dc *DALConsumer) HandleDriverResponse(ctx context.Context, driverID string, rideID string, accepted bool) error {
 	// Publish driver response to driver_topic exchange
@@ -103,30 +100,3 @@ func (dc *DALConsumer) HandleDriverResponse(ctx context.Context, driverID string
 
 	return nil
 }
-
-// Helper methods
-func (dc *DALConsumer) filterNearbyDrivers(drivers []models.Driver, rideType string) []models.Driver {
-	// Implement proximity filtering logic based on coordinates
-	// This is a simplified version - in production you'd use PostGIS
-	var nearbyDrivers []models.Driver
-
-	for _, driver := range drivers {
-		// Check if driver's vehicle type matches
-		if driver.VehicleType != nil && *driver.VehicleType == rideType {
-			// In real implementation, calculate distance using Haversine formula
-			// and check if within max_distance_km
-			nearbyDrivers = append(nearbyDrivers, driver)
-		}
-	}
-
-	return nearbyDrivers
-}
-
-func (dc *DALConsumer) sendRideOffersToDrivers(ctx context.Context, rideReq models.CreateRideRequest, drivers []models.Driver) {
-	// This would integrate with your WebSocket service to send offers to drivers
-	// For now, just log the action
-	for _, driver := range drivers {
-		fmt.Printf("Sending ride offer for ride %s to driver %s\n",
-			rideReq.PassengerID, driver.ID)
-	}
-}
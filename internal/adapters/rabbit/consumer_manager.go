@@ -5,28 +5,35 @@ import (
 	"fmt"
 	"sync"
 
+	"ride-hail/pkg/logger"
 	"ride-hail/pkg/rabbit"
 )
 
 type ConsumerManager struct {
 	consumers []*rabbit.Consumer
+	signer    *rabbit.Signer
+	log       *logger.Logger
 	wg        sync.WaitGroup
 }
 
-func NewConsumerManager() *ConsumerManager {
+// NewConsumerManager returns a manager whose consumers verify messages with
+// signer.
+func NewConsumerManager(signer *rabbit.Signer, log *logger.Logger) *ConsumerManager {
 	return &ConsumerManager{
 		consumers: make([]*rabbit.Consumer, 0),
+		signer:    signer,
+		log:       log,
 	}
 }
 
 // StartDALConsumers starts all required consumers for DAL service
 func (cm *ConsumerManager) StartDALConsumers(ctx context.Context, conn *rabbit.Rabbit, dalConsumer *DALConsumer) error {
 	// Consumer for ride requests (driver matching)
-	rideRequestConsumer := rabbit.NewConsumer(conn.Conn, "ride_topic", "driver_matching")
+	rideRequestConsumer := rabbit.NewConsumer(conn.Conn, "ride_topic", "ride_requests", cm.signer, cm.log)
 	rideRequestConsumer.SetHandler(rabbit.MessageHandlerFunc(dalConsumer.HandleRideRequest))
 
 	// Consumer for ride status updates
-	rideStatusConsumer := rabbit.NewConsumer(conn.Conn, "ride_topic", "ride_status")
+	rideStatusConsumer := rabbit.NewConsumer(conn.Conn, "ride_topic", "ride_status", cm.signer, cm.log)
 	rideStatusConsumer.SetHandler(rabbit.MessageHandlerFunc(dalConsumer.HandleRideStatusUpdate))
 
 	// Start consumers
@@ -55,6 +62,32 @@ func (cm *ConsumerManager) StartDALConsumers(ctx context.Context, conn *rabbit.R
 	return nil
 }
 
+// StartRideConsumers starts all required consumers for Ride service
+func (cm *ConsumerManager) StartRideConsumers(ctx context.Context, conn *rabbit.Rabbit, rideConsumer *RideConsumer) error {
+	statusConsumer := rabbit.NewConsumer(conn.Conn, "ride_topic", "passenger_notifications", cm.signer, cm.log)
+	statusConsumer.SetHandler(rabbit.MessageHandlerFunc(rideConsumer.HandleRideStatus))
+
+	if err := statusConsumer.StartConsuming(ctx); err != nil {
+		return fmt.Errorf("error starting passenger_notifications consumer: %w", err)
+	}
+
+	cm.consumers = append(cm.consumers, statusConsumer)
+	return nil
+}
+
+// StartDriverNotificationConsumer starts the consumer delivering notifications to driver WebSockets
+func (cm *ConsumerManager) StartDriverNotificationConsumer(ctx context.Context, conn *rabbit.Rabbit, dalConsumer *DALConsumer) error {
+	notificationConsumer := rabbit.NewConsumer(conn.Conn, "driver_topic", "driver_notifications", cm.signer, cm.log)
+	notificationConsumer.SetHandler(rabbit.MessageHandlerFunc(dalConsumer.HandleDriverNotification))
+
+	if err := notificationConsumer.StartConsuming(ctx); err != nil {
+		return fmt.Errorf("error starting driver_notifications consumer: %w", err)
+	}
+
+	cm.consumers = append(cm.consumers, notificationConsumer)
+	return nil
+}
+
 // StopAll stops all consumers gracefully
 func (cm *ConsumerManager) StopAll() {
 	cm.wg.Wait()
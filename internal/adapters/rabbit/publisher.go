@@ -3,22 +3,35 @@ package rabbit
 import (
 	"errors"
 	"fmt"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"ride-hail/pkg/rabbit"
 	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type Publisher struct {
-	conn  *amqp.Connection
-	mutex sync.Mutex
+	conn   *amqp.Connection
+	signer *rabbit.Signer
+	mutex  sync.Mutex
 }
 
-func NewPublisher(conn *amqp.Connection) *Publisher {
+// NewPublisher returns a publisher that signs every message with signer.
+func NewPublisher(conn *amqp.Connection, signer *rabbit.Signer) *Publisher {
 	return &Publisher{
-		conn: conn,
+		conn:   conn,
+		signer: signer,
 	}
 }
 
-func (p *Publisher) Publish(exName, queue string, message []byte) error {
+// Publish sends the message to the exchange; queues and bindings are
+// declared once by InitRabbitTopology.
+func (p *Publisher) Publish(exName, routingKey string, message []byte) error {
+	return p.PublishWithPriority(exName, routingKey, 0, message)
+}
+
+// PublishWithPriority sends the message with an AMQP priority; it only
+// affects queues declared with x-max-priority.
+func (p *Publisher) PublishWithPriority(exName, routingKey string, priority uint8, message []byte) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -32,29 +45,16 @@ func (p *Publisher) Publish(exName, queue string, message []byte) error {
 	}
 	defer ch.Close()
 
-	_, err = ch.QueueDeclare(
-		queue,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-
-	if err != nil {
-		return fmt.Errorf("error in declaring queue %w", err)
+	msg := amqp.Publishing{
+		ContentType: "application/json",
+		Priority:    priority,
+		Body:        message,
+	}
+	if err = p.signer.Sign(exName, routingKey, &msg); err != nil {
+		return err
 	}
 
-	err = ch.Publish(
-		exName,
-		queue,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        message,
-		},
-	)
+	err = ch.Publish(exName, routingKey, false, false, msg)
 
 	if err != nil {
 		return fmt.Errorf("error in publishing message %w", err)
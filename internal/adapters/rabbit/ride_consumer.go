@@ -0,0 +1,31 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/ports"
+)
+
+// RideConsumer handles message consumption for Ride Service
+type RideConsumer struct {
+	rideService ports.RideService
+}
+
+func NewRideConsumer(rideService ports.RideService) *RideConsumer {
+	return &RideConsumer{
+		rideService: rideService,
+	}
+}
+
+// HandleRideStatus forwards ride status updates to the passenger
+func (rc *RideConsumer) HandleRideStatus(ctx context.Context, message []byte, routingKey string) error {
+	var update models.RideStatusUpdate
+	if err := json.Unmarshal(message, &update); err != nil {
+		return fmt.Errorf("failed to unmarshal ride status: %w", err)
+	}
+
+	return rc.rideService.NotifyRideStatus(ctx, update)
+}
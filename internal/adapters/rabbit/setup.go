@@ -2,9 +2,16 @@ package rabbit
 
 import (
 	"errors"
+	"ride-hail/internal/core/domain/types"
 	"ride-hail/pkg/rabbit"
+
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// DeadLetterExchange receives the messages consumers reject, e.g. unsigned or
+// replayed ones; they are kept in the dead_letters queue for inspection.
+const DeadLetterExchange = "dead_letter"
+
 func InitRabbitTopology(r *rabbit.Rabbit) error {
 	if r.Conn.IsClosed() {
 		return errors.New("connection is closed")
@@ -19,6 +26,10 @@ func InitRabbitTopology(r *rabbit.Rabbit) error {
 		{"location_fanout", "fanout"},
 	}
 
+	if err := r.SetupExchangesAndQueues(DeadLetterExchange, "fanout", []rabbit.QueueConfig{{Name: "dead_letters"}}); err != nil {
+		return err
+	}
+
 	for _, ex := range exchanges {
 		if err := r.SetupExchangesAndQueues(ex.Name, ex.Type, nil); err != nil {
 			return err
@@ -26,16 +37,31 @@ func InitRabbitTopology(r *rabbit.Rabbit) error {
 	}
 
 	rideQueues := []rabbit.QueueConfig{
-		{"ride_requests", "ride.request.*"},
-		{"ride_status", "ride.status.*"},
+		// ride_requests is a priority queue
+		{Name: "ride_requests", RoutingKey: "ride.request.*", Args: amqp.Table{"x-max-priority": int32(types.RidePriorityMax)}},
+		{Name: "ride_status", RoutingKey: "ride.status.*"},
+		{Name: "passenger_notifications", RoutingKey: "ride.status.*"},
 	}
 	driverQueues := []rabbit.QueueConfig{
-		{"driver_matching", "driver.request.*"},
-		{"driver_responses", "driver.response.*"},
-		{"driver_status", "driver.status.*"},
+		{Name: "driver_matching", RoutingKey: "driver.request.*"},
+		{Name: "driver_responses", RoutingKey: "driver.response.*"},
+		{Name: "driver_status", RoutingKey: "driver.status.*"},
+		{Name: "driver_notifications", RoutingKey: "driver.notification.*"},
 	}
 	locationQueues := []rabbit.QueueConfig{
-		{"location_updates_ride", ""},
+		{Name: "location_updates_ride", RoutingKey: ""},
+	}
+
+	// every queue dead-letters rejected messages. Queues declared before these
+	// arguments existed have to be deleted once, see the queue migration in
+	// README
+	for _, queues := range [][]rabbit.QueueConfig{rideQueues, driverQueues, locationQueues} {
+		for i := range queues {
+			if queues[i].Args == nil {
+				queues[i].Args = amqp.Table{}
+			}
+			queues[i].Args["x-dead-letter-exchange"] = DeadLetterExchange
+		}
 	}
 
 	if err := r.SetupExchangesAndQueues(exchanges[0].Name, exchanges[0].Type, rideQueues); err != nil {
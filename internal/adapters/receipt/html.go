@@ -0,0 +1,94 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+const (
+	mapWidth  = 480
+	mapHeight = 240
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.R.RideNumber}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 520px; margin: 24px auto; }
+h1 { font-size: 20px; margin-bottom: 4px; }
+.muted { color: #777; font-size: 13px; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+td { padding: 4px 0; border-bottom: 1px solid #eee; }
+td.amount { text-align: right; white-space: nowrap; }
+tr.strong td { font-weight: bold; border-top: 2px solid #222; }
+svg { background: #f4f6f8; border-radius: 4px; }
+</style>
+</head>
+<body>
+<h1>Ride receipt</h1>
+<div class="muted">{{.R.RideNumber}} &middot; {{.Completed}}</div>
+
+<table>
+<tr><td>From</td><td class="amount">{{.R.Pickup.Address}}</td></tr>
+<tr><td>To</td><td class="amount">{{.R.Destination.Address}}</td></tr>
+<tr><td>Requested</td><td class="amount">{{.Requested}}</td></tr>
+<tr><td>Picked up</td><td class="amount">{{.Started}}</td></tr>
+<tr><td>Dropped off</td><td class="amount">{{.Completed}}</td></tr>
+{{- if .R.Driver}}
+<tr><td>Driver license</td><td class="amount">{{.R.Driver.LicenseNumber}}</td></tr>
+<tr><td>Vehicle</td><td class="amount">{{.Vehicle}}</td></tr>
+{{- end}}
+</table>
+
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<polyline points="{{.Points}}" fill="none" stroke="#1a73e8" stroke-width="3" stroke-linejoin="round"/>
+</svg>
+
+<table>
+{{- range .Lines}}
+<tr{{if .Strong}} class="strong"{{end}}><td>{{.Label}}</td><td class="amount">{{.Amount}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func (rn *Renderer) HTML(r models.Receipt) ([]byte, error) {
+	requested := r.RequestedAt
+	data := struct {
+		R                             models.Receipt
+		Requested, Started, Completed string
+		Vehicle                       string
+		Lines                         []line
+		Width, Height                 int
+		Points                        string
+	}{
+		R:         r,
+		Requested: rn.formatTime(&requested),
+		Started:   rn.formatTime(r.StartedAt),
+		Completed: rn.formatTime(r.CompletedAt),
+		Vehicle:   vehicle(r.Driver),
+		Lines:     fareLines(r),
+		Width:     mapWidth,
+		Height:    mapHeight,
+	}
+
+	points := make([]string, 0, len(r.Route))
+	for _, p := range project(r.Route, mapWidth, mapHeight, 16) {
+		// svg y grows downwards
+		points = append(points, fmt.Sprintf("%.1f,%.1f", p[0], mapHeight-p[1]))
+	}
+	data.Points = strings.Join(points, " ")
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render html receipt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
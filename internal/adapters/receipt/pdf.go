@@ -0,0 +1,142 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// A4 in points
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+)
+
+// PDF renders a single-page PDF using the standard Helvetica fonts, so no
+// font files are embedded. Characters outside Latin-1 are replaced with '?'.
+func (rn *Renderer) PDF(r models.Receipt) ([]byte, error) {
+	var c pdfContent
+
+	y := float64(pageHeight - margin - 18)
+	c.text("F2", 18, margin, y, "Ride receipt")
+	y -= 20
+	c.text("F1", 10, margin, y, r.RideNumber)
+	y -= 28
+
+	requested := r.RequestedAt
+	info := [][2]string{
+		{"From", r.Pickup.Address},
+		{"To", r.Destination.Address},
+		{"Requested", rn.formatTime(&requested)},
+		{"Picked up", rn.formatTime(r.StartedAt)},
+		{"Dropped off", rn.formatTime(r.CompletedAt)},
+	}
+	if r.Driver != nil {
+		info = append(info, [2]string{"Driver license", r.Driver.LicenseNumber}, [2]string{"Vehicle", vehicle(r.Driver)})
+	}
+	for _, kv := range info {
+		c.text("F2", 10, margin, y, kv[0])
+		c.text("F1", 10, margin+110, y, kv[1])
+		y -= 15
+	}
+	y -= 15
+
+	for _, l := range fareLines(r) {
+		font := "F1"
+		if l.Strong {
+			font = "F2"
+			c.line(margin, y+12, pageWidth-margin, y+12)
+		}
+		c.text(font, 10, margin, y, l.Label)
+		c.textRight(font, 10, pageWidth-margin, y, l.Amount)
+		y -= 15
+	}
+
+	// route map
+	const mapH = 220
+	mapW := float64(pageWidth - 2*margin)
+	c.rect(margin, margin, mapW, mapH)
+	if pts := project(r.Route, mapW, mapH, 12); len(pts) > 1 {
+		c.polyline(margin, margin, pts)
+	}
+
+	return buildPDF(c.String()), nil
+}
+
+type pdfContent struct {
+	bytes.Buffer
+}
+
+func (c *pdfContent) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(c, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// textRight approximates Helvetica width as half the font size per character.
+func (c *pdfContent) textRight(font string, size, right, y float64, s string) {
+	c.text(font, size, right-float64(len(s))*size*0.5, y, s)
+}
+
+func (c *pdfContent) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(c, "0 G 1 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (c *pdfContent) rect(x, y, w, h float64) {
+	fmt.Fprintf(c, "0.96 0.97 0.97 rg %.2f %.2f %.2f %.2f re f\n", x, y, w, h)
+}
+
+func (c *pdfContent) polyline(x, y float64, pts [][2]float64) {
+	fmt.Fprintf(c, "0.1 0.45 0.91 RG 2.5 w 1 j %.2f %.2f m\n", x+pts[0][0], y+pts[0][1])
+	for _, p := range pts[1:] {
+		fmt.Fprintf(c, "%.2f %.2f l\n", x+p[0], y+p[1])
+	}
+	c.WriteString("S\n")
+}
+
+// pdfString escapes s for a PDF literal string in WinAnsiEncoding.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func buildPDF(content string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
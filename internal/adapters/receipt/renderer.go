@@ -0,0 +1,119 @@
+package receipt
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// Renderer renders receipts as HTML and PDF. Times are shown in loc.
+type Renderer struct {
+	loc *time.Location
+}
+
+func NewRenderer(loc *time.Location) *Renderer {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Renderer{
+		loc: loc,
+	}
+}
+
+type line struct {
+	Label  string
+	Amount string
+	Strong bool
+}
+
+// fareLines itemizes the receipt in display order.
+func fareLines(r models.Receipt) []line {
+	money := func(v float64) string {
+		return fmt.Sprintf("%.2f %s", v, r.Currency)
+	}
+
+	lines := make([]line, 0)
+	if f := r.Fare; f != nil {
+		lines = append(lines,
+			line{Label: "Base fare", Amount: money(f.BaseFare)},
+			line{Label: fmt.Sprintf("Distance (%.2f km)", f.DistanceKM), Amount: money(f.DistanceFare)},
+			line{Label: fmt.Sprintf("Time (%d min)", f.DurationMinutes), Amount: money(f.TimeFare)},
+		)
+		if f.Multiplier != 0 && f.Multiplier != 1 {
+			lines = append(lines, line{Label: fmt.Sprintf("%s rate x%.2f", f.Period, f.Multiplier), Amount: ""})
+		}
+		if f.WaitingFare > 0 {
+			lines = append(lines, line{Label: fmt.Sprintf("Waiting (%d min)", f.WaitingMinutes), Amount: money(f.WaitingFare)})
+		}
+		for _, e := range f.ExtraCharges {
+			label := e.Type
+			if e.Description != "" {
+				label += " - " + e.Description
+			}
+			lines = append(lines, line{Label: label, Amount: money(e.Amount)})
+		}
+		lines = append(lines, line{Label: "Subtotal", Amount: money(f.Subtotal)})
+		if f.Discount > 0 {
+			lines = append(lines, line{Label: "Promo " + f.PromoCode, Amount: money(-f.Discount)})
+		}
+	}
+	lines = append(lines, line{Label: "Fare", Amount: money(r.FinalFare)})
+	if r.Tip > 0 {
+		lines = append(lines, line{Label: "Tip", Amount: money(r.Tip)})
+	}
+	lines = append(lines, line{Label: "Total", Amount: money(r.Total), Strong: true})
+	return lines
+}
+
+func (rn *Renderer) formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.In(rn.loc).Format("2006-01-02 15:04")
+}
+
+func vehicle(d *models.ReceiptDriver) string {
+	if d == nil {
+		return "-"
+	}
+	v := d.VehicleType
+	for _, s := range []string{d.VehicleColor, d.VehicleMake, d.VehicleModel} {
+		if s != "" {
+			v += " " + s
+		}
+	}
+	if d.VehiclePlate != "" {
+		v += ", " + d.VehiclePlate
+	}
+	return v
+}
+
+// project maps route points into a w x h box with padding, x to the east and
+// y to the north. Longitude is scaled by cos(latitude) to keep the shape.
+func project(points []models.Point, w, h, pad float64) [][2]float64 {
+	if len(points) == 0 {
+		return nil
+	}
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	scale := math.Cos(points[0].Lat * math.Pi / 180)
+	for _, p := range points {
+		x, y := p.Lng*scale, p.Lat
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	spanX, spanY := maxX-minX, maxY-minY
+	k := math.Min((w-2*pad)/math.Max(spanX, 1e-9), (h-2*pad)/math.Max(spanY, 1e-9))
+	offX := (w - spanX*k) / 2
+	offY := (h - spanY*k) / 2
+
+	out := make([][2]float64, 0, len(points))
+	for _, p := range points {
+		out = append(out, [2]float64{offX + (p.Lng*scale-minX)*k, offY + (p.Lat-minY)*k})
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogFileSender appends text messages to a file instead of sending them, for
+// local development.
+type LogFileSender struct {
+	f  *os.File
+	mu sync.Mutex
+}
+
+func NewLogFileSender(path string) (*LogFileSender, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sms log: %w", err)
+	}
+	return &LogFileSender{
+		f: f,
+	}, nil
+}
+
+func (s *LogFileSender) SendSMS(ctx context.Context, phone, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.f, "%s to=%s %q\n", time.Now().UTC().Format(time.RFC3339), phone, text); err != nil {
+		return fmt.Errorf("failed to write sms log: %w", err)
+	}
+	return nil
+}
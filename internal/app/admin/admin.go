@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+
+	"ride-hail/config"
+	"ride-hail/internal/adapters/export"
+	"ride-hail/internal/adapters/http/handle"
+	"ride-hail/internal/adapters/http/server"
+	"ride-hail/internal/adapters/mailer"
+	"ride-hail/internal/adapters/payment"
+	"ride-hail/internal/adapters/postgres"
+	"ride-hail/internal/adapters/sms"
+	"ride-hail/internal/app/purge"
+	"ride-hail/internal/core/service"
+	"ride-hail/pkg/jwtkey"
+	"ride-hail/pkg/logger"
+	pg "ride-hail/pkg/potgres"
+	"ride-hail/pkg/scheduler"
+	"ride-hail/pkg/txm"
+)
+
+type AdminService struct {
+	server server.Server
+}
+
+func New(ctx context.Context, cfg config.Config) (*AdminService, error) {
+	log := logger.NewLogger(
+		cfg.Mode, logger.LoggerOptions{
+			Pretty: true,
+			Level:  slog.LevelDebug,
+		},
+	)
+	pg, err := pg.New(ctx, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	uRepo := postgres.NewRepo(pg.Pool)
+	dRepo := postgres.NewDriverRepository(pg.Pool)
+	rRepo := postgres.NewRideRepository(pg.Pool)
+	pRepo := postgres.NewPromoRepository(pg.Pool)
+	lgRepo := postgres.NewLedgerRepository(pg.Pool)
+	poRepo := postgres.NewPayoutRepository(pg.Pool)
+	iRepo := postgres.NewIdempotencyRepository(pg.Pool)
+	tkRepo := postgres.NewTokenRepository(pg.Pool)
+	laRepo := postgres.NewLoginAttemptRepository(pg.Pool)
+	otpRepo := postgres.NewOTPRepository(pg.Pool)
+
+	tmx := txm.NewTXManager(pg.Pool)
+
+	keys, err := jwtkey.New(cfg.JWT.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	smsSender, err := sms.NewLogFileSender(cfg.OTP.LogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	authServ := service.NewAuthService(cfg, tmx, uRepo, dRepo, tkRepo, laRepo, keys, mail, log)
+	otpServ, err := service.NewOTPService(log, cfg, otpRepo, uRepo, smsSender, authServ)
+	if err != nil {
+		return nil, err
+	}
+	promoServ := service.NewPromoService(log, pRepo, rRepo)
+	ledgerServ := service.NewLedgerService(log, lgRepo, payment.NewFakeProvider(), cfg.Commission)
+	payoutServ := service.NewPayoutService(log, tmx, poRepo, ledgerServ, export.NewPayoutCSV(cfg.Payouts.ExportDir), cfg.Payouts.MinAmount)
+
+	go scheduler.Every(ctx, cfg.Payouts.Interval, func(ctx context.Context) {
+		payoutServ.RunBatch(ctx)
+	})
+
+	go purge.Hourly(ctx, log, purge.Tables{
+		Idempotency:   iRepo,
+		Tokens:        tkRepo,
+		OTP:           otpRepo,
+		LoginAttempts: laRepo,
+		LoginWindow:   cfg.Login.FailureWindow,
+	})
+
+	authHandle := handle.New(cfg, authServ, otpServ, log)
+	adminHandle := handle.NewAdminHandle(promoServ, payoutServ, authServ, log)
+
+	serv, err := server.New(cfg, log, authHandle, nil, nil, adminHandle, iRepo, tkRepo, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminService{
+		server: serv,
+	}, nil
+}
+
+func (a *AdminService) Run() {
+	a.server.Run()
+}
+
+func (a *AdminService) Stop(ctx context.Context) error {
+	return a.server.Stop(ctx)
+}
@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"ride-hail/config"
+	"ride-hail/internal/adapters/http/handle/dto"
+	"ride-hail/internal/adapters/mailer"
+	"ride-hail/internal/adapters/postgres"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/service"
+	"ride-hail/pkg/jwtkey"
+	"ride-hail/pkg/logger"
+	pg "ride-hail/pkg/potgres"
+	"ride-hail/pkg/txm"
+)
+
+// Bootstrap creates an admin account with a verified email and returns. It is
+// how the first admin of an installation is created, later admins are added
+// through POST /admin/admins.
+func Bootstrap(ctx context.Context, cfg config.Config, req models.RegistrationRequest) error {
+	if ok, msg := dto.ValidateAdmin(&req); !ok {
+		return errors.New(msg)
+	}
+
+	log := logger.NewLogger(
+		cfg.Mode, logger.LoggerOptions{
+			Pretty: true,
+			Level:  slog.LevelInfo,
+		},
+	)
+	pg, err := pg.New(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pg.Pool.Close()
+
+	keys, err := jwtkey.New(cfg.JWT.Config)
+	if err != nil {
+		return err
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	authServ := service.NewAuthService(cfg, txm.NewTXManager(pg.Pool), postgres.NewRepo(pg.Pool), postgres.NewDriverRepository(pg.Pool),
+		postgres.NewTokenRepository(pg.Pool), postgres.NewLoginAttemptRepository(pg.Pool), keys, mail, log)
+	return authServ.BootstrapAdmin(ctx, req)
+}
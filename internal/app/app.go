@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"ride-hail/config"
+	"ride-hail/internal/app/admin"
+	dal "ride-hail/internal/app/drive"
 	"ride-hail/internal/app/ride"
 	"ride-hail/internal/core/domain/types"
 )
@@ -34,11 +36,12 @@ func (app *App) Start() {
 func initService(ctx context.Context, cfg config.Config) (Service, error) {
 	switch cfg.Mode {
 	case types.ModeAdmin:
+		return admin.New(ctx, cfg)
 	case types.ModeDAL:
+		return dal.New(ctx, cfg)
 	case types.ModeRide:
 		return ride.New(ctx, cfg)
 	default:
 		return nil, fmt.Errorf("unknown mode: %s", cfg.Mode)
 	}
-	return nil, nil
 }
@@ -2,18 +2,161 @@ package dal
 
 import (
 	"context"
+	"log/slog"
+	"time"
+	_ "time/tzdata"
 
 	"ride-hail/config"
+	"ride-hail/internal/adapters/http/handle"
+	"ride-hail/internal/adapters/http/server"
+	"ride-hail/internal/adapters/mailer"
+	"ride-hail/internal/adapters/payment"
+	"ride-hail/internal/adapters/postgres"
+	"ride-hail/internal/adapters/rabbit"
+	"ride-hail/internal/adapters/sms"
+	"ride-hail/internal/app/purge"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/service"
+	"ride-hail/internal/core/service/calculator"
+	"ride-hail/pkg/jwtkey"
+	"ride-hail/pkg/logger"
+	pg "ride-hail/pkg/potgres"
+	rb "ride-hail/pkg/rabbit"
+	"ride-hail/pkg/scheduler"
+	"ride-hail/pkg/txm"
+	"ride-hail/pkg/wsm"
 )
 
-type DriverService struct{}
+type DriverService struct {
+	server server.Server
+}
 
 func New(ctx context.Context, cfg config.Config) (*DriverService, error) {
-	return &DriverService{}, nil
+	log := logger.NewLogger(
+		cfg.Mode, logger.LoggerOptions{
+			Pretty: true,
+			Level:  slog.LevelDebug,
+		},
+	)
+	pg, err := pg.New(ctx, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	uRepo := postgres.NewRepo(pg.Pool)
+	dRepo := postgres.NewDriverRepository(pg.Pool)
+	lRepo := postgres.NewLocationRepository(pg.Pool)
+	rRepo := postgres.NewRideRepository(pg.Pool)
+	eRepo := postgres.NewRideEventRepository(pg.Pool)
+	cRepo := postgres.NewCordRepository(pg.Pool)
+	pRepo := postgres.NewPromoRepository(pg.Pool)
+	lgRepo := postgres.NewLedgerRepository(pg.Pool)
+	erRepo := postgres.NewEarningsRepository(pg.Pool)
+	sRepo := postgres.NewRideStopRepository(pg.Pool)
+	tRepo := postgres.NewTripRepository(pg.Pool)
+	iRepo := postgres.NewIdempotencyRepository(pg.Pool)
+	tkRepo := postgres.NewTokenRepository(pg.Pool)
+	laRepo := postgres.NewLoginAttemptRepository(pg.Pool)
+	otpRepo := postgres.NewOTPRepository(pg.Pool)
+	mrRepo := postgres.NewMessageReceiptRepository(pg.Pool)
+
+	rb, err := rb.New(cfg.RabbitMQ)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = rabbit.InitRabbitTopology(rb); err != nil {
+		return nil, err
+	}
+
+	signer, err := rb.NewSigner(cfg.Mode, mrRepo, types.ModeRide, types.ModeDAL)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := rabbit.NewPublisher(rb.Conn, signer)
+
+	tmx := txm.NewTXManager(pg.Pool)
+
+	keys, err := jwtkey.New(cfg.JWT.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	smsSender, err := sms.NewLogFileSender(cfg.OTP.LogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	wsM := wsm.NewWSManager()
+
+	loc, err := time.LoadLocation(cfg.Pricing.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	authServ := service.NewAuthService(cfg, tmx, uRepo, dRepo, tkRepo, laRepo, keys, mail, log)
+	otpServ, err := service.NewOTPService(log, cfg, otpRepo, uRepo, smsSender, authServ)
+	if err != nil {
+		return nil, err
+	}
+	promoServ := service.NewPromoService(log, pRepo, rRepo)
+	ledgerServ := service.NewLedgerService(log, lgRepo, payment.NewFakeProvider(), cfg.Commission)
+	dalServ := service.NewDalService(log, tmx, dRepo, lRepo, rRepo, eRepo, cRepo, sRepo, tRepo, pub, promoServ, ledgerServ, wsM,
+		calculator.PoolPolicy{MaxDetour: cfg.Pool.MaxDetour, MaxPickupKM: cfg.Pool.MaxPickupKM},
+		service.MatchPolicy{
+			RadiusKM:       cfg.Matching.RadiusKM,
+			OfferDrivers:   cfg.Matching.OfferDrivers,
+			CancelWindow:   cfg.Matching.CancelWindow,
+			MaxCancelRate:  cfg.Matching.MaxCancelRate,
+			CancelMinRides: cfg.Matching.CancelMinRides,
+			BlockFor:       cfg.Matching.BlockFor,
+		}, loc)
+	earningsServ := service.NewEarningsService(log, erRepo, dRepo, loc)
+
+	go scheduler.Every(ctx, time.Minute, ledgerServ.CollectPendingCharges)
+
+	go purge.Hourly(ctx, log, purge.Tables{
+		Idempotency:   iRepo,
+		Tokens:        tkRepo,
+		OTP:           otpRepo,
+		Receipts:      mrRepo,
+		LoginAttempts: laRepo,
+		LoginWindow:   cfg.Login.FailureWindow,
+	})
+
+	authHandle := handle.New(cfg, authServ, otpServ, log)
+	dalHandle := handle.NewDalHandle(dalServ, earningsServ, wsM, log)
+
+	serv, err := server.New(cfg, log, authHandle, nil, dalHandle, nil, iRepo, tkRepo, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	dalConsumer := rabbit.NewDALConsumer(dalServ, pub)
+
+	cm := rabbit.NewConsumerManager(signer, log)
+	if err = cm.StartDriverNotificationConsumer(ctx, rb, dalConsumer); err != nil {
+		return nil, err
+	}
+	if err = cm.StartDALConsumers(ctx, rb, dalConsumer); err != nil {
+		return nil, err
+	}
+
+	return &DriverService{
+		server: serv,
+	}, nil
 }
 
-func (r *DriverService) Run() error {
-	return nil
+func (r *DriverService) Run() {
+	r.server.Run()
 }
 
-func (r *DriverService) Stop() {}
+func (r *DriverService) Stop(ctx context.Context) error {
+	return r.server.Stop(ctx)
+}
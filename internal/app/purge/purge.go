@@ -0,0 +1,54 @@
+// Package purge deletes the expired rows of the tables that every service
+// keeps: idempotency keys, tokens, OTP codes, message receipts and login
+// failures.
+package purge
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/adapters/postgres"
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/scheduler"
+)
+
+// Tables are the repositories to purge. Receipts is nil in services that
+// consume no messages. Login failures are kept for LoginWindow.
+type Tables struct {
+	Idempotency   *postgres.IdempotencyRepository
+	Tokens        *postgres.TokenRepository
+	OTP           *postgres.OTPRepository
+	Receipts      *postgres.MessageReceiptRepository
+	LoginAttempts *postgres.LoginAttemptRepository
+	LoginWindow   time.Duration
+}
+
+type job struct {
+	fn, action, msg string
+	run             func(ctx context.Context) error
+}
+
+// Hourly purges the tables once an hour until ctx is cancelled. A failed
+// purge of one table is logged and does not stop the others.
+func Hourly(ctx context.Context, log *logger.Logger, t Tables) {
+	jobs := []job{
+		{"idempotency.purge", action.Idempotency, "failed to purge idempotency keys", t.Idempotency.DeleteExpired},
+		{"tokens.purge", action.Authorization, "failed to purge expired tokens", t.Tokens.DeleteExpired},
+		{"otp.purge", action.OTPLogin, "failed to purge otp codes", t.OTP.DeleteExpired},
+	}
+	if t.Receipts != nil {
+		jobs = append(jobs, job{"receipts.purge", action.MessageReceipts, "failed to purge message receipts", t.Receipts.DeleteExpired})
+	}
+	jobs = append(jobs, job{"login.purge", action.LoginLockout, "failed to purge login failures", func(ctx context.Context) error {
+		return t.LoginAttempts.DeleteExpired(ctx, time.Now().Add(-t.LoginWindow))
+	}})
+
+	scheduler.Every(ctx, time.Hour, func(ctx context.Context) {
+		for _, j := range jobs {
+			if err := j.run(ctx); err != nil {
+				log.Func(j.fn).Error(ctx, j.action, j.msg, "error", err)
+			}
+		}
+	})
+}
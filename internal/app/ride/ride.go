@@ -3,14 +3,27 @@ package ride
 import (
 	"context"
 	"log/slog"
+	"time"
+	_ "time/tzdata"
 
 	"ride-hail/internal/adapters/http/handle"
 	"ride-hail/internal/adapters/http/server"
+	"ride-hail/internal/adapters/mailer"
+	"ride-hail/internal/adapters/osrm"
+	"ride-hail/internal/adapters/payment"
 	"ride-hail/internal/adapters/postgres"
 	"ride-hail/internal/adapters/rabbit"
+	"ride-hail/internal/adapters/receipt"
+	"ride-hail/internal/adapters/sms"
+	"ride-hail/internal/app/purge"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
 	"ride-hail/internal/core/service"
+	"ride-hail/internal/core/service/calculator"
+	"ride-hail/pkg/jwtkey"
 	"ride-hail/pkg/logger"
 	rb "ride-hail/pkg/rabbit"
+	"ride-hail/pkg/scheduler"
 	"ride-hail/pkg/txm"
 	"ride-hail/pkg/wsm"
 
@@ -37,6 +50,18 @@ func New(ctx context.Context, cfg config.Config) (*RideService, error) {
 	uRepo := postgres.NewRepo(pg.Pool)
 	cRepo := postgres.NewCordRepository(pg.Pool)
 	rRepo := postgres.NewRideRepository(pg.Pool)
+	pRepo := postgres.NewPromoRepository(pg.Pool)
+	eRepo := postgres.NewRideEventRepository(pg.Pool)
+	dRepo := postgres.NewDriverRepository(pg.Pool)
+	lgRepo := postgres.NewLedgerRepository(pg.Pool)
+	lRepo := postgres.NewLocationRepository(pg.Pool)
+	sRepo := postgres.NewRideStopRepository(pg.Pool)
+	tRepo := postgres.NewTripRepository(pg.Pool)
+	iRepo := postgres.NewIdempotencyRepository(pg.Pool)
+	tkRepo := postgres.NewTokenRepository(pg.Pool)
+	laRepo := postgres.NewLoginAttemptRepository(pg.Pool)
+	otpRepo := postgres.NewOTPRepository(pg.Pool)
+	mrRepo := postgres.NewMessageReceiptRepository(pg.Pool)
 
 	rb, err := rb.New(cfg.RabbitMQ)
 	if err != nil {
@@ -47,28 +72,107 @@ func New(ctx context.Context, cfg config.Config) (*RideService, error) {
 		return nil, err
 	}
 
-	rPub := rabbit.NewPublisher(rb.Conn)
+	signer, err := rb.NewSigner(cfg.Mode, mrRepo, types.ModeRide, types.ModeDAL)
+	if err != nil {
+		return nil, err
+	}
+
+	rPub := rabbit.NewPublisher(rb.Conn, signer)
 
 	tmx := txm.NewTXManager(pg.Pool)
 
+	keys, err := jwtkey.New(cfg.JWT.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	smsSender, err := sms.NewLogFileSender(cfg.OTP.LogFile)
+	if err != nil {
+		return nil, err
+	}
+
 	wsM := wsm.NewWSManager()
 
-	authServ := service.NewAuthService(cfg, uRepo, log)
-	rideServ := service.NewRideService(log, tmx, rRepo, cRepo, rPub, wsM)
+	loc, err := time.LoadLocation(cfg.Pricing.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	authServ := service.NewAuthService(cfg, tmx, uRepo, dRepo, tkRepo, laRepo, keys, mail, log)
+	otpServ, err := service.NewOTPService(log, cfg, otpRepo, uRepo, smsSender, authServ)
+	if err != nil {
+		return nil, err
+	}
+	promoServ := service.NewPromoService(log, pRepo, rRepo)
+	ledgerServ := service.NewLedgerService(log, lgRepo, payment.NewFakeProvider(), cfg.Commission)
+	receiptServ := service.NewReceiptService(log, rRepo, cRepo, dRepo, lRepo, eRepo, uRepo, receipt.NewRenderer(loc), mail)
+	rideServ := service.NewRideService(log, tmx, rRepo, cRepo, eRepo, dRepo, sRepo, tRepo, rPub, wsM, newRouteProvider(cfg), promoServ, ledgerServ, receiptServ,
+		service.SchedulePolicy{
+			LeadTime:        cfg.Scheduling.LeadTime,
+			ReminderBefore:  cfg.Scheduling.ReminderBefore,
+			MinAdvance:      cfg.Scheduling.MinAdvance,
+			MaxAdvance:      cfg.Scheduling.MaxAdvance,
+			CancellationFee: cfg.Scheduling.CancellationFee,
+		},
+		service.MatchPolicy{RedispatchAfter: cfg.Matching.RedispatchAfter})
+
+	go scheduler.Every(ctx, cfg.Scheduling.PollInterval, func(ctx context.Context) {
+		rideServ.SendRideReminders(ctx)
+		rideServ.DispatchScheduledRides(ctx)
+		rideServ.RedispatchWaitingRides(ctx)
+	})
+
+	go scheduler.Every(ctx, time.Minute, ledgerServ.CollectPendingCharges)
+
+	go purge.Hourly(ctx, log, purge.Tables{
+		Idempotency:   iRepo,
+		Tokens:        tkRepo,
+		OTP:           otpRepo,
+		Receipts:      mrRepo,
+		LoginAttempts: laRepo,
+		LoginWindow:   cfg.Login.FailureWindow,
+	})
 
-	authHandle := handle.New(cfg, authServ, log)
-	rideHandle := handle.NewRideHandle(rideServ, wsM, log)
+	authHandle := handle.New(cfg, authServ, otpServ, log)
+	rideHandle := handle.NewRideHandle(rideServ, receiptServ, wsM, log)
 
-	serv, err := server.New(cfg, log, authHandle, rideHandle, nil, nil)
+	serv, err := server.New(cfg, log, authHandle, rideHandle, nil, nil, iRepo, tkRepo, keys)
 	if err != nil {
 		return nil, err
 	}
 
+	cm := rabbit.NewConsumerManager(signer, log)
+	if err = cm.StartRideConsumers(ctx, rb, rabbit.NewRideConsumer(rideServ)); err != nil {
+		return nil, err
+	}
+
 	return &RideService{
 		server: serv,
 	}, nil
 }
 
+func newRouteProvider(cfg config.Config) ports.RouteProvider {
+	haversine := calculator.NewHaversineRouter()
+	if cfg.Routing.Provider != "osrm" {
+		return haversine
+	}
+	return calculator.NewCachedRouter(
+		osrm.NewRouteProvider(cfg.Routing.OSRMURL, cfg.Routing.Timeout),
+		haversine,
+		calculator.RouterOptions{
+			Timeout:   cfg.Routing.Timeout,
+			CacheTTL:  cfg.Routing.CacheTTL,
+			CacheSize: cfg.Routing.CacheSize,
+			Precision: cfg.Routing.CachePrecision,
+		},
+	)
+}
+
 func (r *RideService) Run() {
 	r.server.Run()
 }
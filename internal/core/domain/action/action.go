@@ -3,16 +3,68 @@ package action
 var (
 	Registration     = "registration"
 	Login            = "login"
+	Logout           = "logout"
+	RefreshToken     = "refresh token"
+	LoginLockout     = "login lockout"
+	UnlockUser       = "unlock user"
+	VerifyEmail      = "verify email"
+	ResetPassword    = "reset password"
+	OTPLogin         = "otp login"
 	StartApplication = "start application"
 	StopApplication  = "stop application"
 )
 
 var (
-	Authorization = "authorization"
+	Authorization   = "authorization"
+	Idempotency     = "idempotency"
+	MessageReceipts = "message receipts"
+	ConsumeMessage  = "consume message"
 )
 
 var (
-	CreateRide  = "create ride"
-	CloseRide   = "close ride"
-	WSPassenger = "ws passenger"
+	CreateRide     = "create ride"
+	CloseRide      = "close ride"
+	WSPassenger    = "ws passenger"
+	RideStatus     = "ride status"
+	TipDriver      = "tip driver"
+	ScheduledRide  = "scheduled ride"
+	ChangeStops    = "change stops"
+	RedispatchRide = "redispatch ride"
+	GetRide        = "get ride"
+)
+
+var (
+	DriverOnline   = "driver online"
+	DriverOffline  = "driver offline"
+	UpdateLocation = "update location"
+	AcceptRide     = "accept ride"
+	DriverArrived  = "driver arrived"
+	StartRide      = "start ride"
+	CompleteRide   = "complete ride"
+	WSDriver       = "ws driver"
+	NotifyDriver   = "notify driver"
+	RideStop       = "ride stop"
+	MatchPool      = "match pool"
+	MatchRide      = "match ride"
+	DriverCancel   = "driver cancel"
+)
+
+var (
+	CreatePromo = "create promo"
+	ApplyPromo  = "apply promo"
+)
+
+var (
+	PostLedger   = "post ledger"
+	DriverLedger = "driver ledger"
+)
+
+var (
+	DriverEarnings = "driver earnings"
+	RunPayouts     = "run payouts"
+	ExportPayouts  = "export payouts"
+)
+
+var (
+	Receipt = "receipt"
 )
@@ -19,6 +19,17 @@ type Driver struct {
 	IsVerified    bool            `db:"is_verified"`    // boolean
 }
 
+// AvailableDriver is an AVAILABLE driver with the last known location. Distance
+// is the distance to the pickup in km, filled in by matching.
+type AvailableDriver struct {
+	ID           string
+	VehicleType  string
+	VehicleAttrs json.RawMessage
+	Latitude     float64
+	Longitude    float64
+	Distance     float64
+}
+
 type DriverSession struct {
 	ID            string     `db:"id"`             // uuid
 	DriverID      string     `db:"driver_id"`      // uuid
@@ -40,3 +51,83 @@ type LocationHistory struct {
 	RecordedAt     time.Time `db:"recorded_at"`     // timestamptz
 	RideID         *string   `db:"ride_id"`         // uuid nullable
 }
+
+type DriverOnlineRequest struct {
+	DriverID  string  `json:"-"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type DriverOnlineResponse struct {
+	Status    string `json:"status"`
+	SessionID string `json:"session_id"`
+	Message   string `json:"message"`
+}
+
+type DriverOfflineResponse struct {
+	Status         string  `json:"status"`
+	SessionID      string  `json:"session_id"`
+	DurationHours  float64 `json:"duration_hours"`
+	RidesCompleted int     `json:"rides_completed"`
+	Earnings       float64 `json:"earnings"`
+	Message        string  `json:"message"`
+}
+
+type LocationUpdateRequest struct {
+	DriverID       string   `json:"-"`
+	Latitude       float64  `json:"latitude"`
+	Longitude      float64  `json:"longitude"`
+	AccuracyMeters *float64 `json:"accuracy_meters"`
+	SpeedKmh       *float64 `json:"speed_kmh"`
+	HeadingDegrees *float64 `json:"heading_degrees"`
+}
+
+type LocationUpdateResponse struct {
+	CoordinateID string    `json:"coordinate_id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type DriverRideRequest struct {
+	DriverID string `json:"-"`
+	RideID   string `json:"ride_id"`
+}
+
+type DriverRideResponse struct {
+	RideID    string    `json:"ride_id"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Message   string    `json:"message"`
+}
+
+type DriverCancelRequest struct {
+	DriverID string `json:"-"`
+	RideID   string `json:"-"`
+	Reason   string `json:"reason"`
+}
+
+type DriverCancelResponse struct {
+	RideID           string     `json:"ride_id"`
+	Status           string     `json:"status"`
+	CancellationRate float64    `json:"cancellation_rate"`
+	BlockedUntil     *time.Time `json:"blocked_until,omitempty"`
+	Message          string     `json:"message"`
+}
+
+type CompleteRideRequest struct {
+	DriverID              string        `json:"-"`
+	RideID                string        `json:"ride_id"`
+	ActualDistanceKm      float64       `json:"actual_distance_km"`
+	ActualDurationMinutes int           `json:"actual_duration_minutes"`
+	ExtraCharges          []ExtraCharge `json:"extra_charges"`
+}
+
+type CompleteRideResponse struct {
+	RideID         string        `json:"ride_id"`
+	Status         string        `json:"status"`
+	CompletedAt    time.Time     `json:"completed_at"`
+	FinalFare      float64       `json:"final_fare"`
+	Fare           FareBreakdown `json:"fare"`
+	Commission     float64       `json:"commission"`
+	DriverEarnings float64       `json:"driver_earnings"`
+	Message        string        `json:"message"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// ErrorResponse is the JSON body of authorization errors.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,26 @@
+package models
+
+type ExtraCharge struct {
+	Type        string  `json:"type"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description,omitempty"`
+}
+
+type FareBreakdown struct {
+	RideType        string        `json:"ride_type"`
+	BaseFare        float64       `json:"base_fare"`
+	DistanceKM      float64       `json:"distance_km"`
+	DistanceFare    float64       `json:"distance_fare"`
+	DurationMinutes int           `json:"duration_minutes"`
+	TimeFare        float64       `json:"time_fare"`
+	Period          string        `json:"period"`
+	Multiplier      float64       `json:"multiplier"`
+	WaitingMinutes  int           `json:"waiting_minutes"`
+	WaitingFare     float64       `json:"waiting_fare"`
+	ExtraCharges    []ExtraCharge `json:"extra_charges"`
+	ExtrasTotal     float64       `json:"extras_total"`
+	Subtotal        float64       `json:"subtotal"`
+	PromoCode       string        `json:"promo_code,omitempty"`
+	Discount        float64       `json:"discount"`
+	Total           float64       `json:"total"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is a request sent with an Idempotency-Key and, once its
+// handler has finished, the response to replay. StatusCode is zero while the
+// request is in progress. Token identifies the reservation of the request
+// that holds the key.
+type IdempotencyRecord struct {
+	UserID      string
+	Key         string
+	Token       string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type Claims struct {
 	ClaimsID string `json:"claims_id"`
@@ -8,3 +12,74 @@ type Claims struct {
 	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
+
+// AccountTokenClaims are the claims of an email verification or password
+// reset token. Subject is the user id, ID the jti of the stored AccountToken.
+type AccountTokenClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// AccountToken is the stored state of a single-use account token.
+type AccountToken struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+// TokenPair is issued on login and on every refresh.
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept.
+type RefreshToken struct {
+	ID             string
+	UserID         string
+	FamilyID       string
+	TokenHash      string
+	AccessClaimsID string
+	ExpiresAt      time.Time
+	UsedAt         *time.Time
+	RevokedAt      *time.Time
+}
+
+// LoginRequest is the body of POST /login. Clients without a cookie jar set
+// ReturnTokens to get the tokens in the response body.
+type LoginRequest struct {
+	User
+	ReturnTokens bool `json:"return_tokens"`
+}
+
+// RefreshRequest carries the refresh token of clients that do not use cookies.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	Message      string `json:"message"`
+	AccessToken  string `json:"access_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// EmailRequest is the body of POST /password/forgot and POST /email/verify/resend.
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// AccountTokenRequest is the body of POST /email/verify.
+type AccountTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ResetPasswordRequest is the body of POST /password/reset.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type LedgerAccount struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	OwnerID   *string   `json:"owner_id"`
+	Currency  string    `json:"currency"`
+}
+
+// LedgerEntry amount is positive for a debit and negative for a credit.
+type LedgerEntry struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	TransactionID string    `json:"transaction_id"`
+	AccountID     string    `json:"account_id"`
+	Amount        float64   `json:"amount"`
+}
+
+type LedgerTransaction struct {
+	ID          string        `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Kind        string        `json:"kind"`
+	RideID      *string       `json:"ride_id"`
+	Reference   string        `json:"reference,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Entries     []LedgerEntry `json:"entries,omitempty"`
+	// ChargeAmount is charged to ChargeCustomerID by the payment provider
+	// after the transaction commits; both are empty when nothing is charged
+	ChargeCustomerID string  `json:"-"`
+	ChargeAmount     float64 `json:"charge_amount,omitempty"`
+	ChargeStatus     string  `json:"charge_status,omitempty"`
+}
+
+// AccountStatementLine is one entry of an account with its transaction.
+type AccountStatementLine struct {
+	EntryID       string    `json:"entry_id"`
+	TransactionID string    `json:"transaction_id"`
+	Kind          string    `json:"kind"`
+	RideID        *string   `json:"ride_id"`
+	Description   string    `json:"description,omitempty"`
+	Amount        float64   `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AccountBalance struct {
+	AccountID string  `json:"account_id"`
+	Type      string  `json:"type"`
+	OwnerID   string  `json:"owner_id"`
+	Balance   float64 `json:"balance"`
+	Currency  string  `json:"currency"`
+}
+
+type RidePayment struct {
+	TransactionID  string  `json:"transaction_id"`
+	Charged        float64 `json:"charged"`
+	Commission     float64 `json:"commission"`
+	CommissionPct  float64 `json:"commission_pct"`
+	DriverEarnings float64 `json:"driver_earnings"`
+	PromoFunded    float64 `json:"promo_funded"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginAuditEntry is a row of the auth audit log. Subject is the throttled
+// key, "account:<email>" or "ip:<address>".
+type LoginAuditEntry struct {
+	Event       string
+	Subject     string
+	UserID      string
+	ActorID     string
+	IP          string
+	Failures    int
+	LockedUntil *time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// OTPCode is a one-time passcode sent to a phone. Only the hash is stored.
+type OTPCode struct {
+	Phone     string
+	CodeHash  string
+	ExpiresAt time.Time
+}
+
+// OTPRequest is the body of POST /auth/otp/request.
+type OTPRequest struct {
+	Phone string `json:"phone"`
+}
+
+// OTPVerifyRequest is the body of POST /auth/otp/verify. ReturnTokens works as
+// in LoginRequest.
+type OTPVerifyRequest struct {
+	Phone        string `json:"phone"`
+	Code         string `json:"code"`
+	ReturnTokens bool   `json:"return_tokens"`
+}
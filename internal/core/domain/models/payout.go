@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type EarningsSummary struct {
+	PeriodStart time.Time `json:"period_start"`
+	Rides       int       `json:"rides"`
+	GrossFares  float64   `json:"gross_fares"`
+	Commission  float64   `json:"commission"`
+	Tips        float64   `json:"tips"`
+	Net         float64   `json:"net"`
+}
+
+type EarningsStatement struct {
+	DriverID               string            `json:"driver_id"`
+	Period                 string            `json:"period"`
+	From                   time.Time         `json:"from"`
+	To                     time.Time         `json:"to"`
+	Periods                []EarningsSummary `json:"periods"`
+	Totals                 EarningsSummary   `json:"totals"`
+	LifetimeEarnings       float64           `json:"lifetime_earnings"`
+	CurrentSessionEarnings float64           `json:"current_session_earnings"`
+}
+
+type RideEarning struct {
+	RideID      string    `json:"ride_id"`
+	RideNumber  string    `json:"ride_number"`
+	CompletedAt time.Time `json:"completed_at"`
+	FinalFare   float64   `json:"final_fare"`
+	Discount    float64   `json:"discount"`
+	GrossFare   float64   `json:"gross_fare"`
+	Commission  float64   `json:"commission"`
+	Tips        float64   `json:"tips"`
+	Net         float64   `json:"net"`
+}
+
+type PayoutBatch struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	TotalAmount  float64   `json:"total_amount"`
+	DriversCount int       `json:"drivers_count"`
+	ExportPath   string    `json:"export_path,omitempty"`
+}
+
+type Payout struct {
+	ID                  string    `json:"id"`
+	CreatedAt           time.Time `json:"created_at"`
+	BatchID             string    `json:"batch_id"`
+	DriverID            string    `json:"driver_id"`
+	Amount              float64   `json:"amount"`
+	Status              string    `json:"status"`
+	LedgerTransactionID string    `json:"ledger_transaction_id"`
+}
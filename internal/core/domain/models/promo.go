@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type PromoCode struct {
+	ID            string     `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	Value         float64    `json:"value"`
+	MaxDiscount   *float64   `json:"max_discount"`
+	UsageLimit    *int       `json:"usage_limit"`
+	PerUserLimit  int        `json:"per_user_limit"`
+	UsedCount     int        `json:"used_count"`
+	ValidFrom     time.Time  `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	FirstRideOnly bool       `json:"first_ride_only"`
+	RideTypes     []string   `json:"ride_types"`
+	IsActive      bool       `json:"is_active"`
+}
+
+type PromoQuote struct {
+	PromoCodeID string  `json:"-"`
+	Code        string  `json:"code"`
+	Discount    float64 `json:"discount"`
+}
+
+type PromoRedemption struct {
+	ID             string    `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	PromoCodeID    string    `json:"promo_code_id"`
+	UserID         string    `json:"user_id"`
+	RideID         string    `json:"ride_id"`
+	DiscountAmount float64   `json:"discount_amount"`
+}
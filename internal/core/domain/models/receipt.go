@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type ReceiptLocation struct {
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type ReceiptDriver struct {
+	ID            string  `json:"id"`
+	LicenseNumber string  `json:"license_number"`
+	Rating        float64 `json:"rating"`
+	VehicleType   string  `json:"vehicle_type"`
+	VehicleMake   string  `json:"vehicle_make,omitempty"`
+	VehicleModel  string  `json:"vehicle_model,omitempty"`
+	VehicleColor  string  `json:"vehicle_color,omitempty"`
+	VehiclePlate  string  `json:"vehicle_plate,omitempty"`
+}
+
+type Receipt struct {
+	RideID      string          `json:"ride_id"`
+	RideNumber  string          `json:"ride_number"`
+	PassengerID string          `json:"passenger_id"`
+	RideType    string          `json:"ride_type"`
+	Pickup      ReceiptLocation `json:"pickup"`
+	Destination ReceiptLocation `json:"destination"`
+	Driver      *ReceiptDriver  `json:"driver,omitempty"`
+	RequestedAt time.Time       `json:"requested_at"`
+	MatchedAt   *time.Time      `json:"matched_at,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Fare        *FareBreakdown  `json:"fare,omitempty"`
+	FinalFare   float64         `json:"final_fare"`
+	Tip         float64         `json:"tip"`
+	Total       float64         `json:"total"`
+	Currency    string          `json:"currency"`
+	Route       []Point         `json:"route"`
+	Polyline    string          `json:"polyline"`
+	IssuedAt    time.Time       `json:"issued_at"`
+}
+
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Email struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []EmailAttachment
+}
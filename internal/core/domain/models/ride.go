@@ -3,46 +3,127 @@ package models
 import "time"
 
 type CreateRideRequest struct {
-	PassengerID          string  `json:"passenger_id"`
-	PickupLatitude       float64 `json:"pickup_latitude"`
-	PickupLongitude      float64 `json:"pickup_longitude"`
-	PickupAddress        string  `json:"pickup_address"`
-	DestinationLatitude  float64 `json:"destination_latitude"`
-	DestinationLongitude float64 `json:"destination_longitude"`
-	DestinationAddress   string  `json:"destination_address"`
-	RideType             string  `json:"ride_type"`
+	PassengerID          string        `json:"passenger_id"`
+	PickupLatitude       float64       `json:"pickup_latitude"`
+	PickupLongitude      float64       `json:"pickup_longitude"`
+	PickupAddress        string        `json:"pickup_address"`
+	DestinationLatitude  float64       `json:"destination_latitude"`
+	DestinationLongitude float64       `json:"destination_longitude"`
+	DestinationAddress   string        `json:"destination_address"`
+	RideType             string        `json:"ride_type"`
+	PromoCode            string        `json:"promo_code,omitempty"`
+	ScheduledAt          *time.Time    `json:"scheduled_at,omitempty"`
+	Stops                []StopRequest `json:"stops,omitempty"`
+	Seats                int           `json:"seats,omitempty"`
 }
 
 type Ride struct {
-	ID                      string    `json:"id"`
-	CreatedAt               time.Time `json:"created_at"`
-	UpdatedAt               time.Time `json:"updated_at"`
-	RideNumber              string    `json:"ride_number"`
-	PassengerID             string    `json:"passenger_id"`
-	DriverID                string    `json:"driver_id"`
-	VehicleType             string    `json:"vehicle_type"`
-	Status                  string    `json:"status"`
-	Priority                int       `json:"priority"`
-	RequestedAt             time.Time `json:"requested_at"`
-	MatchedAt               time.Time `json:"matched_at"`
-	ArrivedAt               time.Time `json:"arrival_at"`
-	StartedAt               time.Time `json:"started_at"`
-	CompletedAt             time.Time `json:"completed_at"`
-	CancelledAt             time.Time `json:"cancelled_at"`
-	CancellationReason      string    `json:"cancellation_reason"`
-	EstimatedFare           float64   `json:"estimated_fare"`
-	FinalFare               float64   `json:"final_fare"`
-	PickupCoordinateId      string    `json:"pickup_coordinate_id"`
-	DestinationCoordinateId string    `json:"destination_coordinate_id"`
+	ID                      string     `json:"id"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+	RideNumber              string     `json:"ride_number"`
+	PassengerID             string     `json:"passenger_id"`
+	DriverID                string     `json:"driver_id"`
+	VehicleType             string     `json:"vehicle_type"`
+	Status                  string     `json:"status"`
+	Priority                int        `json:"priority"`
+	RequestedAt             time.Time  `json:"requested_at"`
+	MatchedAt               *time.Time `json:"matched_at"`
+	ArrivedAt               *time.Time `json:"arrival_at"`
+	StartedAt               *time.Time `json:"started_at"`
+	CompletedAt             *time.Time `json:"completed_at"`
+	CancelledAt             *time.Time `json:"cancelled_at"`
+	CancellationReason      string     `json:"cancellation_reason"`
+	EstimatedFare           float64    `json:"estimated_fare"`
+	FinalFare               float64    `json:"final_fare"`
+	PickupCoordinateId      string     `json:"pickup_coordinate_id"`
+	DestinationCoordinateId string     `json:"destination_coordinate_id"`
+	PromoCodeID             *string    `json:"promo_code_id"`
+	DiscountAmount          float64    `json:"discount_amount"`
+	ScheduledAt             *time.Time `json:"scheduled_at,omitempty"`
+	TripID                  *string    `json:"trip_id,omitempty"`
+	Seats                   int        `json:"seats"`
+}
+
+// Location is an address with its coordinates.
+type Location struct {
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// RideDetail is a ride with its pickup, destination and stops joined in.
+type RideDetail struct {
+	Ride
+	Pickup      Location   `json:"pickup"`
+	Destination Location   `json:"destination"`
+	Stops       []RideStop `json:"stops,omitempty"`
+}
+
+// RideFilter selects a passenger's rides, newest first. From and To bound
+// requested_at; rides after the cursor position are returned.
+type RideFilter struct {
+	PassengerID string
+	Status      string
+	From        time.Time
+	To          time.Time
+	Cursor      string
+	Limit       int
+}
+
+// RideCursor is the keyset position of the last ride on a page.
+type RideCursor struct {
+	RequestedAt time.Time
+	ID          string
+}
+
+type RidePage struct {
+	Rides      []RideDetail `json:"rides"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
 type CreateRideResponse struct {
-	RideID                   string  `json:"ride_id"`
-	RideNumber               string  `json:"ride_number"`
-	Status                   string  `json:"status"`
-	EstimatedFare            float64 `json:"estimated_fare"`
-	EstimatedDurationMinutes int     `json:"estimated_duration_minutes"`
-	EstimatedDistanceKm      float64 `json:"estimated_distance_km"`
+	RideID                   string     `json:"ride_id"`
+	RideNumber               string     `json:"ride_number"`
+	Status                   string     `json:"status"`
+	EstimatedFare            float64    `json:"estimated_fare"`
+	EstimatedDurationMinutes int        `json:"estimated_duration_minutes"`
+	EstimatedDistanceKm      float64    `json:"estimated_distance_km"`
+	PromoCode                string     `json:"promo_code,omitempty"`
+	DiscountAmount           float64    `json:"discount_amount,omitempty"`
+	EstimatedFareDiscounted  float64    `json:"estimated_fare_discounted,omitempty"`
+	ScheduledAt              *time.Time `json:"scheduled_at,omitempty"`
+	Stops                    []RideStop `json:"stops,omitempty"`
+}
+
+// ActiveRideConflict is the 409 body returned when a ride is refused because
+// another one is still active.
+type ActiveRideConflict struct {
+	Error  string `json:"error"`
+	RideID string `json:"ride_id"`
+}
+
+// MessageLocation is a point of a ride in broker messages.
+type MessageLocation struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Address string  `json:"address"`
+}
+
+// RideRequestMessage is published to ride_topic with routing key
+// ride.request.{ride_type} for driver matching.
+type RideRequestMessage struct {
+	RideID              string          `json:"ride_id"`
+	RideNumber          string          `json:"ride_number"`
+	PickupLocation      MessageLocation `json:"pickup_location"`
+	DestinationLocation MessageLocation `json:"destination_location"`
+	Stops               []RideStop      `json:"stops,omitempty"`
+	RideType            string          `json:"ride_type"`
+	EstimatedFare       float64         `json:"estimated_fare"`
+	MaxDistanceKM       float64         `json:"max_distance_km"`
+	Seats               int             `json:"seats"`
+	TimeoutSeconds      int             `json:"timeout_seconds"`
+	CorrelationID       string          `json:"correlation_id"`
 }
 
 type CloseRideRequest struct {
@@ -51,8 +132,23 @@ type CloseRideRequest struct {
 }
 
 type CloseRideResponse struct {
-	RideID      string    `json:"ride_id"`
-	Status      string    `json:"status"`
-	CancelledAt time.Time `json:"cancelled_at"`
-	Message     string    `json:"message"`
+	RideID          string    `json:"ride_id"`
+	Status          string    `json:"status"`
+	CancelledAt     time.Time `json:"cancelled_at"`
+	CancellationFee float64   `json:"cancellation_fee"`
+	Message         string    `json:"message"`
+}
+
+type RideStatusUpdate struct {
+	Type        string         `json:"type"`
+	RideID      string         `json:"ride_id"`
+	RideNumber  string         `json:"ride_number"`
+	PassengerID string         `json:"passenger_id"`
+	DriverID    string         `json:"driver_id,omitempty"`
+	Status      string         `json:"status"`
+	Fare        *FareBreakdown `json:"fare,omitempty"`
+	TipOptions  []TipOption    `json:"tip_options,omitempty"`
+	ScheduledAt *time.Time     `json:"scheduled_at,omitempty"`
+	Message     string         `json:"message,omitempty"`
+	Timestamp   time.Time      `json:"timestamp"`
 }
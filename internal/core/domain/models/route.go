@@ -0,0 +1,12 @@
+package models
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type Route struct {
+	DistanceKM      float64 `json:"distance_km"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Source          string  `json:"source"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// StopRequest is an intermediate stop sent by the passenger. Sequence is the
+// 1-based position among the stops; when adding a stop zero appends it.
+type StopRequest struct {
+	RideID    string  `json:"-"`
+	Sequence  int     `json:"sequence,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+}
+
+type RideStop struct {
+	ID           string     `json:"-"`
+	RideID       string     `json:"-"`
+	CoordinateID string     `json:"-"`
+	Sequence     int        `json:"sequence"`
+	Latitude     float64    `json:"latitude"`
+	Longitude    float64    `json:"longitude"`
+	Address      string     `json:"address"`
+	ArrivedAt    *time.Time `json:"arrived_at,omitempty"`
+	DepartedAt   *time.Time `json:"departed_at,omitempty"`
+}
+
+// StopsQuote is the re-quoted ride after the passenger changed its stops.
+type StopsQuote struct {
+	RideID                   string     `json:"ride_id"`
+	Stops                    []RideStop `json:"stops"`
+	PreviousFare             float64    `json:"previous_fare"`
+	EstimatedFare            float64    `json:"estimated_fare"`
+	EstimatedDistanceKm      float64    `json:"estimated_distance_km"`
+	EstimatedDurationMinutes int        `json:"estimated_duration_minutes"`
+}
+
+type DriverStopRequest struct {
+	DriverID string `json:"-"`
+	RideID   string `json:"ride_id"`
+	Sequence int    `json:"sequence"`
+}
+
+type DriverStopResponse struct {
+	RideID     string     `json:"ride_id"`
+	Sequence   int        `json:"sequence"`
+	ArrivedAt  *time.Time `json:"arrived_at,omitempty"`
+	DepartedAt *time.Time `json:"departed_at,omitempty"`
+	Message    string     `json:"message"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type TipOption struct {
+	Percent float64 `json:"percent"`
+	Amount  float64 `json:"amount"`
+}
+
+type TipRequest struct {
+	PassengerID string  `json:"-"`
+	RideID      string  `json:"-"`
+	Percent     float64 `json:"percent"`
+}
+
+type TipResponse struct {
+	RideID   string    `json:"ride_id"`
+	Percent  float64   `json:"percent"`
+	Amount   float64   `json:"amount"`
+	TippedAt time.Time `json:"tipped_at"`
+	Message  string    `json:"message"`
+}
+
+// DriverNotification is pushed to the driver's WebSocket through driver_topic.
+type DriverNotification struct {
+	Type       string     `json:"type"`
+	DriverID   string     `json:"driver_id"`
+	RideID     string     `json:"ride_id,omitempty"`
+	RideNumber string     `json:"ride_number,omitempty"`
+	Amount     float64    `json:"amount,omitempty"`
+	Stops      []RideStop `json:"stops,omitempty"`
+	TripID     string     `json:"trip_id,omitempty"`
+	Plan       []PlanStop `json:"plan,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PlanStop is a pickup or dropoff of one ride on a pool trip.
+type PlanStop struct {
+	RideID      string  `json:"ride_id"`
+	PassengerID string  `json:"passenger_id"`
+	Kind        string  `json:"kind"`
+	Seats       int     `json:"seats"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Address     string  `json:"address"`
+	Done        bool    `json:"done"`
+}
+
+// Trip links the POOL rides served by one driver along a single route.
+type Trip struct {
+	ID         string     `json:"trip_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DriverID   string     `json:"driver_id"`
+	Status     string     `json:"status"`
+	SeatsTotal int        `json:"seats_total"`
+	Plan       []PlanStop `json:"plan"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
 	ID        string `json:"id"`
 	CreatedAt string `json:"created_at"`
@@ -8,5 +10,37 @@ type User struct {
 	Role      string `json:"role"`
 	Status    string `json:"status"`
 	Password  string `json:"password"`
-	Attrs     struct{}
+	Name      string `json:"name,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	// EmailVerifiedAt is nil until the user confirms the email address
+	EmailVerifiedAt *time.Time `json:"-"`
+}
+
+// RegistrationRequest is the body of POST /registration. Role is PASSENGER or
+// DRIVER; admins are created by another admin or the bootstrap command.
+type RegistrationRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	// Driver is required for DRIVER and must be absent for other roles
+	Driver *DriverProfile `json:"driver,omitempty"`
+}
+
+// DriverProfile becomes the drivers row of a registered driver.
+type DriverProfile struct {
+	LicenseNumber string       `json:"license_number"`
+	VehicleType   string       `json:"vehicle_type"`
+	Vehicle       VehicleAttrs `json:"vehicle"`
+}
+
+// VehicleAttrs is stored as drivers.vehicle_attrs.
+type VehicleAttrs struct {
+	Make  string `json:"vehicle_make"`
+	Model string `json:"vehicle_model"`
+	Color string `json:"vehicle_color"`
+	Plate string `json:"vehicle_plate"`
+	Year  int    `json:"vehicle_year"`
+	Seats int    `json:"seats,omitempty"`
 }
@@ -0,0 +1,7 @@
+package types
+
+// Events of the auth_audit_log table.
+var (
+	AuditLockout = "LOCKOUT"
+	AuditUnlock  = "UNLOCK"
+)
@@ -1,11 +1,110 @@
 package types
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrIncorrectPassword  = errors.New("incorrect password")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrPhoneAlreadyExists = errors.New("phone is already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrLoginLocked        = errors.New("too many failed login attempts")
+	ErrEmailNotVerified   = errors.New("email is not verified")
+	ErrInvalidOTP         = errors.New("invalid or expired code")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+)
+
+// LoginLockedError reports until when logins for the account or the client
+// address are locked. It matches ErrLoginLocked with errors.Is.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error() + ", locked until " + e.Until.UTC().Format(time.RFC3339)
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
+
+var ErrReservationLost = errors.New("idempotency key is no longer reserved by this request")
+
+var (
+	ErrRideNotFound        = errors.New("ride not found")
+	ErrInvalidRideStatus   = errors.New("ride is not in the expected status")
+	ErrRideNotAssigned     = errors.New("ride is not assigned to this driver")
+	ErrRideNotOffered      = errors.New("ride was not offered to this driver")
+	ErrRideNotOwned        = errors.New("ride does not belong to this passenger")
+	ErrInvalidScheduleTime = errors.New("scheduled time is outside the allowed booking window")
+	ErrInvalidCursor       = errors.New("invalid pagination cursor")
+	ErrActiveRideExists    = errors.New("passenger already has an active ride")
+	ErrDriverBusy          = errors.New("driver already has an active ride")
+)
+
+// ActiveRideError reports the active ride that prevents a new one from being
+// created or assigned. It matches ErrActiveRideExists or ErrDriverBusy with
+// errors.Is.
+type ActiveRideError struct {
+	Err    error
+	RideID string
+}
+
+func (e *ActiveRideError) Error() string {
+	return e.Err.Error() + ": " + e.RideID
+}
+
+func (e *ActiveRideError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrInvalidTipPercent = errors.New("tip percent is not one of the preset options")
+	ErrTipWindowClosed   = errors.New("ride can only be tipped within 24 hours of completion")
+	ErrTipAlreadyAdded   = errors.New("ride has already been tipped")
+)
+
+var (
+	ErrTooManyStops       = errors.New("too many intermediate stops")
+	ErrStopNotFound       = errors.New("ride stop not found")
+	ErrInvalidStopStatus  = errors.New("ride stop is not in the expected state")
+	ErrStopAlreadyVisited = errors.New("ride stop has already been visited")
+)
+
+var (
+	ErrDriverNotFound       = errors.New("driver not found")
+	ErrLicenseAlreadyExists = errors.New("license number is already registered")
+)
 
 var (
-	ErrIncorrectPassword = errors.New("incorrect password")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrTripNotFound      = errors.New("trip not found")
+	ErrInvalidSeats      = errors.New("invalid number of seats")
+	ErrNoDriverAvailable = errors.New("no driver available")
 )
 
-var ErrRideNotFound = errors.New("ride not found")
+var (
+	ErrEventNotFound        = errors.New("ride event not found")
+	ErrUnknownReceiptFormat = errors.New("unknown receipt format")
+)
+
+var (
+	ErrPromoNotFound      = errors.New("promo code not found")
+	ErrPromoExpired       = errors.New("promo code is not valid at this time")
+	ErrPromoLimitReached  = errors.New("promo code usage limit reached")
+	ErrPromoNotApplicable = errors.New("promo code is not applicable to this ride")
+	ErrPromoAlreadyExists = errors.New("promo code already exists")
+)
+
+var (
+	ErrUnbalancedTransaction = errors.New("ledger transaction is not balanced")
+	ErrPaymentFailed         = errors.New("payment failed")
+	ErrNoPendingCharge       = errors.New("no pending charge")
+	ErrPayoutBatchNotFound   = errors.New("payout batch not found")
+	ErrDuplicateTransaction  = errors.New("ledger transaction already exists")
+)
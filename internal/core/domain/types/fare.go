@@ -0,0 +1,27 @@
+package types
+
+var (
+	ExtraChargeToll       = "TOLL"
+	ExtraChargeAirportFee = "AIRPORT_FEE"
+	ExtraChargeOther      = "OTHER"
+)
+
+var (
+	FarePeriodNormal = "NORMAL"
+	FarePeriodPeak   = "PEAK"
+	FarePeriodNight  = "NIGHT"
+)
+
+var (
+	DiscountTypePercent = "PERCENT"
+	DiscountTypeFixed   = "FIXED"
+)
+
+// Currency is the currency of all fares and ledger accounts.
+var Currency = "KZT"
+
+var (
+	ReceiptFormatJSON = "json"
+	ReceiptFormatHTML = "html"
+	ReceiptFormatPDF  = "pdf"
+)
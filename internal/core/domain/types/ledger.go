@@ -0,0 +1,33 @@
+package types
+
+var (
+	LedgerAccountPassenger = "PASSENGER"
+	LedgerAccountDriver    = "DRIVER"
+	LedgerAccountPlatform  = "PLATFORM"
+	LedgerAccountPromo     = "PROMO"
+	LedgerAccountPayout    = "PAYOUT"
+)
+
+var (
+	LedgerKindRidePayment     = "RIDE_PAYMENT"
+	LedgerKindTip             = "TIP"
+	LedgerKindPayout          = "PAYOUT"
+	LedgerKindCancellationFee = "CANCELLATION_FEE"
+)
+
+var (
+	ChargeStatusPending = "PENDING"
+	ChargeStatusCharged = "CHARGED"
+	ChargeStatusFailed  = "FAILED"
+)
+
+var (
+	PayoutStatusPending = "PENDING"
+	PayoutStatusPaid    = "PAID"
+	PayoutStatusFailed  = "FAILED"
+)
+
+var (
+	EarningsPeriodDaily  = "daily"
+	EarningsPeriodWeekly = "weekly"
+)
@@ -10,4 +10,5 @@ var (
 	RideTypeECONOMY = "ECONOMY"
 	RideTypePREMIUM = "PREMIUM"
 	RideTypeXL      = "XL"
+	RideTypePOOL    = "POOL"
 )
@@ -0,0 +1,8 @@
+package types
+
+// Ride priorities are stored in rides.priority and sent as the AMQP priority
+// of ride_requests messages; a higher value is matched first.
+const (
+	RidePriorityMin = 1
+	RidePriorityMax = 10
+)
@@ -1,7 +1,8 @@
 package types
 
+// Roles match the values of the roles table.
 var (
-	RoleCustomer = "PASSENGER"
-	RoleDriver   = "Driver"
-	RoleAdmin    = "Admin"
+	RolePassenger = "PASSENGER"
+	RoleDriver    = "DRIVER"
+	RoleAdmin     = "ADMIN"
 )
@@ -6,6 +6,7 @@ var (
 )
 
 var (
+	RideStatusSCHEDULED   = "SCHEDULED"
 	RideStatusREQUESTED   = "REQUESTED"
 	RideStatusMATCHED     = "MATCHED"
 	RideStatusEN_ROUTE    = "EN_ROUTE"
@@ -15,9 +16,50 @@ var (
 	RideStatusCANCELLED   = "CANCELLED"
 )
 
+var (
+	TripStatusOpen      = "OPEN"
+	TripStatusCompleted = "COMPLETED"
+	TripStatusCancelled = "CANCELLED"
+)
+
+var (
+	PlanStopPickup  = "PICKUP"
+	PlanStopDropoff = "DROPOFF"
+)
+
 var (
 	DriverStatusOffline   = "OFFLINE"
 	DriverStatusAvailable = "AVAILABLE"
 	DriverStatusBusy      = "BUSY"
 	DriverStatusEnRoute   = "EN_ROUTE"
 )
+
+var (
+	RideEventScheduled     = "RIDE_SCHEDULED"
+	RideEventRequested     = "RIDE_REQUESTED"
+	RideEventDriverMatched = "DRIVER_MATCHED"
+	RideEventDriverArrived = "DRIVER_ARRIVED"
+	RideEventStarted       = "RIDE_STARTED"
+	RideEventCompleted     = "RIDE_COMPLETED"
+	RideEventCancelled     = "RIDE_CANCELLED"
+	RideEventStatusChanged = "STATUS_CHANGED"
+	RideEventLocation      = "LOCATION_UPDATED"
+	RideEventFareAdjusted  = "FARE_ADJUSTED"
+	RideEventTipAdded      = "TIP_ADDED"
+	RideEventStopArrived   = "STOP_ARRIVED"
+	RideEventStopDeparted  = "STOP_DEPARTED"
+	RideEventStopsChanged  = "STOPS_CHANGED"
+
+	RideEventDriverCancelled = "DRIVER_CANCELLED"
+)
+
+var (
+	NotificationTipReceived   = "tip_received"
+	NotificationRideReminder  = "ride_reminder"
+	NotificationRideCancelled = "ride_cancelled"
+	NotificationRideOffer     = "ride_offer"
+	NotificationStopsChanged  = "stops_changed"
+	NotificationTripPlan      = "trip_plan"
+
+	NotificationDriverCancelled = "driver_cancelled"
+)
@@ -0,0 +1,7 @@
+package types
+
+// Purposes of single-use account tokens.
+var (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
@@ -2,9 +2,12 @@ package ports
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"ride-hail/internal/core/domain/models"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthServices interface {
@@ -14,29 +17,226 @@ type AuthServices interface {
 // auth ports
 
 type AuthService interface {
-	CreateNewUser(ctx context.Context, user models.User) error
-	Login(ctx context.Context, user models.User) (string, error)
+	CreateNewUser(ctx context.Context, req models.RegistrationRequest) error
+	BootstrapAdmin(ctx context.Context, req models.RegistrationRequest) error
+	Login(ctx context.Context, user models.User) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	UnlockUser(ctx context.Context, userID string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string) error
+	CheckResetToken(ctx context.Context, token string) (models.User, error)
+	ResetPassword(ctx context.Context, token, password string) error
 }
 
 type UserRepository interface {
-	CreateNewUser(ctx context.Context, user models.User) error
+	CreateNewUser(ctx context.Context, user models.User) (string, error)
 	GetGyUserEmail(ctx context.Context, email string) (models.User, error)
+	GetUserByID(ctx context.Context, id string) (models.User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id string) error
+	GetUserByPhone(ctx context.Context, phone string) (models.User, error)
+}
+
+type OTPService interface {
+	RequestOTP(ctx context.Context, phone string) error
+	VerifyOTP(ctx context.Context, phone, code string) (models.TokenPair, error)
+}
+
+type OTPRepository interface {
+	CreateOTP(ctx context.Context, code models.OTPCode) error
+	GetLastSentAt(ctx context.Context, phone string) (time.Time, error)
+	CheckOTP(ctx context.Context, phone, codeHash string, maxAttempts int) (bool, error)
+}
+
+// SMSSender delivers text messages to a phone in E.164 format.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone, text string) error
+}
+
+// TokenSigner signs tokens, adding the kid header of its key, and resolves
+// the verification key of tokens for jwt.Parse.
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (any, error)
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, t models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id string) error
+	RevokeTokenFamily(ctx context.Context, familyID string, accessExpiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userID string, accessExpiresAt time.Time) error
+	RevokeAccessToken(ctx context.Context, claimsID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, claimsID string) (bool, error)
+	CreateAccountToken(ctx context.Context, t models.AccountToken) error
+	GetAccountTokenUser(ctx context.Context, id, purpose string) (string, error)
+	ConsumeAccountToken(ctx context.Context, id, purpose string) (string, error)
+	DeleteExpired(ctx context.Context) error
+}
+
+type LoginAttemptRepository interface {
+	GetLockedUntil(ctx context.Context, keys []string) (time.Time, error)
+	RecordFailure(ctx context.Context, key string, resetBefore time.Time) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	ClearFailures(ctx context.Context, key string) (bool, error)
+	CreateAuditEntry(ctx context.Context, e models.LoginAuditEntry) error
 }
 
 // ride ports
 type RideService interface {
 	CreateNewRide(ctx context.Context, r models.CreateRideRequest) (models.CreateRideResponse, error)
 	CloseRide(ctx context.Context, req models.CloseRideRequest) (models.CloseRideResponse, error)
+	NotifyRideStatus(ctx context.Context, update models.RideStatusUpdate) error
+	TipDriver(ctx context.Context, req models.TipRequest) (models.TipResponse, error)
+	DispatchScheduledRides(ctx context.Context) error
+	SendRideReminders(ctx context.Context) error
+	AddStop(ctx context.Context, req models.StopRequest) (models.StopsQuote, error)
+	ChangeStop(ctx context.Context, req models.StopRequest) (models.StopsQuote, error)
+	GetRide(ctx context.Context, rideID string) (models.RideDetail, error)
+	ListRides(ctx context.Context, f models.RideFilter) (models.RidePage, error)
 }
 
 type RidePublisher interface {
-	Publish(exName, queue string, message []byte) error
+	Publish(exName, routingKey string, message []byte) error
+	PublishWithPriority(exName, routingKey string, priority uint8, message []byte) error
 }
 
 type RideRepository interface {
 	CreateNewRide(ctx context.Context, ride models.Ride) (string, error)
 	GetRide(ctx context.Context, id string) (models.Ride, error)
+	GetRideDetail(ctx context.Context, id string) (models.RideDetail, error)
+	ListPassengerRides(ctx context.Context, f models.RideFilter, after *models.RideCursor, limit int) ([]models.RideDetail, error)
 	GenerateRideNumber(ctx context.Context) (int, error)
+	UpdateRideStatus(ctx context.Context, rideID, expectedStatus, newStatus string) error
+	SetFinalFare(ctx context.Context, rideID string, fare float64) error
+	CountCompletedRides(ctx context.Context, passengerID string) (int, error)
+	GetActiveRideID(ctx context.Context, driverID string) (string, error)
+	GetPassengerActiveRideID(ctx context.Context, passengerID string) (string, error)
+	SetEstimatedFare(ctx context.Context, rideID string, fare float64) error
+	AssignDriver(ctx context.Context, rideID, driverID string) error
+	AssignTrip(ctx context.Context, rideID, driverID, tripID string) error
+	ListTripRides(ctx context.Context, tripID string) ([]models.Ride, error)
+	SetCancellationReason(ctx context.Context, rideID, reason string) error
+	ListDueScheduledRides(ctx context.Context, before time.Time, limit int) ([]models.Ride, error)
+	ListRidesToRemind(ctx context.Context, before time.Time, limit int) ([]models.Ride, error)
+	MarkReminderSent(ctx context.Context, rideID string) error
+	SetPriority(ctx context.Context, rideID string, priority int) error
+	ListWaitingRides(ctx context.Context, before time.Time, limit int) ([]models.Ride, error)
+	ListWaitingPickups(ctx context.Context, vehicleType string, minPriority int) ([]models.Point, error)
+	ReleaseDriver(ctx context.Context, rideID, driverID string, priority int) error
+	CountMatchedRides(ctx context.Context, driverID string, since time.Time) (int, error)
+}
+
+type RideStopRepository interface {
+	CreateStop(ctx context.Context, stop models.RideStop) (string, error)
+	ListStops(ctx context.Context, rideID string) ([]models.RideStop, error)
+	DeletePendingStops(ctx context.Context, rideID string) error
+	MarkStopArrived(ctx context.Context, stopID string) error
+	MarkStopDeparted(ctx context.Context, stopID string) error
+}
+
+type RideEventRepository interface {
+	CreateEvent(ctx context.Context, rideID, eventType string, data any) error
+	GetLastEvent(ctx context.Context, rideID, eventType string, dest any) error
+}
+
+type RouteProvider interface {
+	Route(ctx context.Context, from, to models.Point) (models.Route, error)
+}
+
+// promo ports
+type PromoService interface {
+	CreatePromo(ctx context.Context, p models.PromoCode) (models.PromoCode, error)
+	Quote(ctx context.Context, code, userID, rideType string, fare float64) (models.PromoQuote, error)
+	Redeem(ctx context.Context, promoID, userID, rideID, rideType string, fare float64) (models.PromoQuote, error)
+}
+
+type PromoRepository interface {
+	CreatePromo(ctx context.Context, p models.PromoCode) (string, error)
+	GetPromoByCode(ctx context.Context, code string) (models.PromoCode, error)
+	GetPromoForUpdate(ctx context.Context, id string) (models.PromoCode, error)
+	CountUserRedemptions(ctx context.Context, promoID, userID string) (int, error)
+	IncrementUsage(ctx context.Context, promoID string) error
+	CreateRedemption(ctx context.Context, r models.PromoRedemption) error
+}
+
+// ledger ports
+type LedgerService interface {
+	PostRidePayment(ctx context.Context, ride models.Ride, fare models.FareBreakdown) (models.RidePayment, error)
+	Post(ctx context.Context, tx models.LedgerTransaction) (string, error)
+	GetAccountBalance(ctx context.Context, accountType, ownerID string) (models.AccountBalance, error)
+	ListAccountEntries(ctx context.Context, accountType, ownerID string, limit, offset int) ([]models.AccountStatementLine, error)
+	PostPayout(ctx context.Context, driverID string, amount float64, reference string) (string, error)
+	PostTip(ctx context.Context, ride models.Ride, amount float64) (string, error)
+	PostCancellationFee(ctx context.Context, ride models.Ride, amount float64) (string, error)
+	CollectCharge(ctx context.Context, transactionID string) error
+}
+
+type LedgerRepository interface {
+	GetOrCreateAccount(ctx context.Context, accountType, ownerID string) (string, error)
+	CreateTransaction(ctx context.Context, t models.LedgerTransaction) (string, error)
+	ListPendingCharges(ctx context.Context, limit int) ([]models.LedgerTransaction, error)
+	GetPendingCharge(ctx context.Context, transactionID string) (models.LedgerTransaction, error)
+	MarkCharged(ctx context.Context, transactionID, reference string) error
+	RecordChargeFailure(ctx context.Context, transactionID string, maxAttempts int) (bool, error)
+	GetBalance(ctx context.Context, accountID string) (models.AccountBalance, error)
+	ListAccountEntries(ctx context.Context, accountID string, limit, offset int) ([]models.AccountStatementLine, error)
+}
+
+// PaymentProvider charges a customer. reference is an idempotency key: a
+// repeated charge with the same reference returns the first charge.
+type PaymentProvider interface {
+	Charge(ctx context.Context, customerID string, amount float64, reference string) (string, error)
+}
+
+// receipt ports
+type ReceiptService interface {
+	GetReceipt(ctx context.Context, rideID string) (models.Receipt, error)
+	RenderReceipt(receipt models.Receipt, format string) ([]byte, string, error)
+	SendReceipt(ctx context.Context, rideID string) error
+}
+
+type ReceiptRenderer interface {
+	HTML(receipt models.Receipt) ([]byte, error)
+	PDF(receipt models.Receipt) ([]byte, error)
+}
+
+type Mailer interface {
+	Send(ctx context.Context, email models.Email) error
+}
+
+// earnings ports
+type EarningsService interface {
+	GetStatement(ctx context.Context, driverID, period string, from, to time.Time) (models.EarningsStatement, error)
+	ListRideEarnings(ctx context.Context, driverID string, from, to time.Time) ([]models.RideEarning, error)
+}
+
+type EarningsRepository interface {
+	SummarizeEarnings(ctx context.Context, driverID, trunc string, loc *time.Location, from, to time.Time) ([]models.EarningsSummary, error)
+	ListRideEarnings(ctx context.Context, driverID string, from, to time.Time) ([]models.RideEarning, error)
+}
+
+// payout ports
+type PayoutService interface {
+	RunBatch(ctx context.Context) (models.PayoutBatch, error)
+	ExportBatch(ctx context.Context, batchID string, w io.Writer) error
+}
+
+type PayoutRepository interface {
+	LockPayouts(ctx context.Context) error
+	ListPayableBalances(ctx context.Context, minAmount float64) ([]models.AccountBalance, error)
+	CreateBatch(ctx context.Context) (models.PayoutBatch, error)
+	UpdateBatch(ctx context.Context, b models.PayoutBatch) error
+	GetBatch(ctx context.Context, id string) (models.PayoutBatch, error)
+	CreatePayout(ctx context.Context, p models.Payout) (string, error)
+	ListPayouts(ctx context.Context, batchID string) ([]models.Payout, error)
+}
+
+type PayoutExporter interface {
+	Export(batch models.PayoutBatch, payouts []models.Payout) (string, error)
+	Write(w io.Writer, batch models.PayoutBatch, payouts []models.Payout) error
 }
 
 type CoordinatesRepository interface {
@@ -44,6 +244,14 @@ type CoordinatesRepository interface {
 	GetCoordinate(ctx context.Context, id string) (models.Coordinate, error)
 }
 
+// idempotency ports
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, rec models.IdempotencyRecord) error
+	Release(ctx context.Context, rec models.IdempotencyRecord) error
+	DeleteExpired(ctx context.Context) error
+}
+
 // dal ports
 type DriverRepository interface {
 	CreateDriver(ctx context.Context, driver models.Driver) (string, error)
@@ -52,6 +260,24 @@ type DriverRepository interface {
 	DeleteDriver(ctx context.Context, id string) error
 	ListDriversByStatus(ctx context.Context, status string, limit, offset int) ([]models.Driver, error)
 	UpdateDriverStatus(ctx context.Context, driverID string, newStatus string) error
+	StartSession(ctx context.Context, driverID string) (string, error)
+	EndSession(ctx context.Context, driverID string) (*models.DriverSession, error)
+	AddRideEarnings(ctx context.Context, driverID string, amount float64) error
+	GetOpenSession(ctx context.Context, driverID string) (*models.DriverSession, error)
+	AddTip(ctx context.Context, driverID string, amount float64) error
+	ListAvailableDrivers(ctx context.Context, vehicleTypes ...string) ([]models.AvailableDriver, error)
+	RecordCancellation(ctx context.Context, driverID, rideID, reason string) error
+	CountCancellations(ctx context.Context, driverID string, since time.Time) (int, error)
+	ListRideCancellers(ctx context.Context, rideID string) ([]string, error)
+	BlockDriver(ctx context.Context, driverID string, until time.Time) error
+}
+
+type TripRepository interface {
+	CreateTrip(ctx context.Context, trip models.Trip) (string, error)
+	GetTrip(ctx context.Context, id string) (models.Trip, error)
+	ListOpenTrips(ctx context.Context) ([]models.Trip, error)
+	UpdatePlan(ctx context.Context, tripID string, plan []models.PlanStop) error
+	CloseTripIfDone(ctx context.Context, tripID string) (bool, error)
 }
 
 type LocationRepository interface {
@@ -59,6 +285,8 @@ type LocationRepository interface {
 	GetLastLocationByDriver(ctx context.Context, driverID string) (*models.LocationHistory, error)
 	GetLocationHistoryByDriver(ctx context.Context, driverID string, limit int) ([]models.LocationHistory, error)
 	DeleteLocationHistory(ctx context.Context, driverID string, before time.Time) error
+	ListRideRoute(ctx context.Context, rideID string) ([]models.Point, error)
+	ListRideRouteSince(ctx context.Context, rideID string, since time.Time) ([]models.Point, error)
 }
 
 type DalService interface {
@@ -68,7 +296,7 @@ type DalService interface {
 	DeleteDriver(ctx context.Context, driverID string) error
 
 	ChangeDriverStatus(ctx context.Context, driverID string, newStatus string, expectedStatus string) error
-	ListAvailableDriversNear(ctx context.Context) ([]models.Driver, error)
+	ListAvailableDriversNear(ctx context.Context, vehicleType string, lat, lng float64) ([]models.AvailableDriver, error)
 	RecordDriverLocation(ctx context.Context, location models.LocationHistory) (string, error)
 
 	GetDriverLastLocation(ctx context.Context, driverID string) (*models.LocationHistory, error)
@@ -78,4 +306,29 @@ type DalService interface {
 
 	StartDriverSession(ctx context.Context, driverID string) (string, error)
 	EndDriverSession(ctx context.Context, sessionID string) error
+
+	GoOnline(ctx context.Context, req models.DriverOnlineRequest) (models.DriverOnlineResponse, error)
+	GoOffline(ctx context.Context, driverID string) (models.DriverOfflineResponse, error)
+	UpdateLocation(ctx context.Context, req models.LocationUpdateRequest) (models.LocationUpdateResponse, error)
+	AcceptRide(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error)
+	DriverArrived(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error)
+	StartRide(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error)
+	CompleteRide(ctx context.Context, req models.CompleteRideRequest) (models.CompleteRideResponse, error)
+	ArriveAtStop(ctx context.Context, req models.DriverStopRequest) (models.DriverStopResponse, error)
+	DepartFromStop(ctx context.Context, req models.DriverStopRequest) (models.DriverStopResponse, error)
+	CancelRide(ctx context.Context, req models.DriverCancelRequest) (models.DriverCancelResponse, error)
+	MatchRide(ctx context.Context, req models.RideRequestMessage) error
+	MatchPoolRide(ctx context.Context, req models.RideRequestMessage) error
+
+	GetDriverBalance(ctx context.Context, driverID string) (models.AccountBalance, error)
+	ListDriverTransactions(ctx context.Context, driverID string, limit, offset int) ([]models.AccountStatementLine, error)
+
+	NotifyDriver(ctx context.Context, n models.DriverNotification) error
+}
+
+type DalPublisher interface {
+	Publish(exName, routingKey string, message []byte) error
+	PublishWithPriority(exName, routingKey string, priority uint8, message []byte) error
+	PublishDriverLocation(locationMsg interface{}) error
+	PublishDriverStatus(driverID string, status string, rideID string) error
 }
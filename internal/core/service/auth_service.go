@@ -3,9 +3,13 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"net/netip"
 	"ride-hail/config"
 	"ride-hail/internal/core/domain/action"
 	"ride-hail/internal/core/domain/models"
@@ -13,43 +17,303 @@ import (
 	"ride-hail/internal/core/ports"
 	"ride-hail/internal/core/service/hash"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/txm"
+	"strings"
 	"time"
 )
 
 type AuthService struct {
-	secretKey string
-	cfg       config.Config
-	repo      ports.UserRepository
-	log       *logger.Logger
+	signer   ports.TokenSigner
+	hasher   *hash.Hasher
+	cfg      config.Config
+	txm      txm.Manager
+	repo     ports.UserRepository
+	drivers  ports.DriverRepository
+	tokens   ports.TokenRepository
+	attempts ports.LoginAttemptRepository
+	mailer   ports.Mailer
+	log      *logger.Logger
 }
 
-func NewAuthService(cfg config.Config, repo ports.UserRepository, log *logger.Logger) *AuthService {
+func NewAuthService(cfg config.Config, txm txm.Manager, repo ports.UserRepository, drivers ports.DriverRepository, tokens ports.TokenRepository,
+	attempts ports.LoginAttemptRepository, signer ports.TokenSigner, mailer ports.Mailer, log *logger.Logger) *AuthService {
 	return &AuthService{
-		secretKey: cfg.JWT.Secret,
-		repo:      repo,
-		log:       log,
-		cfg:       cfg,
+		drivers:  drivers,
+		attempts: attempts,
+		mailer:   mailer,
+		signer:   signer,
+		hasher: hash.NewHasher(hash.Params{
+			Memory:      cfg.Argon2.MemoryKiB,
+			Iterations:  cfg.Argon2.Iterations,
+			Parallelism: cfg.Argon2.Parallelism,
+			SaltLength:  hash.DefaultParams.SaltLength,
+			KeyLength:   hash.DefaultParams.KeyLength,
+		}),
+		txm:    txm,
+		repo:   repo,
+		tokens: tokens,
+		log:    log,
+		cfg:    cfg,
 	}
 }
 
-// Login returning token and error
-func (s *AuthService) Login(ctx context.Context, user models.User) (string, error) {
+// Login returns a new access and refresh token pair that starts a token family.
+// An unknown email and a wrong password both give types.ErrInvalidCredentials
+// and count as a failure of the account and of the client address; while
+// either is locked a *types.LoginLockedError is returned without checking the
+// password.
+func (s *AuthService) Login(ctx context.Context, user models.User) (models.TokenPair, error) {
 	log := s.log.Func("Login")
 
+	keys := loginKeys(user.Email, logger.GetClientIP(ctx))
+	until, err := s.attempts.GetLockedUntil(ctx, keys)
+	if err != nil {
+		log.Error(ctx, action.Login, "error checking login lockout", "error", err)
+		return models.TokenPair{}, err
+	}
+	if !until.IsZero() {
+		log.Warn(ctx, action.Login, "login attempt while locked", "email", user.Email, "locked_until", until)
+		return models.TokenPair{}, &types.LoginLockedError{Until: until}
+	}
+
 	u, err := s.repo.GetGyUserEmail(ctx, user.Email)
+	if errors.Is(err, types.ErrUserNotFound) {
+		// hash anyway so that unknown emails take as long as wrong passwords
+		s.hasher.HashPassword(user.Password)
+		log.Warn(ctx, action.Login, "unknown email", "email", user.Email)
+		s.recordLoginFailure(ctx, keys, "")
+		return models.TokenPair{}, types.ErrInvalidCredentials
+	}
 	if err != nil {
 		log.Error(ctx, action.Login, "error in getting user email", "email", user.Email, "error", err)
-		return "", err
+		return models.TokenPair{}, err
 	}
 
 	ok, err := hash.VerifyPassword(u.Password, user.Password)
 	if err != nil {
 		log.Error(ctx, action.Login, "error verifying password", "userID", u.ID, "error", err)
-		return "", err
+		return models.TokenPair{}, err
 	}
 	if !ok {
-		log.Warn(ctx, action.Login, "incorrect password")
-		return "", types.ErrIncorrectPassword
+		log.Warn(ctx, action.Login, "incorrect password", "userID", u.ID)
+		s.recordLoginFailure(ctx, keys, u.ID)
+		return models.TokenPair{}, types.ErrInvalidCredentials
+	}
+
+	// the address keeps its count, one valid account must not let it guess others
+	if _, err = s.attempts.ClearFailures(ctx, keys[0]); err != nil {
+		log.Warn(ctx, action.Login, "error clearing login failures", "userID", u.ID, "error", err)
+	}
+
+	if u.EmailVerifiedAt == nil {
+		log.Warn(ctx, action.Login, "email is not verified", "userID", u.ID)
+		return models.TokenPair{}, types.ErrEmailNotVerified
+	}
+
+	if s.hasher.NeedsRehash(u.Password) {
+		s.rehashPassword(ctx, u.ID, user.Password)
+	}
+
+	pair, err := s.issueTokens(ctx, u, newClaimsID())
+	if err != nil {
+		log.Error(ctx, action.Login, "error issuing tokens", "userID", u.ID, "error", err)
+		return models.TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// Refresh rotates the refresh token: the presented token is marked used and a
+// new pair of the same family is issued. Presenting a token that was already
+// used means it leaked, so the whole family is revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	log := s.log.Func("Refresh")
+
+	var (
+		pair   models.TokenPair
+		stored models.RefreshToken
+	)
+	fn := func(ctx context.Context) error {
+		var err error
+		if stored, err = s.tokens.GetRefreshToken(ctx, hashToken(refreshToken)); err != nil {
+			return err
+		}
+		if stored.UsedAt != nil || stored.RevokedAt != nil {
+			return types.ErrRefreshTokenReused
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			return types.ErrInvalidRefreshToken
+		}
+		if err = s.tokens.MarkRefreshTokenUsed(ctx, stored.ID); err != nil {
+			return err
+		}
+
+		u, err := s.repo.GetUserByID(ctx, stored.UserID)
+		if err != nil {
+			return err
+		}
+		pair, err = s.issueTokens(ctx, u, stored.FamilyID)
+		return err
+	}
+
+	err := s.txm.Do(ctx, fn)
+	switch {
+	case err == nil:
+		return pair, nil
+	case errors.Is(err, types.ErrRefreshTokenReused):
+		log.Warn(ctx, action.RefreshToken, "refresh token reuse detected, revoking family",
+			"userID", stored.UserID, "family_id", stored.FamilyID)
+		if revokeErr := s.tokens.RevokeTokenFamily(ctx, stored.FamilyID, time.Now().Add(s.cfg.JWT.AccessTTL)); revokeErr != nil {
+			log.Error(ctx, action.RefreshToken, "error revoking token family", "family_id", stored.FamilyID, "error", revokeErr)
+			return models.TokenPair{}, revokeErr
+		}
+		return models.TokenPair{}, err
+	case errors.Is(err, types.ErrInvalidRefreshToken):
+		log.Warn(ctx, action.RefreshToken, "invalid refresh token")
+		return models.TokenPair{}, err
+	default:
+		log.Error(ctx, action.RefreshToken, "error refreshing tokens", "error", err)
+		return models.TokenPair{}, err
+	}
+}
+
+// Logout revokes the access token of the request and, when given, the family
+// of the refresh token.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	log := s.log.Func("Logout")
+
+	userID := logger.GetUserID(ctx)
+	if err := s.tokens.RevokeAccessToken(ctx, logger.GetClaimsID(ctx), time.Now().Add(s.cfg.JWT.AccessTTL)); err != nil {
+		log.Error(ctx, action.Logout, "error revoking access token", "userID", userID, "error", err)
+		return err
+	}
+
+	if refreshToken != "" {
+		stored, err := s.tokens.GetRefreshToken(ctx, hashToken(refreshToken))
+		if err != nil && !errors.Is(err, types.ErrInvalidRefreshToken) {
+			log.Error(ctx, action.Logout, "error getting refresh token", "userID", userID, "error", err)
+			return err
+		}
+		if err == nil && stored.UserID == userID {
+			if err = s.tokens.RevokeTokenFamily(ctx, stored.FamilyID, time.Now().Add(s.cfg.JWT.AccessTTL)); err != nil {
+				log.Error(ctx, action.Logout, "error revoking token family", "userID", userID, "error", err)
+				return err
+			}
+		}
+	}
+
+	log.Info(ctx, action.Logout, "user logged out", "userID", userID)
+	return nil
+}
+
+// UnlockUser lifts the lockout of the user's account. Locks of client
+// addresses are left to expire.
+func (s *AuthService) UnlockUser(ctx context.Context, userID string) error {
+	log := s.log.Func("UnlockUser")
+
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Warn(ctx, action.UnlockUser, "error getting user", "target_user_id", userID, "error", err)
+		return err
+	}
+
+	key := loginKeys(u.Email, "")[0]
+	cleared, err := s.attempts.ClearFailures(ctx, key)
+	if err != nil {
+		log.Error(ctx, action.UnlockUser, "error clearing login failures", "target_user_id", userID, "error", err)
+		return err
+	}
+	if !cleared {
+		return nil
+	}
+
+	if err = s.attempts.CreateAuditEntry(ctx, models.LoginAuditEntry{
+		Event:   types.AuditUnlock,
+		Subject: key,
+		UserID:  u.ID,
+		ActorID: logger.GetUserID(ctx),
+		IP:      logger.GetClientIP(ctx),
+	}); err != nil {
+		log.Error(ctx, action.UnlockUser, "error writing audit entry", "target_user_id", userID, "error", err)
+	}
+	log.Info(ctx, action.UnlockUser, "account unlocked", "target_user_id", userID)
+	return nil
+}
+
+// recordLoginFailure counts the failure for every key and locks the keys that
+// reached their limit. The first lock lasts LockoutBase and every further one
+// doubles, up to LockoutMax. Errors are only logged: the caller already fails
+// the login.
+func (s *AuthService) recordLoginFailure(ctx context.Context, keys []string, userID string) {
+	log := s.log.Func("recordLoginFailure")
+
+	resetBefore := time.Now().Add(-s.cfg.Login.FailureWindow)
+	for i, key := range keys {
+		limit := s.cfg.Login.MaxFailures
+		subjectUserID := userID
+		if i > 0 {
+			limit = s.cfg.Login.IPMaxFailures
+			subjectUserID = ""
+		}
+
+		failures, err := s.attempts.RecordFailure(ctx, key, resetBefore)
+		if err != nil {
+			log.Error(ctx, action.LoginLockout, "error recording login failure", "key", key, "error", err)
+			continue
+		}
+		if failures < limit {
+			continue
+		}
+
+		until := time.Now().Add(lockoutDuration(s.cfg.Login.LockoutBase, s.cfg.Login.LockoutMax, failures-limit))
+		if err = s.attempts.Lock(ctx, key, until); err != nil {
+			log.Error(ctx, action.LoginLockout, "error locking login", "key", key, "error", err)
+			continue
+		}
+		log.Warn(ctx, action.LoginLockout, "login locked", "key", key, "failures", failures, "locked_until", until)
+
+		if err = s.attempts.CreateAuditEntry(ctx, models.LoginAuditEntry{
+			Event:       types.AuditLockout,
+			Subject:     key,
+			UserID:      subjectUserID,
+			IP:          logger.GetClientIP(ctx),
+			Failures:    failures,
+			LockedUntil: &until,
+		}); err != nil {
+			log.Error(ctx, action.LoginLockout, "error writing audit entry", "key", key, "error", err)
+		}
+	}
+}
+
+// lockoutDuration doubles base for every failure past the limit.
+func lockoutDuration(base, maxLockout time.Duration, over int) time.Duration {
+	d := base
+	for ; over > 0 && d < maxLockout; over-- {
+		d *= 2
+	}
+	return min(d, maxLockout)
+}
+
+// loginKeys returns the throttling keys of a login: the account first, then
+// the client address when it is known.
+func loginKeys(email, ip string) []string {
+	return throttleKeys("account:"+strings.ToLower(strings.TrimSpace(email)), ip)
+}
+
+func throttleKeys(subject, ip string) []string {
+	keys := []string{subject}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		keys = append(keys, "ip:"+addr.Unmap().String())
+	}
+	return keys
+}
+
+// issueTokens signs a new access token and stores a new refresh token of the family.
+func (s *AuthService) issueTokens(ctx context.Context, u models.User, familyID string) (models.TokenPair, error) {
+	now := time.Now()
+	pair := models.TokenPair{
+		AccessExpiresAt:  now.Add(s.cfg.JWT.AccessTTL),
+		RefreshToken:     newRefreshToken(),
+		RefreshExpiresAt: now.Add(time.Duration(s.cfg.JWT.ExpireHours) * time.Hour),
 	}
 
 	claims := models.Claims{
@@ -57,40 +321,363 @@ func (s *AuthService) Login(ctx context.Context, user models.User) (string, erro
 		UserID:   u.ID,
 		Role:     u.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(pair.AccessExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	var err error
+	if pair.AccessToken, err = s.signer.Sign(claims); err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
 
-	tokenString, err := token.SignedString([]byte(s.secretKey))
-	if err != nil {
-		log.Error(ctx, action.Login, "error generating JWT token", "error", err)
-		return "", err
+	if err = s.tokens.CreateRefreshToken(ctx, models.RefreshToken{
+		UserID:         u.ID,
+		FamilyID:       familyID,
+		TokenHash:      hashToken(pair.RefreshToken),
+		AccessClaimsID: claims.ClaimsID,
+		ExpiresAt:      pair.RefreshExpiresAt,
+	}); err != nil {
+		return models.TokenPair{}, err
 	}
+	return pair, nil
+}
 
-	return tokenString, nil
+// rehashPassword upgrades a legacy or outdated hash while the plain password
+// is at hand. Failures only cost another attempt on the next login.
+func (s *AuthService) rehashPassword(ctx context.Context, userID, password string) {
+	log := s.log.Func("rehashPassword")
+
+	hashPass, err := s.hasher.HashPassword(password)
+	if err != nil {
+		log.Warn(ctx, action.Login, "error rehashing password", "userID", userID, "error", err)
+		return
+	}
+	if err = s.repo.UpdatePassword(ctx, userID, hashPass); err != nil {
+		log.Warn(ctx, action.Login, "error storing rehashed password", "userID", userID, "error", err)
+		return
+	}
+	log.Info(ctx, action.Login, "password hash upgraded", "userID", userID)
 }
 
-func (s *AuthService) CreateNewUser(ctx context.Context, user models.User) error {
+// CreateNewUser registers a user of the requested role and emails a
+// verification link. A driver gets the drivers row in the same transaction.
+// The user can log in once the email is verified; a failed email can be resent.
+func (s *AuthService) CreateNewUser(ctx context.Context, req models.RegistrationRequest) error {
 	log := s.log.Func("CreateNewUser")
 
-	hashPass, err := hash.HashPassword(user.Password)
+	user, err := s.createUser(ctx, req, false)
 	if err != nil {
-		log.Error(ctx, action.Registration, "error hashing password", "error", err)
+		log.Error(ctx, action.Registration, "error creating new user", "role", req.Role, "error", err)
 		return err
 	}
+	log.Info(ctx, action.Registration, "user registered", "userID", user.ID, "role", user.Role)
 
-	user.Password = hashPass
-	err = s.repo.CreateNewUser(ctx, user)
+	if err = s.sendVerification(ctx, user); err != nil {
+		log.Error(ctx, action.Registration, "error sending verification email", "userID", user.ID, "error", err)
+	}
+	return nil
+}
+
+// BootstrapAdmin creates an admin with a verified email, for the first admin
+// of a new installation that has nobody to create it.
+func (s *AuthService) BootstrapAdmin(ctx context.Context, req models.RegistrationRequest) error {
+	log := s.log.Func("BootstrapAdmin")
+
+	req.Role = types.RoleAdmin
+	user, err := s.createUser(ctx, req, true)
 	if err != nil {
-		log.Error(ctx, action.Registration, "error creating new user", "error", err)
+		log.Error(ctx, action.Registration, "error creating admin", "email", req.Email, "error", err)
 		return err
 	}
+	log.Info(ctx, action.Registration, "admin created", "userID", user.ID)
 	return nil
 }
 
+func (s *AuthService) createUser(ctx context.Context, req models.RegistrationRequest, verified bool) (models.User, error) {
+	hashPass, err := s.hasher.HashPassword(req.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Email:    req.Email,
+		Role:     req.Role,
+		Password: hashPass,
+		Name:     strings.TrimSpace(req.Name),
+		Phone:    req.Phone,
+	}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if user.ID, err = s.repo.CreateNewUser(ctx, user); err != nil {
+			return err
+		}
+		if req.Role != types.RoleDriver || req.Driver == nil {
+			return nil
+		}
+
+		attrs, err := json.Marshal(req.Driver.Vehicle)
+		if err != nil {
+			return err
+		}
+		_, err = s.drivers.CreateDriver(ctx, models.Driver{
+			ID:            user.ID,
+			LicenseNumber: req.Driver.LicenseNumber,
+			VehicleType:   &req.Driver.VehicleType,
+			VehicleAttrs:  attrs,
+			Rating:        5,
+			Status:        types.DriverStatusOffline,
+		})
+		return err
+	}
+	if err = s.txm.Do(ctx, fn); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// VerifyEmail consumes a verification token and marks the email verified.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	log := s.log.Func("VerifyEmail")
+
+	claims, err := s.parseAccountToken(token, types.PurposeVerifyEmail)
+	if err != nil {
+		log.Warn(ctx, action.VerifyEmail, "invalid verification token", "error", err)
+		return types.ErrInvalidAccountToken
+	}
+
+	fn := func(ctx context.Context) error {
+		userID, err := s.tokens.ConsumeAccountToken(ctx, claims.ID, types.PurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if userID != claims.Subject {
+			return types.ErrInvalidAccountToken
+		}
+		return s.repo.MarkEmailVerified(ctx, userID)
+	}
+	if err = s.txm.Do(ctx, fn); err != nil {
+		if errors.Is(err, types.ErrInvalidAccountToken) {
+			log.Warn(ctx, action.VerifyEmail, "verification token is used or expired", "userID", claims.Subject)
+			return err
+		}
+		log.Error(ctx, action.VerifyEmail, "error verifying email", "userID", claims.Subject, "error", err)
+		return err
+	}
+
+	log.Info(ctx, action.VerifyEmail, "email verified", "userID", claims.Subject)
+	return nil
+}
+
+// ResendVerification emails a new verification link. Unknown and already
+// verified emails are silently ignored, so the response does not reveal them.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	log := s.log.Func("ResendVerification")
+
+	u, err := s.repo.GetGyUserEmail(ctx, email)
+	if errors.Is(err, types.ErrUserNotFound) {
+		log.Debug(ctx, action.VerifyEmail, "verification requested for unknown email", "email", email)
+		return nil
+	}
+	if err != nil {
+		log.Error(ctx, action.VerifyEmail, "error getting user", "email", email, "error", err)
+		return err
+	}
+	if u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if err = s.sendVerification(ctx, u); err != nil {
+		log.Error(ctx, action.VerifyEmail, "error sending verification email", "userID", u.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ForgotPassword emails a password reset link. Unknown emails are silently
+// ignored, so the response does not reveal which emails are registered.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	log := s.log.Func("ForgotPassword")
+
+	u, err := s.repo.GetGyUserEmail(ctx, email)
+	if errors.Is(err, types.ErrUserNotFound) {
+		log.Debug(ctx, action.ResetPassword, "reset requested for unknown email", "email", email)
+		return nil
+	}
+	if err != nil {
+		log.Error(ctx, action.ResetPassword, "error getting user", "email", email, "error", err)
+		return err
+	}
+
+	token, err := s.issueAccountToken(ctx, u.ID, types.PurposeResetPassword, s.cfg.Account.ResetTTL)
+	if err != nil {
+		log.Error(ctx, action.ResetPassword, "error issuing reset token", "userID", u.ID, "error", err)
+		return err
+	}
+
+	err = s.mailer.Send(ctx, models.Email{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your account. Follow the link within %s to choose a new one:\n\n%s/password/reset?token=%s\n\n"+
+			"If it was not you, ignore this email, your password stays the same.", s.cfg.Account.ResetTTL, s.cfg.Account.LinkURL, token),
+	})
+	if err != nil {
+		log.Error(ctx, action.ResetPassword, "error sending reset email", "userID", u.ID, "error", err)
+		return err
+	}
+
+	log.Info(ctx, action.ResetPassword, "password reset email sent", "userID", u.ID)
+	return nil
+}
+
+// CheckResetToken returns the user of a usable reset token without using it
+// up, so that the new password can be validated against the user's email.
+func (s *AuthService) CheckResetToken(ctx context.Context, token string) (models.User, error) {
+	log := s.log.Func("CheckResetToken")
+
+	claims, err := s.parseAccountToken(token, types.PurposeResetPassword)
+	if err != nil {
+		log.Warn(ctx, action.ResetPassword, "invalid reset token", "error", err)
+		return models.User{}, types.ErrInvalidAccountToken
+	}
+
+	userID, err := s.tokens.GetAccountTokenUser(ctx, claims.ID, types.PurposeResetPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+	if userID != claims.Subject {
+		return models.User{}, types.ErrInvalidAccountToken
+	}
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// ResetPassword consumes a reset token and sets the new password. All sessions
+// of the user are revoked and the account lockout is lifted; the email counts
+// as verified since the link reached it.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	log := s.log.Func("ResetPassword")
+
+	claims, err := s.parseAccountToken(token, types.PurposeResetPassword)
+	if err != nil {
+		log.Warn(ctx, action.ResetPassword, "invalid reset token", "error", err)
+		return types.ErrInvalidAccountToken
+	}
+
+	hashPass, err := s.hasher.HashPassword(password)
+	if err != nil {
+		log.Error(ctx, action.ResetPassword, "error hashing password", "error", err)
+		return err
+	}
+
+	var u models.User
+	fn := func(ctx context.Context) error {
+		userID, err := s.tokens.ConsumeAccountToken(ctx, claims.ID, types.PurposeResetPassword)
+		if err != nil {
+			return err
+		}
+		if userID != claims.Subject {
+			return types.ErrInvalidAccountToken
+		}
+		if u, err = s.repo.GetUserByID(ctx, userID); err != nil {
+			return err
+		}
+		if err = s.repo.UpdatePassword(ctx, userID, hashPass); err != nil {
+			return err
+		}
+		if err = s.repo.MarkEmailVerified(ctx, userID); err != nil {
+			return err
+		}
+		return s.tokens.RevokeUserTokens(ctx, userID, time.Now().Add(s.cfg.JWT.AccessTTL))
+	}
+	if err = s.txm.Do(ctx, fn); err != nil {
+		if errors.Is(err, types.ErrInvalidAccountToken) {
+			log.Warn(ctx, action.ResetPassword, "reset token is used or expired", "userID", claims.Subject)
+			return err
+		}
+		log.Error(ctx, action.ResetPassword, "error resetting password", "userID", claims.Subject, "error", err)
+		return err
+	}
+
+	if _, err = s.attempts.ClearFailures(ctx, loginKeys(u.Email, "")[0]); err != nil {
+		log.Warn(ctx, action.ResetPassword, "error clearing login failures", "userID", u.ID, "error", err)
+	}
+
+	log.Info(ctx, action.ResetPassword, "password reset", "userID", u.ID)
+	return nil
+}
+
+func (s *AuthService) sendVerification(ctx context.Context, u models.User) error {
+	token, err := s.issueAccountToken(ctx, u.ID, types.PurposeVerifyEmail, s.cfg.Account.VerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, models.Email{
+		To:      u.Email,
+		Subject: "Confirm your email",
+		Text: fmt.Sprintf("Welcome to ride-hail! Follow the link within %s to confirm your email:\n\n%s/email/verify?token=%s",
+			s.cfg.Account.VerifyTTL, s.cfg.Account.LinkURL, token),
+	})
+}
+
+// issueAccountToken signs a single-use token of the purpose and stores its jti.
+func (s *AuthService) issueAccountToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := models.AccountTokenClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newClaimsID(),
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := s.signer.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign account token: %w", err)
+	}
+
+	if err = s.tokens.CreateAccountToken(ctx, models.AccountToken{
+		ID:        claims.ID,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// parseAccountToken verifies the signature, expiry and purpose of the token.
+// Access tokens carry no purpose, so they are never accepted here.
+func (s *AuthService) parseAccountToken(token, purpose string) (models.AccountTokenClaims, error) {
+	var claims models.AccountTokenClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, s.signer.Keyfunc, jwt.WithExpirationRequired()); err != nil {
+		return models.AccountTokenClaims{}, err
+	}
+	if claims.Purpose != purpose || claims.ID == "" || claims.Subject == "" {
+		return models.AccountTokenClaims{}, fmt.Errorf("token is not a %s token", purpose)
+	}
+	return claims, nil
+}
+
+// newRefreshToken returns a random token. Unlike claims ids it never falls back
+// to a predictable value.
+func newRefreshToken() string {
+	return rand.Text() + rand.Text()
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func newClaimsID() string {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/config"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		base time.Duration
+		max  time.Duration
+		over int
+		want time.Duration
+	}{
+		{"first lock", time.Minute, time.Hour, 0, time.Minute},
+		{"negative overshoot", time.Minute, time.Hour, -3, time.Minute},
+		{"second lock doubles", time.Minute, time.Hour, 1, 2 * time.Minute},
+		{"fifth lock", time.Minute, time.Hour, 4, 16 * time.Minute},
+		{"capped", time.Minute, time.Hour, 6, time.Hour},
+		{"far past the cap", time.Minute, time.Hour, 1000, time.Hour},
+		{"base above max", 2 * time.Hour, time.Hour, 0, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockoutDuration(tt.base, tt.max, tt.over); got != tt.want {
+				t.Errorf("lockoutDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		ip    string
+		want  []string
+	}{
+		{"account and address", " User@Example.com ", "203.0.113.7", []string{"account:user@example.com", "ip:203.0.113.7"}},
+		{"ipv4-mapped address", "user@example.com", "::ffff:203.0.113.7", []string{"account:user@example.com", "ip:203.0.113.7"}},
+		{"unknown address", "user@example.com", "", []string{"account:user@example.com"}},
+		{"invalid address", "user@example.com", "not-an-ip", []string{"account:user@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginKeys(tt.email, tt.ip); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loginKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// refreshTokens stores refresh tokens by hash like TokenRepository.
+type refreshTokens struct {
+	ports.TokenRepository
+	byHash  map[string]*models.RefreshToken
+	revoked []string // revoked families
+}
+
+func (r *refreshTokens) CreateRefreshToken(ctx context.Context, t models.RefreshToken) error {
+	t.ID = "rt-" + strconv.Itoa(len(r.byHash)+1)
+	r.byHash[t.TokenHash] = &t
+	return nil
+}
+
+func (r *refreshTokens) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	t, ok := r.byHash[tokenHash]
+	if !ok {
+		return models.RefreshToken{}, types.ErrInvalidRefreshToken
+	}
+	return *t, nil
+}
+
+func (r *refreshTokens) MarkRefreshTokenUsed(ctx context.Context, id string) error {
+	now := time.Now()
+	for _, t := range r.byHash {
+		if t.ID == id {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *refreshTokens) RevokeTokenFamily(ctx context.Context, familyID string, accessExpiresAt time.Time) error {
+	now := time.Now()
+	for _, t := range r.byHash {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	r.revoked = append(r.revoked, familyID)
+	return nil
+}
+
+type oneUser struct {
+	ports.UserRepository
+	user models.User
+}
+
+func (u oneUser) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	return u.user, nil
+}
+
+type stubSigner struct {
+	ports.TokenSigner
+}
+
+func (stubSigner) Sign(claims jwt.Claims) (string, error) {
+	return "access-token", nil
+}
+
+func newRefreshAuth(tokens *refreshTokens) *AuthService {
+	var cfg config.Config
+	cfg.JWT.AccessTTL = 15 * time.Minute
+	cfg.JWT.ExpireHours = 24
+	users := oneUser{user: models.User{ID: "user-1", Role: types.RolePassenger}}
+	return NewAuthService(cfg, fakeTx{}, users, nil, tokens, nil, stubSigner{}, nil, discardLogger())
+}
+
+func TestRefreshRotatesTheTokenWithinItsFamily(t *testing.T) {
+	tokens := &refreshTokens{byHash: map[string]*models.RefreshToken{}}
+	svc := newRefreshAuth(tokens)
+	tokens.CreateRefreshToken(context.Background(), models.RefreshToken{
+		UserID: "user-1", FamilyID: "family-1", TokenHash: hashToken("first"), ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	pair, err := svc.Refresh(context.Background(), "first")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if pair.RefreshToken == "" || pair.RefreshToken == "first" {
+		t.Fatalf("refresh token was not rotated: %q", pair.RefreshToken)
+	}
+	next, ok := tokens.byHash[hashToken(pair.RefreshToken)]
+	if !ok || next.FamilyID != "family-1" || next.UserID != "user-1" {
+		t.Errorf("new refresh token %+v; want it stored in family-1 for user-1", next)
+	}
+	if tokens.byHash[hashToken("first")].UsedAt == nil {
+		t.Error("the presented token is not marked used")
+	}
+	if len(tokens.revoked) != 0 {
+		t.Errorf("families revoked on a normal refresh: %v", tokens.revoked)
+	}
+}
+
+// A used token presented again means it leaked: whoever holds the family,
+// the thief or the user, must log in again.
+func TestRefreshWithAUsedTokenRevokesTheFamily(t *testing.T) {
+	tokens := &refreshTokens{byHash: map[string]*models.RefreshToken{}}
+	svc := newRefreshAuth(tokens)
+	tokens.CreateRefreshToken(context.Background(), models.RefreshToken{
+		UserID: "user-1", FamilyID: "family-1", TokenHash: hashToken("stolen"), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	ctx := context.Background()
+
+	pair, err := svc.Refresh(ctx, "stolen")
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	if _, err = svc.Refresh(ctx, "stolen"); !errors.Is(err, types.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if len(tokens.revoked) != 1 || tokens.revoked[0] != "family-1" {
+		t.Fatalf("revoked families %v, want [family-1]", tokens.revoked)
+	}
+
+	// the token issued by the first refresh died with its family
+	if _, err = svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, types.ErrRefreshTokenReused) {
+		t.Errorf("rotated token after revocation: err = %v, want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestRefreshRejectsExpiredAndUnknownTokensWithoutRevoking(t *testing.T) {
+	tokens := &refreshTokens{byHash: map[string]*models.RefreshToken{}}
+	svc := newRefreshAuth(tokens)
+	tokens.CreateRefreshToken(context.Background(), models.RefreshToken{
+		UserID: "user-1", FamilyID: "family-1", TokenHash: hashToken("old"), ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	for _, token := range []string{"old", "never-issued"} {
+		if _, err := svc.Refresh(context.Background(), token); !errors.Is(err, types.ErrInvalidRefreshToken) {
+			t.Errorf("Refresh(%q): err = %v, want ErrInvalidRefreshToken", token, err)
+		}
+	}
+	if len(tokens.revoked) != 0 {
+		t.Errorf("families revoked for an invalid token: %v", tokens.revoked)
+	}
+}
+
+func (r *refreshTokens) CreateAccountToken(ctx context.Context, t models.AccountToken) error {
+	return nil
+}
+
+// userTable stores registered users; drivers fail with driverErr when set.
+type userTable struct {
+	ports.UserRepository
+	ports.DriverRepository
+	users     []models.User
+	drivers   []models.Driver
+	driverErr error
+}
+
+func (u *userTable) CreateNewUser(ctx context.Context, user models.User) (string, error) {
+	user.ID = "user-" + strconv.Itoa(len(u.users)+1)
+	u.users = append(u.users, user)
+	return user.ID, nil
+}
+
+func (u *userTable) CreateDriver(ctx context.Context, driver models.Driver) (string, error) {
+	if u.driverErr != nil {
+		return "", u.driverErr
+	}
+	u.drivers = append(u.drivers, driver)
+	return driver.ID, nil
+}
+
+type outbox []models.Email
+
+func (o *outbox) Send(ctx context.Context, email models.Email) error {
+	*o = append(*o, email)
+	return nil
+}
+
+func newRegistrationAuth(table *userTable, mail *outbox) *AuthService {
+	var cfg config.Config
+	cfg.Argon2.MemoryKiB, cfg.Argon2.Iterations, cfg.Argon2.Parallelism = 64, 1, 1
+	cfg.Account.VerifyTTL = time.Hour
+	tokens := &refreshTokens{byHash: map[string]*models.RefreshToken{}}
+	return NewAuthService(cfg, fakeTx{}, table, table, tokens, nil, stubSigner{}, mail, discardLogger())
+}
+
+func TestCreateNewUserRegistersDriversWithTheirProfile(t *testing.T) {
+	table := &userTable{}
+	mail := &outbox{}
+	svc := newRegistrationAuth(table, mail)
+
+	err := svc.CreateNewUser(context.Background(), models.RegistrationRequest{
+		Email: "driver@example.com", Password: "long enough secret", Role: types.RoleDriver, Name: " Aida ", Phone: "+77010000001",
+		Driver: &models.DriverProfile{
+			LicenseNumber: "DL-1", VehicleType: types.RideTypeECONOMY,
+			Vehicle: models.VehicleAttrs{Make: "Toyota", Plate: "123ABC02"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateNewUser: %v", err)
+	}
+
+	if len(table.users) != 1 || len(table.drivers) != 1 {
+		t.Fatalf("%d users and %d drivers stored, want one of each", len(table.users), len(table.drivers))
+	}
+	u, d := table.users[0], table.drivers[0]
+	if u.Role != types.RoleDriver || u.Name != "Aida" || u.EmailVerifiedAt != nil {
+		t.Errorf("user %+v, want an unverified DRIVER named Aida", u)
+	}
+	if u.Password == "long enough secret" || !strings.HasPrefix(u.Password, "$argon2id$") {
+		t.Errorf("password stored as %q, want an argon2id hash", u.Password)
+	}
+	if d.ID != "user-1" || d.Status != types.DriverStatusOffline || !strings.Contains(string(d.VehicleAttrs), "123ABC02") {
+		t.Errorf("driver %+v, want the OFFLINE driver row of user-1 with its vehicle", d)
+	}
+	if len(*mail) != 1 || (*mail)[0].To != "driver@example.com" {
+		t.Errorf("emails %+v, want one verification email to the driver", *mail)
+	}
+}
+
+func TestCreateNewUserFailsWithoutTheDriverRow(t *testing.T) {
+	licenseTaken := errors.New("license number already registered")
+	table := &userTable{driverErr: licenseTaken}
+	mail := &outbox{}
+	svc := newRegistrationAuth(table, mail)
+
+	err := svc.CreateNewUser(context.Background(), models.RegistrationRequest{
+		Email: "driver@example.com", Password: "long enough secret", Role: types.RoleDriver, Name: "Aida",
+		Driver: &models.DriverProfile{LicenseNumber: "DL-1", VehicleType: types.RideTypeECONOMY},
+	})
+	if !errors.Is(err, licenseTaken) {
+		t.Fatalf("err = %v, want the driver error", err)
+	}
+	if len(*mail) != 0 {
+		t.Error("verification email sent for a failed registration")
+	}
+}
+
+func TestBootstrapAdminCreatesAVerifiedAdmin(t *testing.T) {
+	table := &userTable{}
+	mail := &outbox{}
+	svc := newRegistrationAuth(table, mail)
+
+	// the role of the request is ignored
+	if err := svc.BootstrapAdmin(context.Background(), models.RegistrationRequest{
+		Email: "root@example.com", Password: "long enough secret", Role: types.RolePassenger, Name: "Root",
+	}); err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	if u := table.users[0]; u.Role != types.RoleAdmin || u.EmailVerifiedAt == nil {
+		t.Errorf("user %+v, want a verified ADMIN", u)
+	}
+	if len(table.drivers) != 0 || len(*mail) != 0 {
+		t.Errorf("bootstrap created %d drivers and sent %d emails, want none", len(table.drivers), len(*mail))
+	}
+}
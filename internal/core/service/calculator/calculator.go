@@ -3,13 +3,45 @@ package calculator
 import (
 	"fmt"
 	"math"
+	"ride-hail/internal/core/domain/models"
 	"ride-hail/internal/core/domain/types"
+	"time"
 )
 
 const earthRadius = 6371.0
 
 const avgSpeedKmH = 30 // km/h
 
+// WaitingGrace is the free waiting time after the driver has ARRIVED.
+const WaitingGrace = 3 * time.Minute
+
+const (
+	nightMultiplier = 1.2
+	peakMultiplier  = 1.3
+)
+
+type Tariff struct {
+	BaseFare   float64
+	RatePerKm  float64
+	RatePerMin float64
+	WaitPerMin float64
+}
+
+var tariffs = map[string]Tariff{
+	types.RideTypeECONOMY: {BaseFare: 500, RatePerKm: 100, RatePerMin: 50, WaitPerMin: 30},
+	types.RideTypePREMIUM: {BaseFare: 800, RatePerKm: 120, RatePerMin: 60, WaitPerMin: 40},
+	types.RideTypeXL:      {BaseFare: 1000, RatePerKm: 150, RatePerMin: 75, WaitPerMin: 50},
+	types.RideTypePOOL:    {BaseFare: 350, RatePerKm: 75, RatePerMin: 35, WaitPerMin: 30},
+}
+
+func GetTariff(rideType string) (Tariff, error) {
+	t, ok := tariffs[rideType]
+	if !ok {
+		return Tariff{}, fmt.Errorf("unknown ride type: %s", rideType)
+	}
+	return t, nil
+}
+
 func Distance(lat1, lon1, lat2, lon2 float64) float64 {
 	dLat := (lat2 - lat1) * math.Pi / 180.0
 	dLon := (lon2 - lon1) * math.Pi / 180.0
@@ -29,25 +61,129 @@ func Duration(dist float64) int {
 }
 
 func CalculateFare(rideType string, distanceKm float64, durationMin int) (float64, error) {
-	var baseFare, ratePerKm, ratePerMin float64
-
-	switch rideType {
-	case types.RideTypeECONOMY:
-		baseFare = 500
-		ratePerKm = 100
-		ratePerMin = 50
-	case types.RideTypePREMIUM:
-		baseFare = 800
-		ratePerKm = 120
-		ratePerMin = 60
-	case types.RideTypeXL:
-		baseFare = 1000
-		ratePerKm = 150
-		ratePerMin = 75
-	default:
-		return 0, fmt.Errorf("unknown ride type: %s", rideType)
+	t, err := GetTariff(rideType)
+	if err != nil {
+		return 0, err
 	}
 
-	total := baseFare + (distanceKm * ratePerKm) + (float64(durationMin) * ratePerMin)
+	total := t.BaseFare + (distanceKm * t.RatePerKm) + (float64(durationMin) * t.RatePerMin)
 	return total, nil
 }
+
+type FareInput struct {
+	RideType        string
+	DistanceKM      float64
+	DurationMinutes int
+	LocalTime       time.Time // pickup time in the city's local zone
+	ArrivedAt       *time.Time
+	StartedAt       *time.Time
+	Stops           []models.RideStop
+	ExtraCharges    []models.ExtraCharge
+	PoolShare       float64 // split of the trip fare; replaces distance and time for POOL rides
+}
+
+// FinalFare computes the itemized fare of a completed ride. The time-of-day
+// multiplier applies to the metered part (base, distance, time); waiting time
+// at pickup and at each stop and driver-recorded extras are added on top.
+// A POOL ride with a PoolShare pays its share of the trip fare as the metered part.
+func FinalFare(in FareInput) (models.FareBreakdown, error) {
+	t, err := GetTariff(in.RideType)
+	if err != nil {
+		return models.FareBreakdown{}, err
+	}
+
+	period, multiplier := TimePeriod(in.LocalTime)
+	waiting := WaitingMinutes(in.ArrivedAt, in.StartedAt)
+	for _, stop := range in.Stops {
+		waiting += WaitingMinutes(stop.ArrivedAt, stop.DepartedAt)
+	}
+
+	b := models.FareBreakdown{
+		RideType:        in.RideType,
+		BaseFare:        round2(t.BaseFare * multiplier),
+		DistanceKM:      round2(in.DistanceKM),
+		DistanceFare:    round2(in.DistanceKM * t.RatePerKm * multiplier),
+		DurationMinutes: in.DurationMinutes,
+		TimeFare:        round2(float64(in.DurationMinutes) * t.RatePerMin * multiplier),
+		Period:          period,
+		Multiplier:      multiplier,
+		WaitingMinutes:  waiting,
+		WaitingFare:     round2(float64(waiting) * t.WaitPerMin),
+		ExtraCharges:    make([]models.ExtraCharge, 0, len(in.ExtraCharges)),
+	}
+
+	if in.RideType == types.RideTypePOOL && in.PoolShare > 0 {
+		b.BaseFare = round2(in.PoolShare * multiplier)
+		b.DistanceFare, b.TimeFare = 0, 0
+	}
+
+	for _, c := range in.ExtraCharges {
+		if c.Amount < 0 {
+			return models.FareBreakdown{}, fmt.Errorf("negative extra charge: %s", c.Type)
+		}
+		c.Amount = round2(c.Amount)
+		b.ExtrasTotal += c.Amount
+		b.ExtraCharges = append(b.ExtraCharges, c)
+	}
+
+	b.ExtrasTotal = round2(b.ExtrasTotal)
+	b.Subtotal = round2(b.BaseFare + b.DistanceFare + b.TimeFare + b.WaitingFare + b.ExtrasTotal)
+	b.Total = b.Subtotal
+	return b, nil
+}
+
+// Discount returns the amount a promo code takes off the fare.
+func Discount(p models.PromoCode, fare float64) float64 {
+	var d float64
+	switch p.DiscountType {
+	case types.DiscountTypePercent:
+		d = fare * p.Value / 100
+	case types.DiscountTypeFixed:
+		d = p.Value
+	}
+	if p.MaxDiscount != nil && d > *p.MaxDiscount {
+		d = *p.MaxDiscount
+	}
+	if d > fare {
+		d = fare
+	}
+	return round2(d)
+}
+
+// ApplyDiscount subtracts the promo discount from the breakdown total.
+func ApplyDiscount(b *models.FareBreakdown, code string, discount float64) {
+	b.PromoCode = code
+	b.Discount = round2(discount)
+	b.Total = round2(b.Subtotal - b.Discount)
+}
+
+// TimePeriod returns the pricing period for a local time: night 22:00-06:00,
+// peak 07:00-10:00 and 17:00-20:00 on weekdays.
+func TimePeriod(local time.Time) (string, float64) {
+	h := local.Hour()
+	switch {
+	case h >= 22 || h < 6:
+		return types.FarePeriodNight, nightMultiplier
+	case local.Weekday() != time.Saturday && local.Weekday() != time.Sunday &&
+		((h >= 7 && h < 10) || (h >= 17 && h < 20)):
+		return types.FarePeriodPeak, peakMultiplier
+	default:
+		return types.FarePeriodNormal, 1
+	}
+}
+
+// WaitingMinutes returns the paid minutes between arrival and start of the ride.
+func WaitingMinutes(arrivedAt, startedAt *time.Time) int {
+	if arrivedAt == nil || startedAt == nil {
+		return 0
+	}
+	paid := startedAt.Sub(*arrivedAt) - WaitingGrace
+	if paid <= 0 {
+		return 0
+	}
+	return int(math.Ceil(paid.Minutes()))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,157 @@
+package calculator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+)
+
+func TestFinalFare(t *testing.T) {
+	// Wednesday
+	noon := time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := noon.Add(d)
+		return &v
+	}
+
+	tests := []struct {
+		name    string
+		in      FareInput
+		want    models.FareBreakdown
+		wantErr bool
+	}{
+		{
+			name: "normal",
+			in:   FareInput{RideType: types.RideTypeECONOMY, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon},
+			want: models.FareBreakdown{
+				RideType: types.RideTypeECONOMY, BaseFare: 500, DistanceKM: 10, DistanceFare: 1000,
+				DurationMinutes: 20, TimeFare: 1000, Period: types.FarePeriodNormal, Multiplier: 1,
+				ExtraCharges: []models.ExtraCharge{}, Subtotal: 2500, Total: 2500,
+			},
+		},
+		{
+			name: "night",
+			in:   FareInput{RideType: types.RideTypeECONOMY, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon.Add(11 * time.Hour)},
+			want: models.FareBreakdown{
+				RideType: types.RideTypeECONOMY, BaseFare: 600, DistanceKM: 10, DistanceFare: 1200,
+				DurationMinutes: 20, TimeFare: 1200, Period: types.FarePeriodNight, Multiplier: nightMultiplier,
+				ExtraCharges: []models.ExtraCharge{}, Subtotal: 3000, Total: 3000,
+			},
+		},
+		{
+			name: "weekday peak",
+			in:   FareInput{RideType: types.RideTypeECONOMY, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon.Add(-4 * time.Hour)},
+			want: models.FareBreakdown{
+				RideType: types.RideTypeECONOMY, BaseFare: 650, DistanceKM: 10, DistanceFare: 1300,
+				DurationMinutes: 20, TimeFare: 1300, Period: types.FarePeriodPeak, Multiplier: peakMultiplier,
+				ExtraCharges: []models.ExtraCharge{}, Subtotal: 3250, Total: 3250,
+			},
+		},
+		{
+			name: "weekend morning is not peak",
+			in:   FareInput{RideType: types.RideTypeECONOMY, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon.AddDate(0, 0, 3).Add(-4 * time.Hour)},
+			want: models.FareBreakdown{
+				RideType: types.RideTypeECONOMY, BaseFare: 500, DistanceKM: 10, DistanceFare: 1000,
+				DurationMinutes: 20, TimeFare: 1000, Period: types.FarePeriodNormal, Multiplier: 1,
+				ExtraCharges: []models.ExtraCharge{}, Subtotal: 2500, Total: 2500,
+			},
+		},
+		{
+			name: "waiting at pickup and stops, extras",
+			in: FareInput{
+				RideType: types.RideTypeECONOMY, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon,
+				// 2.5 paid minutes at pickup round up to 3, one at the stop
+				ArrivedAt: at(0), StartedAt: at(5*time.Minute + 30*time.Second),
+				Stops: []models.RideStop{
+					{Sequence: 1, ArrivedAt: at(10 * time.Minute), DepartedAt: at(14 * time.Minute)},
+					{Sequence: 2, ArrivedAt: at(20 * time.Minute), DepartedAt: at(22 * time.Minute)},
+					{Sequence: 3, ArrivedAt: at(30 * time.Minute)},
+				},
+				ExtraCharges: []models.ExtraCharge{{Type: "TOLL", Amount: 200}, {Type: "CLEANING", Amount: 99.999}},
+			},
+			want: models.FareBreakdown{
+				RideType: types.RideTypeECONOMY, BaseFare: 500, DistanceKM: 10, DistanceFare: 1000,
+				DurationMinutes: 20, TimeFare: 1000, Period: types.FarePeriodNormal, Multiplier: 1,
+				WaitingMinutes: 4, WaitingFare: 120,
+				ExtraCharges: []models.ExtraCharge{{Type: "TOLL", Amount: 200}, {Type: "CLEANING", Amount: 100}},
+				ExtrasTotal:  300, Subtotal: 2920, Total: 2920,
+			},
+		},
+		{
+			name: "pool share replaces distance and time",
+			in:   FareInput{RideType: types.RideTypePOOL, DistanceKM: 10, DurationMinutes: 20, LocalTime: noon.Add(11 * time.Hour), PoolShare: 400},
+			want: models.FareBreakdown{
+				RideType: types.RideTypePOOL, BaseFare: 480, DistanceKM: 10, DurationMinutes: 20,
+				Period: types.FarePeriodNight, Multiplier: nightMultiplier,
+				ExtraCharges: []models.ExtraCharge{}, Subtotal: 480, Total: 480,
+			},
+		},
+		{
+			name:    "negative extra charge",
+			in:      FareInput{RideType: types.RideTypeECONOMY, LocalTime: noon, ExtraCharges: []models.ExtraCharge{{Type: "TOLL", Amount: -1}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown ride type",
+			in:      FareInput{RideType: "BIKE", LocalTime: noon},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FinalFare(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FinalFare() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FinalFare() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FinalFare() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	limit := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name  string
+		promo models.PromoCode
+		fare  float64
+		want  float64
+	}{
+		{"percent", models.PromoCode{DiscountType: types.DiscountTypePercent, Value: 10}, 2500, 250},
+		{"percent rounded", models.PromoCode{DiscountType: types.DiscountTypePercent, Value: 15}, 1234.5, 185.18},
+		{"percent capped", models.PromoCode{DiscountType: types.DiscountTypePercent, Value: 50, MaxDiscount: limit(500)}, 2500, 500},
+		{"percent under cap", models.PromoCode{DiscountType: types.DiscountTypePercent, Value: 10, MaxDiscount: limit(500)}, 2500, 250},
+		{"fixed", models.PromoCode{DiscountType: types.DiscountTypeFixed, Value: 300}, 2500, 300},
+		{"fixed above fare", models.PromoCode{DiscountType: types.DiscountTypeFixed, Value: 3000}, 2500, 2500},
+		{"unknown type", models.PromoCode{DiscountType: "BOGUS", Value: 300}, 2500, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Discount(tt.promo, tt.fare); got != tt.want {
+				t.Errorf("Discount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDiscount(t *testing.T) {
+	b := models.FareBreakdown{Subtotal: 2920, Total: 2920}
+	ApplyDiscount(&b, "WELCOME10", 292.004)
+
+	if b.PromoCode != "WELCOME10" || b.Discount != 292 || b.Total != 2628 {
+		t.Errorf("ApplyDiscount() = code %q, discount %v, total %v; want WELCOME10, 292, 2628", b.PromoCode, b.Discount, b.Total)
+	}
+}
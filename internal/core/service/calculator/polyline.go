@@ -0,0 +1,48 @@
+package calculator
+
+import (
+	"math"
+	"strings"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// EncodePolyline encodes points with the Google encoded polyline algorithm
+// (precision 1e5), as accepted by static map providers.
+func EncodePolyline(points []models.Point) string {
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * 1e5))
+		lng := int64(math.Round(p.Lng * 1e5))
+		encodeValue(&b, lat-prevLat)
+		encodeValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+// SamplePoints keeps at most max points, always including the first and last.
+func SamplePoints(points []models.Point, max int) []models.Point {
+	if max < 2 || len(points) <= max {
+		return points
+	}
+	step := float64(len(points)-1) / float64(max-1)
+	sampled := make([]models.Point, 0, max)
+	for i := 0; i < max; i++ {
+		sampled = append(sampled, points[int(math.Round(float64(i)*step))])
+	}
+	return sampled
+}
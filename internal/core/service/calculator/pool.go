@@ -0,0 +1,159 @@
+package calculator
+
+import (
+	"encoding/json"
+	"math"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+)
+
+// MaxPoolSeats is the number of seats one passenger can book on a pool ride.
+const MaxPoolSeats = 2
+
+// PoolPolicy limits how pool rides are combined. MaxDetour is the allowed
+// extra in-vehicle distance of every passenger relative to the direct route
+// (0.5 = 50% longer); MaxPickupKM is the radius for starting a new trip.
+type PoolPolicy struct {
+	MaxDetour   float64
+	MaxPickupKM float64
+}
+
+// defaultSeats is the passenger capacity of a vehicle type when vehicle_attrs
+// has no "seats" attribute.
+var defaultSeats = map[string]int{
+	types.RideTypeECONOMY: 3,
+	types.RideTypeXL:      5,
+}
+
+// PoolSeats returns the passenger capacity from vehicle_attrs, or the default
+// of the vehicle type. Zero means the vehicle does not serve pool rides.
+func PoolSeats(vehicleType string, attrs []byte) int {
+	var a struct {
+		Seats int `json:"seats"`
+	}
+	if len(attrs) > 0 && json.Unmarshal(attrs, &a) == nil && a.Seats > 0 {
+		return a.Seats
+	}
+	return defaultSeats[vehicleType]
+}
+
+// InsertRide returns the plan with the pickup and dropoff of a new ride
+// inserted where they add the least distance, keeping stops that are already
+// done in front, the vehicle within capacity and every passenger within the
+// detour limit. ok is false when no insertion satisfies the constraints.
+func InsertRide(plan []models.PlanStop, pickup, dropoff models.PlanStop, capacity int, maxDetour float64) ([]models.PlanStop, float64, bool) {
+	first := 0
+	for first < len(plan) && plan[first].Done {
+		first++
+	}
+
+	base := planLength(plan[first:])
+	best, bestAdded := []models.PlanStop(nil), math.Inf(1)
+
+	for i := first; i <= len(plan); i++ {
+		for j := i; j <= len(plan); j++ {
+			candidate := make([]models.PlanStop, 0, len(plan)+2)
+			candidate = append(candidate, plan[:i]...)
+			candidate = append(candidate, pickup)
+			candidate = append(candidate, plan[i:j]...)
+			candidate = append(candidate, dropoff)
+			candidate = append(candidate, plan[j:]...)
+
+			if !fitsCapacity(candidate, capacity) || !withinDetour(candidate[first:], maxDetour) {
+				continue
+			}
+			if added := planLength(candidate[first:]) - base; added < bestAdded {
+				best, bestAdded = candidate, added
+			}
+		}
+	}
+	return best, bestAdded, best != nil
+}
+
+// PlanLength is the straight-line length of the whole trip plan in km.
+func PlanLength(plan []models.PlanStop) float64 {
+	return planLength(plan)
+}
+
+// SplitFare divides the trip fare between passengers in proportion to their
+// weights (direct distances). Rounding leftovers go to the last passenger.
+func SplitFare(total float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+
+	var assigned float64
+	for i, w := range weights {
+		if i == len(weights)-1 {
+			shares[i] = round2(total - assigned)
+			break
+		}
+		if sum > 0 {
+			shares[i] = round2(total * w / sum)
+		} else {
+			shares[i] = round2(total / float64(len(weights)))
+		}
+		assigned += shares[i]
+	}
+	return shares
+}
+
+func planLength(plan []models.PlanStop) float64 {
+	var km float64
+	for i := 1; i < len(plan); i++ {
+		km += Distance(plan[i-1].Latitude, plan[i-1].Longitude, plan[i].Latitude, plan[i].Longitude)
+	}
+	return km
+}
+
+func fitsCapacity(plan []models.PlanStop, capacity int) bool {
+	onboard := 0
+	for _, s := range plan {
+		switch s.Kind {
+		case types.PlanStopPickup:
+			onboard += s.Seats
+		case types.PlanStopDropoff:
+			onboard -= s.Seats
+		}
+		if onboard > capacity {
+			return false
+		}
+	}
+	return true
+}
+
+// withinDetour checks the passengers with both stops in plan; passengers
+// already on board have accepted their detour so far.
+func withinDetour(plan []models.PlanStop, maxDetour float64) bool {
+	pickups := make(map[string]int)
+	along := 0.0
+	distAt := make([]float64, len(plan))
+	for i := range plan {
+		if i > 0 {
+			along += Distance(plan[i-1].Latitude, plan[i-1].Longitude, plan[i].Latitude, plan[i].Longitude)
+		}
+		distAt[i] = along
+
+		switch plan[i].Kind {
+		case types.PlanStopPickup:
+			pickups[plan[i].RideID] = i
+		case types.PlanStopDropoff:
+			p, ok := pickups[plan[i].RideID]
+			if !ok {
+				continue
+			}
+			direct := Distance(plan[p].Latitude, plan[p].Longitude, plan[i].Latitude, plan[i].Longitude)
+			if distAt[i]-distAt[p] > direct*(1+maxDetour)+1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package calculator
+
+import "ride-hail/internal/core/domain/types"
+
+// DriverCancelBoost is added to the priority of a ride whose matched driver
+// cancelled it, so that the passenger does not wait in line again.
+const DriverCancelBoost = 3
+
+// LoyalRides is the number of completed rides after which a passenger counts
+// as a premium customer.
+const LoyalRides = 50
+
+// PriorityInput holds the facts about a ride that raise its matching priority.
+type PriorityInput struct {
+	RideType       string
+	Scheduled      bool // a scheduled ride dispatched shortly before pickup
+	CompletedRides int  // passenger's completed rides
+}
+
+// RidePriority returns the matching priority of a ride between
+// RidePriorityMin and RidePriorityMax. Scheduled rides nearing pickup get the
+// largest boost because their pickup time was promised in advance; premium
+// customers (PREMIUM rides, loyal passengers) come next.
+func RidePriority(in PriorityInput) int {
+	p := types.RidePriorityMin
+	if in.Scheduled {
+		p += 3
+	}
+	if in.RideType == types.RideTypePREMIUM {
+		p += 2
+	}
+	if in.CompletedRides >= LoyalRides {
+		p++
+	}
+	return min(p, types.RidePriorityMax)
+}
+
+// RaisePriority adds n to the priority of a ride sent back to matching, e.g.
+// one for every redispatch so that waiting rides are not starved.
+func RaisePriority(priority, n int) int {
+	return min(max(priority, types.RidePriorityMin)+n, types.RidePriorityMax)
+}
@@ -0,0 +1,205 @@
+package calculator
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/ports"
+)
+
+const SourceHaversine = "haversine"
+
+// HaversineRouter is the straight-line route provider used when no routing
+// engine is configured or the engine is unavailable.
+type HaversineRouter struct{}
+
+func NewHaversineRouter() *HaversineRouter {
+	return &HaversineRouter{}
+}
+
+func (HaversineRouter) Route(_ context.Context, from, to models.Point) (models.Route, error) {
+	dist := Distance(from.Lat, from.Lng, to.Lat, to.Lng)
+	return models.Route{
+		DistanceKM:      dist,
+		DurationMinutes: Duration(dist),
+		Source:          SourceHaversine,
+	}, nil
+}
+
+// MaxStops is the maximum number of intermediate stops of a ride.
+const MaxStops = 5
+
+// RouteLegs routes through the points in order and sums the legs.
+func RouteLegs(ctx context.Context, provider ports.RouteProvider, points []models.Point) (models.Route, error) {
+	var total models.Route
+	for i := 1; i < len(points); i++ {
+		leg, err := provider.Route(ctx, points[i-1], points[i])
+		if err != nil {
+			return models.Route{}, err
+		}
+		total.DistanceKM += leg.DistanceKM
+		total.DurationMinutes += leg.DurationMinutes
+		if total.Source == "" || leg.Source == SourceHaversine {
+			// report the fallback when any leg used it
+			total.Source = leg.Source
+		}
+	}
+	return total, nil
+}
+
+// defaultCacheSize is the number of routes kept when RouterOptions.CacheSize
+// is not set.
+const defaultCacheSize = 10000
+
+// MaxRouteFactor caps the distance a ride is charged for at this many times
+// the straight line through its pickup, stops and dropoff.
+const MaxRouteFactor = 2.0
+
+// PathLength returns the straight-line length of the path through the points.
+func PathLength(points []models.Point) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += Distance(points[i-1].Lat, points[i-1].Lng, points[i].Lat, points[i].Lng)
+	}
+	return total
+}
+
+// RideDistance returns the distance a ride is charged for: the trail of
+// locations recorded while it was in progress, capped at MaxRouteFactor times
+// the planned path, or the planned path when fewer than two locations were
+// recorded.
+func RideDistance(trail, planned []models.Point) float64 {
+	length := PathLength(planned)
+	if len(trail) < 2 {
+		return length
+	}
+	return min(PathLength(trail), length*MaxRouteFactor)
+}
+
+type RouterOptions struct {
+	Timeout   time.Duration
+	CacheTTL  time.Duration
+	CacheSize int // maximum number of cached routes
+	Precision int // number of decimal places coordinates are rounded to for the cache key
+}
+
+type cacheEntry struct {
+	key       string
+	route     models.Route
+	expiresAt time.Time
+}
+
+// CachedRouter asks the primary provider with a timeout, falls back to the
+// secondary one on error and caches successful primary answers. All entries
+// live for the same TTL, so the insertion order is also the expiry order:
+// expired entries are dropped from the front on every insert, and the oldest
+// one when the cache is full.
+type CachedRouter struct {
+	primary  ports.RouteProvider
+	fallback ports.RouteProvider
+	opts     RouterOptions
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	order *list.List // of *cacheEntry, oldest first
+}
+
+func NewCachedRouter(primary, fallback ports.RouteProvider, opts RouterOptions) *CachedRouter {
+	if opts.Precision <= 0 {
+		opts.Precision = 4
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = defaultCacheSize
+	}
+	return &CachedRouter{
+		primary:  primary,
+		fallback: fallback,
+		opts:     opts,
+		cache:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (r *CachedRouter) Route(ctx context.Context, from, to models.Point) (models.Route, error) {
+	key := r.cacheKey(from, to)
+	if route, ok := r.get(key); ok {
+		return route, nil
+	}
+
+	pctx := ctx
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		pctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	route, err := r.primary.Route(pctx, from, to)
+	if err != nil {
+		if r.fallback == nil {
+			return models.Route{}, err
+		}
+		return r.fallback.Route(ctx, from, to)
+	}
+
+	r.put(key, route)
+	return route, nil
+}
+
+func (r *CachedRouter) cacheKey(from, to models.Point) string {
+	p := r.opts.Precision
+	return fmt.Sprintf("%s,%s;%s,%s",
+		round(from.Lat, p), round(from.Lng, p),
+		round(to.Lat, p), round(to.Lng, p),
+	)
+}
+
+func (r *CachedRouter) get(key string) (models.Route, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	el, ok := r.cache[key]
+	if !ok {
+		return models.Route{}, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expiresAt) {
+		r.remove(el)
+		return models.Route{}, false
+	}
+	return e.route, true
+}
+
+func (r *CachedRouter) put(key string, route models.Route) {
+	if r.opts.CacheTTL <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := r.cache[key]; ok {
+		r.remove(el)
+	}
+	for el := r.order.Front(); el != nil; el = r.order.Front() {
+		if r.order.Len() < r.opts.CacheSize && !now.After(el.Value.(*cacheEntry).expiresAt) {
+			break
+		}
+		r.remove(el)
+	}
+	r.cache[key] = r.order.PushBack(&cacheEntry{key: key, route: route, expiresAt: now.Add(r.opts.CacheTTL)})
+}
+
+func (r *CachedRouter) remove(el *list.Element) {
+	r.order.Remove(el)
+	delete(r.cache, el.Value.(*cacheEntry).key)
+}
+
+func round(v float64, precision int) string {
+	pow := math.Pow(10, float64(precision))
+	return fmt.Sprintf("%.*f", precision, math.Round(v*pow)/pow)
+}
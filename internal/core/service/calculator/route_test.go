@@ -0,0 +1,221 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// stubRouter answers with route or err and counts the calls.
+type stubRouter struct {
+	route models.Route
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (s *stubRouter) Route(ctx context.Context, _, _ models.Point) (models.Route, error) {
+	s.calls++
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return models.Route{}, ctx.Err()
+	}
+	return s.route, s.err
+}
+
+func TestCachedRouterRoute(t *testing.T) {
+	osrm := models.Route{DistanceKM: 5, DurationMinutes: 10, Source: "osrm"}
+	straight := models.Route{DistanceKM: 4, DurationMinutes: 8, Source: SourceHaversine}
+	from := models.Point{Lat: 43.238949, Lng: 76.889709}
+	to := models.Point{Lat: 43.222015, Lng: 76.851511}
+	// differs from "from" below the cache precision
+	nearFrom := models.Point{Lat: 43.238921, Lng: 76.889689}
+
+	tests := []struct {
+		name          string
+		primary       *stubRouter
+		fallback      *stubRouter
+		opts          RouterOptions
+		second        models.Point // from point of the second call
+		want          models.Route
+		wantErr       bool
+		wantPrimary   int
+		wantFallbacks int
+	}{
+		{
+			name:        "cached primary answer",
+			primary:     &stubRouter{route: osrm},
+			fallback:    &stubRouter{route: straight},
+			opts:        RouterOptions{CacheTTL: time.Minute, Precision: 4},
+			second:      nearFrom,
+			want:        osrm,
+			wantPrimary: 1,
+		},
+		{
+			name:        "cache disabled",
+			primary:     &stubRouter{route: osrm},
+			fallback:    &stubRouter{route: straight},
+			opts:        RouterOptions{},
+			second:      from,
+			want:        osrm,
+			wantPrimary: 2,
+		},
+		{
+			name:        "other point is not cached",
+			primary:     &stubRouter{route: osrm},
+			fallback:    &stubRouter{route: straight},
+			opts:        RouterOptions{CacheTTL: time.Minute, Precision: 4},
+			second:      models.Point{Lat: 43.25, Lng: 76.9},
+			want:        osrm,
+			wantPrimary: 2,
+		},
+		{
+			name:          "fallback on error is not cached",
+			primary:       &stubRouter{err: errors.New("osrm down")},
+			fallback:      &stubRouter{route: straight},
+			opts:          RouterOptions{CacheTTL: time.Minute},
+			second:        from,
+			want:          straight,
+			wantPrimary:   2,
+			wantFallbacks: 2,
+		},
+		{
+			name:          "fallback on timeout",
+			primary:       &stubRouter{route: osrm, delay: time.Second},
+			fallback:      &stubRouter{route: straight},
+			opts:          RouterOptions{Timeout: 10 * time.Millisecond, CacheTTL: time.Minute},
+			second:        from,
+			want:          straight,
+			wantPrimary:   2,
+			wantFallbacks: 2,
+		},
+		{
+			name:        "error without fallback",
+			primary:     &stubRouter{err: errors.New("osrm down")},
+			opts:        RouterOptions{CacheTTL: time.Minute},
+			second:      from,
+			wantErr:     true,
+			wantPrimary: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fallback *stubRouter
+			r := NewCachedRouter(tt.primary, nil, tt.opts)
+			if tt.fallback != nil {
+				fallback = tt.fallback
+				r = NewCachedRouter(tt.primary, fallback, tt.opts)
+			}
+
+			for _, p := range []models.Point{from, tt.second} {
+				got, err := r.Route(context.Background(), p, to)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatalf("Route() = %+v, want error", got)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Route() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("Route() = %+v, want %+v", got, tt.want)
+				}
+			}
+
+			if tt.primary.calls != tt.wantPrimary {
+				t.Errorf("primary calls = %d, want %d", tt.primary.calls, tt.wantPrimary)
+			}
+			if fallback != nil && fallback.calls != tt.wantFallbacks {
+				t.Errorf("fallback calls = %d, want %d", fallback.calls, tt.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestCachedRouterExpiry(t *testing.T) {
+	primary := &stubRouter{route: models.Route{DistanceKM: 5, Source: "osrm"}}
+	r := NewCachedRouter(primary, nil, RouterOptions{CacheTTL: 20 * time.Millisecond})
+	from, to := models.Point{Lat: 43.2, Lng: 76.8}, models.Point{Lat: 43.3, Lng: 76.9}
+
+	for i, wait := range []time.Duration{0, 0, 40 * time.Millisecond} {
+		time.Sleep(wait)
+		if _, err := r.Route(context.Background(), from, to); err != nil {
+			t.Fatalf("Route() #%d error = %v", i, err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary calls = %d, want 2", primary.calls)
+	}
+}
+
+func TestCachedRouterSizeLimit(t *testing.T) {
+	primary := &stubRouter{route: models.Route{DistanceKM: 5, Source: "osrm"}}
+	r := NewCachedRouter(primary, nil, RouterOptions{CacheTTL: time.Minute, CacheSize: 2})
+	to := models.Point{Lat: 43.3, Lng: 76.9}
+	a, b, c := models.Point{Lat: 43.1}, models.Point{Lat: 43.2}, models.Point{Lat: 43.4}
+
+	// c pushes a out, b stays cached
+	for _, from := range []models.Point{a, b, c, b} {
+		if _, err := r.Route(context.Background(), from, to); err != nil {
+			t.Fatalf("Route() error = %v", err)
+		}
+	}
+	if primary.calls != 3 {
+		t.Errorf("primary calls = %d, want 3", primary.calls)
+	}
+	if len(r.cache) != 2 || r.order.Len() != 2 {
+		t.Errorf("cache holds %d keys and %d entries, want 2", len(r.cache), r.order.Len())
+	}
+
+	if _, err := r.Route(context.Background(), a, to); err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	if primary.calls != 4 {
+		t.Errorf("evicted route was not fetched again: primary calls = %d, want 4", primary.calls)
+	}
+}
+
+func TestCachedRouterDropsExpiredOnInsert(t *testing.T) {
+	primary := &stubRouter{route: models.Route{DistanceKM: 5, Source: "osrm"}}
+	r := NewCachedRouter(primary, nil, RouterOptions{CacheTTL: 10 * time.Millisecond})
+	to := models.Point{Lat: 43.3, Lng: 76.9}
+
+	for i := range 5 {
+		if _, err := r.Route(context.Background(), models.Point{Lat: float64(i)}, to); err != nil {
+			t.Fatalf("Route() error = %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := r.Route(context.Background(), models.Point{Lat: 10}, to); err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	if len(r.cache) != 1 {
+		t.Errorf("cache holds %d routes, want only the fresh one", len(r.cache))
+	}
+}
+
+func TestRideDistance(t *testing.T) {
+	// about 1.11 km per 0.01 degree of latitude
+	planned := []models.Point{{Lat: 43.20, Lng: 76.9}, {Lat: 43.21, Lng: 76.9}, {Lat: 43.22, Lng: 76.9}}
+	straight := PathLength(planned)
+
+	detour := []models.Point{{Lat: 43.20, Lng: 76.9}, {Lat: 43.20, Lng: 76.91}, {Lat: 43.22, Lng: 76.91}, {Lat: 43.22, Lng: 76.9}}
+	padded := []models.Point{{Lat: 43.20, Lng: 76.9}, {Lat: 43.30, Lng: 76.9}, {Lat: 43.22, Lng: 76.9}}
+
+	if got := RideDistance(nil, planned); got != straight {
+		t.Errorf("without a trail RideDistance() = %v, want the planned %v", got, straight)
+	}
+	if got, want := RideDistance(detour, planned), PathLength(detour); got != want {
+		t.Errorf("plausible detour RideDistance() = %v, want the trail %v", got, want)
+	}
+	if got, want := RideDistance(padded, planned), straight*MaxRouteFactor; math.Abs(got-want) > 1e-9 {
+		t.Errorf("padded trail RideDistance() = %v, want the cap %v", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package calculator
+
+import (
+	"slices"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+// TipWindow is how long after completion a ride can be tipped.
+const TipWindow = 24 * time.Hour
+
+// TipPercentages are the preset tip options offered to the passenger.
+var TipPercentages = []float64{5, 10, 15, 20}
+
+// Tip returns the tip amount for a preset percentage of the final fare.
+func Tip(finalFare, percent float64) (float64, bool) {
+	if !slices.Contains(TipPercentages, percent) {
+		return 0, false
+	}
+	return round2(finalFare * percent / 100), true
+}
+
+// TipOptions lists the preset tips for the final fare.
+func TipOptions(finalFare float64) []models.TipOption {
+	options := make([]models.TipOption, 0, len(TipPercentages))
+	for _, p := range TipPercentages {
+		amount, _ := Tip(finalFare, p)
+		options = append(options, models.TipOption{Percent: p, Amount: amount})
+	}
+	return options
+}
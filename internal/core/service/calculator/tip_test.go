@@ -0,0 +1,46 @@
+package calculator
+
+import (
+	"reflect"
+	"testing"
+
+	"ride-hail/internal/core/domain/models"
+)
+
+func TestTip(t *testing.T) {
+	tests := []struct {
+		name    string
+		fare    float64
+		percent float64
+		want    float64
+		wantOK  bool
+	}{
+		{"5 percent", 2500, 5, 125, true},
+		{"20 percent", 2500, 20, 500, true},
+		{"rounded to cents", 1234.56, 15, 185.18, true},
+		{"not a preset", 2500, 12, 0, false},
+		{"zero", 2500, 0, 0, false},
+		{"negative", 2500, -10, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Tip(tt.fare, tt.percent)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Tip() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTipOptions(t *testing.T) {
+	want := []models.TipOption{
+		{Percent: 5, Amount: 100},
+		{Percent: 10, Amount: 200},
+		{Percent: 15, Amount: 300},
+		{Percent: 20, Amount: 400},
+	}
+	if got := TipOptions(2000); !reflect.DeepEqual(got, want) {
+		t.Errorf("TipOptions() = %+v, want %+v", got, want)
+	}
+}
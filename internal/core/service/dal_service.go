@@ -1,33 +1,88 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
 	"time"
 
+	"ride-hail/internal/core/domain/action"
 	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
 	"ride-hail/internal/core/ports"
+	"ride-hail/internal/core/service/calculator"
 	"ride-hail/pkg/logger"
 	"ride-hail/pkg/txm"
+	"ride-hail/pkg/wsm"
 )
 
 type DalService struct {
-	log  *logger.Logger
-	repo DalRepository
-	txm  txm.Manager
+	log       *logger.Logger
+	repo      DalRepository
+	txm       txm.Manager
+	publisher ports.DalPublisher
+	promo     ports.PromoService
+	ledger    ports.LedgerService
+	wsm       wsm.ServiceWS
+	pool      calculator.PoolPolicy
+	match     MatchPolicy
+	loc       *time.Location
+}
+
+// MatchPolicy controls matching of regular rides. A ride is offered to up to
+// OfferDrivers available drivers within RadiusKM of the pickup; a ride still
+// waiting for a driver after RedispatchAfter is published again with a
+// higher priority. A driver who cancelled more than MaxCancelRate of at least
+// CancelMinRides rides within CancelWindow gets no offers for BlockFor.
+type MatchPolicy struct {
+	RadiusKM        float64
+	OfferDrivers    int
+	RedispatchAfter time.Duration
+	CancelWindow    time.Duration
+	MaxCancelRate   float64
+	CancelMinRides  int
+	BlockFor        time.Duration
 }
 
 type DalRepository struct {
 	driver   ports.DriverRepository
 	location ports.LocationRepository
+	ride     ports.RideRepository
+	event    ports.RideEventRepository
+	cord     ports.CoordinatesRepository
+	stop     ports.RideStopRepository
+	trip     ports.TripRepository
 }
 
-func NewDalService(log *logger.Logger, txm txm.Manager, driverRepo ports.DriverRepository, locationRepo ports.LocationRepository) *DalService {
+func NewDalService(log *logger.Logger, txm txm.Manager, driverRepo ports.DriverRepository, locationRepo ports.LocationRepository,
+	rideRepo ports.RideRepository, eventRepo ports.RideEventRepository, cordRepo ports.CoordinatesRepository, stopRepo ports.RideStopRepository,
+	tripRepo ports.TripRepository, publisher ports.DalPublisher, promo ports.PromoService, ledger ports.LedgerService, wsm wsm.ServiceWS,
+	pool calculator.PoolPolicy, match MatchPolicy, loc *time.Location) *DalService {
+	if loc == nil {
+		loc = time.UTC
+	}
 	return &DalService{
-		log: log,
-		txm: txm,
+		log:       log,
+		txm:       txm,
+		publisher: publisher,
+		promo:     promo,
+		ledger:    ledger,
+		wsm:       wsm,
+		pool:      pool,
+		match:     match,
+		loc:       loc,
 		repo: DalRepository{
 			driver:   driverRepo,
 			location: locationRepo,
+			ride:     rideRepo,
+			event:    eventRepo,
+			cord:     cordRepo,
+			stop:     stopRepo,
+			trip:     tripRepo,
 		},
 	}
 }
@@ -52,8 +107,25 @@ func (svc *DalService) ChangeDriverStatus(ctx context.Context, driverID string,
 	return nil
 }
 
-func (svc *DalService) ListAvailableDriversNear(ctx context.Context) ([]models.Driver, error) {
-	return []models.Driver{}, nil
+// ListAvailableDriversNear returns available drivers of the vehicle type
+// within RadiusKM of the point, nearest first.
+func (svc *DalService) ListAvailableDriversNear(ctx context.Context, vehicleType string, lat, lng float64) ([]models.AvailableDriver, error) {
+	drivers, err := svc.repo.driver.ListAvailableDrivers(ctx, vehicleType)
+	if err != nil {
+		return nil, err
+	}
+
+	nearby := make([]models.AvailableDriver, 0, len(drivers))
+	for _, d := range drivers {
+		d.Distance = calculator.Distance(d.Latitude, d.Longitude, lat, lng)
+		if d.Distance <= svc.match.RadiusKM {
+			nearby = append(nearby, d)
+		}
+	}
+	slices.SortFunc(nearby, func(a, b models.AvailableDriver) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return nearby, nil
 }
 
 func (svc *DalService) RecordDriverLocation(ctx context.Context, location models.LocationHistory) (string, error) {
@@ -79,3 +151,1087 @@ func (svc *DalService) StartDriverSession(ctx context.Context, driverID string)
 func (svc *DalService) EndDriverSession(ctx context.Context, sessionID string) error {
 	return nil
 }
+
+func (svc *DalService) GoOnline(ctx context.Context, req models.DriverOnlineRequest) (models.DriverOnlineResponse, error) {
+	log := svc.log.Func("DalService.GoOnline")
+
+	var sessionID string
+	fn := func(ctx context.Context) error {
+		if err := svc.repo.driver.UpdateDriverStatus(ctx, req.DriverID, types.DriverStatusAvailable); err != nil {
+			return err
+		}
+
+		cordID, err := svc.repo.cord.CreateNewCoordinate(ctx, models.Coordinate{
+			EntityID:   req.DriverID,
+			EntityType: types.EntityRoleDriver,
+			Address:    "driver location",
+			Latitude:   req.Latitude,
+			Longitude:  req.Longitude,
+			IsCurrent:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err = svc.repo.location.SaveLocation(ctx, models.LocationHistory{
+			CoordinateID: &cordID,
+			DriverID:     &req.DriverID,
+			Latitude:     req.Latitude,
+			Longitude:    req.Longitude,
+			RecordedAt:   time.Now(),
+		}); err != nil {
+			return err
+		}
+
+		sessionID, err = svc.repo.driver.StartSession(ctx, req.DriverID)
+		return err
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.DriverOnline, "error switching driver online", "error", err)
+		return models.DriverOnlineResponse{}, err
+	}
+
+	if err := svc.publisher.PublishDriverStatus(req.DriverID, types.DriverStatusAvailable, ""); err != nil {
+		log.Error(ctx, action.DriverOnline, "error publishing driver status", "error", err)
+	}
+
+	return models.DriverOnlineResponse{
+		Status:    types.DriverStatusAvailable,
+		SessionID: sessionID,
+		Message:   "You are now online and ready to accept rides",
+	}, nil
+}
+
+func (svc *DalService) GoOffline(ctx context.Context, driverID string) (models.DriverOfflineResponse, error) {
+	log := svc.log.Func("DalService.GoOffline")
+
+	var session *models.DriverSession
+	fn := func(ctx context.Context) error {
+		if err := svc.repo.driver.UpdateDriverStatus(ctx, driverID, types.DriverStatusOffline); err != nil {
+			return err
+		}
+		var err error
+		session, err = svc.repo.driver.EndSession(ctx, driverID)
+		return err
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.DriverOffline, "error switching driver offline", "error", err)
+		return models.DriverOfflineResponse{}, err
+	}
+
+	if err := svc.publisher.PublishDriverStatus(driverID, types.DriverStatusOffline, ""); err != nil {
+		log.Error(ctx, action.DriverOffline, "error publishing driver status", "error", err)
+	}
+
+	resp := models.DriverOfflineResponse{
+		Status:  types.DriverStatusOffline,
+		Message: "You are now offline",
+	}
+	if session != nil {
+		resp.SessionID = session.ID
+		resp.RidesCompleted = session.TotalRides
+		resp.Earnings = session.TotalEarnings
+		if session.EndedAt != nil {
+			resp.DurationHours = session.EndedAt.Sub(session.StartedAt).Hours()
+		}
+	}
+	return resp, nil
+}
+
+func (svc *DalService) UpdateLocation(ctx context.Context, req models.LocationUpdateRequest) (models.LocationUpdateResponse, error) {
+	log := svc.log.Func("DalService.UpdateLocation")
+
+	now := time.Now()
+	var cordID string
+	fn := func(ctx context.Context) error {
+		var err error
+		cordID, err = svc.repo.cord.CreateNewCoordinate(ctx, models.Coordinate{
+			EntityID:   req.DriverID,
+			EntityType: types.EntityRoleDriver,
+			Address:    "driver location",
+			Latitude:   req.Latitude,
+			Longitude:  req.Longitude,
+			IsCurrent:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		rideID, err := svc.repo.ride.GetActiveRideID(ctx, req.DriverID)
+		if err != nil {
+			return err
+		}
+
+		location := models.LocationHistory{
+			CoordinateID:   &cordID,
+			DriverID:       &req.DriverID,
+			Latitude:       req.Latitude,
+			Longitude:      req.Longitude,
+			AccuracyMeters: req.AccuracyMeters,
+			SpeedKmh:       req.SpeedKmh,
+			HeadingDegrees: req.HeadingDegrees,
+			RecordedAt:     now,
+		}
+		if rideID != "" {
+			location.RideID = &rideID
+		}
+		_, err = svc.repo.location.SaveLocation(ctx, location)
+		return err
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.UpdateLocation, "error saving driver location", "error", err)
+		return models.LocationUpdateResponse{}, err
+	}
+
+	if err := svc.publisher.PublishDriverLocation(struct {
+		DriverID string  `json:"driver_id"`
+		Lat      float64 `json:"lat"`
+		Lng      float64 `json:"lng"`
+		Time     string  `json:"timestamp"`
+	}{req.DriverID, req.Latitude, req.Longitude, now.Format(time.RFC3339)}); err != nil {
+		log.Error(ctx, action.UpdateLocation, "error publishing driver location", "error", err)
+	}
+
+	return models.LocationUpdateResponse{
+		CoordinateID: cordID,
+		UpdatedAt:    now,
+	}, nil
+}
+
+// AcceptRide assigns a regular ride to the driver who accepts its offer. The
+// first driver to accept wins; the others get ErrInvalidRideStatus. Only a
+// driver the ride could have been offered to may accept it, see offeredTo.
+// Pool rides are assigned to trips by MatchPoolRide and cannot be accepted.
+func (svc *DalService) AcceptRide(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error) {
+	log := svc.log.Func("DalService.AcceptRide")
+
+	var ride models.Ride
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.Status != types.RideStatusREQUESTED || ride.DriverID != "" || ride.VehicleType == types.RideTypePOOL {
+			return types.ErrInvalidRideStatus
+		}
+		if err = svc.offeredTo(ctx, ride, req.DriverID); err != nil {
+			return err
+		}
+
+		if err = svc.repo.ride.AssignDriver(ctx, ride.ID, req.DriverID); err != nil {
+			return err
+		}
+		if err = svc.repo.driver.UpdateDriverStatus(ctx, req.DriverID, types.DriverStatusEnRoute); err != nil {
+			return err
+		}
+		ride.Status, ride.DriverID = types.RideStatusMATCHED, req.DriverID
+		return svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventDriverMatched, map[string]any{
+			"old_status": types.RideStatusREQUESTED,
+			"new_status": types.RideStatusMATCHED,
+			"driver_id":  req.DriverID,
+		})
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		if errors.Is(err, types.ErrRideNotOffered) || errors.Is(err, types.ErrInvalidRideStatus) || errors.Is(err, types.ErrDriverBusy) {
+			log.Warn(ctx, action.AcceptRide, "ride not accepted", "ride_id", req.RideID, "error", err)
+		} else {
+			log.Error(ctx, action.AcceptRide, "error accepting ride", "ride_id", req.RideID, "error", err)
+		}
+		return models.DriverRideResponse{}, err
+	}
+	log.Info(ctx, action.AcceptRide, "ride matched", "ride_id", ride.ID, "priority", ride.Priority)
+
+	svc.notifyRideStatus(ctx, ride, types.RideStatusMATCHED, nil, "A driver is on the way")
+
+	return models.DriverRideResponse{
+		RideID:    ride.ID,
+		Status:    types.RideStatusMATCHED,
+		UpdatedAt: time.Now(),
+		Message:   "Ride accepted, head to the pickup",
+	}, nil
+}
+
+// offeredTo reports ErrRideNotOffered unless MatchRide would offer the ride
+// to the driver right now: available and not blocked, with the ride's vehicle
+// type, within RadiusKM of the pickup and not among the drivers who cancelled
+// the ride.
+func (svc *DalService) offeredTo(ctx context.Context, ride models.Ride, driverID string) error {
+	pickup, err := svc.repo.cord.GetCoordinate(ctx, ride.PickupCoordinateId)
+	if err != nil {
+		return err
+	}
+	drivers, err := svc.ListAvailableDriversNear(ctx, ride.VehicleType, pickup.Latitude, pickup.Longitude)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(drivers, func(d models.AvailableDriver) bool { return d.ID == driverID }) {
+		return types.ErrRideNotOffered
+	}
+
+	cancellers, err := svc.repo.driver.ListRideCancellers(ctx, ride.ID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(cancellers, driverID) {
+		return types.ErrRideNotOffered
+	}
+	return nil
+}
+
+func (svc *DalService) DriverArrived(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error) {
+	log := svc.log.Func("DalService.DriverArrived")
+
+	ride, err := svc.changeRideStatus(ctx, req, types.RideStatusMATCHED, types.RideStatusARRIVED, types.RideEventDriverArrived)
+	if err != nil {
+		log.Error(ctx, action.DriverArrived, "error marking arrival", "ride_id", req.RideID, "error", err)
+		return models.DriverRideResponse{}, err
+	}
+
+	svc.notifyRideStatus(ctx, ride, types.RideStatusARRIVED, nil, "Your driver has arrived")
+
+	return models.DriverRideResponse{
+		RideID:    req.RideID,
+		Status:    types.RideStatusARRIVED,
+		UpdatedAt: time.Now(),
+		Message:   "Passenger has been notified about your arrival",
+	}, nil
+}
+
+func (svc *DalService) StartRide(ctx context.Context, req models.DriverRideRequest) (models.DriverRideResponse, error) {
+	log := svc.log.Func("DalService.StartRide")
+
+	ride, err := svc.changeRideStatus(ctx, req, types.RideStatusARRIVED, types.RideStatusIN_PROGRESS, types.RideEventStarted)
+	if err != nil {
+		log.Error(ctx, action.StartRide, "error starting ride", "ride_id", req.RideID, "error", err)
+		return models.DriverRideResponse{}, err
+	}
+
+	if err = svc.repo.driver.UpdateDriverStatus(ctx, req.DriverID, types.DriverStatusBusy); err != nil {
+		log.Error(ctx, action.StartRide, "error updating driver status", "error", err)
+	}
+	svc.notifyRideStatus(ctx, ride, types.RideStatusIN_PROGRESS, nil, "Your ride has started")
+
+	return models.DriverRideResponse{
+		RideID:    req.RideID,
+		Status:    types.RideStatusIN_PROGRESS,
+		UpdatedAt: time.Now(),
+		Message:   "Ride started successfully",
+	}, nil
+}
+
+func (svc *DalService) CompleteRide(ctx context.Context, req models.CompleteRideRequest) (models.CompleteRideResponse, error) {
+	log := svc.log.Func("DalService.CompleteRide")
+
+	var (
+		ride    models.Ride
+		fare    models.FareBreakdown
+		payment models.RidePayment
+	)
+
+	fn := func(ctx context.Context) error {
+		var err error
+		ride, err = svc.repo.ride.GetRide(ctx, req.RideID)
+		if err != nil {
+			return err
+		}
+		if ride.DriverID != req.DriverID {
+			return types.ErrRideNotAssigned
+		}
+
+		stops, err := svc.repo.stop.ListStops(ctx, ride.ID)
+		if err != nil {
+			return err
+		}
+
+		pickupAt := ride.RequestedAt
+		if ride.StartedAt != nil {
+			pickupAt = *ride.StartedAt
+		}
+
+		distance, duration, err := svc.measureRide(ctx, ride, stops)
+		if err != nil {
+			return err
+		}
+		if req.ActualDistanceKm > 0 && math.Abs(distance-req.ActualDistanceKm) > distance*reportTolerance {
+			log.Warn(ctx, action.CompleteRide, "reported distance differs from the recorded one", "ride_id", ride.ID,
+				"reported_km", req.ActualDistanceKm, "recorded_km", distance)
+		}
+
+		in := calculator.FareInput{
+			RideType:        ride.VehicleType,
+			DistanceKM:      distance,
+			DurationMinutes: duration,
+			LocalTime:       pickupAt.In(svc.loc),
+			ArrivedAt:       ride.ArrivedAt,
+			StartedAt:       ride.StartedAt,
+			Stops:           stops,
+			ExtraCharges:    req.ExtraCharges,
+		}
+		if ride.VehicleType == types.RideTypePOOL {
+			in.PoolShare = ride.EstimatedFare
+		}
+
+		fare, err = calculator.FinalFare(in)
+		if err != nil {
+			return err
+		}
+
+		if ride.PromoCodeID != nil {
+			quote, err := svc.promo.Redeem(ctx, *ride.PromoCodeID, ride.PassengerID, ride.ID, ride.VehicleType, fare.Subtotal)
+			switch {
+			case err == nil:
+				calculator.ApplyDiscount(&fare, quote.Code, quote.Discount)
+			case isPromoError(err):
+				// the code was used up while the ride was in progress, charge the full fare
+				log.Warn(ctx, action.CompleteRide, "promo code not redeemed", "ride_id", ride.ID, "error", err)
+			default:
+				return err
+			}
+		}
+
+		if err = svc.repo.ride.UpdateRideStatus(ctx, ride.ID, types.RideStatusIN_PROGRESS, types.RideStatusCOMPLETED); err != nil {
+			return err
+		}
+		if err = svc.repo.ride.SetFinalFare(ctx, ride.ID, fare.Total); err != nil {
+			return err
+		}
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventFareAdjusted, fare); err != nil {
+			return err
+		}
+		if payment, err = svc.ledger.PostRidePayment(ctx, ride, fare); err != nil {
+			return err
+		}
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventCompleted, map[string]any{
+			"old_status": types.RideStatusIN_PROGRESS,
+			"new_status": types.RideStatusCOMPLETED,
+			"driver_id":  req.DriverID,
+			"final_fare": fare.Total,
+		}); err != nil {
+			return err
+		}
+		if err = svc.repo.driver.AddRideEarnings(ctx, req.DriverID, payment.DriverEarnings); err != nil {
+			return err
+		}
+		if ride.TripID != nil {
+			// a pool driver stays busy until the last passenger of the trip is dropped off
+			if closed, err := svc.repo.trip.CloseTripIfDone(ctx, *ride.TripID); err != nil || !closed {
+				return err
+			}
+		}
+		return svc.repo.driver.UpdateDriverStatus(ctx, req.DriverID, types.DriverStatusAvailable)
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.CompleteRide, "error completing ride", "ride_id", req.RideID, "error", err)
+		return models.CompleteRideResponse{}, err
+	}
+
+	if payment.Charged > 0 {
+		// a failed charge stays pending and is retried by the ledger scheduler
+		if err := svc.ledger.CollectCharge(ctx, payment.TransactionID); err != nil {
+			log.Warn(ctx, action.CompleteRide, "ride payment not collected yet", "ride_id", ride.ID, "error", err)
+		}
+	}
+
+	svc.notifyRideStatus(ctx, ride, types.RideStatusCOMPLETED, &fare, "Your ride is complete")
+
+	return models.CompleteRideResponse{
+		RideID:         ride.ID,
+		Status:         types.RideStatusCOMPLETED,
+		CompletedAt:    time.Now(),
+		FinalFare:      fare.Total,
+		Fare:           fare,
+		Commission:     payment.Commission,
+		DriverEarnings: payment.DriverEarnings,
+		Message:        "Ride completed successfully",
+	}, nil
+}
+
+// reportTolerance is the share by which the distance reported by the driver
+// may differ from the recorded one before it is logged.
+const reportTolerance = 0.25
+
+// measureRide returns the distance and the duration of a ride in progress as
+// the server recorded them. The driver's own figures are never charged.
+func (svc *DalService) measureRide(ctx context.Context, ride models.Ride, stops []models.RideStop) (float64, int, error) {
+	pickup, err := svc.repo.cord.GetCoordinate(ctx, ride.PickupCoordinateId)
+	if err != nil {
+		return 0, 0, err
+	}
+	destination, err := svc.repo.cord.GetCoordinate(ctx, ride.DestinationCoordinateId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	planned := make([]models.Point, 0, len(stops)+2)
+	planned = append(planned, models.Point{Lat: pickup.Latitude, Lng: pickup.Longitude})
+	for _, stop := range stops {
+		planned = append(planned, models.Point{Lat: stop.Latitude, Lng: stop.Longitude})
+	}
+	planned = append(planned, models.Point{Lat: destination.Latitude, Lng: destination.Longitude})
+
+	if ride.StartedAt == nil {
+		return calculator.PathLength(planned), 0, nil
+	}
+	trail, err := svc.repo.location.ListRideRouteSince(ctx, ride.ID, *ride.StartedAt)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	duration := int(math.Ceil(time.Since(*ride.StartedAt).Minutes()))
+	return calculator.RideDistance(trail, planned), duration, nil
+}
+
+// MatchRide offers a requested ride to the nearest available drivers. Requests
+// arrive in priority order; when supply is scarce, i.e. there are no more
+// nearby drivers than waiting rides of a higher priority, the drivers are left
+// to those rides and this one waits for the next redispatch.
+func (svc *DalService) MatchRide(ctx context.Context, req models.RideRequestMessage) error {
+	log := svc.log.Func("DalService.MatchRide")
+
+	ride, err := svc.repo.ride.GetRide(ctx, req.RideID)
+	if err != nil {
+		if errors.Is(err, types.ErrRideNotFound) {
+			log.Warn(ctx, action.MatchRide, "ride not found", "ride_id", req.RideID)
+			return nil
+		}
+		log.Error(ctx, action.MatchRide, "error getting ride", "ride_id", req.RideID, "error", err)
+		return err
+	}
+	if ride.Status != types.RideStatusREQUESTED || ride.DriverID != "" {
+		log.Debug(ctx, action.MatchRide, "ride no longer waits for a driver", "ride_id", ride.ID, "status", ride.Status)
+		return nil
+	}
+
+	drivers, err := svc.ListAvailableDriversNear(ctx, req.RideType, req.PickupLocation.Lat, req.PickupLocation.Lng)
+	if err != nil {
+		log.Error(ctx, action.MatchRide, "error listing available drivers", "ride_id", ride.ID, "error", err)
+		return err
+	}
+
+	// drivers who gave up this ride are not offered it again
+	cancellers, err := svc.repo.driver.ListRideCancellers(ctx, ride.ID)
+	if err != nil {
+		log.Error(ctx, action.MatchRide, "error listing ride cancellers", "ride_id", ride.ID, "error", err)
+		return err
+	}
+	drivers = slices.DeleteFunc(drivers, func(d models.AvailableDriver) bool {
+		return slices.Contains(cancellers, d.ID)
+	})
+
+	// demand is counted over the same area as supply: rides ahead in the
+	// queue compete for these drivers only if their pickup is within RadiusKM
+	pickups, err := svc.repo.ride.ListWaitingPickups(ctx, req.RideType, ride.Priority+1)
+	if err != nil {
+		log.Error(ctx, action.MatchRide, "error listing waiting rides", "ride_id", ride.ID, "error", err)
+		return err
+	}
+	ahead := 0
+	for _, p := range pickups {
+		if calculator.Distance(p.Lat, p.Lng, req.PickupLocation.Lat, req.PickupLocation.Lng) <= svc.match.RadiusKM {
+			ahead++
+		}
+	}
+	if len(drivers) <= ahead {
+		log.Info(ctx, action.MatchRide, "ride deferred, supply is scarce", "ride_id", ride.ID,
+			"priority", ride.Priority, "drivers", len(drivers), "rides_ahead", ahead)
+		return nil
+	}
+
+	drivers = drivers[:min(len(drivers), max(svc.match.OfferDrivers, 1))]
+	msg := fmt.Sprintf("%s -> %s", req.PickupLocation.Address, req.DestinationLocation.Address)
+	if len(req.Stops) > 0 {
+		msg = fmt.Sprintf("%s (%d stops)", msg, len(req.Stops))
+	}
+	for _, d := range drivers {
+		_ = svc.NotifyDriver(ctx, models.DriverNotification{
+			Type:       types.NotificationRideOffer,
+			DriverID:   d.ID,
+			RideID:     ride.ID,
+			RideNumber: ride.RideNumber,
+			Amount:     ride.EstimatedFare,
+			Stops:      req.Stops,
+			Message:    msg,
+			Timestamp:  time.Now(),
+		})
+	}
+	log.Info(ctx, action.MatchRide, "ride offered to drivers", "ride_id", ride.ID, "priority", ride.Priority, "drivers", len(drivers))
+	return nil
+}
+
+// CancelRide lets the matched driver back out of a ride before the passenger
+// is picked up. The ride goes back to matching with a raised priority and
+// without this driver, and the passenger is notified.
+func (svc *DalService) CancelRide(ctx context.Context, req models.DriverCancelRequest) (models.DriverCancelResponse, error) {
+	log := svc.log.Func("DalService.CancelRide")
+
+	var (
+		ride         models.Ride
+		request      models.RideRequestMessage
+		rate         float64
+		blockedUntil *time.Time
+	)
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.DriverID != req.DriverID {
+			return types.ErrRideNotAssigned
+		}
+
+		oldStatus := ride.Status
+		ride.Priority = calculator.RaisePriority(ride.Priority, calculator.DriverCancelBoost)
+		if err = svc.repo.ride.ReleaseDriver(ctx, ride.ID, req.DriverID, ride.Priority); err != nil {
+			return err
+		}
+		if err = svc.repo.driver.RecordCancellation(ctx, req.DriverID, ride.ID, req.Reason); err != nil {
+			return err
+		}
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventDriverCancelled, map[string]any{
+			"old_status": oldStatus,
+			"new_status": types.RideStatusREQUESTED,
+			"driver_id":  req.DriverID,
+			"reason":     req.Reason,
+		}); err != nil {
+			return err
+		}
+
+		if err = svc.releaseDriver(ctx, req.DriverID, ride.TripID); err != nil {
+			return err
+		}
+		if rate, blockedUntil, err = svc.checkCancellationRate(ctx, req.DriverID); err != nil {
+			return err
+		}
+
+		ride.Status, ride.DriverID, ride.TripID = types.RideStatusREQUESTED, "", nil
+		request, err = loadRideRequest(ctx, svc.repo.cord, svc.repo.stop, ride)
+		return err
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.DriverCancel, "error cancelling ride", "ride_id", req.RideID, "error", err)
+		return models.DriverCancelResponse{}, err
+	}
+
+	// published only once the ride is committed as REQUESTED, so matching never
+	// sees it still assigned to this driver; a lost message is redispatched
+	if err := svc.requestMatching(ride, request); err != nil {
+		log.Error(ctx, action.DriverCancel, "error returning ride to matching", "ride_id", ride.ID, "error", err)
+	}
+	log.Info(ctx, action.DriverCancel, "driver cancelled ride", "ride_id", ride.ID, "priority", ride.Priority, "cancellation_rate", rate)
+	if blockedUntil != nil {
+		log.Warn(ctx, action.DriverCancel, "driver blocked from offers", "blocked_until", blockedUntil, "cancellation_rate", rate)
+	}
+
+	svc.publishRideStatus(ctx, models.RideStatusUpdate{
+		Type:        types.NotificationDriverCancelled,
+		RideID:      ride.ID,
+		RideNumber:  ride.RideNumber,
+		PassengerID: ride.PassengerID,
+		Status:      types.RideStatusREQUESTED,
+		Message:     "Your driver cancelled the ride, we are looking for another driver",
+		Timestamp:   time.Now(),
+	})
+
+	return models.DriverCancelResponse{
+		RideID:           ride.ID,
+		Status:           types.RideStatusREQUESTED,
+		CancellationRate: rate,
+		BlockedUntil:     blockedUntil,
+		Message:          "Ride cancelled and returned to matching",
+	}, nil
+}
+
+// releaseDriver makes the driver available again unless they still serve
+// other rides of the pool trip the cancelled ride was on.
+func (svc *DalService) releaseDriver(ctx context.Context, driverID string, tripID *string) error {
+	if tripID != nil {
+		trip, err := svc.repo.trip.GetTrip(ctx, *tripID)
+		if err != nil {
+			return err
+		}
+		rides, err := svc.repo.ride.ListTripRides(ctx, trip.ID)
+		if err != nil {
+			return err
+		}
+		if err = svc.repo.trip.UpdatePlan(ctx, trip.ID, syncPlan(trip.Plan, rides)); err != nil {
+			return err
+		}
+		if closed, err := svc.repo.trip.CloseTripIfDone(ctx, trip.ID); err != nil || !closed {
+			return err
+		}
+	}
+	return svc.repo.driver.UpdateDriverStatus(ctx, driverID, types.DriverStatusAvailable)
+}
+
+// checkCancellationRate returns the driver's share of cancelled rides within
+// CancelWindow and blocks the driver from offers when it exceeds MaxCancelRate.
+func (svc *DalService) checkCancellationRate(ctx context.Context, driverID string) (float64, *time.Time, error) {
+	since := time.Now().Add(-svc.match.CancelWindow)
+
+	cancelled, err := svc.repo.driver.CountCancellations(ctx, driverID, since)
+	if err != nil {
+		return 0, nil, err
+	}
+	kept, err := svc.repo.ride.CountMatchedRides(ctx, driverID, since)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total := cancelled + kept
+	if total == 0 {
+		return 0, nil, nil
+	}
+	rate := float64(cancelled) / float64(total)
+	if total < svc.match.CancelMinRides || rate <= svc.match.MaxCancelRate {
+		return rate, nil, nil
+	}
+
+	until := time.Now().Add(svc.match.BlockFor)
+	if err = svc.repo.driver.BlockDriver(ctx, driverID, until); err != nil {
+		return 0, nil, err
+	}
+	return rate, &until, nil
+}
+
+// requestMatching publishes the request of a ride to ride_requests with the
+// ride's priority. Call it after the ride is committed as REQUESTED.
+func (svc *DalService) requestMatching(ride models.Ride, request models.RideRequestMessage) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	priority := uint8(max(ride.Priority, types.RidePriorityMin))
+	return svc.publisher.PublishWithPriority(exchangeName, fmt.Sprintf(rideRequestRoutingKey, ride.VehicleType), priority, data)
+}
+
+// MatchPoolRide puts a POOL ride on the open trip where its pickup and
+// dropoff add the least distance within the detour and seat limits, or starts
+// a new trip with the nearest available driver. Fares of all passengers of
+// the trip are re-split and the driver gets the new pickup/dropoff order.
+// A ride no driver can take stays REQUESTED.
+func (svc *DalService) MatchPoolRide(ctx context.Context, req models.RideRequestMessage) error {
+	log := svc.log.Func("DalService.MatchPoolRide")
+
+	var (
+		ride models.Ride
+		trip models.Trip
+	)
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.Status != types.RideStatusREQUESTED {
+			return types.ErrInvalidRideStatus
+		}
+
+		seats := max(ride.Seats, 1)
+		pickup := models.PlanStop{
+			RideID:      ride.ID,
+			PassengerID: ride.PassengerID,
+			Kind:        types.PlanStopPickup,
+			Seats:       seats,
+			Latitude:    req.PickupLocation.Lat,
+			Longitude:   req.PickupLocation.Lng,
+			Address:     req.PickupLocation.Address,
+		}
+		dropoff := pickup
+		dropoff.Kind = types.PlanStopDropoff
+		dropoff.Latitude, dropoff.Longitude = req.DestinationLocation.Lat, req.DestinationLocation.Lng
+		dropoff.Address = req.DestinationLocation.Address
+
+		trips, err := svc.repo.trip.ListOpenTrips(ctx)
+		if err != nil {
+			return err
+		}
+		cancellers, err := svc.repo.driver.ListRideCancellers(ctx, ride.ID)
+		if err != nil {
+			return err
+		}
+
+		found, bestAdded := false, math.Inf(1)
+		for _, t := range trips {
+			if slices.Contains(cancellers, t.DriverID) {
+				continue
+			}
+			rides, err := svc.repo.ride.ListTripRides(ctx, t.ID)
+			if err != nil {
+				return err
+			}
+			plan, added, ok := calculator.InsertRide(syncPlan(t.Plan, rides), pickup, dropoff, t.SeatsTotal, svc.pool.MaxDetour)
+			if ok && added < bestAdded {
+				found, bestAdded = true, added
+				trip, trip.Plan = t, plan
+			}
+		}
+
+		if found {
+			if err = svc.repo.trip.UpdatePlan(ctx, trip.ID, trip.Plan); err != nil {
+				return err
+			}
+		} else {
+			if trip, err = svc.startTrip(ctx, pickup, dropoff, cancellers); err != nil {
+				return err
+			}
+		}
+
+		if err = svc.repo.ride.AssignTrip(ctx, ride.ID, trip.DriverID, trip.ID); err != nil {
+			return err
+		}
+		ride.DriverID, ride.TripID = trip.DriverID, &trip.ID
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventDriverMatched, map[string]any{
+			"old_status": types.RideStatusREQUESTED,
+			"new_status": types.RideStatusMATCHED,
+			"driver_id":  trip.DriverID,
+			"trip_id":    trip.ID,
+		}); err != nil {
+			return err
+		}
+
+		return svc.splitTripFare(ctx, trip)
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		if errors.Is(err, types.ErrNoDriverAvailable) || errors.Is(err, types.ErrInvalidRideStatus) {
+			log.Warn(ctx, action.MatchPool, "pool ride not matched", "ride_id", req.RideID, "error", err)
+			return nil
+		}
+		log.Error(ctx, action.MatchPool, "error matching pool ride", "ride_id", req.RideID, "error", err)
+		return err
+	}
+	log.Info(ctx, action.MatchPool, "pool ride matched", "ride_id", ride.ID, "trip_id", trip.ID, "driver_id", trip.DriverID)
+
+	pending := make([]models.PlanStop, 0, len(trip.Plan))
+	for _, s := range trip.Plan {
+		if !s.Done {
+			pending = append(pending, s)
+		}
+	}
+	_ = svc.NotifyDriver(ctx, models.DriverNotification{
+		Type:       types.NotificationTripPlan,
+		DriverID:   trip.DriverID,
+		RideID:     ride.ID,
+		RideNumber: ride.RideNumber,
+		TripID:     trip.ID,
+		Plan:       pending,
+		Message:    fmt.Sprintf("Pool ride %s added to your trip: %s -> %s", ride.RideNumber, req.PickupLocation.Address, req.DestinationLocation.Address),
+		Timestamp:  time.Now(),
+	})
+	svc.notifyRideStatus(ctx, ride, types.RideStatusMATCHED, nil, "A driver has been matched to your pool ride")
+	return nil
+}
+
+// startTrip opens a trip with the nearest available driver within
+// MaxPickupKM whose vehicle has enough seats, skipping the excluded drivers.
+func (svc *DalService) startTrip(ctx context.Context, pickup, dropoff models.PlanStop, excluded []string) (models.Trip, error) {
+	drivers, err := svc.repo.driver.ListAvailableDrivers(ctx, types.RideTypeECONOMY, types.RideTypeXL)
+	if err != nil {
+		return models.Trip{}, err
+	}
+
+	var (
+		trip    models.Trip
+		nearest = svc.pool.MaxPickupKM
+	)
+	for _, d := range drivers {
+		seats := calculator.PoolSeats(d.VehicleType, d.VehicleAttrs)
+		if seats < pickup.Seats || slices.Contains(excluded, d.ID) {
+			continue
+		}
+		if dist := calculator.Distance(d.Latitude, d.Longitude, pickup.Latitude, pickup.Longitude); dist <= nearest {
+			nearest = dist
+			trip = models.Trip{DriverID: d.ID, Status: types.TripStatusOpen, SeatsTotal: seats}
+		}
+	}
+	if trip.DriverID == "" {
+		return models.Trip{}, types.ErrNoDriverAvailable
+	}
+
+	trip.Plan = []models.PlanStop{pickup, dropoff}
+	if trip.ID, err = svc.repo.trip.CreateTrip(ctx, trip); err != nil {
+		return models.Trip{}, err
+	}
+	if err = svc.repo.driver.UpdateDriverStatus(ctx, trip.DriverID, types.DriverStatusEnRoute); err != nil {
+		return models.Trip{}, err
+	}
+	return trip, nil
+}
+
+// splitTripFare divides the POOL fare of the whole trip route between its
+// passengers by their direct distance. A passenger's estimate never goes up
+// when someone joins the trip.
+func (svc *DalService) splitTripFare(ctx context.Context, trip models.Trip) error {
+	rides, err := svc.repo.ride.ListTripRides(ctx, trip.ID)
+	if err != nil {
+		return err
+	}
+
+	tripKM := calculator.PlanLength(trip.Plan)
+	total, err := calculator.CalculateFare(types.RideTypePOOL, tripKM, calculator.Duration(tripKM))
+	if err != nil {
+		return err
+	}
+
+	weights := make([]float64, len(rides))
+	for i, r := range rides {
+		weights[i] = directDistance(trip.Plan, r.ID)
+	}
+
+	for i, share := range calculator.SplitFare(total, weights) {
+		r := rides[i]
+		if r.Status == types.RideStatusCOMPLETED || share >= r.EstimatedFare {
+			continue
+		}
+		if err = svc.repo.ride.SetEstimatedFare(ctx, r.ID, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncPlan drops the stops of cancelled rides from the plan and marks
+// pickups of started rides and dropoffs of completed rides as done.
+func syncPlan(plan []models.PlanStop, rides []models.Ride) []models.PlanStop {
+	status := make(map[string]string, len(rides))
+	for _, r := range rides {
+		status[r.ID] = r.Status
+	}
+
+	synced := make([]models.PlanStop, 0, len(plan))
+	for _, s := range plan {
+		st, ok := status[s.RideID]
+		if !ok {
+			continue
+		}
+		switch s.Kind {
+		case types.PlanStopPickup:
+			s.Done = st == types.RideStatusIN_PROGRESS || st == types.RideStatusCOMPLETED
+		case types.PlanStopDropoff:
+			s.Done = st == types.RideStatusCOMPLETED
+		}
+		synced = append(synced, s)
+	}
+	return synced
+}
+
+func directDistance(plan []models.PlanStop, rideID string) float64 {
+	var from, to *models.PlanStop
+	for i := range plan {
+		if plan[i].RideID != rideID {
+			continue
+		}
+		if plan[i].Kind == types.PlanStopPickup {
+			from = &plan[i]
+		} else {
+			to = &plan[i]
+		}
+	}
+	if from == nil || to == nil {
+		return 0
+	}
+	return calculator.Distance(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+}
+
+// ArriveAtStop records the driver's arrival at an intermediate stop. Stops
+// are visited in order, so every previous stop must have been departed.
+func (svc *DalService) ArriveAtStop(ctx context.Context, req models.DriverStopRequest) (models.DriverStopResponse, error) {
+	log := svc.log.Func("DalService.ArriveAtStop")
+
+	ride, stop, err := svc.markStop(ctx, req, types.RideEventStopArrived, func(ctx context.Context, stops []models.RideStop, i int) error {
+		for _, prev := range stops[:i] {
+			if prev.DepartedAt == nil {
+				return types.ErrInvalidStopStatus
+			}
+		}
+		return svc.repo.stop.MarkStopArrived(ctx, stops[i].ID)
+	})
+	if err != nil {
+		log.Error(ctx, action.RideStop, "error marking stop arrival", "ride_id", req.RideID, "sequence", req.Sequence, "error", err)
+		return models.DriverStopResponse{}, err
+	}
+
+	svc.notifyRideStatus(ctx, ride, types.RideStatusIN_PROGRESS, nil,
+		fmt.Sprintf("Your driver has arrived at stop %d: %s", stop.Sequence, stop.Address))
+
+	return models.DriverStopResponse{
+		RideID:    ride.ID,
+		Sequence:  stop.Sequence,
+		ArrivedAt: stop.ArrivedAt,
+		Message:   "Passenger has been notified about your arrival at the stop",
+	}, nil
+}
+
+// DepartFromStop records that the driver left an intermediate stop.
+func (svc *DalService) DepartFromStop(ctx context.Context, req models.DriverStopRequest) (models.DriverStopResponse, error) {
+	log := svc.log.Func("DalService.DepartFromStop")
+
+	ride, stop, err := svc.markStop(ctx, req, types.RideEventStopDeparted, func(ctx context.Context, stops []models.RideStop, i int) error {
+		return svc.repo.stop.MarkStopDeparted(ctx, stops[i].ID)
+	})
+	if err != nil {
+		log.Error(ctx, action.RideStop, "error marking stop departure", "ride_id", req.RideID, "sequence", req.Sequence, "error", err)
+		return models.DriverStopResponse{}, err
+	}
+
+	svc.notifyRideStatus(ctx, ride, types.RideStatusIN_PROGRESS, nil,
+		fmt.Sprintf("Your driver has left stop %d: %s", stop.Sequence, stop.Address))
+
+	return models.DriverStopResponse{
+		RideID:     ride.ID,
+		Sequence:   stop.Sequence,
+		ArrivedAt:  stop.ArrivedAt,
+		DepartedAt: stop.DepartedAt,
+		Message:    "Stop departure recorded",
+	}, nil
+}
+
+// markStop applies mark to the stop req.Sequence of an IN_PROGRESS ride of the
+// driver and records event. Returns the ride and the updated stop.
+func (svc *DalService) markStop(ctx context.Context, req models.DriverStopRequest, event string,
+	mark func(ctx context.Context, stops []models.RideStop, i int) error) (models.Ride, models.RideStop, error) {
+	var (
+		ride models.Ride
+		stop models.RideStop
+	)
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.DriverID != req.DriverID {
+			return types.ErrRideNotAssigned
+		}
+		if ride.Status != types.RideStatusIN_PROGRESS {
+			return types.ErrInvalidRideStatus
+		}
+
+		stops, err := svc.repo.stop.ListStops(ctx, ride.ID)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(stops, func(s models.RideStop) bool { return s.Sequence == req.Sequence })
+		if i < 0 {
+			return types.ErrStopNotFound
+		}
+		if err = mark(ctx, stops, i); err != nil {
+			return err
+		}
+
+		if stops, err = svc.repo.stop.ListStops(ctx, ride.ID); err != nil {
+			return err
+		}
+		stop = stops[i]
+
+		return svc.repo.event.CreateEvent(ctx, ride.ID, event, map[string]any{
+			"driver_id": req.DriverID,
+			"sequence":  stop.Sequence,
+			"address":   stop.Address,
+		})
+	}
+	return ride, stop, svc.txm.Do(ctx, fn)
+}
+
+// changeRideStatus checks that the ride belongs to the driver and moves it
+// between statuses, recording the event in one transaction.
+func (svc *DalService) changeRideStatus(ctx context.Context, req models.DriverRideRequest, from, to, event string) (models.Ride, error) {
+	var ride models.Ride
+	fn := func(ctx context.Context) error {
+		var err error
+		ride, err = svc.repo.ride.GetRide(ctx, req.RideID)
+		if err != nil {
+			return err
+		}
+		if ride.DriverID != req.DriverID {
+			return types.ErrRideNotAssigned
+		}
+		if err = svc.repo.ride.UpdateRideStatus(ctx, ride.ID, from, to); err != nil {
+			return err
+		}
+		return svc.repo.event.CreateEvent(ctx, ride.ID, event, map[string]any{
+			"old_status": from,
+			"new_status": to,
+			"driver_id":  req.DriverID,
+		})
+	}
+	return ride, svc.txm.Do(ctx, fn)
+}
+
+// notifyRideStatus publishes the new ride status to ride_topic; the ride
+// service forwards it to the passenger's WebSocket.
+func (svc *DalService) notifyRideStatus(ctx context.Context, ride models.Ride, status string, fare *models.FareBreakdown, msg string) {
+	update := models.RideStatusUpdate{
+		Type:        "ride_status_update",
+		RideID:      ride.ID,
+		RideNumber:  ride.RideNumber,
+		PassengerID: ride.PassengerID,
+		DriverID:    ride.DriverID,
+		Status:      status,
+		Fare:        fare,
+		Message:     msg,
+		Timestamp:   time.Now(),
+	}
+	if status == types.RideStatusCOMPLETED && fare != nil {
+		update.TipOptions = calculator.TipOptions(fare.Total)
+	}
+	svc.publishRideStatus(ctx, update)
+}
+
+func (svc *DalService) publishRideStatus(ctx context.Context, update models.RideStatusUpdate) {
+	log := svc.log.Func("DalService.publishRideStatus")
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		log.Error(ctx, action.RideStatus, "error marshalling status update", "error", err)
+		return
+	}
+
+	if err = svc.publisher.Publish(exchangeName, fmt.Sprintf("ride.status.%s", update.Status), data); err != nil {
+		log.Error(ctx, action.RideStatus, "error publishing status update", "error", err)
+	}
+}
+
+func (svc *DalService) GetDriverBalance(ctx context.Context, driverID string) (models.AccountBalance, error) {
+	log := svc.log.Func("DalService.GetDriverBalance")
+
+	b, err := svc.ledger.GetAccountBalance(ctx, types.LedgerAccountDriver, driverID)
+	if err != nil {
+		log.Error(ctx, action.DriverLedger, "error getting driver balance", "error", err)
+		return models.AccountBalance{}, err
+	}
+	return b, nil
+}
+
+func (svc *DalService) ListDriverTransactions(ctx context.Context, driverID string, limit, offset int) ([]models.AccountStatementLine, error) {
+	log := svc.log.Func("DalService.ListDriverTransactions")
+
+	lines, err := svc.ledger.ListAccountEntries(ctx, types.LedgerAccountDriver, driverID, limit, offset)
+	if err != nil {
+		log.Error(ctx, action.DriverLedger, "error listing driver transactions", "error", err)
+		return nil, err
+	}
+	return lines, nil
+}
+
+// NotifyDriver forwards a notification to the driver's WebSocket.
+func (svc *DalService) NotifyDriver(ctx context.Context, n models.DriverNotification) error {
+	log := svc.log.Func("DalService.NotifyDriver")
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	if err = svc.wsm.Send(n.DriverID, data); err != nil {
+		// the driver is not connected, nothing to retry
+		log.Warn(ctx, action.NotifyDriver, "driver is not connected", "driver_id", n.DriverID, "error", err)
+	}
+	return nil
+}
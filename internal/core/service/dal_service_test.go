@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/internal/core/service/calculator"
+	"ride-hail/pkg/wsm"
+)
+
+// rideStore keeps rides in memory. AssignDriver only matches a REQUESTED ride
+// without a driver, like the SQL it stands in for.
+type rideStore struct {
+	ports.RideRepository
+	rides   map[string]models.Ride
+	waiting []models.Point
+}
+
+func (s *rideStore) GetRide(ctx context.Context, id string) (models.Ride, error) {
+	ride, ok := s.rides[id]
+	if !ok {
+		return models.Ride{}, types.ErrRideNotFound
+	}
+	return ride, nil
+}
+
+func (s *rideStore) AssignDriver(ctx context.Context, rideID, driverID string) error {
+	ride := s.rides[rideID]
+	if ride.Status != types.RideStatusREQUESTED || ride.DriverID != "" {
+		return types.ErrInvalidRideStatus
+	}
+	ride.Status, ride.DriverID = types.RideStatusMATCHED, driverID
+	s.rides[rideID] = ride
+	return nil
+}
+
+func (s *rideStore) ListWaitingPickups(ctx context.Context, vehicleType string, minPriority int) ([]models.Point, error) {
+	return s.waiting, nil
+}
+
+type driverStore struct {
+	ports.DriverRepository
+	available  []models.AvailableDriver
+	cancellers map[string][]string
+	status     map[string]string
+}
+
+func (s *driverStore) ListAvailableDrivers(ctx context.Context, vehicleTypes ...string) ([]models.AvailableDriver, error) {
+	var drivers []models.AvailableDriver
+	for _, d := range s.available {
+		for _, vt := range vehicleTypes {
+			if d.VehicleType == vt {
+				drivers = append(drivers, d)
+			}
+		}
+	}
+	return drivers, nil
+}
+
+func (s *driverStore) ListRideCancellers(ctx context.Context, rideID string) ([]string, error) {
+	return s.cancellers[rideID], nil
+}
+
+func (s *driverStore) UpdateDriverStatus(ctx context.Context, driverID string, newStatus string) error {
+	s.status[driverID] = newStatus
+	return nil
+}
+
+type coordStore struct {
+	ports.CoordinatesRepository
+	coords map[string]models.Coordinate
+}
+
+func (s *coordStore) GetCoordinate(ctx context.Context, id string) (models.Coordinate, error) {
+	return s.coords[id], nil
+}
+
+type eventLog struct {
+	ports.RideEventRepository
+	events []string
+}
+
+func (l *eventLog) CreateEvent(ctx context.Context, rideID, eventType string, data any) error {
+	l.events = append(l.events, rideID+" "+eventType)
+	return nil
+}
+
+type sentMessages struct {
+	ports.DalPublisher
+	keys []string
+}
+
+func (p *sentMessages) Publish(exName, routingKey string, message []byte) error {
+	p.keys = append(p.keys, routingKey)
+	return nil
+}
+
+type driverSockets struct {
+	wsm.ServiceWS
+	sent []string
+}
+
+func (s *driverSockets) Send(id string, msg []byte) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+// dalFixture is an economy ride waiting at a pickup in central Almaty with
+// one driver next to it, one 20 km away and one next to it who cancelled the
+// ride before.
+type dalFixture struct {
+	svc     *DalService
+	rides   *rideStore
+	drivers *driverStore
+	events  *eventLog
+	sent    *sentMessages
+	sockets *driverSockets
+}
+
+func newDalFixture() *dalFixture {
+	f := &dalFixture{
+		rides: &rideStore{rides: map[string]models.Ride{
+			"ride-1": {ID: "ride-1", VehicleType: types.RideTypeECONOMY, Status: types.RideStatusREQUESTED, PickupCoordinateId: "pickup-1"},
+			"pool-1": {ID: "pool-1", VehicleType: types.RideTypePOOL, Status: types.RideStatusREQUESTED, PickupCoordinateId: "pickup-1"},
+		}},
+		drivers: &driverStore{
+			available: []models.AvailableDriver{
+				{ID: "near", VehicleType: types.RideTypeECONOMY, Latitude: 43.2390, Longitude: 76.8890},
+				{ID: "far", VehicleType: types.RideTypeECONOMY, Latitude: 43.4200, Longitude: 76.8890},
+				{ID: "quitter", VehicleType: types.RideTypeECONOMY, Latitude: 43.2385, Longitude: 76.8895},
+				{ID: "pooler", VehicleType: types.RideTypePOOL, Latitude: 43.2390, Longitude: 76.8890},
+			},
+			cancellers: map[string][]string{"ride-1": {"quitter"}},
+			status:     map[string]string{},
+		},
+		events:  &eventLog{},
+		sent:    &sentMessages{},
+		sockets: &driverSockets{},
+	}
+	coords := &coordStore{coords: map[string]models.Coordinate{
+		"pickup-1": {ID: "pickup-1", Latitude: 43.2389, Longitude: 76.8897},
+	}}
+	f.svc = NewDalService(discardLogger(), fakeTx{}, f.drivers, nil, f.rides, f.events, coords, nil, nil,
+		f.sent, nil, nil, f.sockets, calculator.PoolPolicy{}, MatchPolicy{RadiusKM: 5, OfferDrivers: 3}, nil)
+	return f
+}
+
+func TestAcceptRideMatchesNearbyDriver(t *testing.T) {
+	f := newDalFixture()
+
+	resp, err := f.svc.AcceptRide(context.Background(), models.DriverRideRequest{DriverID: "near", RideID: "ride-1"})
+	if err != nil {
+		t.Fatalf("AcceptRide() error = %v", err)
+	}
+	if resp.Status != types.RideStatusMATCHED {
+		t.Errorf("response status = %s, want %s", resp.Status, types.RideStatusMATCHED)
+	}
+
+	ride := f.rides.rides["ride-1"]
+	if ride.Status != types.RideStatusMATCHED || ride.DriverID != "near" {
+		t.Errorf("ride = %s/%q, want MATCHED/near", ride.Status, ride.DriverID)
+	}
+	if got := f.drivers.status["near"]; got != types.DriverStatusEnRoute {
+		t.Errorf("driver status = %q, want %s", got, types.DriverStatusEnRoute)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != "ride-1 "+types.RideEventDriverMatched {
+		t.Errorf("events = %v, want one %s", f.events.events, types.RideEventDriverMatched)
+	}
+	if len(f.sent.keys) != 1 || f.sent.keys[0] != "ride.status.MATCHED" {
+		t.Errorf("published = %v, want ride.status.MATCHED", f.sent.keys)
+	}
+
+	// the offer went to several drivers, the slower one loses
+	f.drivers.available = append(f.drivers.available, models.AvailableDriver{
+		ID: "second", VehicleType: types.RideTypeECONOMY, Latitude: 43.2388, Longitude: 76.8899,
+	})
+	_, err = f.svc.AcceptRide(context.Background(), models.DriverRideRequest{DriverID: "second", RideID: "ride-1"})
+	if !errors.Is(err, types.ErrInvalidRideStatus) {
+		t.Errorf("second AcceptRide() error = %v, want %v", err, types.ErrInvalidRideStatus)
+	}
+	if ride := f.rides.rides["ride-1"]; ride.DriverID != "near" {
+		t.Errorf("ride driver = %q after the second accept, want near", ride.DriverID)
+	}
+}
+
+func TestAcceptRideRejectsDriversWithoutOffer(t *testing.T) {
+	tests := map[string]struct {
+		driver string
+		ride   string
+		want   error
+	}{
+		"outside the radius":    {"far", "ride-1", types.ErrRideNotOffered},
+		"cancelled it before":   {"quitter", "ride-1", types.ErrRideNotOffered},
+		"offline or busy":       {"ghost", "ride-1", types.ErrRideNotOffered},
+		"pool rides are routed": {"pooler", "pool-1", types.ErrInvalidRideStatus},
+		"unknown ride":          {"near", "nope", types.ErrRideNotFound},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newDalFixture()
+
+			_, err := f.svc.AcceptRide(context.Background(), models.DriverRideRequest{DriverID: tt.driver, RideID: tt.ride})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AcceptRide() error = %v, want %v", err, tt.want)
+			}
+			if ride := f.rides.rides[tt.ride]; ride.DriverID != "" {
+				t.Errorf("ride assigned to %q", ride.DriverID)
+			}
+			if len(f.drivers.status) != 0 || len(f.events.events) != 0 || len(f.sent.keys) != 0 {
+				t.Errorf("side effects: status %v, events %v, published %v", f.drivers.status, f.events.events, f.sent.keys)
+			}
+		})
+	}
+}
+
+func TestMatchRideComparesSupplyAndDemandNearThePickup(t *testing.T) {
+	request := models.RideRequestMessage{
+		RideID:         "ride-1",
+		RideType:       types.RideTypeECONOMY,
+		PickupLocation: models.MessageLocation{Lat: 43.2389, Lng: 76.8897},
+	}
+
+	// two rides ahead across the city do not compete for the driver here
+	f := newDalFixture()
+	f.rides.waiting = []models.Point{{Lat: 43.4200, Lng: 76.8890}, {Lat: 43.1000, Lng: 76.9500}}
+	if err := f.svc.MatchRide(context.Background(), request); err != nil {
+		t.Fatalf("MatchRide() error = %v", err)
+	}
+	if len(f.sockets.sent) != 1 || f.sockets.sent[0] != "near" {
+		t.Errorf("offers sent to %v, want [near]", f.sockets.sent)
+	}
+
+	// one ride ahead next door takes the only driver nearby
+	f = newDalFixture()
+	f.rides.waiting = []models.Point{{Lat: 43.2400, Lng: 76.8900}}
+	if err := f.svc.MatchRide(context.Background(), request); err != nil {
+		t.Fatalf("MatchRide() error = %v", err)
+	}
+	if len(f.sockets.sent) != 0 {
+		t.Errorf("offers sent to %v, want the ride deferred", f.sockets.sent)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+)
+
+type EarningsService struct {
+	log     *logger.Logger
+	repo    ports.EarningsRepository
+	drivers ports.DriverRepository
+	loc     *time.Location
+}
+
+func NewEarningsService(log *logger.Logger, repo ports.EarningsRepository, drivers ports.DriverRepository, loc *time.Location) *EarningsService {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &EarningsService{
+		log:     log,
+		repo:    repo,
+		drivers: drivers,
+		loc:     loc,
+	}
+}
+
+// GetStatement builds daily or weekly earnings summaries for [from, to).
+// Zero from/to default to the last 7 days (daily) or 4 weeks (weekly).
+// Days are counted in the pricing time zone.
+func (svc *EarningsService) GetStatement(ctx context.Context, driverID, period string, from, to time.Time) (models.EarningsStatement, error) {
+	log := svc.log.Func("EarningsService.GetStatement")
+
+	trunc := "day"
+	if period == types.EarningsPeriodWeekly {
+		trunc = "week"
+	} else {
+		period = types.EarningsPeriodDaily
+	}
+	from, to = svc.window(period, from, to)
+
+	periods, err := svc.repo.SummarizeEarnings(ctx, driverID, trunc, svc.loc, from, to)
+	if err != nil {
+		log.Error(ctx, action.DriverEarnings, "error summarizing earnings", "driver_id", driverID, "error", err)
+		return models.EarningsStatement{}, err
+	}
+
+	st := models.EarningsStatement{
+		DriverID: driverID,
+		Period:   period,
+		From:     from,
+		To:       to,
+		Periods:  periods,
+	}
+	for _, p := range periods {
+		st.Totals.Rides += p.Rides
+		st.Totals.GrossFares += p.GrossFares
+		st.Totals.Commission += p.Commission
+		st.Totals.Tips += p.Tips
+		st.Totals.Net += p.Net
+	}
+	st.Totals.GrossFares = round2(st.Totals.GrossFares)
+	st.Totals.Commission = round2(st.Totals.Commission)
+	st.Totals.Tips = round2(st.Totals.Tips)
+	st.Totals.Net = round2(st.Totals.Net)
+
+	driver, err := svc.drivers.GetDriverByID(ctx, driverID)
+	if err != nil {
+		log.Error(ctx, action.DriverEarnings, "error getting driver", "driver_id", driverID, "error", err)
+		return models.EarningsStatement{}, err
+	}
+	st.LifetimeEarnings = driver.TotalEarnings
+
+	session, err := svc.drivers.GetOpenSession(ctx, driverID)
+	if err != nil {
+		log.Error(ctx, action.DriverEarnings, "error getting driver session", "driver_id", driverID, "error", err)
+		return models.EarningsStatement{}, err
+	}
+	if session != nil {
+		st.CurrentSessionEarnings = session.TotalEarnings
+	}
+	return st, nil
+}
+
+func (svc *EarningsService) ListRideEarnings(ctx context.Context, driverID string, from, to time.Time) ([]models.RideEarning, error) {
+	log := svc.log.Func("EarningsService.ListRideEarnings")
+
+	from, to = svc.window(types.EarningsPeriodDaily, from, to)
+	rides, err := svc.repo.ListRideEarnings(ctx, driverID, from, to)
+	if err != nil {
+		log.Error(ctx, action.DriverEarnings, "error listing ride earnings", "driver_id", driverID, "error", err)
+		return nil, err
+	}
+	return rides, nil
+}
+
+// window interprets from/to as calendar dates in the local zone; to is inclusive.
+func (svc *EarningsService) window(period string, from, to time.Time) (time.Time, time.Time) {
+	if to.IsZero() {
+		to = time.Now().In(svc.loc)
+	}
+	to = svc.startOfDay(to).AddDate(0, 0, 1)
+
+	if from.IsZero() {
+		if period == types.EarningsPeriodWeekly {
+			from = to.AddDate(0, 0, -28)
+		} else {
+			from = to.AddDate(0, 0, -7)
+		}
+	}
+	return svc.startOfDay(from), to
+}
+
+func (svc *EarningsService) startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, svc.loc)
+}
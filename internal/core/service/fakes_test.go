@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"ride-hail/pkg/logger"
+)
+
+// fakeTx runs the function without a transaction; the fakes apply writes
+// right away, so tests check that nothing was written before an error.
+type fakeTx struct{}
+
+func (fakeTx) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func discardLogger() *logger.Logger {
+	return logger.NewLogger("test", logger.LoggerOptions{Output: io.Discard})
+}
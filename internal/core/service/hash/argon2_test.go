@@ -0,0 +1,91 @@
+package hash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams keep the tests fast; they are far below a production cost.
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestNeedsRehash(t *testing.T) {
+	stored, err := NewHasher(testParams).HashPassword("secret password")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	with := func(change func(p *Params)) Params {
+		p := testParams
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		params Params
+		stored string
+		want   bool
+	}{
+		{"same params", testParams, stored, false},
+		{"other salt length", with(func(p *Params) { p.SaltLength = 32 }), stored, false},
+		{"more memory", with(func(p *Params) { p.Memory = 128 }), stored, true},
+		{"more iterations", with(func(p *Params) { p.Iterations = 2 }), stored, true},
+		{"more parallelism", with(func(p *Params) { p.Parallelism = 2 }), stored, true},
+		{"longer key", with(func(p *Params) { p.KeyLength = 64 }), stored, true},
+		{"legacy hash", testParams, "c2FsdA==$aGFzaA==", true},
+		{"malformed", testParams, "$argon2id$v=19$broken", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewHasher(tt.params).NeedsRehash(tt.stored); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyArgon2id(t *testing.T) {
+	stored, err := NewHasher(testParams).HashPassword("secret password")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	parts := strings.Split(stored, "$")
+	replace := func(i int, v string) string {
+		p := append([]string(nil), parts...)
+		p[i] = v
+		return strings.Join(p, "$")
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		password string
+		want     bool
+		wantErr  error
+	}{
+		{name: "correct password", stored: stored, password: "secret password", want: true},
+		{name: "wrong password", stored: stored, password: "secret passw0rd"},
+		{name: "empty password", stored: stored, password: ""},
+		{name: "other params", stored: replace(3, "m=128,t=1,p=1"), password: "secret password"},
+		{name: "argon2i", stored: replace(1, "argon2i"), password: "secret password", wantErr: ErrInvalidHash},
+		{name: "other version", stored: replace(2, "v=16"), password: "secret password", wantErr: ErrInvalidHash},
+		{name: "bad params", stored: replace(3, "m=x,t=1,p=1"), password: "secret password", wantErr: ErrInvalidHash},
+		{name: "bad salt", stored: replace(4, "!!"), password: "secret password", wantErr: ErrInvalidHash},
+		{name: "empty key", stored: replace(5, ""), password: "secret password", wantErr: ErrInvalidHash},
+		{name: "missing part", stored: strings.Join(parts[:5], "$"), password: "secret password", wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyArgon2id(tt.stored, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyArgon2id() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("verifyArgon2id() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
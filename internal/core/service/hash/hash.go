@@ -6,7 +6,6 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"io"
 	"strings"
 )
@@ -16,8 +15,8 @@ const (
 	iterCount = 100000
 )
 
-func generateSalt() ([]byte, error) {
-	salt := make([]byte, saltSize)
+func generateSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
 	_, err := io.ReadFull(rand.Reader, salt)
 	if err != nil {
 		return nil, err
@@ -25,35 +24,17 @@ func generateSalt() ([]byte, error) {
 	return salt, nil
 }
 
-func HashPassword(password string) (string, error) {
-	if password == "" {
-		return "", errors.New("password empty")
-	}
-
-	salt, err := generateSalt()
-	if err != nil {
-		return "", err
-	}
-
-	h := sha512.New()
-	h.Write([]byte(password))
-	h.Write(salt)
-	sum := h.Sum(nil)
-
-	for i := 0; i < iterCount; i++ {
-		h = sha512.New()
-		h.Write(sum)
-		h.Write(salt)
-		sum = h.Sum(nil)
+// VerifyPassword checks the password against an Argon2id PHC string or a
+// legacy iterated SHA-512 "salt$hash" value.
+func VerifyPassword(stored, password string) (bool, error) {
+	if strings.HasPrefix(stored, argon2Prefix) {
+		return verifyArgon2id(stored, password)
 	}
-
-	saltB64 := base64.StdEncoding.EncodeToString(salt)
-	hashB64 := base64.StdEncoding.EncodeToString(sum)
-
-	return fmt.Sprintf("%s$%s", saltB64, hashB64), nil
+	return verifyLegacy(stored, password)
 }
 
-func VerifyPassword(stored, password string) (bool, error) {
+// verifyLegacy checks hashes created before the move to Argon2id.
+func verifyLegacy(stored, password string) (bool, error) {
 	parts := strings.SplitN(stored, "$", 2)
 	if len(parts) != 2 {
 		return false, errors.New("invalid stored hash format")
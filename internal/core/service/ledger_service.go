@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+)
+
+const defaultCommissionPct = 20.0
+
+const (
+	maxChargeAttempts  = 5
+	pendingChargeBatch = 100
+)
+
+type LedgerService struct {
+	log        *logger.Logger
+	repo       ports.LedgerRepository
+	payments   ports.PaymentProvider
+	commission map[string]float64
+}
+
+func NewLedgerService(log *logger.Logger, repo ports.LedgerRepository, payments ports.PaymentProvider, commission map[string]float64) *LedgerService {
+	return &LedgerService{
+		log:        log,
+		repo:       repo,
+		payments:   payments,
+		commission: commission,
+	}
+}
+
+// PostRidePayment posts the completed ride to the ledger with a pending
+// charge of the passenger. Must be called inside the transaction that
+// completes the ride; the charge is collected after it commits.
+//
+//	passenger  -charged
+//	promo      -discount
+//	driver     +subtotal - commission
+//	platform   +commission
+func (svc *LedgerService) PostRidePayment(ctx context.Context, ride models.Ride, fare models.FareBreakdown) (models.RidePayment, error) {
+	log := svc.log.Func("LedgerService.PostRidePayment")
+
+	pct := svc.CommissionPct(ride.VehicleType)
+	commission := round2(fare.Subtotal * pct / 100)
+	payment := models.RidePayment{
+		Charged:        fare.Total,
+		Commission:     commission,
+		CommissionPct:  pct,
+		DriverEarnings: round2(fare.Subtotal - commission),
+		PromoFunded:    fare.Discount,
+	}
+
+	lines := []struct {
+		accountType string
+		ownerID     string
+		amount      float64
+	}{
+		{types.LedgerAccountPassenger, ride.PassengerID, -payment.Charged},
+		{types.LedgerAccountPromo, "", -payment.PromoFunded},
+		{types.LedgerAccountDriver, ride.DriverID, payment.DriverEarnings},
+		{types.LedgerAccountPlatform, "", payment.Commission},
+	}
+
+	tx := models.LedgerTransaction{
+		Kind:        types.LedgerKindRidePayment,
+		RideID:      &ride.ID,
+		Description: "ride " + ride.RideNumber,
+	}
+	setCharge(&tx, ride.PassengerID, payment.Charged)
+	for _, l := range lines {
+		e, err := svc.entry(ctx, l.accountType, l.ownerID, l.amount)
+		if err != nil {
+			return models.RidePayment{}, err
+		}
+		if e.Amount != 0 {
+			tx.Entries = append(tx.Entries, e)
+		}
+	}
+
+	var err error
+	if payment.TransactionID, err = svc.Post(ctx, tx); err != nil {
+		log.Error(ctx, action.PostLedger, "error posting ride payment", "ride_id", ride.ID, "error", err)
+		return models.RidePayment{}, err
+	}
+	return payment, nil
+}
+
+// PostTip credits a tip to the driver in full, without commission, with a
+// pending charge of the passenger. A ride can be tipped once: the unique tip
+// index rejects a concurrent second tip before anything is charged.
+//
+//	passenger  -amount
+//	driver     +amount
+func (svc *LedgerService) PostTip(ctx context.Context, ride models.Ride, amount float64) (string, error) {
+	log := svc.log.Func("LedgerService.PostTip")
+
+	passenger, err := svc.entry(ctx, types.LedgerAccountPassenger, ride.PassengerID, -amount)
+	if err != nil {
+		return "", err
+	}
+	driver, err := svc.entry(ctx, types.LedgerAccountDriver, ride.DriverID, amount)
+	if err != nil {
+		return "", err
+	}
+
+	tx := models.LedgerTransaction{
+		Kind:        types.LedgerKindTip,
+		RideID:      &ride.ID,
+		Description: "tip for ride " + ride.RideNumber,
+		Entries:     []models.LedgerEntry{passenger, driver},
+	}
+	setCharge(&tx, ride.PassengerID, amount)
+
+	id, err := svc.Post(ctx, tx)
+	if err != nil {
+		if !errors.Is(err, types.ErrDuplicateTransaction) {
+			log.Error(ctx, action.PostLedger, "error posting tip", "ride_id", ride.ID, "error", err)
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+// PostCancellationFee posts a late cancellation fee with a pending charge of
+// the passenger. The fee goes to the platform.
+//
+//	passenger  -amount
+//	platform   +amount
+func (svc *LedgerService) PostCancellationFee(ctx context.Context, ride models.Ride, amount float64) (string, error) {
+	log := svc.log.Func("LedgerService.PostCancellationFee")
+
+	passenger, err := svc.entry(ctx, types.LedgerAccountPassenger, ride.PassengerID, -amount)
+	if err != nil {
+		return "", err
+	}
+	platform, err := svc.entry(ctx, types.LedgerAccountPlatform, "", amount)
+	if err != nil {
+		return "", err
+	}
+
+	tx := models.LedgerTransaction{
+		Kind:        types.LedgerKindCancellationFee,
+		RideID:      &ride.ID,
+		Description: "cancellation fee for ride " + ride.RideNumber,
+		Entries:     []models.LedgerEntry{passenger, platform},
+	}
+	setCharge(&tx, ride.PassengerID, amount)
+
+	id, err := svc.Post(ctx, tx)
+	if err != nil {
+		log.Error(ctx, action.PostLedger, "error posting cancellation fee", "ride_id", ride.ID, "error", err)
+		return "", err
+	}
+	return id, nil
+}
+
+// PostPayout moves amount from the driver account to the payout clearing
+// account. reference is the payout batch id.
+//
+//	driver  -amount
+//	payout  +amount
+func (svc *LedgerService) PostPayout(ctx context.Context, driverID string, amount float64, reference string) (string, error) {
+	log := svc.log.Func("LedgerService.PostPayout")
+
+	driver, err := svc.entry(ctx, types.LedgerAccountDriver, driverID, -amount)
+	if err != nil {
+		return "", err
+	}
+	payout, err := svc.entry(ctx, types.LedgerAccountPayout, "", amount)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := svc.Post(ctx, models.LedgerTransaction{
+		Kind:        types.LedgerKindPayout,
+		Reference:   reference,
+		Description: "driver payout",
+		Entries:     []models.LedgerEntry{driver, payout},
+	})
+	if err != nil {
+		log.Error(ctx, action.PostLedger, "error posting payout", "driver_id", driverID, "error", err)
+		return "", err
+	}
+	return id, nil
+}
+
+// CollectCharge takes the pending charge of a committed transaction from the
+// payment provider. A refused charge stays pending for CollectPendingCharges
+// until it has been refused maxChargeAttempts times.
+func (svc *LedgerService) CollectCharge(ctx context.Context, transactionID string) error {
+	tx, err := svc.repo.GetPendingCharge(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+	return svc.collect(ctx, tx)
+}
+
+// CollectPendingCharges retries the charges that were not taken right after
+// their transaction committed.
+func (svc *LedgerService) CollectPendingCharges(ctx context.Context) {
+	log := svc.log.Func("LedgerService.CollectPendingCharges")
+
+	txs, err := svc.repo.ListPendingCharges(ctx, pendingChargeBatch)
+	if err != nil {
+		log.Error(ctx, action.PostLedger, "error listing pending charges", "error", err)
+		return
+	}
+	for _, tx := range txs {
+		// collect logs the failures, the charge is retried on the next run
+		_ = svc.collect(ctx, tx)
+	}
+}
+
+func (svc *LedgerService) collect(ctx context.Context, tx models.LedgerTransaction) error {
+	log := svc.log.Func("LedgerService.collect")
+
+	// the transaction id keeps a retried charge from being taken twice
+	chargeID, err := svc.payments.Charge(ctx, tx.ChargeCustomerID, tx.ChargeAmount, tx.ID)
+	if err != nil {
+		failed, ferr := svc.repo.RecordChargeFailure(ctx, tx.ID, maxChargeAttempts)
+		if ferr != nil {
+			log.Error(ctx, action.PostLedger, "error recording charge failure", "transaction_id", tx.ID, "error", ferr)
+		}
+		if failed {
+			log.Error(ctx, action.PostLedger, "charge failed, giving up", "transaction_id", tx.ID, "kind", tx.Kind, "error", err)
+		} else {
+			log.Warn(ctx, action.PostLedger, "charge failed, will retry", "transaction_id", tx.ID, "kind", tx.Kind, "error", err)
+		}
+		return types.ErrPaymentFailed
+	}
+
+	if err = svc.repo.MarkCharged(ctx, tx.ID, chargeID); err != nil {
+		// the provider returns the same charge for the retry
+		log.Error(ctx, action.PostLedger, "error storing charge", "transaction_id", tx.ID, "charge_id", chargeID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// setCharge makes the passenger owe amount once the transaction commits.
+func setCharge(tx *models.LedgerTransaction, customerID string, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	tx.ChargeCustomerID = customerID
+	tx.ChargeAmount = amount
+	tx.ChargeStatus = types.ChargeStatusPending
+}
+
+// Post writes a balanced transaction.
+func (svc *LedgerService) Post(ctx context.Context, tx models.LedgerTransaction) (string, error) {
+	var sum float64
+	for _, e := range tx.Entries {
+		sum += e.Amount
+	}
+	if math.Abs(sum) >= 0.005 {
+		return "", types.ErrUnbalancedTransaction
+	}
+	return svc.repo.CreateTransaction(ctx, tx)
+}
+
+func (svc *LedgerService) GetAccountBalance(ctx context.Context, accountType, ownerID string) (models.AccountBalance, error) {
+	accountID, err := svc.repo.GetOrCreateAccount(ctx, accountType, ownerID)
+	if err != nil {
+		return models.AccountBalance{}, err
+	}
+	return svc.repo.GetBalance(ctx, accountID)
+}
+
+func (svc *LedgerService) ListAccountEntries(ctx context.Context, accountType, ownerID string, limit, offset int) ([]models.AccountStatementLine, error) {
+	accountID, err := svc.repo.GetOrCreateAccount(ctx, accountType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.repo.ListAccountEntries(ctx, accountID, limit, offset)
+}
+
+func (svc *LedgerService) CommissionPct(rideType string) float64 {
+	if pct, ok := svc.commission[rideType]; ok {
+		return pct
+	}
+	return defaultCommissionPct
+}
+
+func (svc *LedgerService) entry(ctx context.Context, accountType, ownerID string, amount float64) (models.LedgerEntry, error) {
+	accountID, err := svc.repo.GetOrCreateAccount(ctx, accountType, ownerID)
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	return models.LedgerEntry{AccountID: accountID, Amount: round2(amount)}, nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
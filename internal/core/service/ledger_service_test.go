@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/internal/core/service/calculator"
+)
+
+// books keeps the accounts and the posted transactions in memory.
+type books struct {
+	ports.LedgerRepository
+	accounts map[string]string // "TYPE/owner" -> account id
+	posted   []models.LedgerTransaction
+	pending  map[string]models.LedgerTransaction
+	charged  map[string]string // transaction id -> charge id
+	failures map[string]int
+}
+
+func newBooks() *books {
+	return &books{
+		accounts: map[string]string{},
+		pending:  map[string]models.LedgerTransaction{},
+		charged:  map[string]string{},
+		failures: map[string]int{},
+	}
+}
+
+func (b *books) GetOrCreateAccount(ctx context.Context, accountType, ownerID string) (string, error) {
+	key := accountType + "/" + ownerID
+	if id, ok := b.accounts[key]; ok {
+		return id, nil
+	}
+	b.accounts[key] = key
+	return key, nil
+}
+
+func (b *books) CreateTransaction(ctx context.Context, t models.LedgerTransaction) (string, error) {
+	t.ID = "tx-" + string(rune('a'+len(b.posted)))
+	b.posted = append(b.posted, t)
+	if t.ChargeStatus == types.ChargeStatusPending {
+		b.pending[t.ID] = t
+	}
+	return t.ID, nil
+}
+
+func (b *books) ListPendingCharges(ctx context.Context, limit int) ([]models.LedgerTransaction, error) {
+	txs := make([]models.LedgerTransaction, 0, len(b.pending))
+	for _, t := range b.pending {
+		txs = append(txs, t)
+	}
+	return txs, nil
+}
+
+func (b *books) GetPendingCharge(ctx context.Context, transactionID string) (models.LedgerTransaction, error) {
+	t, ok := b.pending[transactionID]
+	if !ok {
+		return models.LedgerTransaction{}, errors.New("no pending charge")
+	}
+	return t, nil
+}
+
+func (b *books) MarkCharged(ctx context.Context, transactionID, reference string) error {
+	delete(b.pending, transactionID)
+	b.charged[transactionID] = reference
+	return nil
+}
+
+func (b *books) RecordChargeFailure(ctx context.Context, transactionID string, maxAttempts int) (bool, error) {
+	b.failures[transactionID]++
+	return b.failures[transactionID] >= maxAttempts, nil
+}
+
+// balance sums the entries posted to an account.
+func (b *books) balance(accountType, ownerID string) float64 {
+	var sum float64
+	for _, t := range b.posted {
+		for _, e := range t.Entries {
+			if e.AccountID == accountType+"/"+ownerID {
+				sum += e.Amount
+			}
+		}
+	}
+	return math.Round(sum*100) / 100
+}
+
+// card refuses charges while declined is set.
+type card struct {
+	declined bool
+	charges  []string // references of the accepted charges
+}
+
+func (c *card) Charge(ctx context.Context, customerID string, amount float64, reference string) (string, error) {
+	if c.declined {
+		return "", errors.New("card declined")
+	}
+	c.charges = append(c.charges, reference)
+	return "ch-" + reference, nil
+}
+
+func assertBalanced(t *testing.T, tx models.LedgerTransaction) {
+	t.Helper()
+	var sum float64
+	for _, e := range tx.Entries {
+		sum += e.Amount
+	}
+	if math.Abs(sum) >= 0.005 {
+		t.Errorf("%s transaction is unbalanced by %.4f: %+v", tx.Kind, sum, tx.Entries)
+	}
+}
+
+func TestPostRidePaymentSplitsTheFareAndChargesThePassenger(t *testing.T) {
+	ledger := newBooks()
+	// 17% of 1234.57 is 209.8769: the commission rounds to 209.88 and the
+	// driver gets the rest, so the cents must not go missing
+	svc := NewLedgerService(discardLogger(), ledger, &card{}, map[string]float64{types.RideTypeECONOMY: 17})
+	ride := models.Ride{ID: "ride-1", RideNumber: "RIDE_1", PassengerID: "passenger-1", DriverID: "driver-1", VehicleType: types.RideTypeECONOMY}
+	fare := models.FareBreakdown{Subtotal: 1234.57, Total: 1234.57}
+	promoFare := fare
+	calculator.ApplyDiscount(&promoFare, "SPRING", 150)
+
+	payment, err := svc.PostRidePayment(context.Background(), ride, promoFare)
+	if err != nil {
+		t.Fatalf("PostRidePayment: %v", err)
+	}
+	if payment.Commission != 209.88 || payment.DriverEarnings != 1024.69 {
+		t.Errorf("commission %.2f, driver earnings %.2f; want 209.88 and 1024.69", payment.Commission, payment.DriverEarnings)
+	}
+
+	tx := ledger.posted[0]
+	assertBalanced(t, tx)
+	if tx.ChargeAmount != 1084.57 || tx.ChargeCustomerID != "passenger-1" || tx.ChargeStatus != types.ChargeStatusPending {
+		t.Errorf("charge %.2f to %q (%s); want 1084.57 pending to passenger-1", tx.ChargeAmount, tx.ChargeCustomerID, tx.ChargeStatus)
+	}
+	if got := ledger.balance(types.LedgerAccountPromo, ""); got != -150 {
+		t.Errorf("promo account %.2f; want -150 for the discount", got)
+	}
+
+	// without a promo the promo account gets no zero entry
+	if _, err = svc.PostRidePayment(context.Background(), ride, fare); err != nil {
+		t.Fatalf("PostRidePayment without promo: %v", err)
+	}
+	assertBalanced(t, ledger.posted[1])
+	if n := len(ledger.posted[1].Entries); n != 3 {
+		t.Errorf("%d entries without a promo; want passenger, driver and platform", n)
+	}
+	if got := ledger.balance(types.LedgerAccountDriver, "driver-1"); got != 2049.38 {
+		t.Errorf("driver account %.2f after two rides; want 2049.38", got)
+	}
+}
+
+func TestPostTipAndCancellationFee(t *testing.T) {
+	ledger := newBooks()
+	svc := NewLedgerService(discardLogger(), ledger, &card{}, nil)
+	ride := models.Ride{ID: "ride-1", PassengerID: "passenger-1", DriverID: "driver-1"}
+	ctx := context.Background()
+
+	if _, err := svc.PostTip(ctx, ride, 333.33); err != nil {
+		t.Fatalf("PostTip: %v", err)
+	}
+	if _, err := svc.PostCancellationFee(ctx, ride, 500); err != nil {
+		t.Fatalf("PostCancellationFee: %v", err)
+	}
+
+	for _, tx := range ledger.posted {
+		assertBalanced(t, tx)
+		if tx.ChargeStatus != types.ChargeStatusPending || tx.ChargeCustomerID != "passenger-1" {
+			t.Errorf("%s: passenger is not charged", tx.Kind)
+		}
+	}
+	// the tip goes to the driver in full, the fee to the platform
+	if got := ledger.balance(types.LedgerAccountDriver, "driver-1"); got != 333.33 {
+		t.Errorf("driver account %.2f; want the whole tip", got)
+	}
+	if got := ledger.balance(types.LedgerAccountPlatform, ""); got != 500 {
+		t.Errorf("platform account %.2f; want the cancellation fee", got)
+	}
+	if got := ledger.balance(types.LedgerAccountPassenger, "passenger-1"); got != -833.33 {
+		t.Errorf("passenger account %.2f; want -833.33", got)
+	}
+}
+
+func TestPostPayoutChargesNobody(t *testing.T) {
+	ledger := newBooks()
+	svc := NewLedgerService(discardLogger(), ledger, &card{}, nil)
+
+	if _, err := svc.PostPayout(context.Background(), "driver-1", 12000, "batch-7"); err != nil {
+		t.Fatalf("PostPayout: %v", err)
+	}
+	tx := ledger.posted[0]
+	assertBalanced(t, tx)
+	if tx.ChargeStatus != "" || tx.ChargeAmount != 0 {
+		t.Errorf("payout has a charge: %s %.2f", tx.ChargeStatus, tx.ChargeAmount)
+	}
+	if tx.Reference != "batch-7" {
+		t.Errorf("reference %q; want the batch id", tx.Reference)
+	}
+	if got := ledger.balance(types.LedgerAccountPayout, ""); got != 12000 {
+		t.Errorf("payout account %.2f; want 12000", got)
+	}
+}
+
+func TestPostRejectsUnbalancedTransactions(t *testing.T) {
+	ledger := newBooks()
+	svc := NewLedgerService(discardLogger(), ledger, &card{}, nil)
+
+	_, err := svc.Post(context.Background(), models.LedgerTransaction{
+		Kind: types.LedgerKindPayout,
+		Entries: []models.LedgerEntry{
+			{AccountID: "DRIVER/driver-1", Amount: -100},
+			{AccountID: "PAYOUT/", Amount: 99.99},
+		},
+	})
+	if !errors.Is(err, types.ErrUnbalancedTransaction) {
+		t.Fatalf("err = %v; want ErrUnbalancedTransaction", err)
+	}
+	if len(ledger.posted) != 0 {
+		t.Error("unbalanced transaction was stored")
+	}
+}
+
+func TestCollectCharge(t *testing.T) {
+	ledger := newBooks()
+	provider := &card{declined: true}
+	svc := NewLedgerService(discardLogger(), ledger, provider, nil)
+	ctx := context.Background()
+
+	id, err := svc.PostTip(ctx, models.Ride{ID: "ride-1", PassengerID: "passenger-1", DriverID: "driver-1"}, 200)
+	if err != nil {
+		t.Fatalf("PostTip: %v", err)
+	}
+
+	if err = svc.CollectCharge(ctx, id); !errors.Is(err, types.ErrPaymentFailed) {
+		t.Fatalf("declined charge: err = %v; want ErrPaymentFailed", err)
+	}
+	if ledger.failures[id] != 1 {
+		t.Errorf("%d failures recorded; want 1", ledger.failures[id])
+	}
+	if _, ok := ledger.pending[id]; !ok {
+		t.Fatal("declined charge is no longer pending")
+	}
+
+	// the retry run takes the charge once the card works again
+	provider.declined = false
+	svc.CollectPendingCharges(ctx)
+	if ledger.charged[id] != "ch-"+id {
+		t.Errorf("charge stored as %q; want the provider charge id", ledger.charged[id])
+	}
+	if len(provider.charges) != 1 || provider.charges[0] != id {
+		t.Errorf("provider charges %v; want one charge referenced by the transaction id", provider.charges)
+	}
+}
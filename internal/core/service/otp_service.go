@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"ride-hail/config"
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+)
+
+// OTPService logs users in with a one-time passcode sent to their phone. The
+// phone and the client address are throttled like password logins, and the
+// tokens are issued by the AuthService.
+type OTPService struct {
+	log   *logger.Logger
+	cfg   config.Config
+	repo  ports.OTPRepository
+	users ports.UserRepository
+	sms   ports.SMSSender
+	auth  *AuthService
+}
+
+// minOTPSecretLen is the shortest accepted OTP.Secret.
+const minOTPSecretLen = 32
+
+func NewOTPService(log *logger.Logger, cfg config.Config, repo ports.OTPRepository, users ports.UserRepository, sms ports.SMSSender,
+	auth *AuthService) (*OTPService, error) {
+	if len(cfg.OTP.Secret) < minOTPSecretLen {
+		return nil, fmt.Errorf("otp secret must be at least %d characters", minOTPSecretLen)
+	}
+	return &OTPService{
+		log:   log,
+		cfg:   cfg,
+		repo:  repo,
+		users: users,
+		sms:   sms,
+		auth:  auth,
+	}, nil
+}
+
+// RequestOTP sends a new code to the phone. Unknown phones and requests within
+// the resend interval are silently ignored, so the response does not reveal
+// which phones are registered. An unknown phone still gets a code stored that
+// is never sent, so both cases take the same queries and the response time
+// does not tell them apart either.
+func (svc *OTPService) RequestOTP(ctx context.Context, phone string) error {
+	log := svc.log.Func("OTPService.RequestOTP")
+
+	if err := svc.checkLocked(ctx, phone); err != nil {
+		return err
+	}
+
+	u, err := svc.users.GetUserByPhone(ctx, phone)
+	known := !errors.Is(err, types.ErrUserNotFound)
+	if known && err != nil {
+		log.Error(ctx, action.OTPLogin, "error getting user", "phone", phone, "error", err)
+		return err
+	}
+
+	lastSent, err := svc.repo.GetLastSentAt(ctx, phone)
+	if err != nil {
+		log.Error(ctx, action.OTPLogin, "error getting last code", "userID", u.ID, "error", err)
+		return err
+	}
+	if time.Since(lastSent) < svc.cfg.OTP.ResendInterval {
+		log.Warn(ctx, action.OTPLogin, "code requested again too soon", "userID", u.ID)
+		return nil
+	}
+
+	code, err := newOTP(svc.cfg.OTP.Length)
+	if err != nil {
+		log.Error(ctx, action.OTPLogin, "error generating code", "error", err)
+		return err
+	}
+	if err = svc.repo.CreateOTP(ctx, models.OTPCode{
+		Phone:     phone,
+		CodeHash:  svc.hashOTP(phone, code),
+		ExpiresAt: time.Now().Add(svc.cfg.OTP.TTL),
+	}); err != nil {
+		log.Error(ctx, action.OTPLogin, "error storing code", "userID", u.ID, "error", err)
+		return err
+	}
+
+	// VerifyOTP rejects the code of an unknown phone after checking it
+	if !known {
+		log.Debug(ctx, action.OTPLogin, "code requested for unknown phone", "phone", phone)
+		return nil
+	}
+
+	text := fmt.Sprintf("Your ride-hail code is %s. It expires in %s. Do not share it with anyone.", code, svc.cfg.OTP.TTL)
+	if err = svc.sms.SendSMS(ctx, phone, text); err != nil {
+		log.Error(ctx, action.OTPLogin, "error sending code", "userID", u.ID, "error", err)
+		return err
+	}
+
+	log.Info(ctx, action.OTPLogin, "code sent", "userID", u.ID)
+	return nil
+}
+
+// VerifyOTP checks the code and returns the same token pair as a password
+// login. A wrong code counts as a failed login of the phone and the client
+// address; every code also allows only OTP.MaxAttempts checks.
+func (svc *OTPService) VerifyOTP(ctx context.Context, phone, code string) (models.TokenPair, error) {
+	log := svc.log.Func("OTPService.VerifyOTP")
+
+	if err := svc.checkLocked(ctx, phone); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	ok, err := svc.repo.CheckOTP(ctx, phone, svc.hashOTP(phone, code), svc.cfg.OTP.MaxAttempts)
+	if err != nil {
+		log.Error(ctx, action.OTPLogin, "error checking code", "phone", phone, "error", err)
+		return models.TokenPair{}, err
+	}
+	keys := throttleKeys("phone:"+phone, logger.GetClientIP(ctx))
+	if !ok {
+		log.Warn(ctx, action.OTPLogin, "invalid code", "phone", phone)
+		svc.auth.recordLoginFailure(ctx, keys, "")
+		return models.TokenPair{}, types.ErrInvalidOTP
+	}
+
+	if _, err = svc.auth.attempts.ClearFailures(ctx, keys[0]); err != nil {
+		log.Warn(ctx, action.OTPLogin, "error clearing login failures", "phone", phone, "error", err)
+	}
+
+	// the phone may have moved to another account since the code was sent
+	u, err := svc.users.GetUserByPhone(ctx, phone)
+	if errors.Is(err, types.ErrUserNotFound) {
+		return models.TokenPair{}, types.ErrInvalidOTP
+	}
+	if err != nil {
+		log.Error(ctx, action.OTPLogin, "error getting user", "phone", phone, "error", err)
+		return models.TokenPair{}, err
+	}
+
+	pair, err := svc.auth.issueTokens(ctx, u, newClaimsID())
+	if err != nil {
+		log.Error(ctx, action.OTPLogin, "error issuing tokens", "userID", u.ID, "error", err)
+		return models.TokenPair{}, err
+	}
+
+	log.Info(ctx, action.OTPLogin, "user logged in with code", "userID", u.ID)
+	return pair, nil
+}
+
+func (svc *OTPService) checkLocked(ctx context.Context, phone string) error {
+	until, err := svc.auth.attempts.GetLockedUntil(ctx, throttleKeys("phone:"+phone, logger.GetClientIP(ctx)))
+	if err != nil {
+		svc.log.Func("OTPService.checkLocked").Error(ctx, action.OTPLogin, "error checking login lockout", "error", err)
+		return err
+	}
+	if !until.IsZero() {
+		return &types.LoginLockedError{Until: until}
+	}
+	return nil
+}
+
+// newOTP returns a random code of length digits.
+func newOTP(length int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+// hashOTP binds the code to the phone, so equal codes of two phones differ.
+// The hash is keyed with OTP.Secret: a leaked table alone does not allow
+// trying all the codes of a phone offline.
+func (svc *OTPService) hashOTP(phone, code string) string {
+	mac := hmac.New(sha256.New, []byte(svc.cfg.OTP.Secret))
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
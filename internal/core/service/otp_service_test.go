@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/config"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+)
+
+// otpCodes follows OTPRepository.CheckOTP: every check counts against the
+// newest code that is unused, unexpired and under maxAttempts.
+type otpCodes struct {
+	codes []*otpCode
+}
+
+type otpCode struct {
+	models.OTPCode
+	attempts int
+	used     bool
+	sentAt   time.Time
+}
+
+func (c *otpCodes) CreateOTP(ctx context.Context, code models.OTPCode) error {
+	c.codes = append(c.codes, &otpCode{OTPCode: code, sentAt: time.Now()})
+	return nil
+}
+
+func (c *otpCodes) GetLastSentAt(ctx context.Context, phone string) (time.Time, error) {
+	var last time.Time
+	for _, code := range c.codes {
+		if code.Phone == phone {
+			last = code.sentAt
+		}
+	}
+	return last, nil
+}
+
+func (c *otpCodes) CheckOTP(ctx context.Context, phone, codeHash string, maxAttempts int) (bool, error) {
+	for i := len(c.codes) - 1; i >= 0; i-- {
+		code := c.codes[i]
+		if code.Phone != phone || code.used || time.Now().After(code.ExpiresAt) || code.attempts >= maxAttempts {
+			continue
+		}
+		code.attempts++
+		code.used = code.CodeHash == codeHash
+		return code.used, nil
+	}
+	return false, nil
+}
+
+// loginCounters follows LoginAttemptRepository without the failure window.
+type loginCounters struct {
+	failures map[string]int
+	locked   map[string]time.Time
+}
+
+func (l *loginCounters) GetLockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	var until time.Time
+	for _, key := range keys {
+		if l.locked[key].After(until) {
+			until = l.locked[key]
+		}
+	}
+	return until, nil
+}
+
+func (l *loginCounters) RecordFailure(ctx context.Context, key string, resetBefore time.Time) (int, error) {
+	l.failures[key]++
+	return l.failures[key], nil
+}
+
+func (l *loginCounters) Lock(ctx context.Context, key string, until time.Time) error {
+	l.locked[key] = until
+	return nil
+}
+
+func (l *loginCounters) ClearFailures(ctx context.Context, key string) (bool, error) {
+	cleared := l.failures[key] > 0
+	delete(l.failures, key)
+	return cleared, nil
+}
+
+func (l *loginCounters) CreateAuditEntry(ctx context.Context, e models.LoginAuditEntry) error {
+	return nil
+}
+
+type phoneUsers struct {
+	ports.UserRepository
+	byPhone map[string]models.User
+}
+
+func (p phoneUsers) GetUserByPhone(ctx context.Context, phone string) (models.User, error) {
+	u, ok := p.byPhone[phone]
+	if !ok {
+		return models.User{}, types.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// inbox keeps the texts sent to each phone.
+type inbox map[string][]string
+
+func (in inbox) SendSMS(ctx context.Context, phone, text string) error {
+	in[phone] = append(in[phone], text)
+	return nil
+}
+
+// lastCode returns the code of the last text sent to the phone.
+func (in inbox) lastCode(t *testing.T, phone string) string {
+	t.Helper()
+	texts := in[phone]
+	if len(texts) == 0 {
+		t.Fatalf("no code was sent to %s", phone)
+	}
+	_, rest, _ := strings.Cut(texts[len(texts)-1], "code is ")
+	code, _, _ := strings.Cut(rest, ".")
+	return code
+}
+
+type otpFixture struct {
+	svc      *OTPService
+	codes    *otpCodes
+	attempts *loginCounters
+	sms      inbox
+}
+
+func newOTPFixture(t *testing.T, maxFailures int) *otpFixture {
+	t.Helper()
+	var cfg config.Config
+	cfg.OTP.Secret = strings.Repeat("s", minOTPSecretLen)
+	cfg.OTP.TTL = 5 * time.Minute
+	cfg.OTP.Length = 6
+	cfg.OTP.MaxAttempts = 3
+	cfg.Login.MaxFailures = maxFailures
+	cfg.Login.IPMaxFailures = 100
+	cfg.Login.LockoutBase = time.Minute
+	cfg.Login.LockoutMax = time.Hour
+	cfg.JWT.AccessTTL = 15 * time.Minute
+
+	f := &otpFixture{
+		codes:    &otpCodes{},
+		attempts: &loginCounters{failures: map[string]int{}, locked: map[string]time.Time{}},
+		sms:      inbox{},
+	}
+	users := phoneUsers{byPhone: map[string]models.User{"+77010000001": {ID: "user-1", Role: types.RolePassenger}}}
+	tokens := &refreshTokens{byHash: map[string]*models.RefreshToken{}}
+	auth := NewAuthService(cfg, fakeTx{}, users, nil, tokens, f.attempts, stubSigner{}, nil, discardLogger())
+
+	var err error
+	if f.svc, err = NewOTPService(discardLogger(), cfg, f.codes, users, f.sms, auth); err != nil {
+		t.Fatalf("NewOTPService: %v", err)
+	}
+	return f
+}
+
+func TestVerifyOTPBurnsTheCodeAfterMaxAttempts(t *testing.T) {
+	f := newOTPFixture(t, 100)
+	ctx := context.Background()
+	const phone = "+77010000001"
+
+	if err := f.svc.RequestOTP(ctx, phone); err != nil {
+		t.Fatalf("RequestOTP: %v", err)
+	}
+	code := f.sms.lastCode(t, phone)
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.svc.VerifyOTP(ctx, phone, "wrong"); !errors.Is(err, types.ErrInvalidOTP) {
+			t.Fatalf("wrong code %d: err = %v, want ErrInvalidOTP", i+1, err)
+		}
+	}
+	// three wrong guesses used up the code, the right one comes too late
+	if _, err := f.svc.VerifyOTP(ctx, phone, code); !errors.Is(err, types.ErrInvalidOTP) {
+		t.Fatalf("right code after the attempts ran out: err = %v, want ErrInvalidOTP", err)
+	}
+
+	if err := f.svc.RequestOTP(ctx, phone); err != nil {
+		t.Fatalf("RequestOTP: %v", err)
+	}
+	code = f.sms.lastCode(t, phone)
+	pair, err := f.svc.VerifyOTP(ctx, phone, code)
+	if err != nil {
+		t.Fatalf("new code: %v", err)
+	}
+	if pair.AccessToken == "" || pair.RefreshToken == "" {
+		t.Errorf("no tokens issued: %+v", pair)
+	}
+	if _, err = f.svc.VerifyOTP(ctx, phone, code); !errors.Is(err, types.ErrInvalidOTP) {
+		t.Errorf("code used twice: err = %v, want ErrInvalidOTP", err)
+	}
+}
+
+func TestVerifyOTPLocksThePhoneAfterRepeatedFailures(t *testing.T) {
+	f := newOTPFixture(t, 2)
+	ctx := context.Background()
+	const phone = "+77010000001"
+
+	if err := f.svc.RequestOTP(ctx, phone); err != nil {
+		t.Fatalf("RequestOTP: %v", err)
+	}
+	code := f.sms.lastCode(t, phone)
+	f.svc.VerifyOTP(ctx, phone, "wrong-1")
+	f.svc.VerifyOTP(ctx, phone, "wrong-2")
+
+	var locked *types.LoginLockedError
+	if _, err := f.svc.VerifyOTP(ctx, phone, code); !errors.As(err, &locked) {
+		t.Fatalf("right code on a locked phone: err = %v, want LoginLockedError", err)
+	}
+	if err := f.svc.RequestOTP(ctx, phone); !errors.As(err, &locked) {
+		t.Errorf("new code for a locked phone: err = %v, want LoginLockedError", err)
+	}
+	if len(f.sms[phone]) != 1 {
+		t.Errorf("%d texts sent, want only the first code", len(f.sms[phone]))
+	}
+}
+
+func TestRequestOTPForAnUnknownPhoneSendsNothing(t *testing.T) {
+	f := newOTPFixture(t, 100)
+
+	if err := f.svc.RequestOTP(context.Background(), "+77019999999"); err != nil {
+		t.Fatalf("RequestOTP: %v", err)
+	}
+	if len(f.sms) != 0 {
+		t.Errorf("texts sent to an unknown phone: %v", f.sms)
+	}
+	// a code is stored all the same, so the request looks like any other
+	if len(f.codes.codes) != 1 {
+		t.Errorf("%d codes stored, want 1", len(f.codes.codes))
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/txm"
+)
+
+type PayoutService struct {
+	log       *logger.Logger
+	txm       txm.Manager
+	repo      ports.PayoutRepository
+	ledger    ports.LedgerService
+	exporter  ports.PayoutExporter
+	minAmount float64
+}
+
+func NewPayoutService(log *logger.Logger, txm txm.Manager, repo ports.PayoutRepository, ledger ports.LedgerService,
+	exporter ports.PayoutExporter, minAmount float64) *PayoutService {
+	return &PayoutService{
+		log:       log,
+		txm:       txm,
+		repo:      repo,
+		ledger:    ledger,
+		exporter:  exporter,
+		minAmount: minAmount,
+	}
+}
+
+// RunBatch pays out every driver balance of at least minAmount: each balance
+// is moved to the payout account in the ledger and recorded as a PENDING
+// payout of one batch, which is then exported as CSV for finance.
+// Returns a batch with an empty ID when there is nothing to pay.
+func (svc *PayoutService) RunBatch(ctx context.Context) (models.PayoutBatch, error) {
+	log := svc.log.Func("PayoutService.RunBatch")
+
+	var batch models.PayoutBatch
+	var payouts []models.Payout
+	err := svc.txm.Do(ctx, func(ctx context.Context) error {
+		if err := svc.repo.LockPayouts(ctx); err != nil {
+			return err
+		}
+
+		balances, err := svc.repo.ListPayableBalances(ctx, svc.minAmount)
+		if err != nil {
+			return err
+		}
+		if len(balances) == 0 {
+			return nil
+		}
+
+		if batch, err = svc.repo.CreateBatch(ctx); err != nil {
+			return err
+		}
+
+		for _, b := range balances {
+			amount := round2(b.Balance)
+			txID, err := svc.ledger.PostPayout(ctx, b.OwnerID, amount, batch.ID)
+			if err != nil {
+				return err
+			}
+
+			p := models.Payout{
+				CreatedAt:           batch.CreatedAt,
+				BatchID:             batch.ID,
+				DriverID:            b.OwnerID,
+				Amount:              amount,
+				Status:              types.PayoutStatusPending,
+				LedgerTransactionID: txID,
+			}
+			if p.ID, err = svc.repo.CreatePayout(ctx, p); err != nil {
+				return err
+			}
+			payouts = append(payouts, p)
+
+			batch.TotalAmount += amount
+			batch.DriversCount++
+		}
+		batch.TotalAmount = round2(batch.TotalAmount)
+		return svc.repo.UpdateBatch(ctx, batch)
+	})
+	if err != nil {
+		log.Error(ctx, action.RunPayouts, "error running payout batch", "error", err)
+		return models.PayoutBatch{}, err
+	}
+	if batch.ID == "" {
+		log.Info(ctx, action.RunPayouts, "no payable balances")
+		return batch, nil
+	}
+
+	// the batch is already committed; a failed export can be repeated via ExportBatch
+	path, err := svc.exporter.Export(batch, payouts)
+	if err != nil {
+		log.Error(ctx, action.ExportPayouts, "error exporting payout batch", "batch_id", batch.ID, "error", err)
+		return batch, nil
+	}
+	batch.ExportPath = path
+	if err = svc.repo.UpdateBatch(ctx, batch); err != nil {
+		log.Error(ctx, action.ExportPayouts, "error saving export path", "batch_id", batch.ID, "error", err)
+	}
+
+	log.Info(ctx, action.RunPayouts, "payout batch created",
+		"batch_id", batch.ID, "drivers", batch.DriversCount, "total", batch.TotalAmount)
+	return batch, nil
+}
+
+// ExportBatch writes the batch payouts as CSV to w.
+func (svc *PayoutService) ExportBatch(ctx context.Context, batchID string, w io.Writer) error {
+	log := svc.log.Func("PayoutService.ExportBatch")
+
+	batch, err := svc.repo.GetBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+
+	payouts, err := svc.repo.ListPayouts(ctx, batchID)
+	if err != nil {
+		log.Error(ctx, action.ExportPayouts, "error listing payouts", "batch_id", batchID, "error", err)
+		return err
+	}
+	return svc.exporter.Write(w, batch, payouts)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/internal/core/service/calculator"
+	"ride-hail/pkg/logger"
+)
+
+type PromoService struct {
+	log   *logger.Logger
+	repo  ports.PromoRepository
+	rides ports.RideRepository
+}
+
+func NewPromoService(log *logger.Logger, repo ports.PromoRepository, rides ports.RideRepository) *PromoService {
+	return &PromoService{
+		log:   log,
+		repo:  repo,
+		rides: rides,
+	}
+}
+
+func (svc *PromoService) CreatePromo(ctx context.Context, p models.PromoCode) (models.PromoCode, error) {
+	log := svc.log.Func("PromoService.CreatePromo")
+
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if p.ValidFrom.IsZero() {
+		p.ValidFrom = time.Now()
+	}
+	if p.PerUserLimit == 0 {
+		p.PerUserLimit = 1
+	}
+
+	id, err := svc.repo.CreatePromo(ctx, p)
+	if err != nil {
+		log.Error(ctx, action.CreatePromo, "error creating promo code", "code", p.Code, "error", err)
+		return models.PromoCode{}, err
+	}
+	p.ID = id
+	return p, nil
+}
+
+// Quote checks that the code can be used for the ride and returns the
+// expected discount. Nothing is reserved: the code is redeemed at completion.
+func (svc *PromoService) Quote(ctx context.Context, code, userID, rideType string, fare float64) (models.PromoQuote, error) {
+	log := svc.log.Func("PromoService.Quote")
+
+	p, err := svc.repo.GetPromoByCode(ctx, code)
+	if err != nil {
+		log.Warn(ctx, action.ApplyPromo, "promo code lookup failed", "code", code, "error", err)
+		return models.PromoQuote{}, err
+	}
+
+	if err = svc.check(ctx, p, userID, rideType); err != nil {
+		log.Warn(ctx, action.ApplyPromo, "promo code rejected", "code", code, "error", err)
+		return models.PromoQuote{}, err
+	}
+
+	return models.PromoQuote{
+		PromoCodeID: p.ID,
+		Code:        p.Code,
+		Discount:    calculator.Discount(p, fare),
+	}, nil
+}
+
+// Redeem re-validates the code under a row lock and records the redemption.
+// Must be called inside a transaction.
+func (svc *PromoService) Redeem(ctx context.Context, promoID, userID, rideID, rideType string, fare float64) (models.PromoQuote, error) {
+	log := svc.log.Func("PromoService.Redeem")
+
+	p, err := svc.repo.GetPromoForUpdate(ctx, promoID)
+	if err != nil {
+		return models.PromoQuote{}, err
+	}
+
+	if err = svc.check(ctx, p, userID, rideType); err != nil {
+		log.Warn(ctx, action.ApplyPromo, "promo code can no longer be redeemed", "code", p.Code, "error", err)
+		return models.PromoQuote{}, err
+	}
+
+	discount := calculator.Discount(p, fare)
+	if err = svc.repo.IncrementUsage(ctx, p.ID); err != nil {
+		return models.PromoQuote{}, err
+	}
+	if err = svc.repo.CreateRedemption(ctx, models.PromoRedemption{
+		PromoCodeID:    p.ID,
+		UserID:         userID,
+		RideID:         rideID,
+		DiscountAmount: discount,
+	}); err != nil {
+		return models.PromoQuote{}, err
+	}
+
+	return models.PromoQuote{
+		PromoCodeID: p.ID,
+		Code:        p.Code,
+		Discount:    discount,
+	}, nil
+}
+
+func (svc *PromoService) check(ctx context.Context, p models.PromoCode, userID, rideType string) error {
+	now := time.Now()
+	if !p.IsActive || now.Before(p.ValidFrom) || (p.ValidUntil != nil && now.After(*p.ValidUntil)) {
+		return types.ErrPromoExpired
+	}
+
+	if len(p.RideTypes) > 0 && !slices.Contains(p.RideTypes, rideType) {
+		return types.ErrPromoNotApplicable
+	}
+
+	if p.UsageLimit != nil && p.UsedCount >= *p.UsageLimit {
+		return types.ErrPromoLimitReached
+	}
+
+	used, err := svc.repo.CountUserRedemptions(ctx, p.ID, userID)
+	if err != nil {
+		return err
+	}
+	if used >= p.PerUserLimit {
+		return types.ErrPromoLimitReached
+	}
+
+	if p.FirstRideOnly {
+		completed, err := svc.rides.CountCompletedRides(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if completed > 0 {
+			return types.ErrPromoNotApplicable
+		}
+	}
+	return nil
+}
+
+func isPromoError(err error) bool {
+	for _, e := range []error{types.ErrPromoNotFound, types.ErrPromoExpired, types.ErrPromoLimitReached, types.ErrPromoNotApplicable} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
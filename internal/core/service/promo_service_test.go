@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+)
+
+// promoBook holds one promo code and its redemptions.
+type promoBook struct {
+	ports.PromoRepository
+	promo       models.PromoCode
+	redemptions []models.PromoRedemption
+}
+
+func (b *promoBook) GetPromoByCode(ctx context.Context, code string) (models.PromoCode, error) {
+	if code != b.promo.Code {
+		return models.PromoCode{}, types.ErrPromoNotFound
+	}
+	return b.promo, nil
+}
+
+func (b *promoBook) GetPromoForUpdate(ctx context.Context, id string) (models.PromoCode, error) {
+	return b.promo, nil
+}
+
+func (b *promoBook) CountUserRedemptions(ctx context.Context, promoID, userID string) (int, error) {
+	n := 0
+	for _, r := range b.redemptions {
+		if r.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (b *promoBook) IncrementUsage(ctx context.Context, promoID string) error {
+	b.promo.UsedCount++
+	return nil
+}
+
+func (b *promoBook) CreateRedemption(ctx context.Context, r models.PromoRedemption) error {
+	b.redemptions = append(b.redemptions, r)
+	return nil
+}
+
+// rideHistory counts the completed rides of each passenger.
+type rideHistory struct {
+	ports.RideRepository
+	completed map[string]int
+}
+
+func (h rideHistory) CountCompletedRides(ctx context.Context, passengerID string) (int, error) {
+	return h.completed[passengerID], nil
+}
+
+func TestRedeemEnforcesTheUsageLimits(t *testing.T) {
+	limit := 2
+	maxDiscount := 300.0
+	book := &promoBook{promo: models.PromoCode{
+		ID: "promo-1", Code: "WELCOME", DiscountType: types.DiscountTypePercent, Value: 25, MaxDiscount: &maxDiscount,
+		UsageLimit: &limit, PerUserLimit: 1, ValidFrom: time.Now().Add(-time.Hour), IsActive: true,
+	}}
+	svc := NewPromoService(discardLogger(), book, rideHistory{})
+	ctx := context.Background()
+
+	q, err := svc.Redeem(ctx, "promo-1", "passenger-1", "ride-1", types.RideTypeECONOMY, 2000)
+	if err != nil {
+		t.Fatalf("first redemption: %v", err)
+	}
+	if q.Discount != 300 {
+		t.Errorf("discount %.2f, want 25%% of 2000 capped at 300", q.Discount)
+	}
+
+	// once per passenger
+	if _, err = svc.Redeem(ctx, "promo-1", "passenger-1", "ride-2", types.RideTypeECONOMY, 1000); !errors.Is(err, types.ErrPromoLimitReached) {
+		t.Errorf("second redemption by the same passenger: err = %v, want ErrPromoLimitReached", err)
+	}
+
+	if _, err = svc.Redeem(ctx, "promo-1", "passenger-2", "ride-3", types.RideTypeECONOMY, 1000); err != nil {
+		t.Fatalf("redemption by another passenger: %v", err)
+	}
+	// the code is used up for everyone
+	if _, err = svc.Redeem(ctx, "promo-1", "passenger-3", "ride-4", types.RideTypeECONOMY, 1000); !errors.Is(err, types.ErrPromoLimitReached) {
+		t.Errorf("redemption past the usage limit: err = %v, want ErrPromoLimitReached", err)
+	}
+
+	if book.promo.UsedCount != 2 || len(book.redemptions) != 2 {
+		t.Errorf("used %d times with %d redemptions, want 2 and 2", book.promo.UsedCount, len(book.redemptions))
+	}
+	if r := book.redemptions[0]; r.RideID != "ride-1" || r.DiscountAmount != 300 {
+		t.Errorf("first redemption %+v, want ride-1 with 300 off", r)
+	}
+}
+
+func TestQuoteRejectsCodesThatDoNotApply(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour)
+	valid := models.PromoCode{
+		ID: "promo-1", Code: "FIRST", DiscountType: types.DiscountTypeFixed, Value: 500, PerUserLimit: 1,
+		ValidFrom: yesterday, IsActive: true, FirstRideOnly: true, RideTypes: []string{types.RideTypeECONOMY},
+	}
+	history := rideHistory{completed: map[string]int{"regular": 12}}
+
+	t.Run("new passenger", func(t *testing.T) {
+		svc := NewPromoService(discardLogger(), &promoBook{promo: valid}, history)
+		q, err := svc.Quote(context.Background(), "FIRST", "newcomer", types.RideTypeECONOMY, 400)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Discount != 400 {
+			t.Errorf("discount %.2f, want the whole fare of 400", q.Discount)
+		}
+	})
+
+	t.Run("passenger with completed rides", func(t *testing.T) {
+		svc := NewPromoService(discardLogger(), &promoBook{promo: valid}, history)
+		if _, err := svc.Quote(context.Background(), "FIRST", "regular", types.RideTypeECONOMY, 400); !errors.Is(err, types.ErrPromoNotApplicable) {
+			t.Errorf("err = %v, want ErrPromoNotApplicable", err)
+		}
+	})
+
+	t.Run("other ride type", func(t *testing.T) {
+		svc := NewPromoService(discardLogger(), &promoBook{promo: valid}, history)
+		if _, err := svc.Quote(context.Background(), "FIRST", "newcomer", types.RideTypePREMIUM, 400); !errors.Is(err, types.ErrPromoNotApplicable) {
+			t.Errorf("err = %v, want ErrPromoNotApplicable", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := valid
+		expired.ValidUntil = &yesterday
+		svc := NewPromoService(discardLogger(), &promoBook{promo: expired}, history)
+		if _, err := svc.Quote(context.Background(), "FIRST", "newcomer", types.RideTypeECONOMY, 400); !errors.Is(err, types.ErrPromoExpired) {
+			t.Errorf("err = %v, want ErrPromoExpired", err)
+		}
+	})
+}
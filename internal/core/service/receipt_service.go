@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/internal/core/service/calculator"
+	"ride-hail/pkg/logger"
+)
+
+// maxRoutePoints limits the route drawn on receipts.
+const maxRoutePoints = 200
+
+type ReceiptService struct {
+	log      *logger.Logger
+	repo     ReceiptRepository
+	renderer ports.ReceiptRenderer
+	mailer   ports.Mailer
+}
+
+type ReceiptRepository struct {
+	ride     ports.RideRepository
+	cord     ports.CoordinatesRepository
+	driver   ports.DriverRepository
+	location ports.LocationRepository
+	event    ports.RideEventRepository
+	user     ports.UserRepository
+}
+
+func NewReceiptService(log *logger.Logger, rideRepo ports.RideRepository, cordRepo ports.CoordinatesRepository,
+	driverRepo ports.DriverRepository, locationRepo ports.LocationRepository, eventRepo ports.RideEventRepository,
+	userRepo ports.UserRepository, renderer ports.ReceiptRenderer, mailer ports.Mailer) *ReceiptService {
+	return &ReceiptService{
+		log:      log,
+		renderer: renderer,
+		mailer:   mailer,
+		repo: ReceiptRepository{
+			ride:     rideRepo,
+			cord:     cordRepo,
+			driver:   driverRepo,
+			location: locationRepo,
+			event:    eventRepo,
+			user:     userRepo,
+		},
+	}
+}
+
+// GetReceipt returns the receipt of a completed ride to its passenger or
+// driver, or to an admin.
+func (svc *ReceiptService) GetReceipt(ctx context.Context, rideID string) (models.Receipt, error) {
+	log := svc.log.Func("ReceiptService.GetReceipt")
+
+	ride, err := svc.repo.ride.GetRide(ctx, rideID)
+	if err != nil {
+		return models.Receipt{}, err
+	}
+
+	userID := logger.GetUserID(ctx)
+	if logger.GetRole(ctx) != types.RoleAdmin && userID != ride.PassengerID && userID != ride.DriverID {
+		log.Warn(ctx, action.Receipt, "receipt requested by another user", "ride_id", rideID)
+		return models.Receipt{}, types.ErrRideNotOwned
+	}
+	return svc.receipt(ctx, ride)
+}
+
+// receipt assembles the receipt of a completed ride.
+func (svc *ReceiptService) receipt(ctx context.Context, ride models.Ride) (models.Receipt, error) {
+	log := svc.log.Func("ReceiptService.receipt")
+	rideID := ride.ID
+
+	if ride.Status != types.RideStatusCOMPLETED {
+		return models.Receipt{}, types.ErrInvalidRideStatus
+	}
+
+	pickup, err := svc.repo.cord.GetCoordinate(ctx, ride.PickupCoordinateId)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error getting pickup coordinate", "ride_id", rideID, "error", err)
+		return models.Receipt{}, err
+	}
+	destination, err := svc.repo.cord.GetCoordinate(ctx, ride.DestinationCoordinateId)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error getting destination coordinate", "ride_id", rideID, "error", err)
+		return models.Receipt{}, err
+	}
+
+	r := models.Receipt{
+		RideID:      ride.ID,
+		RideNumber:  ride.RideNumber,
+		PassengerID: ride.PassengerID,
+		RideType:    ride.VehicleType,
+		Pickup: models.ReceiptLocation{
+			Address:   pickup.Address,
+			Latitude:  pickup.Latitude,
+			Longitude: pickup.Longitude,
+		},
+		Destination: models.ReceiptLocation{
+			Address:   destination.Address,
+			Latitude:  destination.Latitude,
+			Longitude: destination.Longitude,
+		},
+		RequestedAt: ride.RequestedAt,
+		MatchedAt:   ride.MatchedAt,
+		StartedAt:   ride.StartedAt,
+		CompletedAt: ride.CompletedAt,
+		FinalFare:   ride.FinalFare,
+		Currency:    types.Currency,
+		IssuedAt:    time.Now(),
+	}
+
+	if ride.DriverID != "" {
+		if r.Driver, err = svc.driver(ctx, ride.DriverID); err != nil {
+			log.Error(ctx, action.Receipt, "error getting driver", "ride_id", rideID, "error", err)
+			return models.Receipt{}, err
+		}
+	}
+
+	var fare models.FareBreakdown
+	switch err = svc.repo.event.GetLastEvent(ctx, ride.ID, types.RideEventFareAdjusted, &fare); {
+	case err == nil:
+		r.Fare = &fare
+	case !errors.Is(err, types.ErrEventNotFound):
+		log.Error(ctx, action.Receipt, "error getting fare breakdown", "ride_id", rideID, "error", err)
+		return models.Receipt{}, err
+	}
+
+	var tip struct {
+		Amount float64 `json:"amount"`
+	}
+	if err = svc.repo.event.GetLastEvent(ctx, ride.ID, types.RideEventTipAdded, &tip); err != nil && !errors.Is(err, types.ErrEventNotFound) {
+		log.Error(ctx, action.Receipt, "error getting tip", "ride_id", rideID, "error", err)
+		return models.Receipt{}, err
+	}
+	r.Tip = tip.Amount
+	r.Total = round2(r.FinalFare + r.Tip)
+
+	route, err := svc.repo.location.ListRideRoute(ctx, ride.ID)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error getting ride route", "ride_id", rideID, "error", err)
+		return models.Receipt{}, err
+	}
+	if len(route) < 2 {
+		route = []models.Point{
+			{Lat: pickup.Latitude, Lng: pickup.Longitude},
+			{Lat: destination.Latitude, Lng: destination.Longitude},
+		}
+	}
+	r.Route = calculator.SamplePoints(route, maxRoutePoints)
+	r.Polyline = calculator.EncodePolyline(r.Route)
+
+	return r, nil
+}
+
+// RenderReceipt renders the receipt and returns the content type.
+func (svc *ReceiptService) RenderReceipt(receipt models.Receipt, format string) ([]byte, string, error) {
+	switch format {
+	case "", types.ReceiptFormatJSON:
+		data, err := json.Marshal(receipt)
+		return data, "application/json", err
+	case types.ReceiptFormatHTML:
+		data, err := svc.renderer.HTML(receipt)
+		return data, "text/html; charset=utf-8", err
+	case types.ReceiptFormatPDF:
+		data, err := svc.renderer.PDF(receipt)
+		return data, "application/pdf", err
+	default:
+		return nil, "", types.ErrUnknownReceiptFormat
+	}
+}
+
+// SendReceipt emails the receipt to the passenger as HTML with a PDF attachment.
+func (svc *ReceiptService) SendReceipt(ctx context.Context, rideID string) error {
+	log := svc.log.Func("ReceiptService.SendReceipt")
+
+	ride, err := svc.repo.ride.GetRide(ctx, rideID)
+	if err != nil {
+		return err
+	}
+	receipt, err := svc.receipt(ctx, ride)
+	if err != nil {
+		return err
+	}
+
+	user, err := svc.repo.user.GetUserByID(ctx, receipt.PassengerID)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error getting passenger", "ride_id", rideID, "error", err)
+		return err
+	}
+
+	html, err := svc.renderer.HTML(receipt)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error rendering html receipt", "ride_id", rideID, "error", err)
+		return err
+	}
+	pdf, err := svc.renderer.PDF(receipt)
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error rendering pdf receipt", "ride_id", rideID, "error", err)
+		return err
+	}
+
+	err = svc.mailer.Send(ctx, models.Email{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Your receipt for ride %s", receipt.RideNumber),
+		Text:    fmt.Sprintf("Thank you for riding with us. Total: %.2f %s", receipt.Total, receipt.Currency),
+		HTML:    string(html),
+		Attachments: []models.EmailAttachment{{
+			Filename:    fmt.Sprintf("receipt_%s.pdf", receipt.RideNumber),
+			ContentType: "application/pdf",
+			Data:        pdf,
+		}},
+	})
+	if err != nil {
+		log.Error(ctx, action.Receipt, "error sending receipt", "ride_id", rideID, "error", err)
+		return err
+	}
+
+	log.Info(ctx, action.Receipt, "receipt sent", "ride_id", rideID)
+	return nil
+}
+
+func (svc *ReceiptService) driver(ctx context.Context, driverID string) (*models.ReceiptDriver, error) {
+	d, err := svc.repo.driver.GetDriverByID(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &models.ReceiptDriver{
+		ID:            d.ID,
+		LicenseNumber: d.LicenseNumber,
+		Rating:        d.Rating,
+	}
+	if d.VehicleType != nil {
+		rd.VehicleType = *d.VehicleType
+	}
+	if len(d.VehicleAttrs) > 0 {
+		var attrs struct {
+			Make  string `json:"vehicle_make"`
+			Model string `json:"vehicle_model"`
+			Color string `json:"vehicle_color"`
+			Plate string `json:"vehicle_plate"`
+		}
+		if err = json.Unmarshal(d.VehicleAttrs, &attrs); err == nil {
+			rd.VehicleMake, rd.VehicleModel, rd.VehicleColor, rd.VehiclePlate = attrs.Make, attrs.Model, attrs.Color, attrs.Plate
+		}
+	}
+	return rd, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ride-hail/internal/core/domain/models"
+	"ride-hail/internal/core/domain/types"
+	"ride-hail/internal/core/ports"
+	"ride-hail/pkg/logger"
+)
+
+type driverProfiles struct {
+	ports.DriverRepository
+}
+
+func (driverProfiles) GetDriverByID(ctx context.Context, id string) (*models.Driver, error) {
+	return &models.Driver{ID: id, LicenseNumber: "KZ-" + id}, nil
+}
+
+type noRideEvents struct {
+	ports.RideEventRepository
+}
+
+func (noRideEvents) GetLastEvent(ctx context.Context, rideID, eventType string, dest any) error {
+	return types.ErrEventNotFound
+}
+
+type noRoute struct {
+	ports.LocationRepository
+}
+
+func (noRoute) ListRideRoute(ctx context.Context, rideID string) ([]models.Point, error) {
+	return nil, nil
+}
+
+func TestGetReceiptIsLimitedToTheRidePartiesAndAdmins(t *testing.T) {
+	rides := &rideStore{rides: map[string]models.Ride{
+		"ride-1": {
+			ID: "ride-1", PassengerID: "passenger-1", DriverID: "driver-1", Status: types.RideStatusCOMPLETED,
+			PickupCoordinateId: "pickup-1", DestinationCoordinateId: "dropoff-1", FinalFare: 1850,
+		},
+	}}
+	coords := &coordStore{coords: map[string]models.Coordinate{
+		"pickup-1":  {Latitude: 43.2389, Longitude: 76.8897},
+		"dropoff-1": {Latitude: 43.2220, Longitude: 76.8512},
+	}}
+	svc := NewReceiptService(discardLogger(), rides, coords, driverProfiles{}, noRoute{}, noRideEvents{}, nil, nil, nil)
+
+	callers := []struct {
+		user, role string
+		allowed    bool
+	}{
+		{"passenger-1", types.RolePassenger, true},
+		{"driver-1", types.RoleDriver, true},
+		{"admin-1", types.RoleAdmin, true},
+		{"passenger-2", types.RolePassenger, false},
+		{"driver-2", types.RoleDriver, false},
+	}
+	for _, c := range callers {
+		ctx := logger.WithRole(logger.WithUserID(context.Background(), c.user), c.role)
+
+		receipt, err := svc.GetReceipt(ctx, "ride-1")
+		switch {
+		case c.allowed && err != nil:
+			t.Errorf("%s (%s): GetReceipt() error = %v", c.user, c.role, err)
+		case c.allowed && (receipt.RideID != "ride-1" || receipt.Total != 1850 || receipt.Driver == nil):
+			t.Errorf("%s (%s): receipt = %+v", c.user, c.role, receipt)
+		case !c.allowed && !errors.Is(err, types.ErrRideNotOwned):
+			t.Errorf("%s (%s): GetReceipt() error = %v, want %v", c.user, c.role, err, types.ErrRideNotOwned)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ride-hail/internal/core/domain/action"
 	"ride-hail/internal/core/domain/models"
@@ -12,6 +14,8 @@ import (
 	"ride-hail/pkg/logger"
 	"ride-hail/pkg/txm"
 	"ride-hail/pkg/wsm"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -20,26 +24,61 @@ type RideService struct {
 	repo      Repository
 	txm       txm.Manager
 	wsm       wsm.ServiceWS
+	route     ports.RouteProvider
+	promo     ports.PromoService
+	ledger    ports.LedgerService
+	receipts  ports.ReceiptService
+	schedule  SchedulePolicy
+	match     MatchPolicy
 	msgBroker MsgBroker
 }
 
+// SchedulePolicy controls rides booked for later. A scheduled ride is sent to
+// matching LeadTime before pickup and can be cancelled for free until then;
+// the passenger gets a reminder ReminderBefore pickup.
+type SchedulePolicy struct {
+	LeadTime        time.Duration
+	ReminderBefore  time.Duration
+	MinAdvance      time.Duration
+	MaxAdvance      time.Duration
+	CancellationFee float64
+}
+
 type MsgBroker struct {
 	publisher ports.RidePublisher
 }
 
 type Repository struct {
-	ride ports.RideRepository
-	cord ports.CoordinatesRepository
+	ride   ports.RideRepository
+	cord   ports.CoordinatesRepository
+	event  ports.RideEventRepository
+	driver ports.DriverRepository
+	stop   ports.RideStopRepository
+	trip   ports.TripRepository
 }
 
-func NewRideService(log *logger.Logger, txm txm.Manager, rideRepo ports.RideRepository, cordRepo ports.CoordinatesRepository, rPub ports.RidePublisher, wsm wsm.ServiceWS) *RideService {
+func NewRideService(log *logger.Logger, txm txm.Manager, rideRepo ports.RideRepository, cordRepo ports.CoordinatesRepository,
+	eventRepo ports.RideEventRepository, driverRepo ports.DriverRepository, stopRepo ports.RideStopRepository,
+	tripRepo ports.TripRepository, rPub ports.RidePublisher, wsm wsm.ServiceWS,
+	route ports.RouteProvider, promo ports.PromoService, ledger ports.LedgerService, receipts ports.ReceiptService,
+	schedule SchedulePolicy, match MatchPolicy) *RideService {
 	return &RideService{
-		log: log,
-		txm: txm,
-		wsm: wsm,
+		log:      log,
+		txm:      txm,
+		wsm:      wsm,
+		route:    route,
+		promo:    promo,
+		ledger:   ledger,
+		receipts: receipts,
+		schedule: schedule,
+		match:    match,
 		repo: Repository{
-			ride: rideRepo,
-			cord: cordRepo,
+			ride:   rideRepo,
+			cord:   cordRepo,
+			event:  eventRepo,
+			driver: driverRepo,
+			stop:   stopRepo,
+			trip:   tripRepo,
 		},
 		msgBroker: MsgBroker{
 			publisher: rPub,
@@ -49,15 +88,58 @@ func NewRideService(log *logger.Logger, txm txm.Manager, rideRepo ports.RideRepo
 
 const exchangeName = "ride_topic"
 
-var (
-	queueRideRequests = "ride_requests"
+const rideRequestRoutingKey = "ride.request.%s"
+
+// scheduledBatchSize is the number of scheduled rides dispatched or reminded per tick.
+const scheduledBatchSize = 50
+
+const (
+	driverExchangeName         = "driver_topic"
+	driverNotificationRouteKey = "driver.notification.%s"
 )
 
 func (svc *RideService) CreateNewRide(ctx context.Context, r models.CreateRideRequest) (models.CreateRideResponse, error) {
 	log := svc.log.Func("RideService.CreateNewRide")
 
-	dist := calculator.Distance(r.PickupLatitude, r.PickupLongitude, r.DestinationLatitude, r.DestinationLongitude)
-	minute := calculator.Duration(dist)
+	status := types.RideStatusREQUESTED
+	if r.ScheduledAt != nil {
+		now := time.Now()
+		if r.ScheduledAt.Before(now.Add(svc.schedule.MinAdvance)) || r.ScheduledAt.After(now.Add(svc.schedule.MaxAdvance)) {
+			return models.CreateRideResponse{}, types.ErrInvalidScheduleTime
+		}
+		status = types.RideStatusSCHEDULED
+	} else if err := svc.activeRideError(ctx, logger.GetUserID(ctx)); err != nil {
+		log.Warn(ctx, action.CreateRide, "passenger cannot request a new ride", "error", err)
+		return models.CreateRideResponse{}, err
+	}
+	if len(r.Stops) > calculator.MaxStops || (r.RideType == types.RideTypePOOL && len(r.Stops) > 0) {
+		// pool trips are planned from pickups and dropoffs only
+		return models.CreateRideResponse{}, types.ErrTooManyStops
+	}
+
+	seats := 1
+	if r.RideType == types.RideTypePOOL && r.Seats != 0 {
+		if r.Seats < 0 || r.Seats > calculator.MaxPoolSeats {
+			return models.CreateRideResponse{}, types.ErrInvalidSeats
+		}
+		seats = r.Seats
+	}
+
+	points := make([]models.Point, 0, len(r.Stops)+2)
+	points = append(points, models.Point{Lat: r.PickupLatitude, Lng: r.PickupLongitude})
+	for _, stop := range r.Stops {
+		points = append(points, models.Point{Lat: stop.Latitude, Lng: stop.Longitude})
+	}
+	points = append(points, models.Point{Lat: r.DestinationLatitude, Lng: r.DestinationLongitude})
+
+	route, err := calculator.RouteLegs(ctx, svc.route, points)
+	if err != nil {
+		log.Error(ctx, action.CreateRide, "error calculating route", "error", err)
+		return models.CreateRideResponse{}, err
+	}
+	log.Debug(ctx, action.CreateRide, "route calculated", "source", route.Source, "distance_km", route.DistanceKM, "stops", len(r.Stops))
+
+	dist, minute := route.DistanceKM, route.DurationMinutes
 	fareAmount, err := calculator.CalculateFare(r.RideType, dist, minute)
 	if err != nil {
 		log.Error(ctx, action.CreateRide, "error calculating fare amount", "error", err)
@@ -67,37 +149,62 @@ func (svc *RideService) CreateNewRide(ctx context.Context, r models.CreateRideRe
 	newRide := models.Ride{
 		PassengerID:   logger.GetUserID(ctx),
 		VehicleType:   r.RideType,
-		Status:        types.RideStatusREQUESTED,
+		Status:        status,
 		EstimatedFare: fareAmount,
+		ScheduledAt:   r.ScheduledAt,
+		Seats:         seats,
+	}
+
+	var quote models.PromoQuote
+	if r.PromoCode != "" {
+		if quote, err = svc.promo.Quote(ctx, r.PromoCode, newRide.PassengerID, r.RideType, fareAmount); err != nil {
+			return models.CreateRideResponse{}, err
+		}
+		newRide.PromoCodeID = &quote.PromoCodeID
+		newRide.DiscountAmount = quote.Discount
+	}
+
+	completed, err := svc.repo.ride.CountCompletedRides(ctx, newRide.PassengerID)
+	if err != nil {
+		log.Error(ctx, action.CreateRide, "error counting completed rides", "error", err)
+		return models.CreateRideResponse{}, err
 	}
+	newRide.Priority = calculator.RidePriority(calculator.PriorityInput{RideType: r.RideType, CompletedRides: completed})
 
+	pickup := models.Coordinate{
+		EntityID:        logger.GetUserID(ctx),
+		EntityType:      types.EntityRolePassenger,
+		Address:         r.PickupAddress,
+		Latitude:        r.PickupLatitude,
+		Longitude:       r.PickupLongitude,
+		FareAmount:      fareAmount,
+		DurationMinutes: minute,
+		DistanceKM:      dist,
+		IsCurrent:       true,
+	}
+	destination := models.Coordinate{
+		EntityID:        logger.GetUserID(ctx),
+		EntityType:      types.EntityRolePassenger,
+		Address:         r.DestinationAddress,
+		Latitude:        r.DestinationLatitude,
+		Longitude:       r.DestinationLongitude,
+		FareAmount:      fareAmount,
+		DurationMinutes: minute,
+		DistanceKM:      dist,
+		IsCurrent:       true,
+	}
+
+	var (
+		stops   []models.RideStop
+		request models.RideRequestMessage
+	)
 	fn := func(ctx context.Context) error {
-		if newRide.PickupCoordinateId, err = svc.repo.cord.CreateNewCoordinate(ctx, models.Coordinate{
-			EntityID:        logger.GetUserID(ctx),
-			EntityType:      types.EntityRolePassenger,
-			Address:         r.PickupAddress,
-			Latitude:        r.PickupLatitude,
-			Longitude:       r.PickupLongitude,
-			FareAmount:      fareAmount,
-			DurationMinutes: minute,
-			DistanceKM:      dist,
-			IsCurrent:       true,
-		}); err != nil {
+		if newRide.PickupCoordinateId, err = svc.repo.cord.CreateNewCoordinate(ctx, pickup); err != nil {
 			log.Error(ctx, action.CreateRide, "error creating new coordinate", "error", err)
 			return err
 		}
 
-		if newRide.DestinationCoordinateId, err = svc.repo.cord.CreateNewCoordinate(ctx, models.Coordinate{
-			EntityID:        logger.GetUserID(ctx),
-			EntityType:      types.EntityRolePassenger,
-			Address:         r.DestinationAddress,
-			Latitude:        r.DestinationLatitude,
-			Longitude:       r.DestinationLongitude,
-			FareAmount:      fareAmount,
-			DurationMinutes: minute,
-			DistanceKM:      dist,
-			IsCurrent:       true,
-		}); err != nil {
+		if newRide.DestinationCoordinateId, err = svc.repo.cord.CreateNewCoordinate(ctx, destination); err != nil {
 			log.Error(ctx, action.CreateRide, "error creating new coordinate", "error", err)
 			return err
 		}
@@ -115,66 +222,775 @@ func (svc *RideService) CreateNewRide(ctx context.Context, r models.CreateRideRe
 			return err
 		}
 
-		if data, err := json.Marshal(struct {
-			RideID         string `json:"ride_id"`
-			RideNumber     string `json:"ride_number"`
-			PickupLocation struct {
-				Lat     float64 `json:"lat"`
-				Lng     float64 `json:"lon"`
-				Address string  `json:"address"`
-			} `json:"pickup_location"`
-			DestinationLocation struct {
-				Lat     float64 `json:"lat"`
-				Lng     float64 `json:"lng"`
-				Address string  `json:"address"`
-			}
-			RideType       string  `json:"ride_type"`
-			EstimatedFare  float64 `json:"estimated_fare"`
-			MaxDistanceKM  float64 `json:"max_distance_km"`
-			TimeoutSeconds int     `json:"timeout_seconds"`
-			CorrelationID  string  `json:"correlation_id"`
-		}{
-			RideID:     newRide.ID,
-			RideNumber: newRide.RideNumber,
-			PickupLocation: struct {
-				Lat     float64 `json:"lat"`
-				Lng     float64 `json:"lon"`
-				Address string  `json:"address"`
-			}{Lat: r.PickupLatitude, Lng: r.PickupLongitude, Address: r.PickupAddress},
-			DestinationLocation: struct {
-				Lat     float64 `json:"lat"`
-				Lng     float64 `json:"lng"`
-				Address string  `json:"address"`
-			}{Lat: r.DestinationLatitude, Lng: r.DestinationLongitude, Address: r.DestinationAddress},
-			RideType:       r.RideType,
-			EstimatedFare:  fareAmount,
-			MaxDistanceKM:  dist,
-			TimeoutSeconds: 30,
-			CorrelationID:  logger.GetRequestID(ctx),
-		}); err != nil {
-			log.Error(ctx, action.CreateRide, "error marshalling new ride", "error", err)
+		stops = make([]models.RideStop, 0, len(r.Stops))
+		for _, req := range r.Stops {
+			stops = append(stops, models.RideStop{Latitude: req.Latitude, Longitude: req.Longitude, Address: req.Address})
+		}
+		if err = svc.saveStops(ctx, newRide.ID, 0, stops); err != nil {
+			log.Error(ctx, action.CreateRide, "error creating ride stops", "error", err)
 			return err
-		} else if err = svc.msgBroker.publisher.Publish(exchangeName, queueRideRequests, data); err != nil {
-			log.Error(ctx, action.CreateRide, "error publishing ride", "error", err)
 		}
 
+		if newRide.Status == types.RideStatusSCHEDULED {
+			// the scheduler publishes the request LeadTime before pickup
+			return svc.repo.event.CreateEvent(ctx, newRide.ID, types.RideEventScheduled, map[string]any{
+				"passenger_id": newRide.PassengerID,
+				"scheduled_at": newRide.ScheduledAt,
+			})
+		}
+
+		request = rideRequestMessage(ctx, newRide, pickup, destination, stops)
 		return nil
 	}
 
 	if err = svc.txm.Do(ctx, fn); err != nil {
+		if errors.Is(err, types.ErrActiveRideExists) {
+			// another request created the ride between the check and the insert
+			if activeErr := svc.activeRideError(ctx, newRide.PassengerID); activeErr != nil {
+				return models.CreateRideResponse{}, activeErr
+			}
+		}
 		return models.CreateRideResponse{}, err
 	}
 
-	return models.CreateRideResponse{
+	if newRide.Status == types.RideStatusREQUESTED {
+		svc.publishRideRequest(ctx, newRide, request)
+	}
+
+	resp := models.CreateRideResponse{
 		RideID:                   newRide.ID,
 		RideNumber:               newRide.RideNumber,
-		Status:                   types.RideStatusREQUESTED,
+		Status:                   newRide.Status,
 		EstimatedFare:            fareAmount,
 		EstimatedDurationMinutes: minute,
 		EstimatedDistanceKm:      dist,
-	}, nil
+		ScheduledAt:              newRide.ScheduledAt,
+		Stops:                    stops,
+	}
+	if quote.Code != "" {
+		resp.PromoCode = quote.Code
+		resp.DiscountAmount = quote.Discount
+		resp.EstimatedFareDiscounted = fareAmount - quote.Discount
+	}
+	return resp, nil
+}
+
+// activeRideError returns an ActiveRideError pointing at the passenger's ride
+// that is waiting for a driver or under way, or nil when there is none.
+func (svc *RideService) activeRideError(ctx context.Context, passengerID string) error {
+	rideID, err := svc.repo.ride.GetPassengerActiveRideID(ctx, passengerID)
+	if err != nil {
+		return err
+	}
+	if rideID == "" {
+		return nil
+	}
+	return &types.ActiveRideError{Err: types.ErrActiveRideExists, RideID: rideID}
+}
+
+// publishRideRequest sends the ride to matching once it is committed as
+// REQUESTED, so matching never looks up a ride that may still roll back. A
+// publishing failure is only logged: the ride is redispatched after
+// RedispatchAfter.
+func (svc *RideService) publishRideRequest(ctx context.Context, ride models.Ride, request models.RideRequestMessage) {
+	log := svc.log.Func("RideService.publishRideRequest")
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		log.Error(ctx, action.CreateRide, "error marshalling new ride", "error", err)
+		return
+	}
+
+	priority := uint8(max(ride.Priority, types.RidePriorityMin))
+	if err = svc.msgBroker.publisher.PublishWithPriority(exchangeName, fmt.Sprintf(rideRequestRoutingKey, ride.VehicleType), priority, data); err != nil {
+		log.Error(ctx, action.CreateRide, "error publishing ride", "ride_id", ride.ID, "error", err)
+	}
+}
+
+// loadRideRequest loads the route of a stored ride for ride_requests. Both the
+// ride service and the driver service send stored rides back to matching.
+func loadRideRequest(ctx context.Context, cord ports.CoordinatesRepository, stop ports.RideStopRepository, ride models.Ride) (models.RideRequestMessage, error) {
+	pickup, err := cord.GetCoordinate(ctx, ride.PickupCoordinateId)
+	if err != nil {
+		return models.RideRequestMessage{}, err
+	}
+	destination, err := cord.GetCoordinate(ctx, ride.DestinationCoordinateId)
+	if err != nil {
+		return models.RideRequestMessage{}, err
+	}
+	stops, err := stop.ListStops(ctx, ride.ID)
+	if err != nil {
+		return models.RideRequestMessage{}, err
+	}
+	return rideRequestMessage(ctx, ride, pickup, destination, stops), nil
+}
+
+func rideRequestMessage(ctx context.Context, ride models.Ride, pickup, destination models.Coordinate, stops []models.RideStop) models.RideRequestMessage {
+	return models.RideRequestMessage{
+		RideID:              ride.ID,
+		RideNumber:          ride.RideNumber,
+		PickupLocation:      models.MessageLocation{Lat: pickup.Latitude, Lng: pickup.Longitude, Address: pickup.Address},
+		DestinationLocation: models.MessageLocation{Lat: destination.Latitude, Lng: destination.Longitude, Address: destination.Address},
+		Stops:               stops,
+		RideType:            ride.VehicleType,
+		EstimatedFare:       ride.EstimatedFare,
+		Seats:               max(ride.Seats, 1),
+		MaxDistanceKM:       pickup.DistanceKM,
+		TimeoutSeconds:      30,
+		CorrelationID:       logger.GetRequestID(ctx),
+	}
+}
+
+// saveStops stores stops as ride_stops numbered after the first offset ones.
+func (svc *RideService) saveStops(ctx context.Context, rideID string, offset int, stops []models.RideStop) error {
+	for i := range stops {
+		stop := &stops[i]
+		if stop.CoordinateID == "" {
+			id, err := svc.repo.cord.CreateNewCoordinate(ctx, models.Coordinate{
+				EntityID:   logger.GetUserID(ctx),
+				EntityType: types.EntityRolePassenger,
+				Address:    stop.Address,
+				Latitude:   stop.Latitude,
+				Longitude:  stop.Longitude,
+				IsCurrent:  true,
+			})
+			if err != nil {
+				return err
+			}
+			stop.CoordinateID = id
+		}
+
+		stop.RideID = rideID
+		stop.Sequence = offset + i + 1
+		id, err := svc.repo.stop.CreateStop(ctx, *stop)
+		if err != nil {
+			return err
+		}
+		stop.ID = id
+	}
+	return nil
+}
+
+// DispatchScheduledRides moves scheduled rides whose pickup is within LeadTime
+// to REQUESTED and publishes them to matching.
+func (svc *RideService) DispatchScheduledRides(ctx context.Context) error {
+	log := svc.log.Func("RideService.DispatchScheduledRides")
+
+	var (
+		dispatched []models.Ride
+		requests   []models.RideRequestMessage
+	)
+	fn := func(ctx context.Context) error {
+		dispatched, requests = dispatched[:0], requests[:0]
+
+		rides, err := svc.repo.ride.ListDueScheduledRides(ctx, time.Now().Add(svc.schedule.LeadTime), scheduledBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, ride := range rides {
+			if err = svc.activeRideError(ctx, ride.PassengerID); errors.Is(err, types.ErrActiveRideExists) {
+				// dispatched on a later poll once the passenger's current ride ends
+				log.Warn(ctx, action.ScheduledRide, "scheduled ride postponed", "ride_id", ride.ID, "error", err)
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err = svc.repo.ride.UpdateRideStatus(ctx, ride.ID, types.RideStatusSCHEDULED, types.RideStatusREQUESTED); err != nil {
+				return err
+			}
+			if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventRequested, map[string]any{
+				"passenger_id": ride.PassengerID,
+				"scheduled_at": ride.ScheduledAt,
+			}); err != nil {
+				return err
+			}
+
+			completed, err := svc.repo.ride.CountCompletedRides(ctx, ride.PassengerID)
+			if err != nil {
+				return err
+			}
+			ride.Priority = calculator.RidePriority(calculator.PriorityInput{
+				RideType:       ride.VehicleType,
+				Scheduled:      true,
+				CompletedRides: completed,
+			})
+			if err = svc.repo.ride.SetPriority(ctx, ride.ID, ride.Priority); err != nil {
+				return err
+			}
+
+			ride.Status = types.RideStatusREQUESTED
+			request, err := loadRideRequest(ctx, svc.repo.cord, svc.repo.stop, ride)
+			if err != nil {
+				return err
+			}
+			dispatched = append(dispatched, ride)
+			requests = append(requests, request)
+		}
+		return nil
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.ScheduledRide, "error dispatching scheduled rides", "error", err)
+		return err
+	}
+
+	for i, ride := range dispatched {
+		svc.publishRideRequest(ctx, ride, requests[i])
+		log.Info(ctx, action.ScheduledRide, "scheduled ride dispatched", "ride_id", ride.ID, "scheduled_at", ride.ScheduledAt)
+		_ = svc.NotifyRideStatus(ctx, models.RideStatusUpdate{
+			Type:        "ride_status_update",
+			RideID:      ride.ID,
+			RideNumber:  ride.RideNumber,
+			PassengerID: ride.PassengerID,
+			Status:      ride.Status,
+			ScheduledAt: ride.ScheduledAt,
+			Message:     "Looking for a driver for your scheduled ride",
+			Timestamp:   time.Now(),
+		})
+	}
+	return nil
+}
+
+// RedispatchWaitingRides publishes rides that are still waiting for a driver
+// RedispatchAfter after the last attempt again, one priority level higher.
+func (svc *RideService) RedispatchWaitingRides(ctx context.Context) error {
+	log := svc.log.Func("RideService.RedispatchWaitingRides")
+
+	var (
+		rides    []models.Ride
+		requests []models.RideRequestMessage
+	)
+	fn := func(ctx context.Context) error {
+		var err error
+		requests = requests[:0]
+		if rides, err = svc.repo.ride.ListWaitingRides(ctx, time.Now().Add(-svc.match.RedispatchAfter), scheduledBatchSize); err != nil {
+			return err
+		}
+
+		for i := range rides {
+			ride := &rides[i]
+			ride.Priority = calculator.RaisePriority(ride.Priority, 1)
+			if err = svc.repo.ride.SetPriority(ctx, ride.ID, ride.Priority); err != nil {
+				return err
+			}
+			request, err := loadRideRequest(ctx, svc.repo.cord, svc.repo.stop, *ride)
+			if err != nil {
+				return err
+			}
+			requests = append(requests, request)
+		}
+		return nil
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.RedispatchRide, "error redispatching waiting rides", "error", err)
+		return err
+	}
+
+	for i, ride := range rides {
+		svc.publishRideRequest(ctx, ride, requests[i])
+		log.Info(ctx, action.RedispatchRide, "ride redispatched", "ride_id", ride.ID, "priority", ride.Priority)
+	}
+	return nil
+}
+
+// SendRideReminders reminds passengers over WebSocket about scheduled rides
+// with pickup within ReminderBefore. Each ride is reminded once.
+func (svc *RideService) SendRideReminders(ctx context.Context) error {
+	log := svc.log.Func("RideService.SendRideReminders")
+
+	var rides []models.Ride
+	fn := func(ctx context.Context) error {
+		var err error
+		if rides, err = svc.repo.ride.ListRidesToRemind(ctx, time.Now().Add(svc.schedule.ReminderBefore), scheduledBatchSize); err != nil {
+			return err
+		}
+		for _, ride := range rides {
+			if err = svc.repo.ride.MarkReminderSent(ctx, ride.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Error(ctx, action.ScheduledRide, "error sending ride reminders", "error", err)
+		return err
+	}
+
+	for _, ride := range rides {
+		_ = svc.NotifyRideStatus(ctx, models.RideStatusUpdate{
+			Type:        types.NotificationRideReminder,
+			RideID:      ride.ID,
+			RideNumber:  ride.RideNumber,
+			PassengerID: ride.PassengerID,
+			Status:      ride.Status,
+			ScheduledAt: ride.ScheduledAt,
+			Message: fmt.Sprintf("Your ride %s is scheduled in %d minutes",
+				ride.RideNumber, int(time.Until(*ride.ScheduledAt).Minutes())),
+			Timestamp: time.Now(),
+		})
+	}
+	return nil
+}
+
+// GetRide returns the ride with its route. Only its passenger, its driver and
+// admins may read it.
+func (svc *RideService) GetRide(ctx context.Context, rideID string) (models.RideDetail, error) {
+	log := svc.log.Func("RideService.GetRide")
+
+	ride, err := svc.repo.ride.GetRideDetail(ctx, rideID)
+	if err != nil {
+		if !errors.Is(err, types.ErrRideNotFound) {
+			log.Error(ctx, action.GetRide, "error getting ride", "ride_id", rideID, "error", err)
+		}
+		return models.RideDetail{}, err
+	}
+
+	userID := logger.GetUserID(ctx)
+	if logger.GetRole(ctx) != types.RoleAdmin && userID != ride.PassengerID && userID != ride.DriverID {
+		return models.RideDetail{}, types.ErrRideNotOwned
+	}
+
+	if ride.Stops, err = svc.repo.stop.ListStops(ctx, ride.ID); err != nil {
+		log.Error(ctx, action.GetRide, "error listing ride stops", "ride_id", rideID, "error", err)
+		return models.RideDetail{}, err
+	}
+	return ride, nil
+}
+
+// ListRides returns a page of the passenger's rides, newest first. The page
+// ends with a cursor when more rides follow.
+func (svc *RideService) ListRides(ctx context.Context, f models.RideFilter) (models.RidePage, error) {
+	log := svc.log.Func("RideService.ListRides")
+
+	var after *models.RideCursor
+	if f.Cursor != "" {
+		c, err := decodeRideCursor(f.Cursor)
+		if err != nil {
+			return models.RidePage{}, err
+		}
+		after = &c
+	}
+
+	// one extra ride tells whether there is a next page
+	rides, err := svc.repo.ride.ListPassengerRides(ctx, f, after, f.Limit+1)
+	if err != nil {
+		log.Error(ctx, action.GetRide, "error listing rides", "error", err)
+		return models.RidePage{}, err
+	}
+
+	page := models.RidePage{Rides: rides}
+	if len(rides) > f.Limit {
+		page.Rides = rides[:f.Limit]
+		last := page.Rides[len(page.Rides)-1]
+		page.NextCursor = encodeRideCursor(models.RideCursor{RequestedAt: last.RequestedAt, ID: last.ID})
+	}
+	return page, nil
+}
+
+func encodeRideCursor(c models.RideCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.RequestedAt.Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+func decodeRideCursor(s string) (models.RideCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.RideCursor{}, types.ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return models.RideCursor{}, types.ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return models.RideCursor{}, types.ErrInvalidCursor
+	}
+	return models.RideCursor{RequestedAt: t, ID: id}, nil
 }
 
+// CloseRide cancels a ride of the calling passenger before it starts. A
+// scheduled ride cancelled less than LeadTime before pickup is charged
+// CancellationFee; any other cancellation is free.
 func (svc *RideService) CloseRide(ctx context.Context, req models.CloseRideRequest) (models.CloseRideResponse, error) {
-	return models.CloseRideResponse{}, nil
+	log := svc.log.Func("RideService.CloseRide")
+
+	var (
+		ride models.Ride
+		fee  float64
+		txID string
+	)
+	now := time.Now()
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.PassengerID != logger.GetUserID(ctx) {
+			return types.ErrRideNotOwned
+		}
+
+		switch ride.Status {
+		case types.RideStatusSCHEDULED, types.RideStatusREQUESTED, types.RideStatusMATCHED,
+			types.RideStatusEN_ROUTE, types.RideStatusARRIVED:
+		default:
+			return types.ErrInvalidRideStatus
+		}
+
+		if err = svc.repo.ride.UpdateRideStatus(ctx, ride.ID, ride.Status, types.RideStatusCANCELLED); err != nil {
+			return err
+		}
+		if err = svc.repo.ride.SetCancellationReason(ctx, ride.ID, req.Reason); err != nil {
+			return err
+		}
+
+		if ride.ScheduledAt != nil && !now.Before(ride.ScheduledAt.Add(-svc.schedule.LeadTime)) {
+			fee = svc.schedule.CancellationFee
+		}
+		if fee > 0 {
+			if txID, err = svc.ledger.PostCancellationFee(ctx, ride, fee); err != nil {
+				return err
+			}
+		}
+
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventCancelled, map[string]any{
+			"passenger_id":     ride.PassengerID,
+			"previous_status":  ride.Status,
+			"reason":           req.Reason,
+			"cancellation_fee": fee,
+			"transaction_id":   txID,
+		}); err != nil {
+			return err
+		}
+
+		if ride.DriverID == "" {
+			return nil
+		}
+		if ride.TripID != nil {
+			// other passengers of the pool trip keep the driver busy
+			if closed, err := svc.repo.trip.CloseTripIfDone(ctx, *ride.TripID); err != nil || !closed {
+				return err
+			}
+		}
+		return svc.repo.driver.UpdateDriverStatus(ctx, ride.DriverID, types.DriverStatusAvailable)
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Warn(ctx, action.CloseRide, "ride was not cancelled", "ride_id", req.RideID, "error", err)
+		return models.CloseRideResponse{}, err
+	}
+
+	if txID != "" {
+		// a failed charge stays pending and is retried by the ledger scheduler
+		if err := svc.ledger.CollectCharge(ctx, txID); err != nil {
+			log.Warn(ctx, action.CloseRide, "cancellation fee not collected yet", "ride_id", ride.ID, "error", err)
+		}
+	}
+
+	if ride.DriverID != "" {
+		svc.notifyDriver(ctx, models.DriverNotification{
+			Type:       types.NotificationRideCancelled,
+			DriverID:   ride.DriverID,
+			RideID:     ride.ID,
+			RideNumber: ride.RideNumber,
+			Message:    fmt.Sprintf("Ride %s was cancelled by the passenger", ride.RideNumber),
+			Timestamp:  now,
+		})
+	}
+
+	msg := "Ride cancelled successfully"
+	if fee > 0 {
+		msg = fmt.Sprintf("Ride cancelled, a cancellation fee of %.2f %s was charged", fee, types.Currency)
+	}
+	return models.CloseRideResponse{
+		RideID:          ride.ID,
+		Status:          types.RideStatusCANCELLED,
+		CancelledAt:     now,
+		CancellationFee: fee,
+		Message:         msg,
+	}, nil
+}
+
+// AddStop inserts a stop at req.Sequence, or before the destination when the
+// sequence is zero, and re-quotes the ride.
+func (svc *RideService) AddStop(ctx context.Context, req models.StopRequest) (models.StopsQuote, error) {
+	return svc.updateStops(ctx, req.RideID, func(visited, pending []models.RideStop) ([]models.RideStop, error) {
+		pos := len(pending)
+		if req.Sequence != 0 {
+			pos = req.Sequence - len(visited) - 1
+			if pos < 0 {
+				return nil, types.ErrStopAlreadyVisited
+			}
+			if pos > len(pending) {
+				return nil, types.ErrStopNotFound
+			}
+		}
+
+		stop := models.RideStop{Latitude: req.Latitude, Longitude: req.Longitude, Address: req.Address}
+		return slices.Insert(pending, pos, stop), nil
+	})
+}
+
+// ChangeStop moves the stop at req.Sequence to a new location and re-quotes
+// the ride. Stops the driver has arrived at cannot be changed.
+func (svc *RideService) ChangeStop(ctx context.Context, req models.StopRequest) (models.StopsQuote, error) {
+	return svc.updateStops(ctx, req.RideID, func(visited, pending []models.RideStop) ([]models.RideStop, error) {
+		pos := req.Sequence - len(visited) - 1
+		switch {
+		case req.Sequence <= 0:
+			return nil, types.ErrStopNotFound
+		case pos < 0:
+			return nil, types.ErrStopAlreadyVisited
+		case pos >= len(pending):
+			return nil, types.ErrStopNotFound
+		}
+
+		pending[pos] = models.RideStop{Latitude: req.Latitude, Longitude: req.Longitude, Address: req.Address}
+		return pending, nil
+	})
+}
+
+// updateStops replaces the stops the driver has not arrived at yet with the
+// result of change, then re-quotes the fare over pickup, all stops and
+// destination. The assigned driver is notified about the new stops.
+func (svc *RideService) updateStops(ctx context.Context, rideID string,
+	change func(visited, pending []models.RideStop) ([]models.RideStop, error)) (models.StopsQuote, error) {
+	log := svc.log.Func("RideService.updateStops")
+
+	var (
+		ride  models.Ride
+		quote models.StopsQuote
+	)
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, rideID); err != nil {
+			return err
+		}
+		if ride.PassengerID != logger.GetUserID(ctx) {
+			return types.ErrRideNotOwned
+		}
+		if ride.VehicleType == types.RideTypePOOL {
+			return types.ErrTooManyStops
+		}
+
+		switch ride.Status {
+		case types.RideStatusSCHEDULED, types.RideStatusREQUESTED, types.RideStatusMATCHED,
+			types.RideStatusEN_ROUTE, types.RideStatusARRIVED, types.RideStatusIN_PROGRESS:
+		default:
+			return types.ErrInvalidRideStatus
+		}
+
+		stops, err := svc.repo.stop.ListStops(ctx, ride.ID)
+		if err != nil {
+			return err
+		}
+		visited := 0
+		for visited < len(stops) && stops[visited].ArrivedAt != nil {
+			visited++
+		}
+
+		pending, err := change(stops[:visited:visited], slices.Clone(stops[visited:]))
+		if err != nil {
+			return err
+		}
+		if visited+len(pending) > calculator.MaxStops {
+			return types.ErrTooManyStops
+		}
+
+		if err = svc.repo.stop.DeletePendingStops(ctx, ride.ID); err != nil {
+			return err
+		}
+		if err = svc.saveStops(ctx, ride.ID, visited, pending); err != nil {
+			return err
+		}
+		stops = append(stops[:visited], pending...)
+
+		pickup, err := svc.repo.cord.GetCoordinate(ctx, ride.PickupCoordinateId)
+		if err != nil {
+			return err
+		}
+		destination, err := svc.repo.cord.GetCoordinate(ctx, ride.DestinationCoordinateId)
+		if err != nil {
+			return err
+		}
+
+		points := make([]models.Point, 0, len(stops)+2)
+		points = append(points, models.Point{Lat: pickup.Latitude, Lng: pickup.Longitude})
+		for _, stop := range stops {
+			points = append(points, models.Point{Lat: stop.Latitude, Lng: stop.Longitude})
+		}
+		points = append(points, models.Point{Lat: destination.Latitude, Lng: destination.Longitude})
+
+		route, err := calculator.RouteLegs(ctx, svc.route, points)
+		if err != nil {
+			return err
+		}
+		fare, err := calculator.CalculateFare(ride.VehicleType, route.DistanceKM, route.DurationMinutes)
+		if err != nil {
+			return err
+		}
+
+		if err = svc.repo.ride.SetEstimatedFare(ctx, ride.ID, fare); err != nil {
+			return err
+		}
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventStopsChanged, map[string]any{
+			"passenger_id":   ride.PassengerID,
+			"stops":          stops,
+			"previous_fare":  ride.EstimatedFare,
+			"estimated_fare": fare,
+		}); err != nil {
+			return err
+		}
+
+		quote = models.StopsQuote{
+			RideID:                   ride.ID,
+			Stops:                    stops,
+			PreviousFare:             ride.EstimatedFare,
+			EstimatedFare:            fare,
+			EstimatedDistanceKm:      route.DistanceKM,
+			EstimatedDurationMinutes: route.DurationMinutes,
+		}
+		return nil
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Warn(ctx, action.ChangeStops, "stops were not changed", "ride_id", rideID, "error", err)
+		return models.StopsQuote{}, err
+	}
+
+	if ride.DriverID != "" {
+		svc.notifyDriver(ctx, models.DriverNotification{
+			Type:       types.NotificationStopsChanged,
+			DriverID:   ride.DriverID,
+			RideID:     ride.ID,
+			RideNumber: ride.RideNumber,
+			Amount:     quote.EstimatedFare,
+			Stops:      quote.Stops,
+			Message:    fmt.Sprintf("The passenger changed the stops of ride %s", ride.RideNumber),
+			Timestamp:  time.Now(),
+		})
+	}
+	return quote, nil
+}
+
+// NotifyRideStatus forwards a ride status update to the passenger's WebSocket
+// and emails the receipt once the ride is completed.
+func (svc *RideService) NotifyRideStatus(ctx context.Context, update models.RideStatusUpdate) error {
+	log := svc.log.Func("RideService.NotifyRideStatus")
+
+	if update.Status == types.RideStatusCOMPLETED {
+		if err := svc.receipts.SendReceipt(ctx, update.RideID); err != nil {
+			// the receipt is still available at GET /rides/{ride_id}/receipt
+			log.Warn(ctx, action.Receipt, "receipt was not sent", "ride_id", update.RideID, "error", err)
+		}
+	}
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	if err = svc.wsm.Send(update.PassengerID, data); err != nil {
+		// the passenger is not connected, nothing to retry
+		log.Warn(ctx, action.RideStatus, "passenger is not connected", "passenger_id", update.PassengerID, "error", err)
+	}
+	return nil
+}
+
+// TipDriver charges the passenger a preset percentage of the final fare and
+// credits it to the driver. A ride can be tipped once, within TipWindow of completion.
+func (svc *RideService) TipDriver(ctx context.Context, req models.TipRequest) (models.TipResponse, error) {
+	log := svc.log.Func("RideService.TipDriver")
+
+	var (
+		ride   models.Ride
+		amount float64
+		txID   string
+	)
+	now := time.Now()
+
+	fn := func(ctx context.Context) error {
+		var err error
+		if ride, err = svc.repo.ride.GetRide(ctx, req.RideID); err != nil {
+			return err
+		}
+		if ride.PassengerID != req.PassengerID {
+			return types.ErrRideNotOwned
+		}
+		if ride.Status != types.RideStatusCOMPLETED || ride.DriverID == "" {
+			return types.ErrInvalidRideStatus
+		}
+		if ride.CompletedAt == nil || now.Sub(*ride.CompletedAt) > calculator.TipWindow {
+			return types.ErrTipWindowClosed
+		}
+
+		var ok bool
+		if amount, ok = calculator.Tip(ride.FinalFare, req.Percent); !ok || amount <= 0 {
+			return types.ErrInvalidTipPercent
+		}
+
+		if txID, err = svc.ledger.PostTip(ctx, ride, amount); err != nil {
+			if errors.Is(err, types.ErrDuplicateTransaction) {
+				return types.ErrTipAlreadyAdded
+			}
+			return err
+		}
+		if err = svc.repo.event.CreateEvent(ctx, ride.ID, types.RideEventTipAdded, map[string]any{
+			"passenger_id":   ride.PassengerID,
+			"driver_id":      ride.DriverID,
+			"percent":        req.Percent,
+			"amount":         amount,
+			"transaction_id": txID,
+		}); err != nil {
+			return err
+		}
+		return svc.repo.driver.AddTip(ctx, ride.DriverID, amount)
+	}
+
+	if err := svc.txm.Do(ctx, fn); err != nil {
+		log.Warn(ctx, action.TipDriver, "tip rejected", "ride_id", req.RideID, "error", err)
+		return models.TipResponse{}, err
+	}
+
+	// a failed charge stays pending and is retried by the ledger scheduler
+	if err := svc.ledger.CollectCharge(ctx, txID); err != nil {
+		log.Warn(ctx, action.TipDriver, "tip payment not collected yet", "ride_id", ride.ID, "error", err)
+	}
+
+	svc.notifyDriver(ctx, models.DriverNotification{
+		Type:       types.NotificationTipReceived,
+		DriverID:   ride.DriverID,
+		RideID:     ride.ID,
+		RideNumber: ride.RideNumber,
+		Amount:     amount,
+		Message:    fmt.Sprintf("You received a %.2f tip for ride %s", amount, ride.RideNumber),
+		Timestamp:  now,
+	})
+
+	return models.TipResponse{
+		RideID:   ride.ID,
+		Percent:  req.Percent,
+		Amount:   amount,
+		TippedAt: now,
+		Message:  "Thank you! Your tip has been sent to the driver",
+	}, nil
+}
+
+// notifyDriver publishes a notification for the driver's WebSocket in the driver service.
+func (svc *RideService) notifyDriver(ctx context.Context, n models.DriverNotification) {
+	log := svc.log.Func("RideService.notifyDriver")
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		log.Error(ctx, action.NotifyDriver, "error marshalling notification", "error", err)
+		return
+	}
+
+	routingKey := fmt.Sprintf(driverNotificationRouteKey, n.DriverID)
+	if err = svc.msgBroker.publisher.Publish(driverExchangeName, routingKey, data); err != nil {
+		log.Error(ctx, action.NotifyDriver, "error publishing notification", "driver_id", n.DriverID, "error", err)
+	}
 }
@@ -0,0 +1,11 @@
+begin;
+
+alter table rides
+    drop column if exists discount_amount,
+    drop column if exists promo_code_id;
+
+drop table if exists promo_redemptions;
+drop table if exists promo_codes;
+drop table if exists discount_type;
+
+commit;
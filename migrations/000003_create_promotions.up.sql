@@ -0,0 +1,50 @@
+begin;
+
+-- Promo discount type enumeration
+create table "discount_type"("value" text not null primary key);
+insert into
+    "discount_type" ("value")
+values
+    ('PERCENT'),   -- Percentage of the fare
+    ('FIXED')      -- Fixed amount off the fare
+;
+
+-- Promo codes
+create table promo_codes (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    code varchar(50) unique not null,
+    discount_type text references "discount_type"(value) not null,
+    value decimal(10,2) not null check (value > 0),
+    max_discount decimal(10,2) check (max_discount > 0),
+    usage_limit integer check (usage_limit > 0),           -- null = unlimited
+    per_user_limit integer not null default 1 check (per_user_limit > 0),
+    used_count integer not null default 0 check (used_count >= 0),
+    valid_from timestamptz not null default now(),
+    valid_until timestamptz,
+    first_ride_only boolean not null default false,
+    ride_types text[],                                     -- null = any ride type
+    is_active boolean not null default true,
+    check (usage_limit is null or used_count <= usage_limit),
+    check (discount_type <> 'PERCENT' or value <= 100)
+);
+
+-- Redemptions are recorded at ride completion
+create table promo_redemptions (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    promo_code_id uuid references promo_codes(id) not null,
+    user_id uuid references users(id) not null,
+    ride_id uuid references rides(id) unique not null,
+    discount_amount decimal(10,2) not null check (discount_amount >= 0)
+);
+
+create index idx_promo_redemptions_user on promo_redemptions(promo_code_id, user_id);
+
+-- Promo attached by the passenger when requesting a ride
+alter table rides
+    add column promo_code_id uuid references promo_codes(id),
+    add column discount_amount decimal(10,2) default 0 check (discount_amount >= 0);
+
+commit;
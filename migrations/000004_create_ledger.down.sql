@@ -0,0 +1,11 @@
+begin;
+
+drop trigger if exists trg_ledger_entries_balanced on ledger_entries;
+drop function if exists check_ledger_transaction_balanced();
+drop table if exists ledger_entries;
+drop table if exists ledger_transactions;
+drop table if exists ledger_accounts;
+drop table if exists ledger_transaction_kind;
+drop table if exists ledger_account_type;
+
+commit;
@@ -0,0 +1,81 @@
+begin;
+
+-- Ledger account type enumeration
+create table "ledger_account_type"("value" text not null primary key);
+insert into
+    "ledger_account_type" ("value")
+values
+    ('PASSENGER'),   -- Money paid by a passenger
+    ('DRIVER'),      -- Money owed to a driver
+    ('PLATFORM'),    -- Platform commission revenue
+    ('PROMO'),       -- Promotions budget funding discounts
+    ('PAYOUT')       -- Money paid out to drivers
+;
+
+-- Ledger transaction kind enumeration
+create table "ledger_transaction_kind"("value" text not null primary key);
+insert into
+    "ledger_transaction_kind" ("value")
+values
+    ('RIDE_PAYMENT'),  -- Completed ride fare
+    ('TIP'),           -- Passenger tip to driver
+    ('PAYOUT')         -- Driver payout
+;
+
+create table ledger_accounts (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    type text references "ledger_account_type"(value) not null,
+    owner_id uuid references users(id),  -- null for platform-wide accounts
+    currency varchar(3) not null default 'KZT'
+);
+
+create unique index idx_ledger_accounts_owner
+    on ledger_accounts(type, coalesce(owner_id, '00000000-0000-0000-0000-000000000000'::uuid));
+
+create table ledger_transactions (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    kind text references "ledger_transaction_kind"(value) not null,
+    ride_id uuid references rides(id),
+    reference text,           -- payment provider charge id
+    description text
+);
+
+create unique index idx_ledger_transactions_ride_payment
+    on ledger_transactions(ride_id) where kind = 'RIDE_PAYMENT';
+
+-- Positive amount = debit, negative amount = credit
+create table ledger_entries (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    transaction_id uuid references ledger_transactions(id) not null,
+    account_id uuid references ledger_accounts(id) not null,
+    amount decimal(12,2) not null check (amount <> 0)
+);
+
+create index idx_ledger_entries_account on ledger_entries(account_id, created_at);
+create index idx_ledger_entries_transaction on ledger_entries(transaction_id);
+
+-- Every transaction must be balanced when the database transaction commits
+create function check_ledger_transaction_balanced() returns trigger as $$
+declare
+    total decimal(12,2);
+begin
+    select coalesce(sum(amount), 0) into total
+    from ledger_entries
+    where transaction_id = new.transaction_id;
+
+    if total <> 0 then
+        raise exception 'ledger transaction % is not balanced: %', new.transaction_id, total;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+
+create constraint trigger trg_ledger_entries_balanced
+    after insert or update on ledger_entries
+    deferrable initially deferred
+    for each row execute function check_ledger_transaction_balanced();
+
+commit;
@@ -0,0 +1,7 @@
+begin;
+
+drop table if exists payouts;
+drop table if exists payout_batches;
+drop table if exists payout_status;
+
+commit;
@@ -0,0 +1,35 @@
+begin;
+
+-- Payout status enumeration
+create table "payout_status"("value" text not null primary key);
+insert into
+    "payout_status" ("value")
+values
+    ('PENDING'),   -- Exported, waiting for the bank transfer
+    ('PAID'),      -- Transfer confirmed by finance
+    ('FAILED')     -- Transfer failed
+;
+
+create table payout_batches (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    total_amount decimal(12,2) not null default 0,
+    drivers_count integer not null default 0,
+    export_path text
+);
+
+create table payouts (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    batch_id uuid references payout_batches(id) not null,
+    driver_id uuid references drivers(id) not null,
+    amount decimal(12,2) not null check (amount > 0),
+    status text references "payout_status"(value) not null default 'PENDING',
+    ledger_transaction_id uuid references ledger_transactions(id) not null
+);
+
+create index idx_payouts_batch on payouts(batch_id);
+create index idx_payouts_driver on payouts(driver_id, created_at);
+
+commit;
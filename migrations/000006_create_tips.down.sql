@@ -0,0 +1,7 @@
+begin;
+
+drop index if exists idx_ledger_transactions_ride_tip;
+delete from ride_events where event_type = 'TIP_ADDED';
+delete from "ride_event_type" where "value" = 'TIP_ADDED';
+
+commit;
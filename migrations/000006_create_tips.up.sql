@@ -0,0 +1,13 @@
+begin;
+
+insert into
+    "ride_event_type" ("value")
+values
+    ('TIP_ADDED')          -- Passenger tipped the driver
+;
+
+-- At most one tip per ride
+create unique index idx_ledger_transactions_ride_tip
+    on ledger_transactions(ride_id) where kind = 'TIP';
+
+commit;
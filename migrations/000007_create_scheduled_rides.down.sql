@@ -0,0 +1,14 @@
+begin;
+
+drop index if exists idx_rides_scheduled;
+
+alter table rides
+    drop column if exists reminder_sent_at,
+    drop column if exists scheduled_at;
+
+delete from "ledger_transaction_kind" where "value" = 'CANCELLATION_FEE';
+delete from ride_events where event_type = 'RIDE_SCHEDULED';
+delete from "ride_event_type" where "value" = 'RIDE_SCHEDULED';
+delete from "ride_status" where "value" = 'SCHEDULED';
+
+commit;
@@ -0,0 +1,27 @@
+begin;
+
+insert into
+    "ride_status" ("value")
+values
+    ('SCHEDULED')          -- Ride booked for a later time, not yet dispatched
+;
+
+insert into
+    "ride_event_type" ("value")
+values
+    ('RIDE_SCHEDULED')     -- Ride booked for a later time
+;
+
+insert into
+    "ledger_transaction_kind" ("value")
+values
+    ('CANCELLATION_FEE')   -- Late cancellation of a scheduled ride
+;
+
+alter table rides
+    add column scheduled_at timestamptz,        -- requested pickup time, null for immediate rides
+    add column reminder_sent_at timestamptz;
+
+create index idx_rides_scheduled on rides(scheduled_at) where status = 'SCHEDULED';
+
+commit;
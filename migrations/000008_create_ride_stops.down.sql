@@ -0,0 +1,8 @@
+begin;
+
+drop table if exists ride_stops;
+
+delete from ride_events where event_type in ('STOP_ARRIVED', 'STOP_DEPARTED', 'STOPS_CHANGED');
+delete from "ride_event_type" where "value" in ('STOP_ARRIVED', 'STOP_DEPARTED', 'STOPS_CHANGED');
+
+commit;
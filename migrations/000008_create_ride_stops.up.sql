@@ -0,0 +1,24 @@
+begin;
+
+insert into
+    "ride_event_type" ("value")
+values
+    ('STOP_ARRIVED'),      -- Driver arrived at an intermediate stop
+    ('STOP_DEPARTED'),     -- Driver left an intermediate stop
+    ('STOPS_CHANGED')      -- Passenger added or changed a stop, fare re-quoted
+;
+
+-- Intermediate stops between pickup and destination, visited in sequence order
+create table ride_stops (
+                            id uuid primary key default gen_random_uuid(),
+                            created_at timestamptz not null default now(),
+                            updated_at timestamptz not null default now(),
+                            ride_id uuid not null references rides(id) on delete cascade,
+                            sequence integer not null check (sequence > 0),
+                            coordinate_id uuid not null references coordinates(id),
+                            arrived_at timestamptz,
+                            departed_at timestamptz,
+                            unique (ride_id, sequence)
+);
+
+commit;
@@ -0,0 +1,14 @@
+begin;
+
+drop index if exists idx_rides_trip;
+
+alter table rides
+    drop column if exists seats,
+    drop column if exists trip_id;
+
+drop table if exists trips;
+drop table if exists "trip_status";
+
+delete from "vehicle_type" where "value" = 'POOL';
+
+commit;
@@ -0,0 +1,38 @@
+begin;
+
+insert into
+    "vehicle_type" ("value")
+values
+    ('POOL')               -- Shared ride, passengers going the same way share one vehicle
+;
+
+-- Trip status enumeration
+create table "trip_status"("value" text not null primary key);
+insert into
+    "trip_status" ("value")
+values
+    ('OPEN'),              -- Driver is on the trip and can take more pool passengers
+    ('COMPLETED'),         -- Every ride of the trip is completed or cancelled
+    ('CANCELLED')          -- Trip was cancelled before any ride completed
+;
+
+-- One driver route shared by several POOL rides
+create table trips (
+                       id uuid primary key default gen_random_uuid(),
+                       created_at timestamptz not null default now(),
+                       updated_at timestamptz not null default now(),
+                       driver_id uuid not null references drivers(id),
+                       status text references "trip_status"(value) not null default 'OPEN',
+                       seats_total integer not null check (seats_total > 0),
+                       plan jsonb not null default '[]'::jsonb -- ordered pickups and dropoffs
+);
+
+create index idx_trips_open on trips(driver_id) where status = 'OPEN';
+
+alter table rides
+    add column trip_id uuid references trips(id),
+    add column seats integer not null default 1 check (seats > 0);
+
+create index idx_rides_trip on rides(trip_id) where trip_id is not null;
+
+commit;
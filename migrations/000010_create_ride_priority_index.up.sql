@@ -0,0 +1,6 @@
+begin;
+
+-- rides waiting for a driver, counted by matching when supply is scarce
+create index idx_rides_waiting on rides(vehicle_type, priority desc) where status = 'REQUESTED';
+
+commit;
@@ -0,0 +1,11 @@
+begin;
+
+alter table drivers
+    drop column if exists blocked_until;
+
+drop table if exists driver_cancellations;
+
+delete from ride_events where event_type = 'DRIVER_CANCELLED';
+delete from "ride_event_type" where "value" = 'DRIVER_CANCELLED';
+
+commit;
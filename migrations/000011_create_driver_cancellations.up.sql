@@ -0,0 +1,25 @@
+begin;
+
+insert into
+    "ride_event_type" ("value")
+values
+    ('DRIVER_CANCELLED')   -- Matched driver backed out, ride returned to matching
+;
+
+-- Rides given up by their matched driver, used for the cancellation rate and
+-- to keep the driver out of matching for the same ride
+create table driver_cancellations (
+                                      id uuid primary key default gen_random_uuid(),
+                                      created_at timestamptz not null default now(),
+                                      driver_id uuid not null references drivers(id),
+                                      ride_id uuid not null references rides(id),
+                                      reason text
+);
+
+create index idx_driver_cancellations_driver on driver_cancellations(driver_id, created_at);
+create index idx_driver_cancellations_ride on driver_cancellations(ride_id);
+
+alter table drivers
+    add column blocked_until timestamptz;      -- no ride offers until this time
+
+commit;
@@ -0,0 +1,6 @@
+begin;
+
+-- keyset pagination of a passenger's ride history
+create index idx_rides_passenger_history on rides(passenger_id, requested_at desc, id desc);
+
+commit;
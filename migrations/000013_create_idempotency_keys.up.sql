@@ -0,0 +1,19 @@
+begin;
+
+-- Responses of mutating requests sent with an Idempotency-Key header, replayed
+-- for retries of the same request until expires_at
+create table idempotency_keys (
+                                  user_id uuid not null,
+                                  key text not null,
+                                  created_at timestamptz not null default now(),
+                                  expires_at timestamptz not null,
+                                  request_hash text not null,              -- sha256 of method, path and body
+                                  status_code integer,                     -- null while the request is in progress
+                                  content_type text,
+                                  body bytea,
+                                  primary key (user_id, key)
+);
+
+create index idx_idempotency_keys_expires on idempotency_keys(expires_at);
+
+commit;
@@ -0,0 +1,6 @@
+begin;
+
+drop index if exists uq_rides_driver_active;
+drop index if exists uq_rides_passenger_active;
+
+commit;
@@ -0,0 +1,11 @@
+begin;
+
+-- a passenger can wait for or take only one ride at a time
+create unique index uq_rides_passenger_active on rides(passenger_id)
+    where status in ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS');
+
+-- a driver can hold only one ride outside of a pool trip
+create unique index uq_rides_driver_active on rides(driver_id)
+    where trip_id is null and status in ('MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS');
+
+commit;
@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists revoked_tokens;
+drop table if exists refresh_tokens;
+
+commit;
@@ -0,0 +1,29 @@
+begin;
+
+-- Rotating refresh tokens. Every refresh marks the presented token as used and
+-- issues a new one in the same family; presenting a used token revokes the family.
+create table refresh_tokens (
+                       id uuid primary key default gen_random_uuid(),
+                       created_at timestamptz not null default now(),
+                       user_id uuid not null references users(id),
+                       family_id text not null,
+                       token_hash text not null unique,         -- sha256 of the token, the token itself is never stored
+                       access_claims_id text not null,          -- claims_id of the access token issued with it
+                       expires_at timestamptz not null,
+                       used_at timestamptz,
+                       revoked_at timestamptz
+);
+
+create index idx_refresh_tokens_family on refresh_tokens(family_id);
+create index idx_refresh_tokens_expires on refresh_tokens(expires_at);
+
+-- Access tokens revoked before they expire, keyed by the claims_id claim
+create table revoked_tokens (
+                       claims_id text primary key,
+                       created_at timestamptz not null default now(),
+                       expires_at timestamptz not null
+);
+
+create index idx_revoked_tokens_expires on revoked_tokens(expires_at);
+
+commit;
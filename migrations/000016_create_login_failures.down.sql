@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists auth_audit_log;
+drop table if exists login_failures;
+
+commit;
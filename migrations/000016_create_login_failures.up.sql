@@ -0,0 +1,29 @@
+begin;
+
+-- Failed logins per account and per client address. Reaching the threshold
+-- locks the key; every further lock doubles the lockout.
+create table login_failures (
+                       key text primary key,                    -- 'account:<email>' or 'ip:<address>'
+                       failures int not null default 0,
+                       last_failure_at timestamptz not null default now(),
+                       locked_until timestamptz
+);
+
+create index idx_login_failures_last_failure on login_failures(last_failure_at);
+
+-- Lockouts and admin unlocks
+create table auth_audit_log (
+                       id uuid primary key default gen_random_uuid(),
+                       created_at timestamptz not null default now(),
+                       event text not null,                     -- LOCKOUT, UNLOCK
+                       subject text not null,                   -- login_failures key
+                       user_id uuid references users(id),       -- null for unknown emails and addresses
+                       actor_id uuid references users(id),      -- admin who unlocked
+                       ip text,
+                       failures int,
+                       locked_until timestamptz
+);
+
+create index idx_auth_audit_log_user on auth_audit_log(user_id, created_at);
+
+commit;
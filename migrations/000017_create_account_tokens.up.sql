@@ -0,0 +1,22 @@
+begin;
+
+-- Users registered from now on confirm their email before the first login.
+-- Existing users are treated as verified.
+alter table users add column email_verified_at timestamptz;
+update users set email_verified_at = created_at;
+
+-- Single-use email verification and password reset tokens. The token itself
+-- is a signed JWT; the row, keyed by its jti, records whether it was used.
+create table account_tokens (
+                       id text primary key,                     -- jti of the token
+                       created_at timestamptz not null default now(),
+                       user_id uuid not null references users(id),
+                       purpose text not null,                   -- verify_email, reset_password
+                       expires_at timestamptz not null,
+                       used_at timestamptz
+);
+
+create index idx_account_tokens_user on account_tokens(user_id, purpose);
+create index idx_account_tokens_expires on account_tokens(expires_at);
+
+commit;
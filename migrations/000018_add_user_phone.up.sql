@@ -0,0 +1,7 @@
+begin;
+
+-- Contact phone of passengers and drivers in E.164 format, e.g. +77011234567.
+-- The profile name is kept in attrs.
+alter table users add column phone varchar(16) unique;
+
+commit;
@@ -0,0 +1,18 @@
+begin;
+
+-- One-time passcodes for phone login. A new code supersedes the unused ones of
+-- the phone; every check counts as an attempt.
+create table otp_codes (
+                       id uuid primary key default gen_random_uuid(),
+                       created_at timestamptz not null default now(),
+                       phone varchar(16) not null,
+                       code_hash text not null,                 -- hmac-sha256 of phone and code
+                       expires_at timestamptz not null,
+                       attempts int not null default 0,
+                       used_at timestamptz
+);
+
+create index idx_otp_codes_phone on otp_codes(phone, created_at);
+create index idx_otp_codes_expires on otp_codes(expires_at);
+
+commit;
@@ -0,0 +1,11 @@
+begin;
+
+drop index if exists idx_ledger_transactions_pending_charge;
+alter table ledger_transactions
+    drop column if exists charge_attempts,
+    drop column if exists charge_status,
+    drop column if exists charge_amount,
+    drop column if exists charge_customer_id;
+drop table if exists "charge_status";
+
+commit;
@@ -0,0 +1,29 @@
+begin;
+
+-- Charge status enumeration
+create table "charge_status"("value" text not null primary key);
+insert into
+    "charge_status" ("value")
+values
+    ('PENDING'),   -- Posted to the ledger, the provider is not charged yet
+    ('CHARGED'),   -- Charged, reference holds the provider charge id
+    ('FAILED')     -- The provider kept refusing the charge
+;
+
+-- A passenger is charged only after the ledger transaction commits, with the
+-- transaction id as the idempotency reference of the provider
+alter table ledger_transactions
+    add column charge_customer_id uuid references users(id),
+    add column charge_amount decimal(12,2),
+    add column charge_status text references "charge_status"(value),
+    add column charge_attempts integer not null default 0;
+
+-- charges taken before this migration
+update ledger_transactions
+set charge_status = 'CHARGED'
+where reference is not null and kind <> 'PAYOUT';
+
+create index idx_ledger_transactions_pending_charge
+    on ledger_transactions(created_at) where charge_status = 'PENDING';
+
+commit;
@@ -0,0 +1,15 @@
+begin;
+
+-- Ids of RabbitMQ messages received per queue, kept until the message is too
+-- old to pass the age check, so that a captured message is not accepted twice
+create table message_receipts (
+                                  queue text not null,
+                                  message_id text not null,
+                                  received_at timestamptz not null default now(),
+                                  expires_at timestamptz not null,
+                                  primary key (queue, message_id)
+);
+
+create index idx_message_receipts_expires on message_receipts(expires_at);
+
+commit;
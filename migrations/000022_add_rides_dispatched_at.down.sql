@@ -0,0 +1,5 @@
+begin;
+
+alter table rides drop column if exists dispatched_at;
+
+commit;
@@ -0,0 +1,11 @@
+begin;
+
+-- When the ride was last sent to matching: on creation, when a scheduled ride
+-- is dispatched and when a driver gives it up. requested_at keeps the time the
+-- passenger asked for the ride
+alter table rides
+    add column dispatched_at timestamptz not null default now();
+
+update rides set dispatched_at = requested_at;
+
+commit;
@@ -0,0 +1,5 @@
+begin;
+
+alter table idempotency_keys drop column if exists token;
+
+commit;
@@ -0,0 +1,9 @@
+begin;
+
+-- Identifies the request holding a reservation: once a lease expires another
+-- request can take the key over, and the first one must not complete or
+-- release it afterwards
+alter table idempotency_keys
+    add column token uuid not null default gen_random_uuid();
+
+commit;
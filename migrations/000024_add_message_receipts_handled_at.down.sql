@@ -0,0 +1,5 @@
+begin;
+
+alter table message_receipts drop column if exists handled_at;
+
+commit;
@@ -0,0 +1,12 @@
+begin;
+
+-- A message is claimed before its handler runs and marked handled once the
+-- handler succeeds. A broker redelivery of a message that was never handled,
+-- e.g. after its consumer died, takes the claim over instead of being
+-- rejected as a replay
+alter table message_receipts
+    add column handled_at timestamptz;
+
+update message_receipts set handled_at = received_at;
+
+commit;
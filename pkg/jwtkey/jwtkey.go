@@ -0,0 +1,233 @@
+// Package jwtkey signs and verifies JWTs with RS256 or EdDSA keys loaded from
+// PEM files. Every token carries the kid of its key so that several
+// verification keys can be active while the signing key is rotated.
+package jwtkey
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	minRSABits = 2048
+	// minSecretLen is the shortest accepted HS256 secret
+	minSecretLen = 32
+)
+
+// leakedSecrets were once committed to the repository as the default secret
+// and must never sign tokens.
+var leakedSecrets = map[string]bool{
+	"V9muwjpb7rRfuAH0fNg+8g80/42v0kT7f7W67cabf3uCpMXATsE0Gzg/3GJtultt": true,
+}
+
+var (
+	ErrNoSigningKey = errors.New("no signing key configured")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+type Config struct {
+	// Secret signs HS256 tokens when no signing or verification key is set.
+	Secret string `json:"-"`
+	// SigningKey is the PEM file of the RSA or Ed25519 private key.
+	SigningKey string
+	// VerifyKeys are PEM files of keys accepted besides the signing key,
+	// e.g. the previous key during rotation.
+	VerifyKeys []string
+}
+
+// JWKS is the JSON Web Key Set served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type verifyKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+type KeySet struct {
+	method  jwt.SigningMethod
+	signKey any
+	signKID string
+	keys    map[string]verifyKey
+	jwks    JWKS
+}
+
+// New loads the keys of cfg. Without any key files it falls back to HS256
+// with cfg.Secret; such a set publishes no JWKS.
+func New(cfg Config) (*KeySet, error) {
+	if cfg.SigningKey == "" && len(cfg.VerifyKeys) == 0 {
+		switch {
+		case cfg.Secret == "":
+			return nil, errors.New("jwt: neither signing key nor secret is configured")
+		case leakedSecrets[cfg.Secret]:
+			return nil, errors.New("jwt: the secret was published in the repository, set a new one")
+		case len(cfg.Secret) < minSecretLen:
+			return nil, fmt.Errorf("jwt: secret must be at least %d characters", minSecretLen)
+		}
+		return &KeySet{method: jwt.SigningMethodHS256, signKey: []byte(cfg.Secret), jwks: JWKS{Keys: []JWK{}}}, nil
+	}
+
+	ks := &KeySet{keys: make(map[string]verifyKey), jwks: JWKS{Keys: []JWK{}}}
+	if cfg.SigningKey != "" {
+		key, err := readKey(cfg.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("jwt: %s is not a private key", cfg.SigningKey)
+		}
+		if ks.signKID, err = ks.add(signer.Public()); err != nil {
+			return nil, fmt.Errorf("jwt: %s: %w", cfg.SigningKey, err)
+		}
+		ks.method = ks.keys[ks.signKID].method
+		ks.signKey = signer
+	}
+
+	for _, path := range cfg.VerifyKeys {
+		key, err := readKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+		if _, err = ks.add(key); err != nil {
+			return nil, fmt.Errorf("jwt: %s: %w", path, err)
+		}
+	}
+	return ks, nil
+}
+
+// Sign returns the signed token with the kid header of the signing key.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signKey == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.signKID != "" {
+		token.Header["kid"] = ks.signKID
+	}
+	return token.SignedString(ks.signKey)
+}
+
+// Keyfunc resolves the verification key of a token for jwt.Parse. The token
+// algorithm must match the key it names, so a public key is never accepted as
+// an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	if ks.keys == nil {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return ks.signKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public verification keys.
+func (ks *KeySet) JWKS() JWKS {
+	return ks.jwks
+}
+
+// add registers a public key under its RFC 7638 thumbprint and returns the kid.
+func (ks *KeySet) add(public crypto.PublicKey) (string, error) {
+	var jwk JWK
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("rsa key must be at least %d bits", minRSABits)
+		}
+		jwk = JWK{Kty: "RSA", Alg: jwt.SigningMethodRS256.Alg(), N: b64(k.N.Bytes()), E: b64(big.NewInt(int64(k.E)).Bytes())}
+		jwk.Kid = thumbprint(fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N))
+		ks.keys[jwk.Kid] = verifyKey{method: jwt.SigningMethodRS256, public: k}
+	case ed25519.PublicKey:
+		jwk = JWK{Kty: "OKP", Alg: jwt.SigningMethodEdDSA.Alg(), Crv: "Ed25519", X: b64(k)}
+		jwk.Kid = thumbprint(fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X))
+		ks.keys[jwk.Kid] = verifyKey{method: jwt.SigningMethodEdDSA, public: k}
+	default:
+		return "", fmt.Errorf("unsupported key type %T, want RSA or Ed25519", public)
+	}
+
+	for _, existing := range ks.jwks.Keys {
+		if existing.Kid == jwk.Kid {
+			return jwk.Kid, nil
+		}
+	}
+	jwk.Use = "sig"
+	ks.jwks.Keys = append(ks.jwks.Keys, jwk)
+	return jwk.Kid, nil
+}
+
+// readKey parses the first PEM block of the file as a private or public key.
+func readKey(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: %s is not a PEM file", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt: %s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func thumbprint(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+package jwtkey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePEM stores the PKCS#8 private key in a PEM file and returns its path.
+func writePEM(t *testing.T, name string, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestNewSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"empty", "", true},
+		{"committed default", "V9muwjpb7rRfuAH0fNg+8g80/42v0kT7f7W67cabf3uCpMXATsE0Gzg/3GJtultt", true},
+		{"too short", strings.Repeat("s", minSecretLen-1), true},
+		{"long enough", strings.Repeat("s", minSecretLen), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Config{Secret: tt.secret})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, strangerKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaSet, err := New(Config{SigningKey: writePEM(t, "rsa.pem", rsaKey)})
+	if err != nil {
+		t.Fatalf("New(rsa) error = %v", err)
+	}
+	edSet, err := New(Config{SigningKey: writePEM(t, "ed.pem", edKey), VerifyKeys: []string{writePEM(t, "old.pem", oldKey)}})
+	if err != nil {
+		t.Fatalf("New(ed25519) error = %v", err)
+	}
+	hsSet, err := New(Config{Secret: strings.Repeat("s", minSecretLen)})
+	if err != nil {
+		t.Fatalf("New(secret) error = %v", err)
+	}
+	oldSet, err := New(Config{SigningKey: writePEM(t, "old-sign.pem", oldKey)})
+	if err != nil {
+		t.Fatalf("New(old) error = %v", err)
+	}
+
+	claims := jwt.MapClaims{"sub": "user"}
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return s
+	}
+	signWith := func(ks *KeySet) string {
+		s, err := ks.Sign(claims)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return s
+	}
+	rsaPublicDER := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+
+	tests := []struct {
+		name    string
+		keys    *KeySet
+		token   string
+		wantErr bool
+	}{
+		{name: "rs256 with its kid", keys: rsaSet, token: signWith(rsaSet)},
+		{name: "eddsa with its kid", keys: edSet, token: signWith(edSet)},
+		{name: "previous key during rotation", keys: edSet, token: signWith(oldSet)},
+		{name: "hs256", keys: hsSet, token: signWith(hsSet)},
+		{name: "unknown kid", keys: edSet, token: sign(jwt.SigningMethodEdDSA, "stranger", strangerKey), wantErr: true},
+		{name: "missing kid", keys: edSet, token: sign(jwt.SigningMethodEdDSA, "", edKey), wantErr: true},
+		{name: "foreign key under a known kid", keys: edSet, token: sign(jwt.SigningMethodEdDSA, edSet.signKID, strangerKey), wantErr: true},
+		{name: "hs256 keyed with the public rsa key", keys: rsaSet, token: sign(jwt.SigningMethodHS256, rsaSet.signKID, rsaPublicDER), wantErr: true},
+		{name: "eddsa under an rsa kid", keys: rsaSet, token: sign(jwt.SigningMethodEdDSA, rsaSet.signKID, edKey), wantErr: true},
+		{name: "rs256 for an hs256 set", keys: hsSet, token: signWith(rsaSet), wantErr: true},
+		{name: "hs256 for a key set", keys: rsaSet, token: signWith(hsSet), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.Parse(tt.token, tt.keys.Keyfunc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err = jwt.Parse(sign(jwt.SigningMethodEdDSA, "stranger", strangerKey), edSet.Keyfunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Parse() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestNewRejectsWeakRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New(Config{SigningKey: writePEM(t, "weak.pem", key)}); err == nil {
+		t.Error("New() accepted a 1024-bit rsa key")
+	}
+}
@@ -18,6 +18,8 @@ const (
 	RequestIDKey contextKey = "request_id"
 	UserIDKey    contextKey = "user_id"
 	RoleKey      contextKey = "role"
+	ClaimsIDKey  contextKey = "claims_id"
+	ClientIPKey  contextKey = "client_ip"
 )
 
 // Logger — основной логгер
@@ -174,6 +176,32 @@ func GetRole(ctx context.Context) string {
 	return ""
 }
 
+func WithClaimsID(ctx context.Context, claimsID string) context.Context {
+	return context.WithValue(ctx, ClaimsIDKey, claimsID)
+}
+
+func GetClaimsID(ctx context.Context) string {
+	if v := ctx.Value(ClaimsIDKey); v != nil {
+		if id, ok := v.(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ClientIPKey, ip)
+}
+
+func GetClientIP(ctx context.Context) string {
+	if v := ctx.Value(ClientIPKey); v != nil {
+		if ip, ok := v.(string); ok {
+			return ip
+		}
+	}
+	return ""
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PRETTY JSON HANDLER
 ////////////////////////////////////////////////////////////////////////////////
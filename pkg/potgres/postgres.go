@@ -17,7 +17,7 @@ type Config struct {
 	Host         string `env:"POSTGRES_HOST"`
 	Port         string `env:"POSTGRES_PORT"`
 	User         string `env:"POSTGRES_USER"`
-	Password     string `env:"POSTGRES_PASSWORD"`
+	Password     string `env:"POSTGRES_PASSWORD" json:"-"`
 	Database     string `env:"POSTGRES_DATABASE"`
 	MaxOpenConns int32  `env:"POSTGRES_MAX_OPEN_CONN" default:"25"`
 	MaxIdleTime  string `env:"POSTGRES_MAX_IDLE_TIME" default:"15m"`
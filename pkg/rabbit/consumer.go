@@ -7,6 +7,9 @@ import (
 	"sync"
 	"time"
 
+	"ride-hail/internal/core/domain/action"
+	"ride-hail/pkg/logger"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -15,6 +18,8 @@ type Consumer struct {
 	exchange string
 	queue    string
 	handler  MessageHandler
+	signer   *Signer
+	log      *logger.Logger
 	mutex    sync.Mutex
 }
 
@@ -28,11 +33,15 @@ func (f MessageHandlerFunc) HandleMessage(ctx context.Context, message []byte, r
 	return f(ctx, message, routingKey)
 }
 
-func NewConsumer(conn *amqp.Connection, exchange, queue string) *Consumer {
+// NewConsumer returns a consumer of the queue that passes only messages with a
+// valid envelope from signer to its handler.
+func NewConsumer(conn *amqp.Connection, exchange, queue string, signer *Signer, log *logger.Logger) *Consumer {
 	return &Consumer{
 		conn:     conn,
 		exchange: exchange,
 		queue:    queue,
+		signer:   signer,
+		log:      log,
 	}
 }
 
@@ -44,6 +53,9 @@ func (c *Consumer) StartConsuming(ctx context.Context) error {
 	if c.handler == nil {
 		return errors.New("message handler not set")
 	}
+	if c.signer == nil {
+		return errors.New("message signer not set")
+	}
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -114,12 +126,39 @@ func (c *Consumer) processMessage(ctx context.Context, ch *amqp.Channel, msg amq
 		return
 	}
 
-	err := c.handler.HandleMessage(ctx, msg.Body, msg.RoutingKey)
+	log := c.log.Func("Consumer.processMessage")
+
+	// Unsigned, forged and replayed messages are never retried, they go to
+	// the dead letter queue
+	service, err := c.signer.Verify(msg)
+	if err == nil {
+		err = c.signer.Claim(ctx, c.queue, msg)
+		if err != nil && !errors.Is(err, ErrReplayed) {
+			log.Error(ctx, action.ConsumeMessage, "error claiming message", "queue", c.queue, "message_id", msg.MessageId, "error", err)
+			msg.Nack(false, true)
+			return
+		}
+	}
 	if err != nil {
-		// Log error and requeue the message (with exponential backoff in production)
-		fmt.Printf("Error processing message: %v\n", err)
+		log.Warn(ctx, action.ConsumeMessage, "rejecting message", "queue", c.queue, "message_id", msg.MessageId,
+			"service", service, "error", err)
+		msg.Reject(false)
+		return
+	}
+
+	// the message stays claimed but unhandled until the handler succeeds, so a
+	// redelivery after a crash mid-handle is accepted again
+	if err = c.handler.HandleMessage(ctx, msg.Body, msg.RoutingKey); err != nil {
+		log.Error(ctx, action.ConsumeMessage, "error handling message", "queue", c.queue, "message_id", msg.MessageId, "error", err)
+		if err = c.signer.Release(context.WithoutCancel(ctx), c.queue, msg); err != nil {
+			log.Error(ctx, action.ConsumeMessage, "error releasing message", "queue", c.queue, "message_id", msg.MessageId, "error", err)
+		}
 		msg.Nack(false, true) // Requeue the message
-	} else {
-		msg.Ack(false) // Acknowledge successful processing
+		return
+	}
+
+	if err = c.signer.Complete(context.WithoutCancel(ctx), c.queue, msg); err != nil {
+		log.Error(ctx, action.ConsumeMessage, "error completing message", "queue", c.queue, "message_id", msg.MessageId, "error", err)
 	}
+	msg.Ack(false) // Acknowledge successful processing
 }
@@ -0,0 +1,109 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"ride-hail/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// outcome records how the consumer settled a delivery.
+type outcome struct {
+	result string
+}
+
+func (o *outcome) Ack(uint64, bool) error {
+	o.result = "ack"
+	return nil
+}
+
+func (o *outcome) Nack(_ uint64, _ bool, requeue bool) error {
+	o.result = "dead-letter"
+	if requeue {
+		o.result = "requeue"
+	}
+	return nil
+}
+
+func (o *outcome) Reject(_ uint64, requeue bool) error {
+	return o.Nack(0, false, requeue)
+}
+
+func newTestConsumer(t *testing.T, s *Signer, handle func() error) (*Consumer, *int) {
+	t.Helper()
+	calls := 0
+	c := NewConsumer(nil, "ride_topic", "ride_requests", s, logger.NewLogger("test", logger.LoggerOptions{Output: io.Discard}))
+	c.SetHandler(MessageHandlerFunc(func(context.Context, []byte, string) error {
+		calls++
+		return handle()
+	}))
+	return c, &calls
+}
+
+// deliver runs the delivery through the consumer and returns how it was settled.
+func deliver(c *Consumer, d amqp.Delivery, redelivered bool) string {
+	o := &outcome{}
+	d.Acknowledger, d.Redelivered = o, redelivered
+	c.processMessage(context.Background(), nil, d)
+	return o.result
+}
+
+func TestConsumerHandlesAMessageOnce(t *testing.T) {
+	s := newTestSigner(t, "ride", testSecret)
+	c, calls := newTestConsumer(t, s, func() error { return nil })
+	d := signed(t, s, `{"ride_id":"1"}`)
+
+	if got := deliver(c, d, false); got != "ack" {
+		t.Fatalf("first delivery = %s, want ack", got)
+	}
+	if got := deliver(c, d, false); got != "dead-letter" {
+		t.Errorf("replayed copy = %s, want dead-letter", got)
+	}
+	if got := deliver(c, d, true); got != "dead-letter" {
+		t.Errorf("redelivery of a handled message = %s, want dead-letter", got)
+	}
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want once", *calls)
+	}
+
+	if got := deliver(c, amqp.Delivery{MessageId: "1", Body: d.Body}, false); got != "dead-letter" {
+		t.Errorf("unsigned message = %s, want dead-letter", got)
+	}
+}
+
+func TestConsumerRetriesMessagesThatWereNotHandled(t *testing.T) {
+	s := newTestSigner(t, "ride", testSecret)
+	failures := 1
+	c, calls := newTestConsumer(t, s, func() error {
+		if failures > 0 {
+			failures--
+			return errors.New("database is down")
+		}
+		return nil
+	})
+
+	d := signed(t, s, `{"ride_id":"1"}`)
+	if got := deliver(c, d, false); got != "requeue" {
+		t.Fatalf("failed delivery = %s, want requeue", got)
+	}
+	if got := deliver(c, d, true); got != "ack" {
+		t.Errorf("redelivery after a handler error = %s, want ack", got)
+	}
+
+	// the consumer claimed the message and died before the handler returned
+	crashed := signed(t, s, `{"ride_id":"2"}`)
+	if err := s.Claim(context.Background(), "ride_requests", crashed); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if got := deliver(c, crashed, true); got != "ack" {
+		t.Errorf("redelivery after a crash = %s, want ack", got)
+	}
+
+	if *calls != 3 {
+		t.Errorf("handler ran %d times, want 3", *calls)
+	}
+}
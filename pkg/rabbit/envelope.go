@@ -0,0 +1,195 @@
+package rabbit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Envelope headers added to every published message. The signature covers
+// the service, the issue time, the message id, the exchange, the routing key
+// and the body.
+const (
+	HeaderService   = "x-service"
+	HeaderIssuedAt  = "x-issued-at"
+	HeaderSignature = "x-signature"
+)
+
+const (
+	minSecretLen = 32
+	// maxClockSkew is how far ahead of the local clock a message may be issued
+	maxClockSkew = time.Minute
+)
+
+var (
+	ErrUnsigned       = errors.New("message is not signed")
+	ErrBadSignature   = errors.New("message signature is invalid")
+	ErrUnknownService = errors.New("message is from an unknown service")
+	ErrStale          = errors.New("message is too old")
+	ErrReplayed       = errors.New("message was already delivered")
+)
+
+// ReceiptStore records the ids of received messages per queue. It is shared by
+// all instances of a service, so a captured message can't be delivered again
+// to any of them.
+type ReceiptStore interface {
+	// Claim records the message id for the queue until expiresAt. It reports
+	// false when the id is already recorded, unless takeOver is set and the
+	// message was never handled.
+	Claim(ctx context.Context, queue, messageID string, expiresAt time.Time, takeOver bool) (bool, error)
+	// Complete marks the claimed message as handled.
+	Complete(ctx context.Context, queue, messageID string) error
+	// Release forgets the message id, so that a requeued message is accepted
+	// again.
+	Release(ctx context.Context, queue, messageID string) error
+}
+
+// Signer signs outgoing messages as one service and verifies incoming ones.
+// All services share the secret. Message ids are claimed in receipts until
+// the message is too old to pass the age check anyway.
+type Signer struct {
+	service  string
+	secret   []byte
+	maxAge   time.Duration
+	services map[string]bool
+	receipts ReceiptStore
+}
+
+// NewSigner returns a signer publishing as service and accepting messages from
+// the given services only. A signer that only publishes may have nil receipts.
+func NewSigner(service, secret string, maxAge time.Duration, receipts ReceiptStore, services ...string) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("message secret is not configured")
+	}
+	if len(secret) < minSecretLen {
+		return nil, fmt.Errorf("message secret must be at least %d characters", minSecretLen)
+	}
+	if maxAge <= 0 {
+		return nil, errors.New("message max age must be positive")
+	}
+
+	allowed := make(map[string]bool, len(services))
+	for _, s := range services {
+		allowed[s] = true
+	}
+
+	return &Signer{
+		service:  service,
+		secret:   []byte(secret),
+		maxAge:   maxAge,
+		services: allowed,
+		receipts: receipts,
+	}, nil
+}
+
+// NewSigner returns a signer for service with the message secret and max age
+// of the connection config.
+func (r *Rabbit) NewSigner(service string, receipts ReceiptStore, services ...string) (*Signer, error) {
+	return NewSigner(service, r.Cfg.MessageSecret, r.Cfg.MessageMaxAge, receipts, services...)
+}
+
+// Sign sets the message id and the envelope headers of msg.
+func (s *Signer) Sign(exchange, routingKey string, msg *amqp.Publishing) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate message id: %w", err)
+	}
+
+	msg.MessageId = hex.EncodeToString(b)
+	issuedAt := strconv.FormatInt(time.Now().Unix(), 10)
+
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Headers[HeaderService] = s.service
+	msg.Headers[HeaderIssuedAt] = issuedAt
+	msg.Headers[HeaderSignature] = s.signature(s.service, issuedAt, msg.MessageId, exchange, routingKey, msg.Body)
+	return nil
+}
+
+// Verify checks the signature and the age of a delivery and returns the
+// service that sent it. Replays are caught by Claim.
+func (s *Signer) Verify(d amqp.Delivery) (string, error) {
+	service, _ := d.Headers[HeaderService].(string)
+	issuedAt, _ := d.Headers[HeaderIssuedAt].(string)
+	signature, _ := d.Headers[HeaderSignature].(string)
+	if service == "" || issuedAt == "" || signature == "" || d.MessageId == "" {
+		return "", ErrUnsigned
+	}
+
+	expected := s.signature(service, issuedAt, d.MessageId, d.Exchange, d.RoutingKey, d.Body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return service, ErrBadSignature
+	}
+	if !s.services[service] {
+		return service, ErrUnknownService
+	}
+
+	unix, err := strconv.ParseInt(issuedAt, 10, 64)
+	if err != nil {
+		return service, ErrUnsigned
+	}
+	issued := time.Unix(unix, 0)
+	now := time.Now()
+	if now.Sub(issued) > s.maxAge || issued.Sub(now) > maxClockSkew {
+		return service, ErrStale
+	}
+	return service, nil
+}
+
+// Claim records a verified delivery as received on the queue. It returns
+// ErrReplayed when the message was received there before. A broker
+// redelivery of a message that was claimed but never handled takes the claim
+// over: the broker only redelivers an unacknowledged message once the
+// consumer holding it is gone, and a message the consumer requeues after an
+// error is released first.
+func (s *Signer) Claim(ctx context.Context, queue string, d amqp.Delivery) error {
+	if s.receipts == nil {
+		return errors.New("message receipts not set")
+	}
+
+	// by then the message fails the age check, even if issued ahead of time
+	ok, err := s.receipts.Claim(ctx, queue, d.MessageId, time.Now().Add(s.maxAge+maxClockSkew), d.Redelivered)
+	if err != nil {
+		return fmt.Errorf("failed to claim message: %w", err)
+	}
+	if !ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Complete marks a claimed delivery as handled, so that it is never taken
+// over again.
+func (s *Signer) Complete(ctx context.Context, queue string, d amqp.Delivery) error {
+	if s.receipts == nil {
+		return errors.New("message receipts not set")
+	}
+	return s.receipts.Complete(ctx, queue, d.MessageId)
+}
+
+// Release forgets a claimed delivery before it is requeued.
+func (s *Signer) Release(ctx context.Context, queue string, d amqp.Delivery) error {
+	if s.receipts == nil {
+		return errors.New("message receipts not set")
+	}
+	return s.receipts.Release(ctx, queue, d.MessageId)
+}
+
+func (s *Signer) signature(service, issuedAt, messageID, exchange, routingKey string, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	for _, part := range []string{service, issuedAt, messageID, exchange, routingKey} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,178 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// memoryReceipts is a ReceiptStore for one process. It maps the claimed
+// messages to whether they were handled.
+type memoryReceipts map[string]bool
+
+func (m memoryReceipts) Claim(_ context.Context, queue, messageID string, _ time.Time, takeOver bool) (bool, error) {
+	handled, ok := m[queue+"/"+messageID]
+	if ok && (!takeOver || handled) {
+		return false, nil
+	}
+	m[queue+"/"+messageID] = false
+	return true, nil
+}
+
+func (m memoryReceipts) Complete(_ context.Context, queue, messageID string) error {
+	m[queue+"/"+messageID] = true
+	return nil
+}
+
+func (m memoryReceipts) Release(_ context.Context, queue, messageID string) error {
+	delete(m, queue+"/"+messageID)
+	return nil
+}
+
+func newTestSigner(t *testing.T, service, secret string) *Signer {
+	t.Helper()
+	s, err := NewSigner(service, secret, 15*time.Minute, memoryReceipts{}, "ride", "dal")
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return s
+}
+
+// signed returns the delivery of a message signed by s.
+func signed(t *testing.T, s *Signer, body string) amqp.Delivery {
+	t.Helper()
+	msg := amqp.Publishing{Body: []byte(body)}
+	if err := s.Sign("ride_topic", "ride.request.ECONOMY", &msg); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return amqp.Delivery{
+		Headers:    msg.Headers,
+		MessageId:  msg.MessageId,
+		Exchange:   "ride_topic",
+		RoutingKey: "ride.request.ECONOMY",
+		Body:       msg.Body,
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		maxAge  time.Duration
+		wantErr bool
+	}{
+		{"valid", testSecret, time.Minute, false},
+		{"empty secret", "", time.Minute, true},
+		{"short secret", testSecret[:minSecretLen-1], time.Minute, true},
+		{"zero max age", testSecret, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSigner("ride", tt.secret, tt.maxAge, nil, "ride")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	ride := newTestSigner(t, "ride", testSecret)
+	stranger := newTestSigner(t, "admin", testSecret)
+	forger := newTestSigner(t, "ride", strings.Repeat("x", minSecretLen))
+
+	// resign sets the issue time and signs the delivery again with ride's secret
+	resign := func(d amqp.Delivery, issued time.Time) amqp.Delivery {
+		at := strconv.FormatInt(issued.Unix(), 10)
+		d.Headers = amqp.Table{
+			HeaderService:   "ride",
+			HeaderIssuedAt:  at,
+			HeaderSignature: ride.signature("ride", at, d.MessageId, d.Exchange, d.RoutingKey, d.Body),
+		}
+		return d
+	}
+	change := func(d amqp.Delivery, f func(d *amqp.Delivery)) amqp.Delivery {
+		d.Headers = amqp.Table{HeaderService: d.Headers[HeaderService], HeaderIssuedAt: d.Headers[HeaderIssuedAt], HeaderSignature: d.Headers[HeaderSignature]}
+		f(&d)
+		return d
+	}
+	valid := signed(t, ride, `{"ride_id":"1"}`)
+
+	tests := []struct {
+		name        string
+		delivery    amqp.Delivery
+		wantService string
+		wantErr     error
+	}{
+		{name: "valid", delivery: valid, wantService: "ride"},
+		{name: "redelivered", delivery: change(valid, func(d *amqp.Delivery) { d.Redelivered = true }), wantService: "ride"},
+		{name: "no headers", delivery: amqp.Delivery{MessageId: "1", Body: valid.Body}, wantErr: ErrUnsigned},
+		{name: "no message id", delivery: change(valid, func(d *amqp.Delivery) { d.MessageId = "" }), wantService: "", wantErr: ErrUnsigned},
+		{name: "changed body", delivery: change(valid, func(d *amqp.Delivery) { d.Body = []byte(`{"ride_id":"2"}`) }), wantService: "ride", wantErr: ErrBadSignature},
+		{name: "changed routing key", delivery: change(valid, func(d *amqp.Delivery) { d.RoutingKey = "ride.request.PREMIUM" }), wantService: "ride", wantErr: ErrBadSignature},
+		{name: "changed message id", delivery: change(valid, func(d *amqp.Delivery) { d.MessageId = "other" }), wantService: "ride", wantErr: ErrBadSignature},
+		{name: "claimed other service", delivery: change(valid, func(d *amqp.Delivery) { d.Headers[HeaderService] = "dal" }), wantService: "dal", wantErr: ErrBadSignature},
+		{name: "other secret", delivery: signed(t, forger, `{"ride_id":"1"}`), wantService: "ride", wantErr: ErrBadSignature},
+		{name: "unknown service", delivery: signed(t, stranger, `{"ride_id":"1"}`), wantService: "admin", wantErr: ErrUnknownService},
+		{name: "stale", delivery: resign(valid, time.Now().Add(-16*time.Minute)), wantService: "ride", wantErr: ErrStale},
+		{name: "old but fresh enough", delivery: resign(valid, time.Now().Add(-14*time.Minute)), wantService: "ride"},
+		{name: "slightly ahead", delivery: resign(valid, time.Now().Add(30*time.Second)), wantService: "ride"},
+		{name: "from the future", delivery: resign(valid, time.Now().Add(2*time.Minute)), wantService: "ride", wantErr: ErrStale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := ride.Verify(tt.delivery)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if service != tt.wantService {
+				t.Errorf("Verify() service = %q, want %q", service, tt.wantService)
+			}
+		})
+	}
+}
+
+func TestSignerClaim(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSigner(t, "ride", testSecret)
+	d := signed(t, s, `{"ride_id":"1"}`)
+	redelivered := d
+	redelivered.Redelivered = true
+
+	if err := s.Claim(ctx, "ride_requests", d); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if err := s.Claim(ctx, "ride_requests", d); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Claim() of a copy published again error = %v, want %v", err, ErrReplayed)
+	}
+	if err := s.Claim(ctx, "ride_status", d); err != nil {
+		t.Errorf("Claim() on another queue error = %v", err)
+	}
+
+	// the consumer died before handling it, the broker delivers it again
+	if err := s.Claim(ctx, "ride_requests", redelivered); err != nil {
+		t.Fatalf("Claim() of an unhandled redelivery error = %v", err)
+	}
+	if err := s.Complete(ctx, "ride_requests", redelivered); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := s.Claim(ctx, "ride_requests", redelivered); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Claim() of a handled redelivery error = %v, want %v", err, ErrReplayed)
+	}
+
+	if err := s.Release(ctx, "ride_status", d); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := s.Claim(ctx, "ride_status", redelivered); err != nil {
+		t.Errorf("Claim() after Release() error = %v", err)
+	}
+}
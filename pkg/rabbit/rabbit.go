@@ -1,7 +1,9 @@
 package rabbit
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -15,7 +17,11 @@ type Config struct {
 	Host     string
 	Port     int
 	User     string
-	Password string
+	Password string `json:"-"`
+	// MessageSecret signs the envelopes of messages between services, messages
+	// older than MessageMaxAge are rejected
+	MessageSecret string `json:"-"`
+	MessageMaxAge time.Duration
 }
 
 func (c Config) GetRabbitDsn() string {
@@ -45,6 +51,8 @@ func (r *Rabbit) Close() {
 type QueueConfig struct {
 	Name       string
 	RoutingKey string
+	// Args are optional queue arguments such as x-max-priority
+	Args amqp.Table
 }
 
 func (r *Rabbit) SetupExchangesAndQueues(exchangeName, exchangeType string, queues []QueueConfig) error {
@@ -59,7 +67,7 @@ func (r *Rabbit) SetupExchangesAndQueues(exchangeName, exchangeType string, queu
 	}
 
 	for _, qCfg := range queues {
-		q, err := r.ensureQueue(ch, qCfg.Name)
+		q, err := r.ensureQueue(ch, qCfg.Name, qCfg.Args)
 		if err != nil {
 			return err
 		}
@@ -82,6 +90,14 @@ func (r *Rabbit) ensureExchange(ch *amqp.Channel, name, kind string) error {
 	return ch.ExchangeDeclare(name, kind, true, false, false, false, nil)
 }
 
-func (r *Rabbit) ensureQueue(ch *amqp.Channel, name string) (amqp.Queue, error) {
-	return ch.QueueDeclare(name, true, false, false, false, nil)
+// ensureQueue declares a durable queue. Queue arguments can't be changed on
+// an existing queue: the broker closes the channel with PRECONDITION_FAILED
+// and the queue has to be deleted once, see the queue migration in README.
+func (r *Rabbit) ensureQueue(ch *amqp.Channel, name string, args amqp.Table) (amqp.Queue, error) {
+	q, err := ch.QueueDeclare(name, true, false, false, false, args)
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+		return q, fmt.Errorf("queue %s was declared with other arguments and has to be deleted once before startup: %w", name, err)
+	}
+	return q, err
 }
@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// Every calls fn once per interval until ctx is cancelled.
+// A non-positive interval disables the job.
+func Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}